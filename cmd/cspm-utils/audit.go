@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/acceptance"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/audit"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// attachAuditLog records the changes made through c in the audit_log table of dbPath and,
+// when jsonlPath is set, appends them to that file. The returned function closes both.
+func attachAuditLog(c *client.CSPMClient, cfg *config.Config, dbPath, command, jsonlPath string) (func(), error) {
+	db, err := database.NewDatabase(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log database: %w", err)
+	}
+
+	logger := audit.NewLogger(db, cfg.Profile, c.Endpoints().APIURL, command)
+	var f *os.File
+	if jsonlPath != "" {
+		f, err = os.OpenFile(jsonlPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("failed to open audit log file: %w", err)
+		}
+		logger.JSONL = f
+	}
+	c.Auditor = logger
+
+	return func() {
+		c.Auditor = nil
+		if f != nil {
+			_ = f.Close()
+		}
+		_ = db.Close()
+	}, nil
+}
+
+// showAuditLog prints the recorded write operations; an empty within shows all of them
+func showAuditLog(dbPath, acceptanceID, osUser, within, format string) error {
+	if format != "" && format != "table" && format != "json" {
+		return fmt.Errorf("unknown format %q for audit (table, json)", format)
+	}
+	q := database.AuditQuery{AcceptanceID: acceptanceID, OSUser: osUser}
+	if within != "" {
+		window, err := acceptance.ParseWindow(within)
+		if err != nil {
+			return err
+		}
+		q.Since = time.Now().Add(-window)
+	}
+
+	db, err := database.OpenReadOnly(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	entries, err := db.GetAuditEntries(q)
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		return audit.WriteJSON(os.Stdout, entries)
+	}
+	return audit.WriteTable(os.Stdout, entries)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/sysdig"
+)
+
+func listProfiles(cfg *config.Config) error {
+	names := cfg.ProfileNames()
+	if len(names) == 0 {
+		fmt.Println("No profiles defined (use -config to specify a config file with \"profiles\")")
+		return nil
+	}
+
+	fmt.Printf("%-3s %-20s %-35s %-20s %-25s\n", "", "PROFILE", "API URL", "TOKEN", "DATABASE")
+	fmt.Println(strings.Repeat("-", 105))
+
+	for _, name := range names {
+		profile := cfg.Profiles[name]
+
+		marker := ""
+		if name == cfg.Profile {
+			marker = "*"
+		}
+
+		token := config.RedactToken(profile.APIToken)
+		if profile.APIToken == "" {
+			switch {
+			case profile.TokenFile != "":
+				token = "file:" + profile.TokenFile
+			case profile.TokenCommand != "":
+				token = "(command)"
+			case profile.APITokenEnv != "":
+				token = "$" + profile.APITokenEnv
+			}
+		}
+
+		fmt.Printf("%-3s %-20s %-35s %-20s %-25s\n", marker, name, profile.APIURL, token, profile.DBPath)
+	}
+
+	fmt.Printf("\nTotal: %d profiles (* = selected)\n", len(names))
+	return nil
+}
+
+func showConfig(cfg *config.Config, dbPath, zoneName, policies string) error {
+	profile := cfg.Profile
+	if profile == "" {
+		profile = "(none)"
+	}
+
+	tokenSource := cfg.TokenSource
+	if tokenSource == "" {
+		tokenSource = "not configured"
+	}
+
+	fmt.Printf("Profile:       %s\n", profile)
+	region := cfg.Region
+	if region == "" {
+		if r, ok := sysdig.RegionForURL(cfg.APIURL); ok {
+			region = r.Name
+		} else {
+			region = "(custom)"
+		}
+	}
+
+	endpoints, err := cfg.Endpoints()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Region:        %s\n", region)
+	fmt.Printf("API URL:       %s\n", endpoints.APIURL)
+	fmt.Printf("Secure API:    %s\n", endpoints.SecureAPIURL)
+	token := config.RedactToken(cfg.APIToken)
+	if cfg.HasPendingToken() {
+		// token_file・token_commandはconfig-showでは読み込まない
+		token = "(resolved at runtime)"
+	}
+	fmt.Printf("API token:     %s (%s)\n", token, tokenSource)
+	fmt.Printf("Database:      %s\n", dbPath)
+	fmt.Printf("Zone:          %s\n", zoneName)
+	if policies == "" {
+		policies = "(all)"
+	}
+	fmt.Printf("Policies:      %s\n", policies)
+
+	return nil
+}
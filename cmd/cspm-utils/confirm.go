@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// mutatingCommands are the commands that change the tenant; they support -dry-run and -yes
+// and record their changes in the audit log
+var mutatingCommands = map[string]bool{
+	"risk-delete": true,
+	"risk-renew":  true,
+	"risk-import": true,
+	"risk-apply":  true,
+	"tui":         true,
+}
+
+// mutationOptions are the safety flags of the commands that change the tenant
+type mutationOptions struct {
+	// DryRun prints the API requests instead of sending them and leaves the database unchanged
+	DryRun bool
+	// Yes skips the confirmation prompt
+	Yes bool
+}
+
+// confirm asks on the terminal before changing the tenant. Dry runs and -yes proceed without
+// asking; non-interactive runs (cron, CI) fail unless -yes is set.
+func (o mutationOptions) confirm(prompt string) (bool, error) {
+	if o.DryRun || o.Yes {
+		return true, nil
+	}
+	if !isTerminal(os.Stdin) {
+		return false, errors.New("stdin is not a terminal: use -yes to confirm non-interactively")
+	}
+
+	fmt.Printf("%s [y/N]: ", prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	fmt.Println("Cancelled")
+	return false, nil
+}
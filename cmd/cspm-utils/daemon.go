@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/daemon"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/plan"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/sysdig"
+)
+
+func runDaemon(cfg *config.Config, endpoints sysdig.Endpoints, zoneName string, batchSize, apiDelay int) error {
+	if cfg.Daemon == nil {
+		return fmt.Errorf("no daemon section in the config file (set -config)")
+	}
+	ownershipCfg, err := loadOwnership(cfg)
+	if err != nil {
+		return err
+	}
+	notifyCfg, err := loadNotify(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Each job run gets its own client so that plans can use different rate limits
+	newClient := func() *client.CSPMClient {
+		return client.NewCSPMClientWithEndpoints(endpoints, cfg.APIToken)
+	}
+	base := plan.ResolvedTarget{
+		Zone:      zoneName,
+		PageSize:  50,
+		BatchSize: batchSize,
+		APIDelay:  apiDelay,
+		Ownership: ownershipCfg,
+		Notify:    notifyCfg,
+	}
+
+	d, err := daemon.New(*cfg.Daemon, newClient, base)
+	if err != nil {
+		return fmt.Errorf("invalid daemon configuration: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// 2回目のシグナルで即時終了できるようにする
+		stop()
+	}()
+
+	log.Printf("Starting daemon with %d jobs", len(cfg.Daemon.Jobs))
+	return d.Run(ctx)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+func migrateDatabase(dbPath string) error {
+	fmt.Printf("Migrating database %s...\n", dbPath)
+
+	db, err := database.OpenDatabase(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	applied, err := db.Migrate()
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("Database is already up to date")
+	}
+	for _, m := range applied {
+		fmt.Printf("  Applied migration %d: %s\n", m.Version, m.Name)
+	}
+
+	fmt.Printf("✓ Schema version: %d\n", database.LatestSchemaVersion())
+	return nil
+}
+
+func showDatabaseVersion(dbPath string) error {
+	db, err := database.OpenDatabase(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	latest := database.LatestSchemaVersion()
+	fmt.Printf("Database:               %s\n", dbPath)
+	fmt.Printf("Schema version:         %d\n", current)
+	fmt.Printf("Supported version:      %d\n", latest)
+
+	if current > latest {
+		fmt.Println("\n[WARN] Database was written by a newer version of sysdig-cspm-utils")
+		return nil
+	}
+
+	applied, err := db.AppliedMigrations()
+	if err != nil {
+		return fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	if len(applied) > 0 {
+		fmt.Println("\nApplied migrations:")
+		for _, m := range applied {
+			fmt.Printf("  %3d  %-40s %s\n", m.Version, m.Name, m.AppliedAt.Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		return fmt.Errorf("failed to get pending migrations: %w", err)
+	}
+
+	if len(pending) == 0 {
+		fmt.Println("\nNo pending migrations")
+		return nil
+	}
+
+	fmt.Println("\nPending migrations (run -command db-migrate):")
+	for _, m := range pending {
+		fmt.Printf("  %3d  %s\n", m.Version, m.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// showInventory prints the resources of the database matching a filter expression with their
+// failing, passing and accepted controls, worst first
+func showInventory(dbPath, filterExpr, format string) error {
+	if format == "" {
+		format = "table"
+	}
+	known := false
+	for _, f := range queryFormats {
+		known = known || f == format
+	}
+	if !known {
+		return fmt.Errorf("unknown format %q for inventory (%s)", format, strings.Join(queryFormats, ", "))
+	}
+
+	db, err := database.OpenReadOnly(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.GetInventory(filterExpr)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		if rows == nil {
+			rows = []database.InventoryRow{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write(inventoryHeader)
+		for _, r := range rows {
+			_ = w.Write(inventoryRecord(r))
+		}
+		w.Flush()
+		return w.Error()
+	case "markdown":
+		fmt.Printf("| %s |\n", strings.Join(inventoryHeader, " | "))
+		fmt.Printf("|%s\n", strings.Repeat("---|", len(inventoryHeader)))
+		for _, r := range rows {
+			record := inventoryRecord(r)
+			for i, v := range record {
+				record[i] = strings.ReplaceAll(v, "|", `\|`)
+			}
+			fmt.Printf("| %s |\n", strings.Join(record, " | "))
+		}
+		return nil
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No matching resources")
+		return nil
+	}
+	fmt.Printf("%-8s %6s %6s %8s %-40s %-30s %-15s %-15s %-15s\n", "WORST", "FAILED", "PASSED", "ACCEPTED", "NAME", "TYPE", "ACCOUNT", "LOCATION", "TEAM")
+	fmt.Println(strings.Repeat("-", 151))
+	for _, r := range rows {
+		worst := r.HighestSeverity
+		if worst == "" {
+			worst = "-"
+		}
+		fmt.Printf("%-8s %6d %6d %8d %-40s %-30s %-15s %-15s %-15s\n", worst, r.Failed, r.Passed, r.Accepted, r.Name, r.Type, r.Account, r.Location, r.Team)
+	}
+	fmt.Printf("\nTotal: %d resources\n", len(rows))
+	return nil
+}
+
+var inventoryHeader = []string{"hash", "name", "type", "platform", "account", "location", "cluster", "os", "team", "failed", "passed", "accepted", "highest_severity"}
+
+func inventoryRecord(r database.InventoryRow) []string {
+	return []string{r.Hash, r.Name, r.Type, r.Platform, r.Account, r.Location, r.Cluster, r.OS, r.Team,
+		strconv.Itoa(r.Failed), strconv.Itoa(r.Passed), strconv.Itoa(r.Accepted), r.HighestSeverity}
+}
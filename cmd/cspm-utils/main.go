@@ -1,15 +1,21 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/acceptance"
 	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
 	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/collector"
 	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
 	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/plan"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/sysdig"
 )
 
 const version = "1.0.0"
@@ -17,17 +23,38 @@ const version = "1.0.0"
 func main() {
 	var (
 		configFile   = flag.String("config", "", "Path to configuration file")
+		profileName  = flag.String("profile", "", "Configuration profile name (or set SYSDIG_PROFILE)")
 		apiToken     = flag.String("token", "", "Sysdig API token")
-		apiURL       = flag.String("url", "https://us2.app.sysdig.com", "Sysdig API base URL")
-		command      = flag.String("command", "list", "Command to execute: list, collect, risk-collect, risk-list, risk-delete")
+		apiURL       = flag.String("url", "", "Sysdig API base URL (default \""+config.DefaultAPIURL+"\")")
+		secureAPIURL = flag.String("secure-url", "", "Sysdig public API URL for /secure/ endpoints (derived from -url when omitted)")
+		region       = flag.String("region", "", "Sysdig SaaS region: "+strings.Join(sysdig.RegionNames(), ", "))
+		command      = flag.String("command", "list", "Command to execute: list, collect, daemon, risk-collect, risk-list, risk-delete, db-migrate, db-version, config-list, config-show, serve, serve-metrics, ui, tui, trend, risk-expiring, risk-renew, risk-lint, risk-show, query, risk-export, risk-import, risk-plan, risk-apply, audit, inventory, scorecard, owners, notify")
 		dbPath       = flag.String("db", "data/cspm.db", "SQLite database path")
+		planFile     = flag.String("plan", "", "Collection plan YAML file (for collect)")
+		file         = flag.String("file", "", "Export file for risk-export and risk-import (.json, .yaml or .yml); acceptance YAML files or directories for risk-plan and risk-apply (comma-separated)")
+		source       = flag.String("source", "database", "Source of risk-export: database or api")
+		resultLog    = flag.String("result-log", "", "Write the per-item result of risk-import as JSON lines to this file")
+		prune        = flag.Bool("prune", false, "Revoke the risk acceptances not declared in the files (for risk-plan and risk-apply)")
+		dryRun       = flag.Bool("dry-run", false, "Print the API requests of risk-delete, risk-renew, risk-import, risk-apply and tui instead of sending them, without changing the database")
+		assumeYes    = flag.Bool("yes", false, "Do not ask for confirmation before changing the tenant")
+		auditLogFile = flag.String("audit-log", "", "Also append the audit log of write operations to this JSON lines file")
+		incremental  = flag.Bool("incremental", false, "Only fetch risk acceptances newer than the last collected one (for risk-collect)")
+		filterExpr   = flag.String("filter", "", "Filter expression for query and inventory, e.g. platform = \"AWS\" and status = \"failed\"")
+		team         = flag.String("team", "", "Only include the resources owned by this team (for query, inventory and scorecard)")
+		groupBy      = flag.String("group-by", "account", "Scorecard entries: account or team")
+		format       = flag.String("format", "", "Output format (trend: markdown, csv, json; risk-expiring: table, json; risk-lint: text, json; query and inventory: table, csv, json, markdown; audit, scorecard and owners: table, json)")
+		outputDir    = flag.String("output-dir", "", "Directory for the Markdown and HTML scorecard of every account (for scorecard) or the webhook payloads (test mode of notify)")
+		within       = flag.String("within", "30d", "Window for risk-expiring, risk-renew and audit, e.g. 30d, 2w, 36h")
+		expiresIn    = flag.String("expires-in", "", "New expiry of renewed risk acceptances from now, e.g. 90d (for risk-renew)")
+		listenAddr   = flag.String("listen", "", "Listen address for server commands (serve default \""+defaultAPIAddr+"\", serve-metrics default \""+defaultMetricsAddr+"\", ui default \""+defaultUIAddr+"\")")
 		policyType   = flag.String("policy", "", "Filter by policy name (comma-separated for multiple, partial match)")
 		platform     = flag.String("platform", "", "Filter by platform (AWS, GCP, Azure, Kubernetes)")
 		zoneName     = flag.String("zone", "Entire Infrastructure", "Filter by zone name")
 		batchSize    = flag.Int("batch-size", 3, "Number of concurrent API requests for pagination (default 3)")
 		apiDelay     = flag.Int("api-delay", 1, "Delay in seconds between API batches (default 1)")
-		controlID    = flag.String("control-id", "", "Filter by control ID (for risk-list)")
-		acceptanceID = flag.String("acceptance-id", "", "Risk acceptance ID (for risk-delete)")
+		controlID    = flag.String("control-id", "", "Filter by control ID (for risk-list and risk-renew)")
+		acceptanceID = flag.String("acceptance-id", "", "Risk acceptance ID (for risk-delete, risk-renew, risk-show and audit)")
+		username     = flag.String("user", "", "Filter by the user who created the risk acceptance (for risk-renew) or the OS user who ran a change (for audit)")
 		showHelp     = flag.Bool("help", false, "Show help")
 		showVersion  = flag.Bool("version", false, "Show version")
 	)
@@ -43,86 +70,192 @@ func main() {
 		return
 	}
 
-	var err error
+	// Load configuration
+	cfg, err := config.LoadWithOverrides(*configFile, config.Overrides{
+		Profile:      *profileName,
+		APIToken:     *apiToken,
+		APIURL:       *apiURL,
+		SecureAPIURL: *secureAPIURL,
+		Region:       *region,
+	})
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Profile defaults apply only to flags that were not given explicitly
+	setFlags := explicitFlags()
+	if setFlags["token"] {
+		log.Println("[WARN] -token exposes the API token in shell history and process listings; " +
+			"prefer SYSDIG_API_TOKEN, token_file or token_command")
+	}
+	if !setFlags["db"] && cfg.DBPath != "" {
+		*dbPath = cfg.DBPath
+	}
+	if !setFlags["zone"] && cfg.Zone != "" {
+		*zoneName = cfg.Zone
+	}
+	if !setFlags["policy"] && len(cfg.Policies) > 0 {
+		*policyType = strings.Join(cfg.Policies, ",")
+	}
 
-	// risk-list command doesn't need API token
-	if *command != "risk-list" {
-		// Load configuration
-		cfg, loadErr := config.Load(*configFile, *apiToken, *apiURL)
-		if loadErr != nil {
-			log.Fatalf("Failed to load configuration: %v", loadErr)
+	if *dryRun && !mutatingCommands[*command] {
+		log.Fatalf("-dry-run is not supported by %s (risk-delete, risk-renew, risk-import, risk-apply, tui)", *command)
+	}
+	if *team != "" && !teamCommands[*command] {
+		log.Fatalf("-team is not supported by %s (query, inventory, scorecard)", *command)
+	}
+	mutation := mutationOptions{DryRun: *dryRun, Yes: *assumeYes}
+	if !setFlags["audit-log"] && cfg.AuditLog != "" {
+		*auditLogFile = cfg.AuditLog
+	}
+	closeAudit := func() {}
+
+	// Local commands only read from or maintain the database and don't need API token
+	if !isLocalCommand(*command) {
+		// Resolve token_command only when the API is actually used
+		if err := cfg.ResolveToken(); err != nil {
+			log.Fatalf("Failed to get API token: %v", err)
 		}
+		log.SetOutput(config.NewRedactingWriter(os.Stderr, cfg.APIToken))
 
 		// Validate configuration
 		if cfg.APIToken == "" {
-			log.Fatal("API token is required. Set via -token flag or SYSDIG_API_TOKEN environment variable")
+			log.Fatal("API token is required. Set via -token flag, SYSDIG_API_TOKEN environment variable or a config profile")
+		}
+
+		endpoints, endpointErr := cfg.Endpoints()
+		if endpointErr != nil {
+			log.Fatalf("Invalid API endpoints: %v", endpointErr)
 		}
 
 		// Create CSPM client
-		cspmClient := client.NewCSPMClient(cfg.APIURL, cfg.APIToken)
+		cspmClient := client.NewCSPMClientWithEndpoints(endpoints, cfg.APIToken)
+		if mutation.DryRun {
+			cspmClient.DryRun = os.Stdout
+		} else if mutatingCommands[*command] {
+			// 書き込み操作は -db のaudit_logに記録する
+			closeAudit, err = attachAuditLog(cspmClient, cfg, *dbPath, *command, *auditLogFile)
+			if err != nil {
+				log.Fatalf("Failed to open audit log: %v", err)
+			}
+		}
 
 		// Execute command
 		switch *command {
 		case "list":
 			err = listCompliance(cspmClient, *policyType, *platform, *zoneName)
 		case "collect":
-			err = collectResources(cspmClient, *dbPath, *policyType, *platform, *zoneName, *batchSize, *apiDelay)
+			if *planFile != "" {
+				if setFlags["policy"] || setFlags["platform"] || setFlags["db"] {
+					log.Fatal("-policy, -platform and -db cannot be combined with -plan; set them per target in the plan file")
+				}
+				err = runCollectionPlan(cfg, cspmClient, *planFile, *zoneName, *batchSize, *apiDelay)
+			} else {
+				err = collectResources(cfg, cspmClient, *dbPath, *policyType, *platform, *zoneName, *batchSize, *apiDelay)
+			}
+		case "daemon":
+			err = runDaemon(cfg, endpoints, *zoneName, *batchSize, *apiDelay)
 		case "risk-collect":
-			err = collectRiskAcceptances(cspmClient, *dbPath)
+			err = collectRiskAcceptances(cspmClient, *dbPath, *incremental)
 		case "risk-delete":
-			err = deleteRiskAcceptance(cspmClient, *dbPath, *acceptanceID)
+			err = deleteRiskAcceptance(cspmClient, *dbPath, *acceptanceID, mutation)
+		case "risk-renew":
+			// -within はデフォルト値があるため、明示された場合のみ期限で絞り込む
+			renewWithin := ""
+			if setFlags["within"] {
+				renewWithin = *within
+			}
+			err = renewRiskAcceptances(cspmClient, *dbPath, *acceptanceID, *controlID, *username, renewWithin, *expiresIn, mutation)
+		case "risk-import":
+			err = importRiskAcceptances(cspmClient, *dbPath, *file, *resultLog, mutation)
+		case "risk-plan":
+			err = planRiskAcceptances(cspmClient, *dbPath, *file, *prune)
+		case "risk-apply":
+			err = applyRiskAcceptances(cspmClient, *dbPath, *file, *prune, mutation)
 		default:
 			log.Fatalf("Unknown command: %s", *command)
 		}
 	} else {
-		// risk-list command only reads from database
-		err = listRiskAcceptances(*dbPath, *controlID)
+		switch *command {
+		case "risk-list":
+			err = listRiskAcceptances(*dbPath, *controlID)
+		case "db-migrate":
+			err = migrateDatabase(*dbPath)
+		case "db-version":
+			err = showDatabaseVersion(*dbPath)
+		case "config-list":
+			err = listProfiles(cfg)
+		case "config-show":
+			err = showConfig(cfg, *dbPath, *zoneName, *policyType)
+		case "serve":
+			err = serveAPI(cfg, *dbPath, *listenAddr)
+		case "serve-metrics":
+			err = serveMetrics(*dbPath, *listenAddr)
+		case "ui":
+			err = serveUI(cfg, *dbPath, *listenAddr)
+		case "tui":
+			err = runTUI(cfg, *dbPath, mutation.DryRun, *auditLogFile)
+		case "trend":
+			err = showTrend(*dbPath, *format)
+		case "risk-expiring":
+			err = listExpiringRiskAcceptances(*dbPath, *within, *format)
+		case "risk-lint":
+			err = lintRiskAcceptances(cfg, *dbPath, *format)
+		case "risk-show":
+			// IDは -acceptance-id または位置引数で指定できる
+			id := *acceptanceID
+			if id == "" {
+				id = flag.Arg(0)
+			}
+			err = showRiskAcceptance(*dbPath, id)
+		case "query":
+			err = queryResources(*dbPath, teamFilter(*filterExpr, *team), *format)
+		case "inventory":
+			err = showInventory(*dbPath, teamFilter(*filterExpr, *team), *format)
+		case "scorecard":
+			err = showScorecards(cfg, *dbPath, *format, *outputDir, *groupBy, *team)
+		case "owners":
+			err = resolveOwners(cfg, *dbPath, *format)
+		case "notify":
+			err = sendNotifications(cfg, *dbPath, *outputDir)
+		case "risk-export":
+			err = exportRiskAcceptances(cfg, *dbPath, *source, *file)
+		case "audit":
+			// -within はデフォルト値があるため、明示された場合のみ期間で絞り込む
+			auditWithin := ""
+			if setFlags["within"] {
+				auditWithin = *within
+			}
+			err = showAuditLog(*dbPath, *acceptanceID, *username, auditWithin, *format)
+		}
 	}
+	closeAudit()
 
+	if errors.Is(err, errFindings) {
+		os.Exit(exitFindings)
+	}
 	if err != nil {
 		log.Fatalf("Command failed: %v", err)
 	}
 }
 
-// buildFilter constructs a Sysdig CSPM API filter string from CLI parameters
-func buildFilter(policies, platform, zoneName string, includePass bool) string {
-	conditions := []string{}
-
-	// passフィルター（include-passフラグがfalseの場合のみ追加）
-	if !includePass {
-		conditions = append(conditions, `pass = "false"`)
-	}
-
-	// ポリシーフィルター（複数対応、部分一致）
-	if policies != "" {
-		policyList := strings.Split(policies, ",")
-		policyConditions := []string{}
-		for _, p := range policyList {
-			p = strings.TrimSpace(p)
-			if p != "" {
-				policyConditions = append(policyConditions,
-					fmt.Sprintf(`policy.name contains "%s"`, p))
-			}
-		}
-		if len(policyConditions) > 0 {
-			conditions = append(conditions,
-				fmt.Sprintf("(%s)", strings.Join(policyConditions, " or ")))
-		}
-	}
-
-	// プラットフォームフィルター（完全一致）
-	if platform != "" {
-		conditions = append(conditions,
-			fmt.Sprintf(`platform = "%s"`, platform))
-	}
+// explicitFlags returns the names of flags that were set on the command line
+func explicitFlags() map[string]bool {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
 
-	// ゾーンフィルター（完全一致、in演算子）
-	if zoneName != "" {
-		conditions = append(conditions,
-			fmt.Sprintf(`zone.name in ("%s")`, zoneName))
+// isLocalCommand reports whether the command works without the Sysdig API
+func isLocalCommand(command string) bool {
+	switch command {
+	case "risk-list", "db-migrate", "db-version", "config-list", "config-show", "serve", "serve-metrics", "ui", "tui", "trend", "risk-expiring", "risk-lint", "risk-show", "query", "risk-export", "audit", "inventory", "scorecard", "owners", "notify":
+		return true
+	default:
+		return false
 	}
-
-	return strings.Join(conditions, " and ")
 }
 
 func printUsage() {
@@ -137,18 +270,102 @@ Usage:
 Options:
   -config string
         Path to configuration file
+  -profile string
+        Configuration profile name defined in the config file (or set SYSDIG_PROFILE)
   -token string
         Sysdig API token (or set SYSDIG_API_TOKEN environment variable)
+        Not recommended: the token is visible in shell history and process listings.
+        Prefer SYSDIG_API_TOKEN or token_file/token_command in the config file
   -url string
         Sysdig API base URL (default "https://us2.app.sysdig.com")
+  -secure-url string
+        Sysdig public API URL for /secure/ endpoints (derived from -url when omitted)
+  -region string
+        Sysdig SaaS region: us1, us2, us4, eu1, au1, me2, in1
+        Sets both -url and -secure-url; explicit URLs take precedence
   -command string
-        Command to execute: list, collect, risk-collect, risk-list, risk-delete (default "list")
+        Command to execute: list, collect, daemon, risk-collect, risk-list, risk-delete,
+        db-migrate, db-version, config-list, config-show, serve, serve-metrics, ui,
+        tui, trend, risk-expiring, risk-renew, risk-lint, risk-show, query, risk-export,
+        risk-import, risk-plan, risk-apply, audit, inventory,
+        scorecard, owners, notify (default "list")
   -db string
         SQLite database path (default "data/cspm.db")
+        serve-metrics accepts a comma-separated list and glob patterns
+        (the newest match is used, e.g. "data/*/cis_aws.db")
+        trend accepts a comma-separated list of files, directories and glob patterns
+        (every match is used)
+        risk-lint accepts a comma-separated list (acceptances and controls of all are checked)
+        scorecard accepts a comma-separated list (e.g. one database per policy)
+  -listen string
+        Listen address for server commands (serve default "`+defaultAPIAddr+`",
+        serve-metrics default "`+defaultMetricsAddr+`", ui default "`+defaultUIAddr+`")
+  -plan string
+        Collection plan YAML file (for collect); runs all targets of the plan
+        in one process and prints a consolidated summary
+  -file string
+        Export file for risk-export and risk-import; .yaml or .yml is YAML, anything else JSON
+        Acceptance files for risk-plan and risk-apply: comma-separated YAML files and
+        directories (every .yaml/.yml file of a directory is read)
+  -source string
+        Source of risk-export: database (default) or api (the live tenant; needs a token)
+  -dry-run
+        Print the exact API requests that would change the tenant instead of sending them and
+        leave the database unchanged (risk-delete, risk-renew, risk-import, risk-apply, tui)
+  -yes
+        Do not ask for confirmation before changing the tenant; required for runs without a
+        terminal on stdin (cron, CI)
+  -audit-log string
+        Also append the audit log of write operations to this JSON lines file
+        (default: audit_log of the config file; the audit_log table of -db is always written)
+  -prune
+        risk-plan and risk-apply also revoke the risk acceptances no file declares
+        (without it they are left in place; system acceptances are never revoked)
+  -result-log string
+        Write the per-item result of risk-import as JSON lines to this file
+  -incremental
+        risk-collect only fetches acceptances newer than the last collected one and stops
+        paging there; acceptances revoked in Sysdig are only detected by a full collection
+  -filter string
+        Filter expression for query in the Sysdig CSPM filter syntax
+        (=, !=, in, not in, contains, startsWith, and, or, not, parentheses), e.g.
+        platform = "AWS" and location in ("ap-northeast-1") and status = "failed"
+        inventory filters resources by hash, name, type, platform, account, location,
+        organization, cluster, os and team, e.g. platform = "Kubernetes" and os = "linux"
+        query and inventory filter by the owning team with team = "payments"
+  -team string
+        Only include the resources owned by this team (query, inventory and scorecard);
+        added to -filter with "and"; other commands reject it
+  -format string
+        Output format for trend: markdown (weekly summary, default), csv or json (time series)
+        Output format for risk-expiring: table (default) or json
+        Output format for risk-lint: text (default) or json
+        Output format for query and inventory: table (default), csv, json or markdown
+        Output format for audit: table (default) or json
+        Output format for scorecard: table (default) or json (the ranking on stdout)
+        Output format for owners: table (default) or json
+  -output-dir string
+        scorecard also writes <account>.md and <account>.html per account to this directory
+        notify writes the webhook payloads to this directory instead of posting them (test mode;
+        the baseline of the next notification is left unchanged)
+  -group-by string
+        Rank the scorecard by account (default) or by the owning team
+  -within string
+        Window for risk-expiring: acceptances expiring within it are listed (default "30d")
+        Window for risk-renew: only acceptances expired or expiring within it are renewed
+        (applies only when given explicitly)
+        Window for audit: only changes recorded within it are listed (applies only when given explicitly)
+        Accepts days (30d), weeks (2w) or Go durations (36h)
+  -expires-in string
+        New expiry of renewed risk acceptances, counted from now (required for risk-renew, e.g. 90d)
   -control-id string
-        Filter by control ID (for risk-list)
+        Filter by control ID (for risk-list and risk-renew)
   -acceptance-id string
-        Risk acceptance ID (for risk-delete)
+        Risk acceptance ID (for risk-delete, risk-renew, risk-show and audit; risk-show also
+        accepts the ID as an argument)
+  -user string
+        Filter by the user who created the risk acceptance (for risk-renew)
+        Filter by the OS user who ran the change (for audit)
   -policy string
         Filter by policy name (comma-separated for multiple, partial match)
         Examples: "CIS AWS", "SOC 2", "CIS AWS,CIS GCP,SOC 2"
@@ -164,9 +381,65 @@ Options:
 Commands:
   list         - List compliance requirements with violations
   collect      - Collect compliance violations and associated resources to database
-  risk-collect - Collect all risk acceptances from API to database
+                 (with -plan: collect every target of a collection plan)
+  daemon       - Run the collection plans of the config "daemon" section on their schedules
+  risk-collect - Synchronize risk acceptances from API to database: acceptances no longer
+                 returned by the API are marked revoked (with -incremental: only fetch new ones)
   risk-list    - List risk acceptances from database (optionally filtered by control ID)
   risk-delete  - Delete a risk acceptance by ID (from both API and database)
+  risk-expiring - List risk acceptances from database that expired or expire within -within,
+                 grouped by control and owner (exit status 2 when any are found)
+  risk-export  - Write the risk acceptances of the database (or the API with -source api)
+                 to a versioned JSON/YAML file with control and zone names
+  risk-import  - Recreate the risk acceptances of an export file in the tenant of the token,
+                 mapping control and zone IDs by name via the target's collected database (-db)
+                 and skipping acceptances that already exist
+  risk-plan    - Compare the risk acceptances declared in YAML files (-file) with the live
+                 acceptances and show what risk-apply would create, replace or revoke
+                 (exit status 2 when changes are pending)
+  risk-apply   - Create, replace (recreate and revoke the old one) and, with -prune, revoke
+                 risk acceptances so the tenant matches the files
+  query        - List the control/resource pairs of the database matching -filter
+  inventory    - List the resources of the database matching -filter with the number of
+                 controls each fails, passes and has accepted and its highest failing
+                 severity, worst first
+  scorecard    - Rank accounts and projects (platform account ID for cluster resources) by a
+                 severity-weighted compliance score with an A-F grade per policy; weights and
+                 grades come from the config "scorecard" section (with -group-by team:
+                 rank the teams of the config "ownership" section)
+  owners       - Assign the resources of the database to teams with the config "ownership"
+                 section and show the resources and evaluations per team (collect does the
+                 same at the end of every collection)
+  notify       - Post the violations that are new, resolved or risk-accepted since the last
+                 notification to the webhooks of the config "notify" section (collect does the
+                 same at the end of every collection; the first run of a webhook only records
+                 the baseline)
+  risk-show    - Show the collected resources a risk acceptance's filter covers; without an ID,
+                 list every acceptance with its number of covered resources
+                 (exit status 2 when an acceptance matches nothing)
+  risk-lint    - Check risk acceptances from database against the risk_lint rules of the config
+                 (reason, description, ticket ID, accept period, "Never" expiry by severity,
+                 system users, unknown controls; exit status 2 when any are found)
+  risk-renew   - Recreate the risk acceptances selected by -acceptance-id, -control-id, -user
+                 and -within with a new expiry (-expires-in), revoke the old ones and record
+                 the link between them in the database
+  audit        - List the write operations (create, revoke) this tool sent to the API, recorded
+                 in the audit_log table of the database by risk-delete, risk-renew, risk-import,
+                 risk-apply and tui
+  db-migrate   - Apply pending schema migrations to the database
+  db-version   - Show the database schema version and pending migrations
+  config-list  - List configuration profiles (tokens are redacted)
+  config-show  - Show the effective configuration for the selected profile (tokens are redacted)
+  serve        - Serve the database as a read-only JSON API on /api/v1/ (API keys from the
+                 config "server" section, OpenAPI document on /openapi.yaml)
+  serve-metrics - Expose the stored compliance posture as Prometheus metrics on /metrics
+  ui           - Browse policies, requirements, controls, resources and risk acceptances
+                 in the embedded web dashboard
+  tui          - Triage violations in the terminal: browse requirements, controls and
+                 resources, mark failed resources and create risk acceptances for them
+                 (the API token is only needed when acceptances are submitted)
+  trend        - Show failing/passing/accepted counts over the recorded collections and
+                 snapshot databases with week-over-week changes
 
 Examples:
   # List all compliance violations
@@ -193,10 +466,40 @@ Examples:
     -platform "AWS" \
     -db "data/aws_compliance.db"
 
+  # Collect all targets of a collection plan (see examples/collection-plan.yaml)
+  sysdig-cspm-utils -command collect -plan examples/collection-plan.yaml
+
+  # Run scheduled collections (see examples/daemon-config.json)
+  sysdig-cspm-utils -config examples/daemon-config.json -command daemon
+
+  # Serve a database as a JSON API (see examples/server-config.json)
+  sysdig-cspm-utils -config examples/server-config.json -command serve \
+    -db "data/cis_aws.db" -listen :8080
+  curl -H "Authorization: Bearer $CSPM_API_KEY" \
+    "http://localhost:8080/api/v1/controls?pass=false&sort=-severity"
+
+  # Browse a database in the web dashboard (http://127.0.0.1:8088/)
+  sysdig-cspm-utils -command ui -db "data/cis_aws.db"
+
+  # Triage violations in the terminal and accept marked resources in bulk
+  sysdig-cspm-utils -command tui -db "data/cis_aws.db"
+
+  # Weekly posture trend of all snapshots of a collection plan
+  sysdig-cspm-utils -command trend -db "data/" > trend.md
+  sysdig-cspm-utils -command trend -db "data/*/cis_aws.db" -format csv > trend.csv
+
+  # Expose the latest snapshots as Prometheus metrics
+  sysdig-cspm-utils -command serve-metrics -listen :9108 \
+    -db "data/*/cis_aws.db,data/*/cis_gcp.db,data/*/soc2.db"
+
   # Collect all risk acceptances
   sysdig-cspm-utils -token YOUR_TOKEN -command risk-collect \
     -db "data/risk_acceptances.db"
 
+  # Fetch only the risk acceptances created since the last collection
+  sysdig-cspm-utils -token YOUR_TOKEN -command risk-collect -incremental \
+    -db "data/risk_acceptances.db"
+
   # List all risk acceptances
   sysdig-cspm-utils -command risk-list -db "data/risk_acceptances.db"
 
@@ -204,14 +507,91 @@ Examples:
   sysdig-cspm-utils -command risk-list -db "data/risk_acceptances.db" \
     -control-id "16022"
 
+  # Alert on risk acceptances expiring in the next 30 days (exit status 2 when any are found)
+  sysdig-cspm-utils -command risk-expiring -db "data/risk_acceptances.db" \
+    -within 30d -format json
+
+  # Move risk acceptances to another tenant (names come from each tenant's collected DB)
+  sysdig-cspm-utils -profile old -command risk-export -source api \
+    -db "data/old/cis_aws.db" -file acceptances.yaml
+  sysdig-cspm-utils -profile new -command risk-import -db "data/new/cis_aws.db" \
+    -file acceptances.yaml -dry-run
+
+  # Review risk acceptances as code: plan in the pull request, apply after merge
+  sysdig-cspm-utils -command risk-plan -db "data/cis_aws.db" -file acceptances/
+  sysdig-cspm-utils -command risk-apply -db "data/cis_aws.db" -file acceptances/ -prune -yes
+
+  # Query failed AWS resources in Tokyo without writing SQL
+  sysdig-cspm-utils -command query -db "data/cis_aws.db" -format csv \
+    -filter 'platform = "AWS" and location in ("ap-northeast-1") and status = "failed"'
+
+  # Resources of one account, worst first (highest failing severity, then failing controls)
+  sysdig-cspm-utils -command inventory -db "data/cis_aws.db" -filter 'account = "111111111111"'
+
+  # Grade every account across policies and write a scorecard per account
+  sysdig-cspm-utils -config examples/scorecard-config.json -command scorecard \
+    -db "data/cis_aws.db,data/cis_gcp.db,data/soc2.db" -output-dir reports/scorecards
+
+  # Map resources to teams (see examples/ownership-config.json) and grade the teams
+  sysdig-cspm-utils -config examples/ownership-config.json -command owners -db "data/cis_aws.db"
+  sysdig-cspm-utils -command scorecard -db "data/cis_aws.db" -group-by team
+
+  # Failed resources of one team
+  sysdig-cspm-utils -command inventory -db "data/cis_aws.db" -team payments
+
+  # Write the webhook payloads of new and resolved violations to files (see examples/notify-config.json)
+  sysdig-cspm-utils -config examples/notify-config.json -command notify \
+    -db "data/cis_aws.db" -output-dir notify-payloads
+
+  # Show the resources a risk acceptance covers (acceptances and resources in one database)
+  sysdig-cspm-utils -command risk-show -db "data/cis_aws.db" 6763aab48ebb8c821a3ddf89
+
+  # Check risk acceptance hygiene against the controls of the latest collection
+  sysdig-cspm-utils -config config.json -command risk-lint \
+    -db "data/risk_acceptances.db,data/cis_aws.db" -format json
+
+  # Renew a control's risk acceptances that expire in the next 14 days by 90 days
+  sysdig-cspm-utils -token YOUR_TOKEN -command risk-renew \
+    -db "data/risk_acceptances.db" \
+    -control-id "16022" -within 14d -expires-in 90d
+
   # Delete a risk acceptance
   sysdig-cspm-utils -token YOUR_TOKEN -command risk-delete \
     -db "data/risk_acceptances.db" \
     -acceptance-id "6763aab48ebb8c821a3ddf89"
 
+  # Who changed a risk acceptance through this tool in the last 7 days
+  sysdig-cspm-utils -command audit -db "data/risk_acceptances.db" \
+    -acceptance-id "6763aab48ebb8c821a3ddf89" -within 7d
+
+  # Upgrade an existing database to the latest schema
+  sysdig-cspm-utils -command db-migrate -db "data/cis_aws.db"
+
+  # Show schema version of a database
+  sysdig-cspm-utils -command db-version -db "data/cis_aws.db"
+
+  # Collect with a named profile (URL, token, DB, zone and policies from config)
+  sysdig-cspm-utils -config config.json -profile sandbox -command collect
+
+  # Use the EU region (application and public API hosts are resolved automatically)
+  sysdig-cspm-utils -region eu1 -command list
+
+  # On-prem installation with a separate public API host
+  sysdig-cspm-utils -url https://sysdig.example.com -secure-url https://sysdig-api.example.com
+
+  # Read the token from a password manager (config file)
+  #   {"token_command": "op read op://infra/sysdig/token"}
+  sysdig-cspm-utils -config config.json -command list
+
+  # Show profiles defined in the config file
+  sysdig-cspm-utils -config config.json -command config-list
+
 Environment Variables:
   SYSDIG_API_TOKEN  - API token for authentication
   SYSDIG_API_URL    - Base URL for Sysdig API
+  SYSDIG_SECURE_API_URL - Public API URL for /secure/ endpoints
+  SYSDIG_REGION     - Sysdig SaaS region
+  SYSDIG_PROFILE    - Configuration profile name
 
 `, version)
 }
@@ -220,7 +600,7 @@ func listCompliance(cspmClient *client.CSPMClient, policyType, platform, zoneNam
 	fmt.Printf("Getting compliance requirements (policy: %s, platform: %s, zone: %s)...\n", policyType, platform, zoneName)
 
 	// Build filter based on parameters (failed only)
-	filter := buildFilter(policyType, platform, zoneName, false)
+	filter := collector.BuildFilter(policyType, platform, zoneName, false)
 	fmt.Printf("Filter: %s\n\n", filter)
 
 	// Get compliance violations
@@ -253,34 +633,79 @@ func listCompliance(cspmClient *client.CSPMClient, policyType, platform, zoneNam
 	return nil
 }
 
-func collectResources(cspmClient *client.CSPMClient, dbPath, policyType, platform, zoneName string, batchSize, apiDelay int) error {
+func collectResources(cfg *config.Config, cspmClient *client.CSPMClient, dbPath, policyType, platform, zoneName string, batchSize, apiDelay int) error {
+	ownershipCfg, err := loadOwnership(cfg)
+	if err != nil {
+		return err
+	}
+	notifyCfg, err := loadNotify(cfg)
+	if err != nil {
+		return err
+	}
 	fmt.Printf("Collecting compliance violations and control resources to %s...\n", dbPath)
 	fmt.Printf("Parameters: policy=%s, platform=%s, zone=%s\n", policyType, platform, zoneName)
 
-	// Initialize database
-	db, err := database.NewDatabase(dbPath)
+	// Lock the database, run collection and record it in collection_runs
+	_, err = collector.CollectToDatabase(cspmClient, dbPath, collector.Options{
+		Policy:    policyType,
+		Platform:  platform,
+		Zone:      zoneName,
+		PageSize:  50,
+		BatchSize: batchSize,
+		APIDelay:  apiDelay,
+		Ownership: ownershipCfg,
+		Notify:    notifyCfg,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to initialize database: %w", err)
+		return err
 	}
-	defer func() { _ = db.Close() }()
 
-	// Build filter (failed only)
-	filter := buildFilter(policyType, platform, zoneName, false)
-	fmt.Printf("API Filter: %s\n\n", filter)
+	fmt.Println("\n✓ Collection completed successfully")
 
-	// Create collector and run collection
-	c := collector.NewComplianceCollector(cspmClient, db)
+	return nil
+}
 
-	if err := c.CollectComplianceData(filter, 50, batchSize, apiDelay); err != nil {
-		return fmt.Errorf("failed to collect compliance data: %w", err)
+func runCollectionPlan(cfg *config.Config, cspmClient *client.CSPMClient, planFile, zoneName string, batchSize, apiDelay int) error {
+	ownershipCfg, err := loadOwnership(cfg)
+	if err != nil {
+		return err
+	}
+	notifyCfg, err := loadNotify(cfg)
+	if err != nil {
+		return err
+	}
+	p, err := plan.Load(planFile)
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("\n✓ Collection completed successfully")
+	fmt.Printf("Running collection plan %s (%d targets)...\n", planFile, len(p.Targets))
+	if p.RateLimit.RequestsPerSecond > 0 {
+		fmt.Printf("Rate limit: %.2f requests/second\n", p.RateLimit.RequestsPerSecond)
+	}
 
+	// Flag and profile values are the fallback for settings not given in the plan
+	base := plan.ResolvedTarget{
+		Zone:      zoneName,
+		PageSize:  50,
+		BatchSize: batchSize,
+		APIDelay:  apiDelay,
+		Ownership: ownershipCfg,
+		Notify:    notifyCfg,
+	}
+
+	summary := p.Run(cspmClient, base, time.Now())
+	summary.Print(os.Stdout)
+
+	if failed := summary.Failed(); failed > 0 {
+		return fmt.Errorf("%d of %d targets failed", failed, len(summary.Results))
+	}
+
+	fmt.Println("\n✓ All targets collected successfully")
 	return nil
 }
 
-func collectRiskAcceptances(cspmClient *client.CSPMClient, dbPath string) error {
+func collectRiskAcceptances(cspmClient *client.CSPMClient, dbPath string, incremental bool) error {
 	fmt.Printf("Collecting risk acceptances to %s...\n\n", dbPath)
 
 	// Initialize database
@@ -290,19 +715,43 @@ func collectRiskAcceptances(cspmClient *client.CSPMClient, dbPath string) error
 	}
 	defer func() { _ = db.Close() }()
 
-	// Fetch all risk acceptances from API
+	// 差分収集では最後に収集した受容まででページングを打ち切る
+	var mark *acceptance.Watermark
+	if incremental {
+		latest, err := db.GetLatestRiskAcceptance()
+		if err != nil {
+			return fmt.Errorf("failed to get the latest risk acceptance: %w", err)
+		}
+		if mark, err = acceptance.NewWatermark(latest); err != nil {
+			return fmt.Errorf("invalid acceptance date of %s: %w", latest.ID, err)
+		}
+		if mark != nil {
+			fmt.Printf("Incremental collection: stopping at %s (accepted %s)\n", mark.ID, mark.AcceptanceDate.Local().Format("2006-01-02 15:04"))
+		}
+	}
+
+	// Fetch risk acceptances from API
 	fmt.Println("Fetching risk acceptances from API...")
-	acceptances, err := cspmClient.ListRiskAcceptances()
+	acceptances, err := cspmClient.ListRiskAcceptancesUntil(mark.Reached, 3)
 	if err != nil {
 		return fmt.Errorf("failed to list risk acceptances: %w", err)
 	}
 
-	fmt.Printf("\nSaving %d risk acceptances to database...\n", len(acceptances))
+	fmt.Printf("\nSynchronizing %d risk acceptances with the database...\n", len(acceptances))
 
-	// Save to database
-	if err := db.SaveRiskAcceptances(acceptances); err != nil {
+	// 全件収集の場合のみ、APIから返されなかった受容を取り消し済みにする
+	result, err := db.SyncRiskAcceptances(acceptances, !incremental, time.Now())
+	if err != nil {
 		return fmt.Errorf("failed to save risk acceptances: %w", err)
 	}
+	fmt.Printf("  Added: %d, Changed: %d, Removed (revoked): %d, Unchanged: %d\n\n", result.Added, result.Changed, result.Removed, result.Unchanged)
+
+	// 収集済みのリソースがあれば各受容の対象リソースを解決する
+	results, err := db.ResolveAcceptanceMatches("")
+	if err != nil {
+		return fmt.Errorf("failed to resolve risk acceptance resources: %w", err)
+	}
+	printMatchSummary(results)
 
 	fmt.Println("✓ Risk acceptance collection completed successfully")
 	return nil
@@ -361,11 +810,15 @@ func listRiskAcceptances(dbPath, controlID string) error {
 	return nil
 }
 
-func deleteRiskAcceptance(cspmClient *client.CSPMClient, dbPath, acceptanceID string) error {
+func deleteRiskAcceptance(cspmClient *client.CSPMClient, dbPath, acceptanceID string, mutation mutationOptions) error {
 	if acceptanceID == "" {
 		return fmt.Errorf("acceptance-id is required for risk-delete command")
 	}
 
+	if ok, err := mutation.confirm(fmt.Sprintf("Revoke risk acceptance %s on Sysdig?", acceptanceID)); err != nil || !ok {
+		return err
+	}
+
 	fmt.Printf("Deleting risk acceptance %s...\n", acceptanceID)
 
 	// Delete from API
@@ -373,17 +826,21 @@ func deleteRiskAcceptance(cspmClient *client.CSPMClient, dbPath, acceptanceID st
 	if err := cspmClient.DeleteRiskAcceptance(acceptanceID); err != nil {
 		return fmt.Errorf("failed to delete from API: %w", err)
 	}
+	if mutation.DryRun {
+		fmt.Println("Dry run: nothing was revoked and the database was not changed")
+		return nil
+	}
 
-	// Delete from database
-	fmt.Println("  Deleting from database...")
+	// 削除せずに取り消し日時を記録し、UIでの取り消しと同じく履歴を残す
+	fmt.Println("  Marking as revoked in database...")
 	db, err := database.NewDatabase(dbPath)
 	if err != nil {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer func() { _ = db.Close() }()
 
-	if err := db.DeleteRiskAcceptanceFromDB(acceptanceID); err != nil {
-		return fmt.Errorf("failed to delete from database: %w", err)
+	if err := db.MarkRiskAcceptanceRevoked(acceptanceID, time.Now()); err != nil {
+		return fmt.Errorf("failed to update database: %w", err)
 	}
 
 	fmt.Println("✓ Risk acceptance deleted successfully")
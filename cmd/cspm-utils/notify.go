@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/notify"
+)
+
+// loadNotify decodes and checks the notify section; nil when it is omitted. Collections
+// call it before they start, since they only warn when notifications fail.
+func loadNotify(cfg *config.Config) (*notify.Config, error) {
+	var section notify.Config
+	if ok, err := config.DecodeSection("notify", cfg.Notify, &section); err != nil || !ok {
+		return nil, err
+	}
+	if err := section.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid notify configuration: %w", err)
+	}
+	return &section, nil
+}
+
+// sendNotifications posts the violations of the database that are new, resolved or
+// risk-accepted since the last notification to the webhooks of the notify section. With
+// payloadDir the payloads are written to files instead (test mode).
+func sendNotifications(cfg *config.Config, dbPath, payloadDir string) error {
+	notifyCfg, err := loadNotify(cfg)
+	if err != nil {
+		return err
+	}
+	if notifyCfg == nil {
+		return fmt.Errorf("no notify section in the config file (set -config)")
+	}
+
+	db, err := database.OpenReadOnly(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	n := notify.New(*notifyCfg)
+	n.PayloadDir = payloadDir
+	result, err := n.Run(db, dbPath)
+	if result != nil {
+		for _, file := range result.Written() {
+			fmt.Printf("Wrote %s\n", file)
+		}
+		fmt.Printf("%s: %s\n", notify.SeriesName(dbPath), result.Summary())
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/ownership"
+)
+
+// teamCommands are the commands that filter by -team; the others reject it instead of
+// silently reporting every team
+var teamCommands = map[string]bool{
+	"query":     true,
+	"inventory": true,
+	"scorecard": true,
+}
+
+// loadOwnership decodes and checks the ownership section; nil when it is omitted. Collections
+// call it before they start, since they only warn when owners cannot be resolved.
+func loadOwnership(cfg *config.Config) (*ownership.Config, error) {
+	var section ownership.Config
+	if ok, err := config.DecodeSection("ownership", cfg.Ownership, &section); err != nil || !ok {
+		return nil, err
+	}
+	if _, err := section.Compile(); err != nil {
+		return nil, fmt.Errorf("invalid ownership configuration: %w", err)
+	}
+	return &section, nil
+}
+
+// resolveOwners recomputes the owners table of the database from the ownership section
+// and prints the resources and evaluations per team
+func resolveOwners(cfg *config.Config, dbPath, format string) error {
+	if format != "" && format != "table" && format != "json" {
+		return fmt.Errorf("unknown format %q for owners (table, json)", format)
+	}
+	ownershipCfg, err := loadOwnership(cfg)
+	if err != nil {
+		return err
+	}
+	if ownershipCfg == nil {
+		return fmt.Errorf("no ownership section in the config file (set -config)")
+	}
+
+	// 収集中のDBの所有者を書き換えないよう収集ロックを取る
+	lock, err := database.AcquireLock(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = lock.Release() }()
+
+	db, err := database.NewDatabase(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	result, err := ownership.Resolve(db, ownershipCfg, time.Now())
+	if err != nil {
+		return err
+	}
+	summaries, err := db.GetTeamSummaries()
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		if summaries == nil {
+			summaries = []database.TeamSummary{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summaries)
+	}
+
+	fmt.Printf("Assigned %d of %d resources to teams\n\n", result.Owned, result.Resources)
+	if len(summaries) == 0 {
+		fmt.Println("No collected resources found")
+		return nil
+	}
+	fmt.Printf("%-20s %9s %16s %8s %8s %8s  %s\n", "TEAM", "RESOURCES", "FAILED RESOURCES", "FAILED", "PASSED", "ACCEPTED", "CONTACTS")
+	fmt.Println(strings.Repeat("-", 110))
+	for _, s := range summaries {
+		team := s.Team
+		if team == "" {
+			team = "(no team)"
+		}
+		fmt.Printf("%-20s %9d %16d %8d %8d %8d  %s\n", team, s.Resources, s.FailedResources, s.Failed, s.Passed, s.Accepted, strings.Join(s.Contacts, ", "))
+	}
+	return nil
+}
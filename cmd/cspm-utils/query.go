@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/filter"
+)
+
+// queryFormats lists the output formats of the query command
+var queryFormats = []string{"table", "csv", "json", "markdown"}
+
+// queryResources prints the control/resource pairs of the database matching a filter expression
+func queryResources(dbPath, filterExpr, format string) error {
+	if format == "" {
+		format = "table"
+	}
+	known := false
+	for _, f := range queryFormats {
+		known = known || f == format
+	}
+	if !known {
+		return fmt.Errorf("unknown format %q for query (%s)", format, strings.Join(queryFormats, ", "))
+	}
+
+	db, err := database.OpenReadOnly(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	rows, err := db.QueryResources(filterExpr)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		if rows == nil {
+			rows = []database.QueryRow{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write(queryHeader)
+		for _, r := range rows {
+			_ = w.Write(queryRecord(r))
+		}
+		w.Flush()
+		return w.Error()
+	case "markdown":
+		fmt.Printf("| %s |\n", strings.Join(queryHeader, " | "))
+		fmt.Printf("|%s\n", strings.Repeat("---|", len(queryHeader)))
+		for _, r := range rows {
+			record := queryRecord(r)
+			for i, v := range record {
+				record[i] = strings.ReplaceAll(v, "|", `\|`)
+			}
+			fmt.Printf("| %s |\n", strings.Join(record, " | "))
+		}
+		return nil
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No matching resources")
+		return nil
+	}
+	fmt.Printf("%-10s %-8s %-40s %-30s %-15s %-15s %-15s %-9s\n", "CONTROL", "SEVERITY", "NAME", "TYPE", "ACCOUNT", "LOCATION", "TEAM", "STATUS")
+	fmt.Println(strings.Repeat("-", 149))
+	for _, r := range rows {
+		fmt.Printf("%-10s %-8s %-40s %-30s %-15s %-15s %-15s %-9s\n", r.ControlID, r.Severity, r.Name, r.Type, r.Account, r.Location, r.Team, r.Status)
+	}
+	fmt.Printf("\nTotal: %d resources\n", len(rows))
+	return nil
+}
+
+// teamFilter narrows a filter expression to the resources owned by a team (-team)
+func teamFilter(filterExpr, team string) string {
+	if team == "" {
+		return filterExpr
+	}
+	cond := "team = " + filter.Quote(team)
+	if strings.TrimSpace(filterExpr) == "" {
+		return cond
+	}
+	return "(" + filterExpr + ") and " + cond
+}
+
+var queryHeader = []string{"control_id", "control_name", "severity", "hash", "name", "type", "platform", "account", "location", "team", "status", "justification"}
+
+func queryRecord(r database.QueryRow) []string {
+	return []string{r.ControlID, r.ControlName, r.Severity, r.Hash, r.Name, r.Type, r.Platform, r.Account, r.Location, r.Team, r.Status, r.Justification}
+}
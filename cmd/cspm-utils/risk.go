@@ -0,0 +1,369 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/acceptance"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+// exitFindings is the exit status of checks that found something to report (e.g. for alerting from cron)
+const exitFindings = 2
+
+// errFindings is returned by check commands after printing their findings
+var errFindings = errors.New("findings reported")
+
+func listExpiringRiskAcceptances(dbPath, within, format string) error {
+	window, err := acceptance.ParseWindow(within)
+	if err != nil {
+		return err
+	}
+	if format != "" && format != "table" && format != "json" {
+		return fmt.Errorf("unknown format %q for risk-expiring (table, json)", format)
+	}
+
+	db, err := database.OpenReadOnly(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	acceptances, err := db.GetRiskAcceptances("")
+	if err != nil {
+		return fmt.Errorf("failed to get risk acceptances: %w", err)
+	}
+
+	report := acceptance.FindExpiring(acceptances, time.Now(), window)
+	for _, invalid := range report.Invalid {
+		log.Printf("[WARN] %v", invalid)
+	}
+
+	if format == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteTable(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.Expired+report.Expiring > 0 {
+		return errFindings
+	}
+	return nil
+}
+
+// renewRiskAcceptances renews the acceptances of the database matching the criteria;
+// an empty within selects acceptances regardless of their expiry
+func renewRiskAcceptances(cspmClient *client.CSPMClient, dbPath, acceptanceID, controlID, username, within, expiresIn string, mutation mutationOptions) error {
+	sel := acceptance.Selector{ID: acceptanceID, ControlID: controlID, Username: username, Now: time.Now()}
+	if within != "" {
+		window, err := acceptance.ParseWindow(within)
+		if err != nil {
+			return err
+		}
+		if window == 0 {
+			return fmt.Errorf("-within must be positive for risk-renew")
+		}
+		sel.Within = window
+	}
+	if sel.IsZero() {
+		return fmt.Errorf("-acceptance-id, -control-id, -user or -within is required for risk-renew command")
+	}
+	if expiresIn == "" {
+		return fmt.Errorf("-expires-in is required for risk-renew command (e.g. 90d)")
+	}
+	period, err := acceptance.ParseWindow(expiresIn)
+	if err != nil {
+		return err
+	}
+	if period <= 0 {
+		return fmt.Errorf("-expires-in must be positive")
+	}
+
+	db, err := database.NewDatabase(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	acceptances, err := db.GetRiskAcceptances("")
+	if err != nil {
+		return fmt.Errorf("failed to get risk acceptances: %w", err)
+	}
+	selected, err := acceptance.Select(acceptances, sel)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		fmt.Println("No risk acceptances matched (run risk-collect to refresh the database)")
+		return nil
+	}
+
+	now := sel.Now
+	expiresAt := now.Add(period)
+	for _, a := range selected {
+		fmt.Printf("  %s (control %s, %s)\n", a.ID, a.ControlID, a.Username)
+	}
+	prompt := fmt.Sprintf("Recreate these %d risk acceptances until %s and revoke the old ones?", len(selected), expiresAt.Format("2006-01-02"))
+	if ok, err := mutation.confirm(prompt); err != nil || !ok {
+		return err
+	}
+	fmt.Printf("Renewing %d risk acceptances until %s...\n", len(selected), expiresAt.Format("2006-01-02 15:04 MST"))
+
+	var failed int
+	for _, r := range acceptance.Renew(cspmClient, selected, expiresAt) {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("  ✗ %s: %v\n", r.Old.ID, r.Err)
+			continue
+		}
+		if mutation.DryRun {
+			continue
+		}
+
+		// 新しい受容はAPIに作成済みなので、ローカルの記録に失敗しても残りの更新は続ける
+		if err := db.SaveRiskAcceptances([]models.RiskAcceptance{*r.New}); err != nil {
+			log.Printf("[WARN] Failed to save risk acceptance %s: %v", r.New.ID, err)
+		}
+		renewal := &database.RiskAcceptanceRenewal{
+			OldID:             r.Old.ID,
+			NewID:             r.New.ID,
+			ControlID:         r.Old.ControlID,
+			OriginalUsername:  r.Old.Username,
+			PreviousExpiresAt: r.Old.ExpiresAt,
+			NewExpiresAt:      r.New.ExpiresAt,
+			RenewedAt:         now,
+		}
+		if err := db.RecordRiskAcceptanceRenewal(renewal); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
+
+		if r.RevokeErr != nil {
+			failed++
+			fmt.Printf("  ✗ %s → %s: created, but the old acceptance is still active: %v\n", r.Old.ID, r.New.ID, r.RevokeErr)
+			continue
+		}
+		if err := db.MarkRiskAcceptanceRevoked(r.Old.ID, now); err != nil {
+			log.Printf("[WARN] %v", err)
+		}
+		fmt.Printf("  ✓ %s → %s (control %s)\n", r.Old.ID, r.New.ID, r.Old.ControlID)
+	}
+
+	if mutation.DryRun {
+		fmt.Printf("\nDry run: %d risk acceptances would be renewed; the database was not changed\n", len(selected)-failed)
+	} else {
+		fmt.Printf("\nRenewed: %d, failed: %d\n", len(selected)-failed, failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d risk acceptances could not be renewed", failed, len(selected))
+	}
+	return nil
+}
+
+// lintRiskAcceptances checks the acceptances of the databases against the risk_lint rules.
+// Controls are taken from every database, so acceptance and collection databases can be combined.
+func lintRiskAcceptances(cfg *config.Config, dbPaths, format string) error {
+	if format != "" && format != "text" && format != "json" {
+		return fmt.Errorf("unknown format %q for risk-lint (text, json)", format)
+	}
+	rules := acceptance.DefaultLintRules()
+	var section acceptance.LintRules
+	if ok, err := config.DecodeSection("risk_lint", cfg.RiskLint, &section); err != nil {
+		return err
+	} else if ok {
+		rules = section
+	}
+
+	var acceptances []models.RiskAcceptance
+	seen := make(map[string]bool)
+	controls := make(map[string]string)
+	for _, path := range splitList(dbPaths) {
+		db, err := database.OpenReadOnly(path)
+		if err != nil {
+			return err
+		}
+		dbAcceptances, err := db.GetRiskAcceptances("")
+		if err == nil {
+			var severities map[string]string
+			severities, err = db.GetControlSeverities()
+			for id, severity := range severities {
+				controls[id] = severity
+			}
+		}
+		_ = db.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		for _, a := range dbAcceptances {
+			if !seen[a.ID] {
+				seen[a.ID] = true
+				acceptances = append(acceptances, a)
+			}
+		}
+	}
+
+	report, err := acceptance.Lint(acceptances, controls, rules)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(report.Findings) > 0 {
+		return errFindings
+	}
+	return nil
+}
+
+// printMatchSummary prints the outcome of resolving the resources covered by risk acceptances
+func printMatchSummary(results []database.AcceptanceMatchResult) {
+	var candidates, resources, empty int
+	for _, r := range results {
+		if r.Err != nil {
+			log.Printf("[WARN] %v", r.Err)
+			continue
+		}
+		candidates += r.Candidates
+		resources += r.Matched
+		if r.Matched == 0 {
+			empty++
+		}
+	}
+	if len(results) == 0 {
+		return
+	}
+	if candidates == 0 {
+		fmt.Println("  No collected resources for these controls; collect into the same database to resolve covered resources")
+		return
+	}
+	fmt.Printf("  Covered resources: %d (%d risk acceptances match nothing, see risk-show)\n", resources, empty)
+}
+
+// showRiskAcceptance resolves and prints the resources covered by one risk acceptance,
+// or lists every acceptance with its number of covered resources when acceptanceID is empty.
+// Acceptances that match nothing are reported as findings.
+func showRiskAcceptance(dbPath, acceptanceID string) error {
+	db, err := database.NewDatabase(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	if acceptanceID == "" {
+		return listAcceptanceCoverage(db)
+	}
+
+	acceptances, err := db.GetRiskAcceptances("")
+	if err != nil {
+		return fmt.Errorf("failed to get risk acceptances: %w", err)
+	}
+	var a *models.RiskAcceptance
+	for i := range acceptances {
+		if acceptances[i].ID == acceptanceID {
+			a = &acceptances[i]
+			break
+		}
+	}
+	if a == nil {
+		// risk-collectで取り消し済みと判定された受容は理由を示す
+		if revokedAt, revoked, err := db.GetRiskAcceptanceRevokedAt(acceptanceID); err == nil && revoked {
+			return fmt.Errorf("risk acceptance %s was revoked (missing from the API since %s)", acceptanceID, revokedAt.Local().Format("2006-01-02 15:04"))
+		}
+		return fmt.Errorf("risk acceptance %s not found (run risk-collect to refresh the database)", acceptanceID)
+	}
+
+	results, err := db.ResolveAcceptanceMatches(acceptanceID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve covered resources: %w", err)
+	}
+	result := results[0]
+
+	expires := "never"
+	if at, ok, err := acceptance.ExpiryOf(*a); err != nil {
+		expires = a.ExpiresAt
+	} else if ok {
+		expires = at.Local().Format("2006-01-02 15:04")
+	}
+	fmt.Printf("Risk acceptance %s\n", a.ID)
+	fmt.Printf("  Control:     %s\n", a.ControlID)
+	fmt.Printf("  Reason:      %s\n", a.Reason)
+	fmt.Printf("  Description: %s\n", a.Description)
+	fmt.Printf("  User:        %s\n", a.Username)
+	fmt.Printf("  Filter:      %s\n", a.Filter)
+	fmt.Printf("  Zone:        %s\n", a.ZoneID)
+	fmt.Printf("  Source:      %s\n", a.SourceID)
+	fmt.Printf("  Expires:     %s\n\n", expires)
+
+	if result.Err != nil {
+		return result.Err
+	}
+
+	matches, err := db.GetAcceptanceMatches(acceptanceID)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		fmt.Printf("⚠ Matches none of the %d collected resources of control %s in its zone and source\n", result.Candidates, a.ControlID)
+		return errFindings
+	}
+
+	fmt.Printf("Covered resources: %d of %d in scope\n\n", len(matches), result.Candidates)
+	fmt.Printf("%-40s %-30s %-15s %-15s %-9s\n", "NAME", "TYPE", "ACCOUNT", "LOCATION", "STATUS")
+	fmt.Println(strings.Repeat("-", 113))
+	for _, m := range matches {
+		fmt.Printf("%-40s %-30s %-15s %-15s %-9s\n", m.Name, m.Type, m.Account, m.Location, m.Status)
+	}
+	return nil
+}
+
+// listAcceptanceCoverage resolves every risk acceptance and lists its number of covered resources
+func listAcceptanceCoverage(db *database.Database) error {
+	results, err := db.ResolveAcceptanceMatches("")
+	if err != nil {
+		return fmt.Errorf("failed to resolve covered resources: %w", err)
+	}
+	if len(results) == 0 {
+		fmt.Println("No risk acceptances found")
+		return nil
+	}
+
+	fmt.Printf("%-26s %-10s %10s %10s  %s\n", "ID", "CONTROL", "RESOURCES", "IN SCOPE", "NOTE")
+	fmt.Println(strings.Repeat("-", 80))
+	var empty int
+	for _, r := range results {
+		note := ""
+		switch {
+		case r.Err != nil:
+			note = "⚠ " + r.Err.Error()
+		case r.Matched == 0:
+			note = "⚠ matches nothing"
+		}
+		if r.Err != nil || r.Matched == 0 {
+			empty++
+		}
+		fmt.Printf("%-26s %-10s %10d %10d  %s\n", r.AcceptanceID, r.ControlID, r.Matched, r.Candidates, note)
+	}
+
+	fmt.Printf("\nTotal: %d risk acceptances, %d match nothing\n", len(results), empty)
+	if empty > 0 {
+		return errFindings
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/acceptance"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// planCode compares the acceptances declared in the files with the live acceptances of the tenant.
+// Control and zone names are resolved with the collection database when it exists.
+func planCode(cspmClient *client.CSPMClient, command, dbPath, files string, prune bool) (*acceptance.CodePlan, acceptance.Names, error) {
+	if files == "" {
+		return nil, acceptance.Names{}, fmt.Errorf("-file is required for %s command (acceptance YAML files or directories, comma-separated)", command)
+	}
+	decls, err := acceptance.LoadDeclarations(splitList(files))
+	if err != nil {
+		return nil, acceptance.Names{}, err
+	}
+
+	var names acceptance.Names
+	if _, statErr := os.Stat(dbPath); statErr == nil {
+		db, err := database.OpenReadOnly(dbPath)
+		if err != nil {
+			return nil, acceptance.Names{}, err
+		}
+		names, err = loadNames(db)
+		_ = db.Close()
+		if err != nil {
+			return nil, acceptance.Names{}, err
+		}
+	}
+	if len(names.Controls) == 0 {
+		log.Printf("[WARN] No collected controls in %s; controls and zones must be declared by ID", dbPath)
+	}
+
+	fmt.Println("Fetching live risk acceptances...")
+	live, err := cspmClient.ListRiskAcceptances()
+	if err != nil {
+		return nil, acceptance.Names{}, fmt.Errorf("failed to list risk acceptances: %w", err)
+	}
+
+	plan := acceptance.PlanCode(decls, acceptance.CodeOptions{Names: names, Live: live, Prune: prune, Now: time.Now()})
+	return plan, names, nil
+}
+
+// planRiskAcceptances prints what risk-apply would change; pending changes exit with status 2
+func planRiskAcceptances(cspmClient *client.CSPMClient, dbPath, files string, prune bool) error {
+	plan, names, err := planCode(cspmClient, "risk-plan", dbPath, files, prune)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	if err := plan.WritePlan(os.Stdout, names); err != nil {
+		return err
+	}
+	if n := plan.Count(acceptance.CodeInvalid); n > 0 {
+		return fmt.Errorf("%d declarations are invalid", n)
+	}
+	if plan.Pending() {
+		return errFindings
+	}
+	return nil
+}
+
+// applyRiskAcceptances makes the tenant match the declared acceptances. Nothing is changed
+// while any declaration is invalid.
+func applyRiskAcceptances(cspmClient *client.CSPMClient, dbPath, files string, prune bool, mutation mutationOptions) error {
+	plan, names, err := planCode(cspmClient, "risk-apply", dbPath, files, prune)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+	if n := plan.Count(acceptance.CodeInvalid); n > 0 {
+		if err := plan.WritePlan(os.Stdout, names); err != nil {
+			return err
+		}
+		return fmt.Errorf("%d declarations are invalid; nothing was applied", n)
+	}
+	if !plan.Pending() {
+		return plan.WritePlan(os.Stdout, names)
+	}
+
+	if !mutation.DryRun {
+		if err := plan.WritePlan(os.Stdout, names); err != nil {
+			return err
+		}
+		changes := plan.Count(acceptance.CodeCreate) + plan.Count(acceptance.CodeReplace) + plan.Count(acceptance.CodeRevoke)
+		ok, err := mutation.confirm(fmt.Sprintf("Apply %d changes to the risk acceptances on Sysdig?", changes))
+		if err != nil || !ok {
+			return err
+		}
+		fmt.Println()
+	}
+
+	// ドライランではリクエストを表示した後に計画を表示する
+	acceptance.ApplyCode(cspmClient, plan)
+	if mutation.DryRun {
+		fmt.Println()
+	}
+	if err := plan.WritePlan(os.Stdout, names); err != nil {
+		return err
+	}
+	if mutation.DryRun {
+		return nil
+	}
+	if n := plan.Failed(); n > 0 {
+		return fmt.Errorf("%d changes failed", n)
+	}
+	fmt.Println("Run risk-collect to refresh the risk acceptances of the database")
+	return nil
+}
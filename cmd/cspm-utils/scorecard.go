@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/scorecard"
+)
+
+// showScorecards prints the accounts (or owning teams) of the databases ranked by their
+// severity-weighted score and, with outputDir, writes a Markdown and an HTML scorecard per entry.
+// With team only the resources of that team are scored.
+func showScorecards(cfg *config.Config, dbPaths, format, outputDir, groupBy, team string) error {
+	if format != "" && format != "table" && format != "json" {
+		return fmt.Errorf("unknown format %q for scorecard (table, json)", format)
+	}
+	if groupBy == "" {
+		groupBy = scorecard.GroupByAccount
+	}
+	if groupBy != scorecard.GroupByAccount && groupBy != scorecard.GroupByTeam {
+		return fmt.Errorf("unknown -group-by %q for scorecard (account, team)", groupBy)
+	}
+	scoring := scorecard.DefaultConfig()
+	var section scorecard.Config
+	if ok, err := config.DecodeSection("scorecard", cfg.Scorecard, &section); err != nil {
+		return err
+	} else if ok {
+		scoring = section.WithDefaults()
+	}
+	if err := scoring.Validate(); err != nil {
+		return err
+	}
+
+	paths := splitList(dbPaths)
+	if len(paths) == 0 {
+		return fmt.Errorf("-db is required for scorecard command")
+	}
+	var counts []database.PostureCount
+	for _, path := range paths {
+		db, err := database.OpenReadOnly(path)
+		if err != nil {
+			return err
+		}
+		dbCounts, err := db.GetResourcePosture()
+		_ = db.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, c := range dbCounts {
+			if team == "" || c.Team == team {
+				counts = append(counts, c)
+			}
+		}
+	}
+
+	accounts := scorecard.ComputeBy(counts, scoring, groupBy)
+	if format == "json" {
+		if err := scorecard.WriteJSON(os.Stdout, accounts); err != nil {
+			return err
+		}
+	} else if err := scorecard.WriteTable(os.Stdout, accounts); err != nil {
+		return err
+	}
+
+	if outputDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputDir, err)
+	}
+	now := time.Now()
+	for _, a := range accounts {
+		for _, f := range scorecard.Formats {
+			if err := writeScorecard(filepath.Join(outputDir, scorecard.FileName(a.Name(), f)), f, a, scoring, now); err != nil {
+				return err
+			}
+		}
+	}
+	// 表をJSONで出力した場合は標準出力を汚さない
+	if format != "json" {
+		fmt.Printf("Wrote the scorecards of %d %ss to %s\n", len(accounts), groupBy, outputDir)
+	}
+	return nil
+}
+
+func writeScorecard(path, format string, a scorecard.Account, scoring scorecard.Config, now time.Time) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	err = scorecard.Write(f, format, a, scoring, now)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
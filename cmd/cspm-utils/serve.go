@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/api"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/metrics"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/ui"
+)
+
+// Default listen addresses when -listen is not given
+const (
+	defaultMetricsAddr = ":9108"
+	defaultAPIAddr     = ":8080"
+	defaultUIAddr      = "127.0.0.1:8088"
+)
+
+func serveAPI(cfg *config.Config, dbPath, listenAddr string) error {
+	if listenAddr == "" {
+		listenAddr = defaultAPIAddr
+	}
+
+	keys, err := cfg.Server.ResolveAPIKeys()
+	if err != nil {
+		return fmt.Errorf("invalid server configuration: %w", err)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no API keys configured; add server.api_keys to the config file (see examples/server-config.json)")
+	}
+
+	db, err := database.OpenReadOnly(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	fmt.Printf("Serving %s on http://%s/api/v1/ (%d API keys, OpenAPI document at /openapi.yaml)\n", dbPath, listenAddr, len(keys))
+	return listenAndServe(listenAddr, api.NewServer(db, keys).Handler())
+}
+
+func serveMetrics(dbPaths, listenAddr string) error {
+	if listenAddr == "" {
+		listenAddr = defaultMetricsAddr
+	}
+
+	exporter := metrics.NewExporter(splitList(dbPaths))
+	sources, err := exporter.ResolveSources()
+	if err != nil {
+		return err
+	}
+	for _, src := range sources {
+		fmt.Printf("Exporting %s as db=%q\n", src.Path, src.Name)
+	}
+
+	fmt.Printf("Serving metrics on http://%s/metrics\n", listenAddr)
+	return listenAndServe(listenAddr, exporter.Handler())
+}
+
+// serveUI serves the dashboard and the JSON API it uses.
+// Without server.api_keys the API is unauthenticated, which is only allowed on a loopback address.
+func serveUI(cfg *config.Config, dbPath, listenAddr string) error {
+	if listenAddr == "" {
+		listenAddr = defaultUIAddr
+	}
+
+	keys, err := cfg.Server.ResolveAPIKeys()
+	if err != nil {
+		return fmt.Errorf("invalid server configuration: %w", err)
+	}
+	if len(keys) == 0 && !isLoopback(listenAddr) {
+		return fmt.Errorf("no API keys configured; listen on a loopback address (e.g. %s) or add server.api_keys to the config file", defaultUIAddr)
+	}
+
+	db, err := database.OpenReadOnly(dbPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	if len(keys) > 0 {
+		fmt.Printf("API keys are required; the dashboard asks for one on first access\n")
+	}
+	fmt.Printf("Serving dashboard for %s on http://%s/\n", dbPath, listenAddr)
+	return listenAndServe(listenAddr, ui.Handler(api.NewServer(db, keys).Handler()))
+}
+
+// isLoopback reports whether the listen address only accepts local connections
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// listenAndServe serves handler until SIGINT/SIGTERM
+func listenAndServe(addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		log.Println("Shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// splitList splits a comma-separated flag value and drops empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/acceptance"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+// exportRiskAcceptances writes the acceptances of the database or the API to an export file.
+// Control and zone names are taken from the database when it exists.
+func exportRiskAcceptances(cfg *config.Config, dbPath, source, path string) error {
+	if path == "" {
+		return fmt.Errorf("-file is required for risk-export command (.json, .yaml or .yml)")
+	}
+	if source == "" {
+		source = acceptance.SourceDatabase
+	}
+
+	var db *database.Database
+	if _, err := os.Stat(dbPath); err == nil {
+		if db, err = database.OpenReadOnly(dbPath); err != nil {
+			return err
+		}
+		defer func() { _ = db.Close() }()
+	} else if source == acceptance.SourceDatabase {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	var acceptances []models.RiskAcceptance
+	var err error
+	switch source {
+	case acceptance.SourceDatabase:
+		acceptances, err = db.GetRiskAcceptances("")
+	case acceptance.SourceAPI:
+		var c *client.CSPMClient
+		if c, err = newAPIClient(cfg); err != nil {
+			return err
+		}
+		fmt.Println("Fetching risk acceptances from API...")
+		acceptances, err = c.ListRiskAcceptances()
+	default:
+		return fmt.Errorf("unknown source %q for risk-export (database, api)", source)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get risk acceptances: %w", err)
+	}
+
+	names, err := loadNames(db)
+	if err != nil {
+		return err
+	}
+	if len(names.Controls) == 0 {
+		log.Printf("[WARN] No collected controls in %s; the export has no control names and risk-import will keep the control IDs", dbPath)
+	}
+
+	export, skipped := acceptance.NewExport(acceptances, names, source, time.Now())
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := acceptance.WriteExport(f, path, export); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Exported %d risk acceptances to %s (%d system acceptances skipped)\n", len(export.Acceptances), path, skipped)
+	return nil
+}
+
+// importRiskAcceptances recreates the acceptances of an export file in the tenant of cspmClient.
+// Control and zone IDs are mapped by name using the target collection database when it exists.
+func importRiskAcceptances(cspmClient *client.CSPMClient, dbPath, path, resultLog string, mutation mutationOptions) error {
+	if path == "" {
+		return fmt.Errorf("-file is required for risk-import command (.json, .yaml or .yml)")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	export, err := acceptance.ReadExport(f, path)
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+
+	var names acceptance.Names
+	if _, statErr := os.Stat(dbPath); statErr == nil {
+		db, err := database.OpenReadOnly(dbPath)
+		if err != nil {
+			return err
+		}
+		names, err = loadNames(db)
+		_ = db.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if len(names.Controls) == 0 {
+		log.Printf("[WARN] No collected controls of the target tenant in %s; exported control and zone IDs are kept", dbPath)
+	}
+
+	fmt.Println("Fetching existing risk acceptances of the target tenant...")
+	existing, err := cspmClient.ListRiskAcceptances()
+	if err != nil {
+		return fmt.Errorf("failed to list risk acceptances: %w", err)
+	}
+
+	items := acceptance.PlanImport(export, acceptance.ImportOptions{Names: names, Existing: existing, Now: time.Now()})
+	var toCreate int
+	for _, item := range items {
+		if item.Status == acceptance.ImportCreate {
+			toCreate++
+		}
+	}
+
+	switch {
+	case toCreate == 0:
+	case mutation.DryRun:
+		// 送信されるリクエストを表示するだけで、各項目はcreateのまま残す
+		for _, item := range items {
+			if item.Status != acceptance.ImportCreate {
+				continue
+			}
+			if _, err := cspmClient.CreateRiskAcceptance(item.Request); err != nil {
+				return err
+			}
+		}
+	default:
+		ok, err := mutation.confirm(fmt.Sprintf("Create %d risk acceptances in the target tenant?", toCreate))
+		if err != nil || !ok {
+			return err
+		}
+		acceptance.Import(cspmClient, items)
+	}
+
+	counts := make(map[string]int)
+	for _, item := range items {
+		counts[item.Status]++
+		line := fmt.Sprintf("  %-17s %s → control %d", item.Status, item.Source.ID, item.Request.ControlID)
+		if item.NewID != "" {
+			line += " (" + item.NewID + ")"
+		}
+		if item.Err != nil {
+			line += ": " + item.Err.Error()
+		}
+		fmt.Println(line)
+	}
+
+	if resultLog != "" {
+		lf, err := os.Create(resultLog)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", resultLog, err)
+		}
+		err = acceptance.WriteImportLog(lf, items)
+		if closeErr := lf.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", resultLog, err)
+		}
+	}
+
+	if mutation.DryRun {
+		fmt.Printf("\nDry run: %d would be created, %d already exist, %d expired, %d cannot be mapped\n",
+			counts[acceptance.ImportCreate], counts[acceptance.ImportExisting], counts[acceptance.ImportExpired], counts[acceptance.ImportFailed])
+		return nil
+	}
+	fmt.Printf("\nCreated: %d, already existing: %d, expired: %d, failed: %d\n",
+		counts[acceptance.ImportCreated], counts[acceptance.ImportExisting], counts[acceptance.ImportExpired], counts[acceptance.ImportFailed])
+	if counts[acceptance.ImportFailed] > 0 {
+		return fmt.Errorf("%d of %d risk acceptances could not be imported", counts[acceptance.ImportFailed], len(items))
+	}
+	return nil
+}
+
+// loadNames returns the control and zone names of a database (none for a nil database)
+func loadNames(db *database.Database) (acceptance.Names, error) {
+	if db == nil {
+		return acceptance.Names{}, nil
+	}
+	controls, err := db.GetControlNames()
+	if err != nil {
+		return acceptance.Names{}, err
+	}
+	zones, err := db.GetZoneNames()
+	if err != nil {
+		return acceptance.Names{}, err
+	}
+	return acceptance.Names{Controls: controls, Zones: zones}, nil
+}
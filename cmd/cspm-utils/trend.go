@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/trend"
+)
+
+func showTrend(dbPaths, format string) error {
+	switch format {
+	case "":
+		format = trend.FormatMarkdown
+	case trend.FormatMarkdown, trend.FormatCSV, trend.FormatJSON:
+	default:
+		return fmt.Errorf("unknown format %q for trend (%s)", format, strings.Join(trend.Formats, ", "))
+	}
+
+	paths, err := trend.ExpandPaths(splitList(dbPaths))
+	if err != nil {
+		return err
+	}
+
+	points, err := trend.Load(paths)
+	if err != nil {
+		return err
+	}
+
+	return trend.Write(os.Stdout, format, points)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/acceptance"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/tui"
+)
+
+func runTUI(cfg *config.Config, dbPath string, dryRun bool, auditLogFile string) error {
+	// ドライランではマイグレーションも含めてDBを変更しない
+	open := database.NewDatabase
+	if dryRun {
+		open = database.OpenReadOnly
+	}
+	db, err := open(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	app := tui.New(db, os.Stdin, os.Stdout)
+	app.ClearScreen = isTerminal(os.Stdout)
+	app.DryRun = dryRun
+	var closers []func()
+	// Browsing is local; the token is resolved only when acceptances are submitted
+	app.NewCreator = func() (acceptance.Creator, error) {
+		c, err := newAPIClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if dryRun {
+			c.DryRun = os.Stdout
+			return c, nil
+		}
+		closeAudit, err := attachAuditLog(c, cfg, dbPath, "tui", auditLogFile)
+		if err != nil {
+			return nil, err
+		}
+		closers = append(closers, closeAudit)
+		return c, nil
+	}
+	defer func() {
+		for _, closeAudit := range closers {
+			closeAudit()
+		}
+	}()
+
+	return app.Run()
+}
+
+// newAPIClient resolves the token and creates a client for commands that use the API only
+// in some cases
+func newAPIClient(cfg *config.Config) (*client.CSPMClient, error) {
+	if err := cfg.ResolveToken(); err != nil {
+		return nil, fmt.Errorf("failed to get API token: %w", err)
+	}
+	log.SetOutput(config.NewRedactingWriter(os.Stderr, cfg.APIToken))
+	if cfg.APIToken == "" {
+		return nil, fmt.Errorf("API token is required. Set via -token flag, SYSDIG_API_TOKEN environment variable or a config profile")
+	}
+
+	endpoints, err := cfg.Endpoints()
+	if err != nil {
+		return nil, fmt.Errorf("invalid API endpoints: %w", err)
+	}
+	return client.NewCSPMClientWithEndpoints(endpoints, cfg.APIToken), nil
+}
+
+// isTerminal reports whether f is a character device (an interactive terminal)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -5,4 +5,5 @@ go 1.23
 require (
 	github.com/kaz-under-the-bridge/sysdig-vuls-utils v0.0.0-20251002120635-7d8c9fc9ed65
 	github.com/mattn/go-sqlite3 v1.14.32
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -2,11 +2,15 @@
 package testutil
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
 )
 
 // MockServerConfig holds configuration for the CSPM mock server
@@ -21,6 +25,42 @@ type MockServerConfig struct {
 	UnauthorizedResponse bool
 	// RateLimitResponse controls whether to return 429 for all requests
 	RateLimitResponse bool
+	// RiskAcceptances are returned by the risk acceptance search, newest first
+	RiskAcceptances []models.RiskAcceptance
+
+	mu sync.Mutex
+	// riskAcceptanceSearchPages records the page numbers of the risk acceptance search requests
+	riskAcceptanceSearchPages []int
+	// riskAcceptanceRequests records the bodies of risk acceptance create requests
+	riskAcceptanceRequests []map[string]interface{}
+	// revokedRiskAcceptances records the IDs of revoked risk acceptances
+	revokedRiskAcceptances []string
+}
+
+// RevokedRiskAcceptances returns the IDs of the risk acceptances revoked so far
+func (c *MockServerConfig) RevokedRiskAcceptances() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.revokedRiskAcceptances...)
+}
+
+// RiskAcceptanceSearchPages returns the page numbers requested from the risk acceptance search so far
+func (c *MockServerConfig) RiskAcceptanceSearchPages() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]int(nil), c.riskAcceptanceSearchPages...)
+}
+
+// RiskAcceptanceRequests returns the bodies of the risk acceptance create requests received so far
+func (c *MockServerConfig) RiskAcceptanceRequests() []map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	requests := make([]map[string]interface{}, len(c.riskAcceptanceRequests))
+	copy(requests, c.riskAcceptanceRequests)
+	return requests
 }
 
 // DefaultMockServerConfig returns a default configuration
@@ -76,6 +116,15 @@ func NewMockServer(config *MockServerConfig) *httptest.Server {
 		case strings.HasPrefix(path, "/api/cspm/v1/clusteranalysis/resources"):
 			handleClusterAnalysisResources(w, r, config)
 
+		case path == "/api/cspm/v1/compliance/violations/acceptances/search" && r.Method == http.MethodPost:
+			handleSearchRiskAcceptances(w, r, config)
+
+		case path == "/api/cspm/v1/compliance/violations/acceptances" && r.Method == http.MethodPost:
+			handleCreateRiskAcceptance(w, r, config)
+
+		case path == "/api/cspm/v1/compliance/violations/revoke" && r.Method == http.MethodPost:
+			handleRevokeRiskAcceptance(w, r, config)
+
 		default:
 			w.WriteHeader(http.StatusNotFound)
 			_, _ = w.Write([]byte(`{"message": "endpoint not found"}`))
@@ -231,3 +280,97 @@ func handleClusterAnalysisResources(w http.ResponseWriter, r *http.Request, conf
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(data)
 }
+
+// handleSearchRiskAcceptances handles POST /api/cspm/v1/compliance/violations/acceptances/search
+func handleSearchRiskAcceptances(w http.ResponseWriter, r *http.Request, config *MockServerConfig) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var request models.RiskAcceptanceSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.PageNumber < 1 || request.PageSize < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"message": "pageNumber and pageSize are required"}`))
+		return
+	}
+
+	config.mu.Lock()
+	config.riskAcceptanceSearchPages = append(config.riskAcceptanceSearchPages, request.PageNumber)
+	config.mu.Unlock()
+
+	// RiskAcceptancesは新しい順に並んでいる前提でページを切り出す
+	start := (request.PageNumber - 1) * request.PageSize
+	end := start + request.PageSize
+	if start > len(config.RiskAcceptances) {
+		start = len(config.RiskAcceptances)
+	}
+	if end > len(config.RiskAcceptances) {
+		end = len(config.RiskAcceptances)
+	}
+
+	response := models.RiskAcceptanceSearchResponse{
+		Data:       append([]models.RiskAcceptance{}, config.RiskAcceptances[start:end]...),
+		TotalCount: len(config.RiskAcceptances),
+	}
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// handleCreateRiskAcceptance handles POST /api/cspm/v1/compliance/violations/acceptances
+func handleCreateRiskAcceptance(w http.ResponseWriter, r *http.Request, config *MockServerConfig) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"message": "invalid request body"}`))
+		return
+	}
+
+	// 必須パラメータチェック（controlIdは数値）
+	if _, ok := body["controlId"].(float64); !ok || body["reason"] == nil || body["reason"] == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"message": "controlId and reason are required"}`))
+		return
+	}
+
+	config.mu.Lock()
+	config.riskAcceptanceRequests = append(config.riskAcceptanceRequests, body)
+	id := fmt.Sprintf("mock-acceptance-%d", len(config.riskAcceptanceRequests))
+	config.mu.Unlock()
+
+	response := map[string]interface{}{
+		"id":              id,
+		"acceptanceDate":  "1660742030427",
+		"isExpired":       false,
+		"username":        "mock@example.com",
+		"userDisplayName": "Mock User",
+	}
+	for _, key := range []string{"controlId", "reason", "description", "filter", "expiresAt", "sourceId", "zoneId"} {
+		if v, ok := body[key]; ok {
+			response[key] = v
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// handleRevokeRiskAcceptance handles POST /api/cspm/v1/compliance/violations/revoke
+func handleRevokeRiskAcceptance(w http.ResponseWriter, r *http.Request, config *MockServerConfig) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"message": "id is required"}`))
+		return
+	}
+
+	config.mu.Lock()
+	config.revokedRiskAcceptances = append(config.revokedRiskAcceptances, body.ID)
+	config.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`{}`))
+}
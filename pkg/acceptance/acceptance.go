@@ -0,0 +1,208 @@
+package acceptance
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+// MaxResourcesPerRequest caps the resource names in one acceptance filter
+const MaxResourcesPerRequest = 50
+
+// Reasons accepted by the Sysdig API
+var Reasons = []string{
+	"Risk Owned",
+	"Risk Transferred",
+	"Risk Avoided",
+	"Risk Mitigated",
+	"Risk Not Relevant",
+	"Custom",
+}
+
+// Target is a failed resource of a control selected for acceptance
+type Target struct {
+	ControlID    string
+	ZoneID       string
+	ResourceHash string
+	ResourceName string
+	// Account is the account ID, project ID or cluster name (sent as sourceId)
+	Account  string
+	Location string
+}
+
+// Options are shared by all acceptances created from one selection
+type Options struct {
+	Reason      string
+	Description string
+	// ExpiresAt is the expiry; zero means the acceptance never expires
+	ExpiresAt time.Time
+}
+
+// Request is one acceptance to create, covering the targets of one control, zone, account and location
+type Request struct {
+	Targets []Target
+	models.RiskAcceptanceCreateRequest
+}
+
+// Plan groups the targets into create requests.
+// Targets without a name cannot be matched by a filter and are rejected.
+func Plan(targets []Target, opts Options) ([]Request, error) {
+	if !validReason(opts.Reason) {
+		return nil, fmt.Errorf("invalid reason %q (one of: %s)", opts.Reason, strings.Join(Reasons, ", "))
+	}
+	if opts.Reason == "Custom" && strings.TrimSpace(opts.Description) == "" {
+		return nil, errors.New("a description is required for the Custom reason")
+	}
+	if !opts.ExpiresAt.IsZero() && !opts.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expiry must be in the future")
+	}
+
+	type groupKey struct {
+		controlID, zoneID, account, location string
+	}
+	groups := make(map[groupKey][]Target)
+	var keys []groupKey
+	seen := make(map[string]bool)
+
+	for _, t := range targets {
+		if t.ResourceName == "" {
+			return nil, fmt.Errorf("resource %s has no name and cannot be accepted by filter", t.ResourceHash)
+		}
+		// 同じコントロールで同じリソースを二重に登録しない
+		if seen[t.ControlID+"\x00"+t.ResourceHash] {
+			continue
+		}
+		seen[t.ControlID+"\x00"+t.ResourceHash] = true
+
+		key := groupKey{t.ControlID, t.ZoneID, t.Account, t.Location}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], t)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.controlID != b.controlID {
+			return a.controlID < b.controlID
+		}
+		if a.zoneID != b.zoneID {
+			return a.zoneID < b.zoneID
+		}
+		if a.account != b.account {
+			return a.account < b.account
+		}
+		return a.location < b.location
+	})
+
+	var requests []Request
+	for _, key := range keys {
+		controlID, err := strconv.Atoi(key.controlID)
+		if err != nil {
+			return nil, fmt.Errorf("control ID %q is not numeric", key.controlID)
+		}
+		// ゾーンIDが数値でない場合はゾーン指定なし
+		zoneID, _ := strconv.Atoi(key.zoneID)
+
+		group := groups[key]
+		sort.Slice(group, func(i, j int) bool { return group[i].ResourceName < group[j].ResourceName })
+
+		for start := 0; start < len(group); start += MaxResourcesPerRequest {
+			end := start + MaxResourcesPerRequest
+			if end > len(group) {
+				end = len(group)
+			}
+			chunk := group[start:end]
+
+			req := Request{
+				Targets: chunk,
+				RiskAcceptanceCreateRequest: models.RiskAcceptanceCreateRequest{
+					ControlID:   controlID,
+					Reason:      opts.Reason,
+					Description: opts.Description,
+					Filter:      Filter(chunk),
+					SourceID:    key.account,
+					ZoneID:      zoneID,
+				},
+			}
+			if !opts.ExpiresAt.IsZero() {
+				req.ExpiresAt = strconv.FormatInt(opts.ExpiresAt.UnixMilli(), 10)
+			}
+			requests = append(requests, req)
+		}
+	}
+
+	return requests, nil
+}
+
+// Filter builds the acceptance filter matching the targets by name (and location when all share one)
+func Filter(targets []Target) string {
+	names := make([]string, 0, len(targets))
+	for _, t := range targets {
+		names = append(names, quote(t.ResourceName))
+	}
+	filter := fmt.Sprintf("name in (%s)", strings.Join(names, ","))
+
+	if len(targets) > 0 && targets[0].Location != "" {
+		location := targets[0].Location
+		for _, t := range targets[1:] {
+			if t.Location != location {
+				return filter
+			}
+		}
+		filter = fmt.Sprintf("location in (%s) and %s", quote(location), filter)
+	}
+	return filter
+}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func validReason(reason string) bool {
+	for _, r := range Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Creator creates risk acceptances in Sysdig (implemented by client.CSPMClient)
+type Creator interface {
+	CreateRiskAcceptance(request models.RiskAcceptanceCreateRequest) (*models.RiskAcceptance, error)
+}
+
+// Result is the outcome of one create request
+type Result struct {
+	Request    Request
+	Acceptance *models.RiskAcceptance
+	Err        error
+}
+
+// Apply sends the requests in order; a failed request does not stop the remaining ones
+func Apply(c Creator, requests []Request) []Result {
+	results := make([]Result, 0, len(requests))
+	for _, req := range requests {
+		acceptance, err := c.CreateRiskAcceptance(req.RiskAcceptanceCreateRequest)
+		results = append(results, Result{Request: req, Acceptance: acceptance, Err: err})
+	}
+	return results
+}
+
+// Created returns the acceptances of the successful results
+func Created(results []Result) []models.RiskAcceptance {
+	var created []models.RiskAcceptance
+	for _, r := range results {
+		if r.Err == nil && r.Acceptance != nil {
+			created = append(created, *r.Acceptance)
+		}
+	}
+	return created
+}
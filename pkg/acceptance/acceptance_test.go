@@ -0,0 +1,124 @@
+package acceptance
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestPlan(t *testing.T) {
+	t.Run("コントロール・アカウント・ロケーションごとにまとめる", func(t *testing.T) {
+		targets := []Target{
+			{ControlID: "16022", ZoneID: "7", ResourceHash: "h2", ResourceName: "bucket-b", Account: "111", Location: "us-east-1"},
+			{ControlID: "16022", ZoneID: "7", ResourceHash: "h1", ResourceName: "bucket-a", Account: "111", Location: "us-east-1"},
+			{ControlID: "16022", ZoneID: "7", ResourceHash: "h3", ResourceName: "bucket-c", Account: "222", Location: "us-east-1"},
+			{ControlID: "16022", ZoneID: "7", ResourceHash: "h1", ResourceName: "bucket-a", Account: "111", Location: "us-east-1"},
+			{ControlID: "16031", ZoneID: "zone-x", ResourceHash: "h1", ResourceName: "bucket-a", Account: "111"},
+		}
+		expires := time.Now().Add(24 * time.Hour)
+
+		requests, err := Plan(targets, Options{Reason: "Risk Owned", Description: "tracked in JIRA-1", ExpiresAt: expires})
+		if err != nil {
+			t.Fatalf("Plan failed: %v", err)
+		}
+		if len(requests) != 3 {
+			t.Fatalf("Expected 3 requests, got %d", len(requests))
+		}
+
+		first := requests[0]
+		if first.ControlID != 16022 || first.ZoneID != 7 || first.SourceID != "111" || len(first.Targets) != 2 {
+			t.Errorf("Unexpected first request: %+v", first)
+		}
+		if want := `location in ("us-east-1") and name in ("bucket-a","bucket-b")`; first.Filter != want {
+			t.Errorf("Expected filter %s, got %s", want, first.Filter)
+		}
+		if first.ExpiresAt != fmt.Sprint(expires.UnixMilli()) || first.Description != "tracked in JIRA-1" {
+			t.Errorf("Unexpected options: %+v", first.RiskAcceptanceCreateRequest)
+		}
+
+		// 数値でないゾーンIDはゾーン指定なし、ロケーションなしは名前のみ
+		last := requests[2]
+		if last.ControlID != 16031 || last.ZoneID != 0 || last.Filter != `name in ("bucket-a")` {
+			t.Errorf("Unexpected last request: %+v", last)
+		}
+	})
+
+	t.Run("上限を超えるリソースは分割", func(t *testing.T) {
+		var targets []Target
+		for i := 0; i < MaxResourcesPerRequest+1; i++ {
+			targets = append(targets, Target{ControlID: "1", ResourceHash: fmt.Sprint(i), ResourceName: fmt.Sprintf("r-%03d", i)})
+		}
+		requests, err := Plan(targets, Options{Reason: "Risk Avoided"})
+		if err != nil {
+			t.Fatalf("Plan failed: %v", err)
+		}
+		if len(requests) != 2 || len(requests[0].Targets) != MaxResourcesPerRequest || len(requests[1].Targets) != 1 {
+			t.Errorf("Expected requests of %d and 1 resources, got %d", MaxResourcesPerRequest, len(requests))
+		}
+		if requests[0].ExpiresAt != "" {
+			t.Errorf("Expected no expiry, got %s", requests[0].ExpiresAt)
+		}
+	})
+
+	t.Run("不正な入力", func(t *testing.T) {
+		target := []Target{{ControlID: "1", ResourceHash: "h", ResourceName: "r"}}
+		cases := map[string]struct {
+			targets []Target
+			opts    Options
+		}{
+			"理由なし":          {target, Options{}},
+			"Customで説明なし":   {target, Options{Reason: "Custom"}},
+			"過去の期限":         {target, Options{Reason: "Risk Owned", ExpiresAt: time.Now().Add(-time.Hour)}},
+			"名前のないリソース":     {[]Target{{ControlID: "1", ResourceHash: "h"}}, Options{Reason: "Risk Owned"}},
+			"数値でないコントロールID": {[]Target{{ControlID: "ctrl", ResourceHash: "h", ResourceName: "r"}}, Options{Reason: "Risk Owned"}},
+		}
+		for name, c := range cases {
+			if _, err := Plan(c.targets, c.opts); err == nil {
+				t.Errorf("%s: expected error", name)
+			}
+		}
+	})
+}
+
+func TestFilter(t *testing.T) {
+	filter := Filter([]Target{
+		{ResourceName: `say "hi"`, Location: "a"},
+		{ResourceName: `back\slash`, Location: "b"},
+	})
+	// ロケーションが揃わない場合は名前のみ
+	if want := `name in ("say \"hi\"","back\\slash")`; filter != want {
+		t.Errorf("Expected %s, got %s", want, filter)
+	}
+}
+
+type fakeCreator struct {
+	fail map[string]bool
+}
+
+func (f *fakeCreator) CreateRiskAcceptance(req models.RiskAcceptanceCreateRequest) (*models.RiskAcceptance, error) {
+	if f.fail[req.Filter] {
+		return nil, errors.New("boom")
+	}
+	return &models.RiskAcceptance{ID: "ra-" + req.Filter, ControlID: fmt.Sprint(req.ControlID)}, nil
+}
+
+func TestApply(t *testing.T) {
+	requests := []Request{
+		{RiskAcceptanceCreateRequest: models.RiskAcceptanceCreateRequest{ControlID: 1, Filter: "a"}},
+		{RiskAcceptanceCreateRequest: models.RiskAcceptanceCreateRequest{ControlID: 1, Filter: "b"}},
+		{RiskAcceptanceCreateRequest: models.RiskAcceptanceCreateRequest{ControlID: 2, Filter: "c"}},
+	}
+
+	results := Apply(&fakeCreator{fail: map[string]bool{"b": true}}, requests)
+	if len(results) != 3 || results[1].Err == nil {
+		t.Fatalf("Expected the second request to fail, got %+v", results)
+	}
+
+	created := Created(results)
+	if len(created) != 2 || created[0].ID != "ra-a" || created[1].ID != "ra-c" {
+		t.Errorf("Unexpected created acceptances: %+v", created)
+	}
+}
@@ -0,0 +1,472 @@
+package acceptance
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+// NeverExpires is the expires value of a declaration that does not expire
+const NeverExpires = "never"
+
+// Declaration is a risk acceptance declared in an acceptances-as-code file
+type Declaration struct {
+	// Control is the control ID or name
+	Control string `yaml:"control"`
+	// Zone is the zone ID or name; empty means no zone
+	Zone string `yaml:"zone,omitempty"`
+	// SourceID is the account ID, project ID or cluster name
+	SourceID    string `yaml:"source_id,omitempty"`
+	Filter      string `yaml:"filter,omitempty"`
+	Reason      string `yaml:"reason"`
+	Description string `yaml:"description,omitempty"`
+	// Expires is a date (YYYY-MM-DD, UTC) or "never"
+	Expires string `yaml:"expires"`
+
+	// Origin is the file and line of the declaration
+	Origin string `yaml:"-"`
+}
+
+// codeFile is the layout of an acceptances-as-code file
+type codeFile struct {
+	Acceptances []yaml.Node `yaml:"acceptances"`
+}
+
+// LoadDeclarations reads the acceptances-as-code files; a directory loads its .yaml and .yml
+// files (not recursively) in name order
+func LoadDeclarations(paths []string) ([]Declaration, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && isYAML(e.Name()) {
+				files = append(files, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no acceptance files found")
+	}
+
+	var decls []Declaration
+	for _, file := range files {
+		d, err := readDeclarations(file)
+		if err != nil {
+			return nil, err
+		}
+		decls = append(decls, d...)
+	}
+	return decls, nil
+}
+
+func readDeclarations(path string) ([]Declaration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var file codeFile
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	decls := make([]Declaration, 0, len(file.Acceptances))
+	for _, node := range file.Acceptances {
+		var d Declaration
+		// 未知のキー（typo）はレビューで見逃しやすいのでエラーにする
+		if err := decodeStrict(&node, &d); err != nil {
+			return nil, fmt.Errorf("failed to parse %s:%d: %w", path, node.Line, err)
+		}
+		d.Origin = fmt.Sprintf("%s:%d", path, node.Line)
+		decls = append(decls, d)
+	}
+	return decls, nil
+}
+
+// decodeStrict decodes a node, rejecting unknown keys
+func decodeStrict(node *yaml.Node, v interface{}) error {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	return dec.Decode(v)
+}
+
+// Plan actions
+const (
+	CodeCreate    = "create"
+	CodeReplace   = "replace"
+	CodeRevoke    = "revoke"
+	CodeUnchanged = "unchanged"
+	CodeInvalid   = "invalid"
+)
+
+// CodeChange is one step of a plan
+type CodeChange struct {
+	Action string
+	// Declaration is nil for revocations
+	Declaration *Declaration
+	// Live is the acceptance in the tenant; nil for creations and invalid declarations
+	Live *models.RiskAcceptance
+	// Request creates the declared acceptance (create and replace)
+	Request models.RiskAcceptanceCreateRequest
+	// Diff lists the changed fields of a replacement
+	Diff []string
+	// NewID is the ID of the acceptance created by ApplyCode
+	NewID string
+	Err   error
+}
+
+// CodePlan is the difference between the declarations and the tenant
+type CodePlan struct {
+	Changes []CodeChange
+	// Unmanaged counts the live acceptances no declaration covers that are left in place
+	Unmanaged int
+}
+
+// Count returns the number of changes with the action
+func (p *CodePlan) Count(action string) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Pending reports whether applying the plan changes the tenant
+func (p *CodePlan) Pending() bool {
+	return p.Count(CodeCreate)+p.Count(CodeReplace)+p.Count(CodeRevoke) > 0
+}
+
+// CodeOptions holds what is known about the tenant
+type CodeOptions struct {
+	// Names resolve control and zone names in the declarations
+	Names Names
+	// Live are the acceptances in the tenant
+	Live []models.RiskAcceptance
+	// Prune revokes the live acceptances that no declaration covers
+	Prune bool
+	Now   time.Time
+}
+
+// PlanCode compares the declarations with the live acceptances. Declarations are matched by
+// control, zone, source and filter; a match with a different reason, description or expiry
+// date is replaced, since the API cannot update an acceptance. System acceptances are never touched.
+func PlanCode(decls []Declaration, opts CodeOptions) *CodePlan {
+	controls, controlErr := invert(opts.Names.Controls)
+	zones, zoneErr := invert(opts.Names.Zones)
+
+	live := make(map[string][]int)
+	for i, a := range opts.Live {
+		if a.IsSystem {
+			continue
+		}
+		controlID, _ := strconv.Atoi(a.ControlID)
+		zoneID, _ := strconv.Atoi(a.ZoneID)
+		key := importKey(controlID, zoneID, a.SourceID, a.Filter)
+		live[key] = append(live[key], i)
+	}
+
+	plan := &CodePlan{}
+	declared := make(map[string]string)
+	matched := make(map[int]bool)
+	for i := range decls {
+		d := &decls[i]
+		change := CodeChange{Declaration: d}
+		change.Request, change.Err = declarationRequest(*d, controls, controlErr, zones, zoneErr, opts.Now)
+		if change.Err != nil {
+			change.Action = CodeInvalid
+			plan.Changes = append(plan.Changes, change)
+			continue
+		}
+
+		key := importKey(change.Request.ControlID, change.Request.ZoneID, change.Request.SourceID, change.Request.Filter)
+		if origin, ok := declared[key]; ok {
+			change.Action, change.Err = CodeInvalid, fmt.Errorf("duplicates the declaration at %s", origin)
+			plan.Changes = append(plan.Changes, change)
+			continue
+		}
+		declared[key] = d.Origin
+
+		candidates := live[key]
+		if len(candidates) == 0 {
+			change.Action = CodeCreate
+			plan.Changes = append(plan.Changes, change)
+			continue
+		}
+		// 同じ対象の受容が複数ある場合は先頭を管理対象とし、残りは管理外として扱う
+		matched[candidates[0]] = true
+		a := opts.Live[candidates[0]]
+		change.Live = &a
+		change.Diff = diffAcceptance(a, change.Request)
+		if len(change.Diff) == 0 {
+			change.Action = CodeUnchanged
+		} else {
+			change.Action = CodeReplace
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	var revoke []models.RiskAcceptance
+	for i, a := range opts.Live {
+		if a.IsSystem || matched[i] {
+			continue
+		}
+		if !opts.Prune {
+			plan.Unmanaged++
+			continue
+		}
+		revoke = append(revoke, a)
+	}
+	sort.Slice(revoke, func(i, j int) bool { return revoke[i].ID < revoke[j].ID })
+	for i := range revoke {
+		plan.Changes = append(plan.Changes, CodeChange{Action: CodeRevoke, Live: &revoke[i]})
+	}
+	return plan
+}
+
+func declarationRequest(d Declaration, controls map[string]string, controlErr map[string]bool,
+	zones map[string]string, zoneErr map[string]bool, now time.Time) (models.RiskAcceptanceCreateRequest, error) {
+	if strings.TrimSpace(d.Control) == "" {
+		return models.RiskAcceptanceCreateRequest{}, errors.New("control is required")
+	}
+	controlID, err := resolveName("control", d.Control, controls, controlErr)
+	if err != nil {
+		return models.RiskAcceptanceCreateRequest{}, err
+	}
+	zoneID := 0
+	if d.Zone != "" {
+		if zoneID, err = resolveName("zone", d.Zone, zones, zoneErr); err != nil {
+			return models.RiskAcceptanceCreateRequest{}, err
+		}
+	}
+
+	if !validReason(d.Reason) {
+		return models.RiskAcceptanceCreateRequest{}, fmt.Errorf("invalid reason %q (one of: %s)", d.Reason, strings.Join(Reasons, ", "))
+	}
+	if d.Reason == "Custom" && strings.TrimSpace(d.Description) == "" {
+		return models.RiskAcceptanceCreateRequest{}, errors.New("a description is required for the Custom reason")
+	}
+
+	req := models.RiskAcceptanceCreateRequest{
+		ControlID:   controlID,
+		Reason:      d.Reason,
+		Description: d.Description,
+		Filter:      d.Filter,
+		SourceID:    d.SourceID,
+		ZoneID:      zoneID,
+	}
+	// 有効期限は明示させる（書き忘れで無期限の受容が作られないように）
+	switch expires := strings.TrimSpace(d.Expires); expires {
+	case "":
+		return models.RiskAcceptanceCreateRequest{}, fmt.Errorf("expires is required (a date or %q)", NeverExpires)
+	case NeverExpires:
+	default:
+		t, err := time.Parse("2006-01-02", expires)
+		if err != nil {
+			return models.RiskAcceptanceCreateRequest{}, fmt.Errorf("invalid expires %q (YYYY-MM-DD or %q)", expires, NeverExpires)
+		}
+		if !t.After(now) {
+			return models.RiskAcceptanceCreateRequest{}, fmt.Errorf("expires %s is in the past", expires)
+		}
+		req.ExpiresAt = strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return req, nil
+}
+
+// resolveName returns a numeric ID as is and looks up anything else as a name
+func resolveName(kind, value string, byName map[string]string, ambiguous map[string]bool) (int, error) {
+	if id, err := strconv.Atoi(value); err == nil {
+		return id, nil
+	}
+	if len(byName) == 0 {
+		return 0, fmt.Errorf("%s %q is a name, but no %s names are collected (run collect first or use the ID)", kind, value, kind)
+	}
+	if ambiguous[value] {
+		return 0, fmt.Errorf("%s name %q matches several %ss; use the ID", kind, value, kind)
+	}
+	id, ok := byName[value]
+	if !ok {
+		return 0, fmt.Errorf("%s %q not found", kind, value)
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("%s ID %q is not numeric", kind, id)
+	}
+	return n, nil
+}
+
+// diffAcceptance lists the fields of the live acceptance that differ from the request.
+// Expiries are compared by UTC date, the precision of the declarations.
+func diffAcceptance(a models.RiskAcceptance, req models.RiskAcceptanceCreateRequest) []string {
+	var diff []string
+	if a.Reason != req.Reason {
+		diff = append(diff, fmt.Sprintf("reason: %q → %q", a.Reason, req.Reason))
+	}
+	if a.Description != req.Description {
+		diff = append(diff, fmt.Sprintf("description: %q → %q", a.Description, req.Description))
+	}
+	liveExpiry := NeverExpires
+	if t, ok, err := ExpiryOf(a); err == nil && ok {
+		liveExpiry = t.Format("2006-01-02")
+	}
+	wantExpiry := NeverExpires
+	if t, ok, err := ParseTimestamp(req.ExpiresAt); err == nil && ok {
+		wantExpiry = t.Format("2006-01-02")
+	}
+	if liveExpiry != wantExpiry {
+		diff = append(diff, fmt.Sprintf("expires: %s → %s", liveExpiry, wantExpiry))
+	}
+	return diff
+}
+
+// ApplyCode executes the plan. A replacement creates the new acceptance before revoking the
+// old one, so the resources stay accepted if anything fails. A failure does not stop the
+// remaining changes; it is recorded in the change.
+func ApplyCode(c Client, plan *CodePlan) {
+	for i := range plan.Changes {
+		change := &plan.Changes[i]
+		switch change.Action {
+		case CodeCreate, CodeReplace:
+			created, err := c.CreateRiskAcceptance(change.Request)
+			if err != nil {
+				change.Err = fmt.Errorf("failed to create risk acceptance: %w", err)
+				continue
+			}
+			change.NewID = created.ID
+			if change.Action == CodeReplace {
+				if err := c.DeleteRiskAcceptance(change.Live.ID); err != nil {
+					change.Err = fmt.Errorf("created %s, but failed to revoke risk acceptance %s: %w", created.ID, change.Live.ID, err)
+				}
+			}
+		case CodeRevoke:
+			if err := c.DeleteRiskAcceptance(change.Live.ID); err != nil {
+				change.Err = fmt.Errorf("failed to revoke risk acceptance %s: %w", change.Live.ID, err)
+			}
+		}
+	}
+}
+
+// Failed counts the changes that failed to apply
+func (p *CodePlan) Failed() int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Err != nil && c.Action != CodeInvalid {
+			n++
+		}
+	}
+	return n
+}
+
+// WritePlan prints the changes and a summary; unchanged declarations are only counted
+func (p *CodePlan) WritePlan(w io.Writer, names Names) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range p.Changes {
+		switch c.Action {
+		case CodeUnchanged:
+			continue
+		case CodeInvalid:
+			fmt.Fprintf(tw, "!\t%s\t%s\t%v\n", c.Action, c.Declaration.Origin, c.Err)
+			continue
+		}
+
+		target := ""
+		if c.Action == CodeRevoke {
+			target = describeTarget(c.Live.ControlID, c.Live.ZoneID, c.Live.SourceID, c.Live.Filter, names)
+		} else {
+			target = describeTarget(strconv.Itoa(c.Request.ControlID), strconv.Itoa(c.Request.ZoneID), c.Request.SourceID, c.Request.Filter, names)
+		}
+		detail := ""
+		switch c.Action {
+		case CodeCreate:
+			detail = c.Declaration.Origin
+		case CodeReplace:
+			detail = c.Live.ID + ": " + strings.Join(c.Diff, "; ")
+		case CodeRevoke:
+			detail = c.Live.ID + ": not declared"
+		}
+		status := ""
+		switch {
+		case c.Err != nil:
+			status = fmt.Sprintf(" (failed: %v)", c.Err)
+		case c.NewID != "":
+			status = " (created " + c.NewID + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s%s\n", codeSymbol(c.Action), c.Action, target, detail, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf("Plan: %d to create, %d to replace, %d to revoke, %d unchanged",
+		p.Count(CodeCreate), p.Count(CodeReplace), p.Count(CodeRevoke), p.Count(CodeUnchanged))
+	if n := p.Count(CodeInvalid); n > 0 {
+		summary += fmt.Sprintf(", %d invalid", n)
+	}
+	if p.Unmanaged > 0 {
+		summary += fmt.Sprintf(" (%d unmanaged acceptances ignored; -prune revokes them)", p.Unmanaged)
+	}
+	_, err := fmt.Fprintln(w, summary)
+	return err
+}
+
+func codeSymbol(action string) string {
+	switch action {
+	case CodeCreate:
+		return "+"
+	case CodeReplace:
+		return "~"
+	case CodeRevoke:
+		return "-"
+	}
+	return " "
+}
+
+func describeTarget(controlID, zoneID, sourceID, filterExpr string, names Names) string {
+	s := "control " + controlID
+	if name := names.Controls[controlID]; name != "" {
+		s += " (" + truncate(name, 40) + ")"
+	}
+	if zoneID != "" && zoneID != "0" {
+		s += " zone " + zoneID
+		if name := names.Zones[zoneID]; name != "" {
+			s += " (" + name + ")"
+		}
+	}
+	if sourceID != "" {
+		s += " source " + sourceID
+	}
+	if filterExpr != "" {
+		s += " [" + truncate(filterExpr, 60) + "]"
+	}
+	return s
+}
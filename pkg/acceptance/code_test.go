@@ -0,0 +1,149 @@
+package acceptance
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestLoadDeclarations(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("b.yaml", `acceptances:
+  - control: Bucket ACL
+    zone: Prod
+    filter: name in ("bucket-a")
+    reason: Risk Owned
+    expires: 2027-01-31
+`)
+	write("a.yml", `acceptances:
+  - control: "16031"
+    reason: Risk Avoided
+    expires: never
+`)
+	write("notes.txt", "not an acceptance file")
+
+	decls, err := LoadDeclarations([]string{dir})
+	if err != nil {
+		t.Fatalf("LoadDeclarations failed: %v", err)
+	}
+	if len(decls) != 2 || decls[0].Control != "16031" || decls[1].Zone != "Prod" || decls[1].Expires != "2027-01-31" {
+		t.Fatalf("Unexpected declarations: %+v", decls)
+	}
+	if want := filepath.Join(dir, "b.yaml") + ":2"; decls[1].Origin != want {
+		t.Errorf("Expected origin %s, got %s", want, decls[1].Origin)
+	}
+
+	t.Run("未知のキー", func(t *testing.T) {
+		write("typo.yaml", "acceptances:\n  - control: \"1\"\n    reasn: Risk Owned\n")
+		if _, err := LoadDeclarations([]string{filepath.Join(dir, "typo.yaml")}); err == nil || !strings.Contains(err.Error(), "typo.yaml:2") {
+			t.Errorf("Expected an error naming the declaration, got %v", err)
+		}
+	})
+}
+
+func TestPlanCode(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	expiry := time.Date(2027, 1, 31, 0, 0, 0, 0, time.UTC)
+	ms := func(t time.Time) string { return fmt.Sprint(t.UnixMilli()) }
+
+	live := []models.RiskAcceptance{
+		{ID: "same", ControlID: "16022", ZoneID: "7", Filter: `name in ("bucket-a")`, Reason: "Risk Owned", ExpiresAt: ms(expiry.Add(5 * time.Hour))},
+		{ID: "changed", ControlID: "16022", ZoneID: "7", Filter: `name in ("bucket-b")`, Reason: "Risk Owned", ExpiresAt: ms(expiry)},
+		{ID: "unmanaged", ControlID: "16040", Reason: "Risk Owned", ExpiresAt: "0", AcceptPeriod: "Never"},
+		{ID: "sys", ControlID: "16050", IsSystem: true},
+	}
+	decls := []Declaration{
+		{Control: "Bucket ACL", Zone: "Prod", Filter: `name  in ( "bucket-a" )`, Reason: "Risk Owned", Expires: "2027-01-31", Origin: "a.yaml:2"},
+		{Control: "16022", Zone: "7", Filter: `name in ("bucket-b")`, Reason: "Risk Transferred", Expires: "never", Origin: "a.yaml:8"},
+		{Control: "16031", Reason: "Risk Avoided", Expires: "2027-01-31", Origin: "a.yaml:14"},
+		{Control: "Missing", Reason: "Risk Owned", Expires: "never", Origin: "a.yaml:20"},
+		{Control: "16031", Reason: "Risk Avoided", Expires: "2026-01-01", Origin: "a.yaml:24"},
+		{Control: "16031", Reason: "Risk Owned", Expires: "2027-01-31", Origin: "a.yaml:28"},
+	}
+	opts := CodeOptions{
+		Names: Names{Controls: map[string]string{"16022": "Bucket ACL"}, Zones: map[string]string{"7": "Prod"}},
+		Live:  live,
+		Now:   now,
+	}
+
+	plan := PlanCode(decls, opts)
+	want := []string{CodeUnchanged, CodeReplace, CodeCreate, CodeInvalid, CodeInvalid, CodeInvalid}
+	if len(plan.Changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %+v", len(want), plan.Changes)
+	}
+	for i, action := range want {
+		if plan.Changes[i].Action != action {
+			t.Errorf("Change %d: expected %s, got %s (%v)", i, action, plan.Changes[i].Action, plan.Changes[i].Err)
+		}
+	}
+	if c := plan.Changes[1]; c.Live.ID != "changed" || strings.Join(c.Diff, "; ") != `reason: "Risk Owned" → "Risk Transferred"; expires: 2027-01-31 → never` {
+		t.Errorf("Unexpected replacement: %+v", c)
+	}
+	if c := plan.Changes[5]; !strings.Contains(c.Err.Error(), "a.yaml:14") {
+		t.Errorf("Expected the duplicate to name the first declaration, got %v", c.Err)
+	}
+	if plan.Unmanaged != 1 || !plan.Pending() {
+		t.Errorf("Expected 1 unmanaged acceptance and pending changes, got %+v", plan)
+	}
+
+	t.Run("prune", func(t *testing.T) {
+		opts.Prune = true
+		plan := PlanCode(decls[:3], opts)
+		last := plan.Changes[len(plan.Changes)-1]
+		// システムの受容は取り消さない
+		if plan.Count(CodeRevoke) != 1 || last.Live.ID != "unmanaged" || plan.Unmanaged != 0 {
+			t.Errorf("Expected only the unmanaged acceptance to be revoked, got %+v", plan.Changes)
+		}
+	})
+
+	t.Run("名前の解決にはDBが必要", func(t *testing.T) {
+		plan := PlanCode(decls[:1], CodeOptions{Live: live, Now: now})
+		if c := plan.Changes[0]; c.Action != CodeInvalid || !strings.Contains(c.Err.Error(), "no control names") {
+			t.Errorf("Expected the name to be rejected without names, got %+v", c)
+		}
+	})
+}
+
+func TestApplyCode(t *testing.T) {
+	plan := &CodePlan{Changes: []CodeChange{
+		{Action: CodeCreate, Request: models.RiskAcceptanceCreateRequest{ControlID: 1, Filter: "a"}, Declaration: &Declaration{Origin: "a.yaml:2"}},
+		{Action: CodeReplace, Request: models.RiskAcceptanceCreateRequest{ControlID: 1, Filter: "b"}, Live: &models.RiskAcceptance{ID: "old-b"}},
+		{Action: CodeReplace, Request: models.RiskAcceptanceCreateRequest{ControlID: 1, Filter: "c"}, Live: &models.RiskAcceptance{ID: "old-c"}},
+		{Action: CodeRevoke, Live: &models.RiskAcceptance{ID: "old-d"}},
+		{Action: CodeUnchanged, Live: &models.RiskAcceptance{ID: "same"}},
+	}}
+	c := &fakeClient{fakeCreator: fakeCreator{fail: map[string]bool{"c": true}}}
+
+	ApplyCode(c, plan)
+	if plan.Changes[0].NewID != "ra-a" || plan.Changes[1].NewID != "ra-b" {
+		t.Errorf("Expected the created IDs, got %+v", plan.Changes)
+	}
+	// 再作成に失敗した受容は取り消さない
+	if plan.Changes[2].Err == nil || plan.Failed() != 1 {
+		t.Errorf("Expected only the third change to fail, got %+v", plan.Changes)
+	}
+	if strings.Join(c.revoked, ",") != "old-b,old-d" {
+		t.Errorf("Expected old-b and old-d to be revoked, got %v", c.revoked)
+	}
+
+	var out bytes.Buffer
+	if err := plan.WritePlan(&out, Names{Controls: map[string]string{"1": "Bucket ACL"}}); err != nil {
+		t.Fatalf("WritePlan failed: %v", err)
+	}
+	for _, want := range []string{"+  create", "(Bucket ACL)", "created ra-b", "failed:", "Plan: 1 to create, 2 to replace, 1 to revoke, 1 unchanged"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in output:\n%s", want, out.String())
+		}
+	}
+}
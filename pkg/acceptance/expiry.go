@@ -0,0 +1,290 @@
+package acceptance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+// Expiry statuses
+const (
+	StatusExpired  = "expired"
+	StatusExpiring = "expiring"
+)
+
+// ParseTimestamp parses the timestamps of the Sysdig API: Unix milliseconds as a string
+// (seconds are accepted too), RFC 3339 or a date. "0" and "" mean no timestamp.
+func ParseTimestamp(s string) (time.Time, bool, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return time.Time{}, false, nil
+	}
+
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		// 1e11ミリ秒は1973年なので、それ未満は秒として扱う
+		if n < 1e11 {
+			return time.Unix(n, 0).UTC(), true, nil
+		}
+		return time.UnixMilli(n).UTC(), true, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid timestamp %q", s)
+}
+
+// ExpiryOf returns when the acceptance expires; false means it never expires.
+// Without expiresAt the expiry is derived from acceptanceDate and a numeric acceptPeriod (days).
+func ExpiryOf(a models.RiskAcceptance) (time.Time, bool, error) {
+	expires, ok, err := ParseTimestamp(a.ExpiresAt)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("risk acceptance %s: expiresAt: %w", a.ID, err)
+	}
+	if ok {
+		return expires, true, nil
+	}
+
+	days, err := strconv.Atoi(strings.TrimSpace(a.AcceptPeriod))
+	if err != nil || days <= 0 {
+		// "Never" など
+		return time.Time{}, false, nil
+	}
+	accepted, ok, err := ParseTimestamp(a.AcceptanceDate)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("risk acceptance %s: acceptanceDate: %w", a.ID, err)
+	}
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	return accepted.AddDate(0, 0, days), true, nil
+}
+
+// ParseWindow parses a window such as "30d", "2w" or any time.ParseDuration value ("36h")
+func ParseWindow(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			days, err := strconv.Atoi(n)
+			if err != nil || days < 0 {
+				return 0, fmt.Errorf("invalid window %q", s)
+			}
+			return time.Duration(days) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid window %q (e.g. 30d, 2w, 36h)", s)
+	}
+	return d, nil
+}
+
+// Expiring is an acceptance that has expired or expires within the window
+type Expiring struct {
+	Acceptance models.RiskAcceptance
+	// ExpiresAt is zero for acceptances flagged as expired by Sysdig without an expiry date
+	ExpiresAt time.Time
+	Status    string
+	// DaysLeft is negative for expired acceptances
+	DaysLeft int
+}
+
+// ExpiryGroup holds the expiring acceptances of one control and owner
+type ExpiryGroup struct {
+	ControlID string
+	Owner     string
+	Items     []Expiring
+}
+
+// ExpiryReport is the result of FindExpiring
+type ExpiryReport struct {
+	Now      time.Time
+	Within   time.Duration
+	Expired  int
+	Expiring int
+	Groups   []ExpiryGroup
+	// Invalid lists the acceptances whose dates could not be parsed
+	Invalid []error
+}
+
+// FindExpiring returns the acceptances that have expired or expire before now+within,
+// grouped by control and owner (username) and sorted by expiry
+func FindExpiring(acceptances []models.RiskAcceptance, now time.Time, within time.Duration) *ExpiryReport {
+	report := &ExpiryReport{Now: now, Within: within}
+	deadline := now.Add(within)
+
+	type groupKey struct{ control, owner string }
+	groups := make(map[groupKey]*ExpiryGroup)
+	var keys []groupKey
+
+	for _, a := range acceptances {
+		expires, ok, err := ExpiryOf(a)
+		if err != nil {
+			report.Invalid = append(report.Invalid, err)
+			if !a.IsExpired {
+				continue
+			}
+		}
+
+		var item Expiring
+		switch {
+		case a.IsExpired || (ok && !expires.After(now)):
+			item = Expiring{Acceptance: a, Status: StatusExpired}
+			report.Expired++
+		case ok && !expires.After(deadline):
+			item = Expiring{Acceptance: a, Status: StatusExpiring}
+			report.Expiring++
+		default:
+			continue
+		}
+		if ok {
+			item.ExpiresAt = expires
+			item.DaysLeft = daysBetween(now, expires)
+		}
+
+		key := groupKey{a.ControlID, a.Username}
+		g, exists := groups[key]
+		if !exists {
+			g = &ExpiryGroup{ControlID: a.ControlID, Owner: a.Username}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.Items = append(g.Items, item)
+	}
+
+	for _, key := range keys {
+		g := groups[key]
+		sort.SliceStable(g.Items, func(i, j int) bool { return g.Items[i].ExpiresAt.Before(g.Items[j].ExpiresAt) })
+		report.Groups = append(report.Groups, *g)
+	}
+	// 期限の近いグループから表示する
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if !a.Items[0].ExpiresAt.Equal(b.Items[0].ExpiresAt) {
+			return a.Items[0].ExpiresAt.Before(b.Items[0].ExpiresAt)
+		}
+		if a.ControlID != b.ControlID {
+			return a.ControlID < b.ControlID
+		}
+		return a.Owner < b.Owner
+	})
+
+	return report
+}
+
+// daysBetween returns the whole days from now until t, rounded towards now
+func daysBetween(now, t time.Time) int {
+	return int(t.Sub(now) / (24 * time.Hour))
+}
+
+// expiryJSON is the JSON form of an ExpiryReport
+type expiryJSON struct {
+	GeneratedAt time.Time         `json:"generated_at"`
+	Deadline    time.Time         `json:"deadline"`
+	Expired     int               `json:"expired"`
+	Expiring    int               `json:"expiring"`
+	Groups      []expiryGroupJSON `json:"groups"`
+}
+
+type expiryGroupJSON struct {
+	ControlID   string         `json:"control_id"`
+	Owner       string         `json:"owner"`
+	Acceptances []expiringJSON `json:"acceptances"`
+}
+
+type expiringJSON struct {
+	ID           string     `json:"id"`
+	Status       string     `json:"status"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	DaysLeft     *int       `json:"days_left"`
+	Reason       string     `json:"reason"`
+	AcceptPeriod string     `json:"accept_period"`
+	Description  string     `json:"description"`
+	Filter       string     `json:"filter"`
+	SourceID     string     `json:"source_id"`
+	ZoneID       string     `json:"zone_id"`
+}
+
+// WriteJSON writes the report as JSON
+func (r *ExpiryReport) WriteJSON(w io.Writer) error {
+	out := expiryJSON{
+		GeneratedAt: r.Now.UTC(),
+		Deadline:    r.Now.Add(r.Within).UTC(),
+		Expired:     r.Expired,
+		Expiring:    r.Expiring,
+		Groups:      []expiryGroupJSON{},
+	}
+	for _, g := range r.Groups {
+		group := expiryGroupJSON{ControlID: g.ControlID, Owner: g.Owner}
+		for _, item := range g.Items {
+			a := item.Acceptance
+			e := expiringJSON{
+				ID:           a.ID,
+				Status:       item.Status,
+				Reason:       a.Reason,
+				AcceptPeriod: a.AcceptPeriod,
+				Description:  a.Description,
+				Filter:       a.Filter,
+				SourceID:     a.SourceID,
+				ZoneID:       a.ZoneID,
+			}
+			if !item.ExpiresAt.IsZero() {
+				expires, days := item.ExpiresAt, item.DaysLeft
+				e.ExpiresAt, e.DaysLeft = &expires, &days
+			}
+			group.Acceptances = append(group.Acceptances, e)
+		}
+		out.Groups = append(out.Groups, group)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WriteTable writes the report grouped by control and owner
+func (r *ExpiryReport) WriteTable(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Risk acceptances expired or expiring before %s\n\n", r.Now.Add(r.Within).Format("2006-01-02 15:04 MST"))
+
+	if len(r.Groups) == 0 {
+		b.WriteString("No risk acceptances expired or expiring\n")
+	}
+	for _, g := range r.Groups {
+		owner := g.Owner
+		if owner == "" {
+			owner = "(unknown owner)"
+		}
+		fmt.Fprintf(&b, "Control %s / %s\n", g.ControlID, owner)
+		fmt.Fprintf(&b, "  %-26s %-9s %-12s %6s  %-20s %s\n", "ID", "STATUS", "EXPIRES", "DAYS", "REASON", "FILTER")
+		for _, item := range g.Items {
+			expires, days := "-", "-"
+			if !item.ExpiresAt.IsZero() {
+				expires = item.ExpiresAt.Format("2006-01-02")
+				days = strconv.Itoa(item.DaysLeft)
+			}
+			fmt.Fprintf(&b, "  %-26s %-9s %-12s %6s  %-20s %s\n",
+				item.Acceptance.ID, item.Status, expires, days, truncate(item.Acceptance.Reason, 20), truncate(item.Acceptance.Filter, 60))
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "Expired: %d, expiring: %d\n", r.Expired, r.Expiring)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
@@ -0,0 +1,133 @@
+package acceptance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestExpiryOf(t *testing.T) {
+	at := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	ms := fmt.Sprint(at.UnixMilli())
+
+	cases := []struct {
+		name string
+		a    models.RiskAcceptance
+		want time.Time
+		ok   bool
+	}{
+		{"ミリ秒", models.RiskAcceptance{ExpiresAt: ms}, at, true},
+		{"秒", models.RiskAcceptance{ExpiresAt: fmt.Sprint(at.Unix())}, at, true},
+		{"RFC3339", models.RiskAcceptance{ExpiresAt: "2026-10-01T00:00:00Z"}, at, true},
+		{"無期限", models.RiskAcceptance{ExpiresAt: "0", AcceptPeriod: "Never"}, time.Time{}, false},
+		{"受容期間から算出", models.RiskAcceptance{AcceptanceDate: fmt.Sprint(at.AddDate(0, 0, -30).UnixMilli()), AcceptPeriod: "30"}, at, true},
+	}
+	for _, c := range cases {
+		got, ok, err := ExpiryOf(c.a)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if ok != c.ok || !got.Equal(c.want) {
+			t.Errorf("%s: expected %v/%v, got %v/%v", c.name, c.want, c.ok, got, ok)
+		}
+	}
+
+	if _, _, err := ExpiryOf(models.RiskAcceptance{ID: "x", ExpiresAt: "next week"}); err == nil {
+		t.Error("Expected error for invalid expiresAt")
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	for s, want := range map[string]time.Duration{"30d": 30 * 24 * time.Hour, "2w": 14 * 24 * time.Hour, "36h": 36 * time.Hour, "0d": 0} {
+		got, err := ParseWindow(s)
+		if err != nil || got != want {
+			t.Errorf("%s: expected %s, got %s (%v)", s, want, got, err)
+		}
+	}
+	for _, s := range []string{"", "d", "-1d", "soon"} {
+		if _, err := ParseWindow(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestFindExpiring(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	ms := func(d time.Duration) string { return fmt.Sprint(now.Add(d).UnixMilli()) }
+	day := 24 * time.Hour
+
+	acceptances := []models.RiskAcceptance{
+		{ID: "expired", ControlID: "16022", Username: "alice@example.com", ExpiresAt: ms(-2 * day)},
+		{ID: "soon", ControlID: "16022", Username: "alice@example.com", ExpiresAt: ms(10 * day)},
+		{ID: "later", ControlID: "16022", Username: "alice@example.com", ExpiresAt: ms(60 * day)},
+		{ID: "never", ControlID: "16022", Username: "alice@example.com", ExpiresAt: "0", AcceptPeriod: "Never"},
+		{ID: "flagged", ControlID: "16031", Username: "bob@example.com", IsExpired: true},
+		{ID: "other-owner", ControlID: "16022", Username: "bob@example.com", ExpiresAt: ms(5 * day)},
+		{ID: "broken", ControlID: "16040", ExpiresAt: "tomorrow"},
+	}
+
+	report := FindExpiring(acceptances, now, 30*day)
+	if report.Expired != 2 || report.Expiring != 2 {
+		t.Errorf("Expected 2 expired and 2 expiring, got %d/%d", report.Expired, report.Expiring)
+	}
+	if len(report.Invalid) != 1 {
+		t.Errorf("Expected 1 invalid acceptance, got %v", report.Invalid)
+	}
+
+	var groups []string
+	for _, g := range report.Groups {
+		var ids []string
+		for _, item := range g.Items {
+			ids = append(ids, item.Acceptance.ID)
+		}
+		groups = append(groups, g.ControlID+"/"+g.Owner+":"+strings.Join(ids, ","))
+	}
+	// 期限不明の期限切れが先頭、以降は期限の近い順
+	want := "16031/bob@example.com:flagged 16022/alice@example.com:expired,soon 16022/bob@example.com:other-owner"
+	if got := strings.Join(groups, " "); got != want {
+		t.Errorf("Expected groups %s, got %s", want, got)
+	}
+	if item := report.Groups[1].Items[0]; item.Status != StatusExpired || item.DaysLeft != -2 {
+		t.Errorf("Unexpected expired item: %+v", item)
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := report.WriteJSON(&buf); err != nil {
+			t.Fatalf("WriteJSON failed: %v", err)
+		}
+		var out struct {
+			Expired int `json:"expired"`
+			Groups  []struct {
+				Acceptances []struct {
+					ID       string `json:"id"`
+					DaysLeft *int   `json:"days_left"`
+				} `json:"acceptances"`
+			} `json:"groups"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatalf("Invalid JSON: %v", err)
+		}
+		if out.Expired != 2 || len(out.Groups) != 3 || out.Groups[0].Acceptances[0].DaysLeft != nil {
+			t.Errorf("Unexpected JSON: %s", buf.String())
+		}
+	})
+
+	t.Run("表", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := report.WriteTable(&buf); err != nil {
+			t.Fatalf("WriteTable failed: %v", err)
+		}
+		for _, want := range []string{"Control 16022 / alice@example.com", "soon", "expiring", "Expired: 2, expiring: 2"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("Expected %q in:\n%s", want, buf.String())
+			}
+		}
+	})
+}
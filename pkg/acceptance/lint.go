@@ -0,0 +1,244 @@
+package acceptance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+// Lint rule names
+const (
+	RuleReasonRequired      = "reason-required"
+	RuleDescriptionRequired = "description-required"
+	RuleTicketID            = "ticket-id"
+	RuleMaxAcceptPeriod     = "max-accept-period"
+	RuleNeverExpiry         = "never-expiry"
+	RuleSystemUser          = "system-user"
+	RuleUnknownControl      = "unknown-control"
+)
+
+// LintRules configures risk-lint (the risk_lint section of the config file).
+// Zero values disable a rule.
+type LintRules struct {
+	RequireReason      bool `json:"require_reason"`
+	RequireDescription bool `json:"require_description"`
+	// TicketPattern is a regular expression the description must match, e.g. "[A-Z]+-[0-9]+"
+	TicketPattern string `json:"ticket_pattern,omitempty"`
+	// MaxAcceptDays limits the period of acceptances that expire; "Never" is covered by NoNeverSeverities
+	MaxAcceptDays int `json:"max_accept_days,omitempty"`
+	// NoNeverSeverities lists control severities whose acceptances must expire, e.g. ["High"]
+	NoNeverSeverities []string `json:"no_never_severities,omitempty"`
+	// FlagSystem reports acceptances created by system users
+	FlagSystem bool `json:"flag_system"`
+	// FlagUnknownControls reports acceptances of controls missing from the collected controls
+	FlagUnknownControls bool `json:"flag_unknown_controls"`
+}
+
+// DefaultLintRules returns the rules used when the config file has no risk_lint section
+func DefaultLintRules() LintRules {
+	return LintRules{
+		RequireReason:       true,
+		RequireDescription:  true,
+		MaxAcceptDays:       365,
+		NoNeverSeverities:   []string{"High"},
+		FlagSystem:          true,
+		FlagUnknownControls: true,
+	}
+}
+
+// Finding is one rule violated by an acceptance
+type Finding struct {
+	Acceptance models.RiskAcceptance
+	Rule       string
+	Message    string
+}
+
+// LintReport is the result of Lint
+type LintReport struct {
+	Checked  int
+	Findings []Finding
+	// Skipped lists the rules that could not be checked, with the reason
+	Skipped []string
+}
+
+// ByRule returns the number of findings per rule
+func (r *LintReport) ByRule() map[string]int {
+	counts := make(map[string]int)
+	for _, f := range r.Findings {
+		counts[f.Rule]++
+	}
+	return counts
+}
+
+// Lint checks the acceptances against the rules. controls maps the collected control IDs to
+// their severity; with no collected controls the rules that need them are skipped.
+func Lint(acceptances []models.RiskAcceptance, controls map[string]string, rules LintRules) (*LintReport, error) {
+	var ticket *regexp.Regexp
+	if rules.TicketPattern != "" {
+		var err error
+		if ticket, err = regexp.Compile(rules.TicketPattern); err != nil {
+			return nil, fmt.Errorf("invalid ticket_pattern: %w", err)
+		}
+	}
+
+	report := &LintReport{Checked: len(acceptances)}
+	haveControls := len(controls) > 0
+	if !haveControls {
+		if rules.FlagUnknownControls {
+			report.Skipped = append(report.Skipped, RuleUnknownControl+": no collected controls")
+		}
+		if len(rules.NoNeverSeverities) > 0 {
+			report.Skipped = append(report.Skipped, RuleNeverExpiry+": no collected controls")
+		}
+	}
+
+	for _, a := range acceptances {
+		add := func(rule, format string, args ...interface{}) {
+			report.Findings = append(report.Findings, Finding{Acceptance: a, Rule: rule, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if rules.RequireReason && strings.TrimSpace(a.Reason) == "" {
+			add(RuleReasonRequired, "no reason")
+		}
+		description := strings.TrimSpace(a.Description)
+		if rules.RequireDescription && description == "" {
+			add(RuleDescriptionRequired, "no description")
+		}
+		if ticket != nil && description != "" && !ticket.MatchString(description) {
+			add(RuleTicketID, "description has no ticket ID matching %s", rules.TicketPattern)
+		}
+
+		days, finite, err := acceptDays(a)
+		if err != nil {
+			add(RuleMaxAcceptPeriod, "%v", err)
+		} else if finite && rules.MaxAcceptDays > 0 && days > rules.MaxAcceptDays {
+			add(RuleMaxAcceptPeriod, "accepted for %d days (max %d)", days, rules.MaxAcceptDays)
+		}
+
+		severity, known := controls[a.ControlID]
+		if err == nil && !finite && known && containsFold(rules.NoNeverSeverities, severity) {
+			add(RuleNeverExpiry, "%s severity control accepted without expiry", severity)
+		}
+		if rules.FlagSystem && a.IsSystem {
+			add(RuleSystemUser, "created by a system user")
+		}
+		if rules.FlagUnknownControls && haveControls && !known {
+			add(RuleUnknownControl, "control %s is not in the collected controls", a.ControlID)
+		}
+	}
+
+	return report, nil
+}
+
+// acceptDays returns the accept period in days; false means the acceptance never expires
+func acceptDays(a models.RiskAcceptance) (int, bool, error) {
+	if days, err := strconv.Atoi(strings.TrimSpace(a.AcceptPeriod)); err == nil && days > 0 {
+		return days, true, nil
+	}
+
+	expires, ok, err := ExpiryOf(a)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	accepted, ok, err := ParseTimestamp(a.AcceptanceDate)
+	if err != nil {
+		return 0, false, fmt.Errorf("risk acceptance %s: acceptanceDate: %w", a.ID, err)
+	}
+	if !ok {
+		// 受容日が不明な場合は期間を判定できないが、期限はある
+		return 0, true, nil
+	}
+	// 端数の日は切り上げる
+	return int((expires.Sub(accepted) + 24*time.Hour - 1) / (24 * time.Hour)), true, nil
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// lintJSON is the JSON form of a LintReport
+type lintJSON struct {
+	Checked  int            `json:"checked"`
+	Findings []findingJSON  `json:"findings"`
+	ByRule   map[string]int `json:"by_rule"`
+	Skipped  []string       `json:"skipped_rules"`
+}
+
+type findingJSON struct {
+	ID        string `json:"id"`
+	ControlID string `json:"control_id"`
+	Username  string `json:"username"`
+	Rule      string `json:"rule"`
+	Message   string `json:"message"`
+}
+
+// WriteJSON writes the report as JSON
+func (r *LintReport) WriteJSON(w io.Writer) error {
+	out := lintJSON{
+		Checked:  r.Checked,
+		Findings: []findingJSON{},
+		ByRule:   r.ByRule(),
+		Skipped:  append([]string{}, r.Skipped...),
+	}
+	for _, f := range r.Findings {
+		out.Findings = append(out.Findings, findingJSON{
+			ID:        f.Acceptance.ID,
+			ControlID: f.Acceptance.ControlID,
+			Username:  f.Acceptance.Username,
+			Rule:      f.Rule,
+			Message:   f.Message,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WriteText writes the findings as a table followed by the counts per rule
+func (r *LintReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Risk acceptance lint: %d checked, %d findings\n\n", r.Checked, len(r.Findings))
+
+	if len(r.Findings) > 0 {
+		fmt.Fprintf(&b, "%-26s %-10s %-30s %-21s %s\n", "ID", "CONTROL", "USER", "RULE", "MESSAGE")
+		for _, f := range r.Findings {
+			fmt.Fprintf(&b, "%-26s %-10s %-30s %-21s %s\n",
+				f.Acceptance.ID, f.Acceptance.ControlID, truncate(f.Acceptance.Username, 30), f.Rule, f.Message)
+		}
+		b.WriteString("\nFindings by rule:\n")
+		counts := r.ByRule()
+		rules := make([]string, 0, len(counts))
+		for rule := range counts {
+			rules = append(rules, rule)
+		}
+		sort.Strings(rules)
+		for _, rule := range rules {
+			fmt.Fprintf(&b, "  %-21s %d\n", rule, counts[rule])
+		}
+	} else {
+		b.WriteString("No findings\n")
+	}
+
+	if len(r.Skipped) > 0 {
+		b.WriteString("\n")
+	}
+	for _, s := range r.Skipped {
+		fmt.Fprintf(&b, "Skipped %s\n", s)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,121 @@
+package acceptance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestLint(t *testing.T) {
+	accepted := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ms := func(at time.Time) string { return fmt.Sprint(at.UnixMilli()) }
+
+	acceptances := []models.RiskAcceptance{
+		{ID: "ok", ControlID: "16022", Reason: "Risk Owned", Description: "SEC-123", AcceptanceDate: ms(accepted), AcceptPeriod: "30"},
+		{ID: "bare", ControlID: "16022", AcceptanceDate: ms(accepted), AcceptPeriod: "30"},
+		{ID: "no-ticket", ControlID: "16022", Reason: "Risk Owned", Description: "approved by Bob", AcceptanceDate: ms(accepted), AcceptPeriod: "30"},
+		{ID: "long", ControlID: "16022", Reason: "Risk Owned", Description: "SEC-1", AcceptanceDate: ms(accepted), ExpiresAt: ms(accepted.AddDate(2, 0, 0))},
+		{ID: "never-high", ControlID: "16022", Reason: "Risk Owned", Description: "SEC-2", AcceptPeriod: "Never", ExpiresAt: "0"},
+		{ID: "never-low", ControlID: "16031", Reason: "Risk Owned", Description: "SEC-3", AcceptPeriod: "Never", ExpiresAt: "0"},
+		{ID: "system", ControlID: "16031", Reason: "Risk Owned", Description: "SEC-4", AcceptPeriod: "30", IsSystem: true},
+		{ID: "gone", ControlID: "99999", Reason: "Risk Owned", Description: "SEC-5", AcceptPeriod: "30"},
+	}
+	controls := map[string]string{"16022": "High", "16031": "Low"}
+	rules := DefaultLintRules()
+	rules.TicketPattern = `[A-Z]+-[0-9]+`
+
+	report, err := Lint(acceptances, controls, rules)
+	if err != nil {
+		t.Fatalf("Lint failed: %v", err)
+	}
+
+	var got []string
+	for _, f := range report.Findings {
+		got = append(got, f.Acceptance.ID+":"+f.Rule)
+	}
+	want := []string{
+		"bare:" + RuleReasonRequired,
+		"bare:" + RuleDescriptionRequired,
+		"no-ticket:" + RuleTicketID,
+		"long:" + RuleMaxAcceptPeriod,
+		"never-high:" + RuleNeverExpiry,
+		"system:" + RuleSystemUser,
+		"gone:" + RuleUnknownControl,
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected findings\n%v\ngot\n%v", want, got)
+	}
+	if report.Checked != len(acceptances) || len(report.Skipped) != 0 {
+		t.Errorf("Unexpected report: checked %d, skipped %v", report.Checked, report.Skipped)
+	}
+
+	t.Run("収集済みコントロールなし", func(t *testing.T) {
+		report, err := Lint(acceptances, nil, rules)
+		if err != nil {
+			t.Fatalf("Lint failed: %v", err)
+		}
+		counts := report.ByRule()
+		if counts[RuleUnknownControl] != 0 || counts[RuleNeverExpiry] != 0 || len(report.Skipped) != 2 {
+			t.Errorf("Expected control rules to be skipped, got %v / %v", counts, report.Skipped)
+		}
+	})
+
+	t.Run("不正なチケットパターン", func(t *testing.T) {
+		if _, err := Lint(acceptances, controls, LintRules{TicketPattern: "("}); err == nil {
+			t.Error("Expected error for invalid pattern")
+		}
+	})
+
+	t.Run("出力", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := report.WriteText(&buf); err != nil {
+			t.Fatalf("WriteText failed: %v", err)
+		}
+		for _, want := range []string{"8 checked, 7 findings", "accepted for 730 days (max 365)", "High severity control accepted without expiry"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("Expected %q in:\n%s", want, buf.String())
+			}
+		}
+
+		buf.Reset()
+		if err := report.WriteJSON(&buf); err != nil {
+			t.Fatalf("WriteJSON failed: %v", err)
+		}
+		var out struct {
+			Findings []struct {
+				ID   string `json:"id"`
+				Rule string `json:"rule"`
+			} `json:"findings"`
+			ByRule map[string]int `json:"by_rule"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatalf("Invalid JSON: %v", err)
+		}
+		if len(out.Findings) != 7 || out.ByRule[RuleReasonRequired] != 1 {
+			t.Errorf("Unexpected JSON: %s", buf.String())
+		}
+	})
+}
+
+func TestLintExampleConfig(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "examples", "risk-lint-config.json"))
+	if err != nil {
+		t.Fatalf("Failed to read example: %v", err)
+	}
+	var file struct {
+		RiskLint LintRules `json:"risk_lint"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("Failed to parse example: %v", err)
+	}
+	if !file.RiskLint.RequireReason || file.RiskLint.MaxAcceptDays != 180 || len(file.RiskLint.NoNeverSeverities) != 2 {
+		t.Errorf("Unexpected risk_lint section: %+v", file.RiskLint)
+	}
+}
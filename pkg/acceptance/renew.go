@@ -0,0 +1,130 @@
+package acceptance
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+// Selector chooses the acceptances to renew. Set criteria are combined with AND.
+type Selector struct {
+	ID        string
+	ControlID string
+	Username  string
+	// Within selects acceptances expired or expiring before Now+Within; 0 ignores the expiry
+	Within time.Duration
+	Now    time.Time
+}
+
+// IsZero reports whether no criteria are set
+func (s Selector) IsZero() bool {
+	return s.ID == "" && s.ControlID == "" && s.Username == "" && s.Within == 0
+}
+
+// Select returns the acceptances matching the selector. System acceptances are never selected,
+// and acceptances that never expire are skipped when Within is set.
+func Select(acceptances []models.RiskAcceptance, sel Selector) ([]models.RiskAcceptance, error) {
+	if sel.IsZero() {
+		return nil, errors.New("an acceptance ID, control ID, user or expiry window is required")
+	}
+
+	var selected []models.RiskAcceptance
+	for _, a := range acceptances {
+		if a.IsSystem {
+			continue
+		}
+		if sel.ID != "" && a.ID != sel.ID {
+			continue
+		}
+		if sel.ControlID != "" && a.ControlID != sel.ControlID {
+			continue
+		}
+		if sel.Username != "" && !strings.EqualFold(a.Username, sel.Username) {
+			continue
+		}
+		if sel.Within > 0 {
+			expires, ok, err := ExpiryOf(a)
+			if err != nil {
+				return nil, err
+			}
+			if !a.IsExpired && (!ok || expires.After(sel.Now.Add(sel.Within))) {
+				continue
+			}
+		}
+		selected = append(selected, a)
+	}
+	return selected, nil
+}
+
+// RenewRequest builds a create request with the control, filter, zone, source and reason
+// of the acceptance and a new expiry
+func RenewRequest(a models.RiskAcceptance, expiresAt time.Time) (models.RiskAcceptanceCreateRequest, error) {
+	controlID, err := strconv.Atoi(a.ControlID)
+	if err != nil {
+		return models.RiskAcceptanceCreateRequest{}, fmt.Errorf("risk acceptance %s: control ID %q is not numeric", a.ID, a.ControlID)
+	}
+	// ゾーンIDが数値でない場合（"0" や空を含む）はゾーン指定なし
+	zoneID, _ := strconv.Atoi(a.ZoneID)
+
+	req := models.RiskAcceptanceCreateRequest{
+		ControlID:   controlID,
+		Reason:      a.Reason,
+		Description: a.Description,
+		Filter:      a.Filter,
+		SourceID:    a.SourceID,
+		ZoneID:      zoneID,
+	}
+	if !expiresAt.IsZero() {
+		req.ExpiresAt = strconv.FormatInt(expiresAt.UnixMilli(), 10)
+	}
+	return req, nil
+}
+
+// Client creates and revokes risk acceptances (implemented by client.CSPMClient)
+type Client interface {
+	Creator
+	DeleteRiskAcceptance(id string) error
+}
+
+// Renewal is the outcome of renewing one acceptance
+type Renewal struct {
+	Old models.RiskAcceptance
+	// New is nil when the acceptance could not be recreated; the old one is then left in place
+	New *models.RiskAcceptance
+	Err error
+	// RevokeErr is set when the new acceptance was created but the old one could not be revoked
+	RevokeErr error
+}
+
+// Renew recreates each acceptance with the new expiry and revokes the old one once the new one
+// exists, so the resources stay accepted if anything fails. A failure does not stop the remaining ones.
+func Renew(c Client, acceptances []models.RiskAcceptance, expiresAt time.Time) []Renewal {
+	renewals := make([]Renewal, 0, len(acceptances))
+	for _, a := range acceptances {
+		r := Renewal{Old: a}
+		req, err := RenewRequest(a, expiresAt)
+		if err != nil {
+			r.Err = err
+			renewals = append(renewals, r)
+			continue
+		}
+
+		created, err := c.CreateRiskAcceptance(req)
+		if err != nil {
+			r.Err = fmt.Errorf("failed to recreate risk acceptance %s: %w", a.ID, err)
+			renewals = append(renewals, r)
+			continue
+		}
+		r.New = created
+
+		if err := c.DeleteRiskAcceptance(a.ID); err != nil {
+			r.RevokeErr = fmt.Errorf("failed to revoke risk acceptance %s: %w", a.ID, err)
+		}
+		renewals = append(renewals, r)
+	}
+	return renewals
+}
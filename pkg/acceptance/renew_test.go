@@ -0,0 +1,120 @@
+package acceptance
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestSelect(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	ms := func(d time.Duration) string { return fmt.Sprint(now.Add(d).UnixMilli()) }
+	day := 24 * time.Hour
+
+	acceptances := []models.RiskAcceptance{
+		{ID: "a1", ControlID: "16022", Username: "alice@example.com", ExpiresAt: ms(10 * day)},
+		{ID: "a2", ControlID: "16022", Username: "bob@example.com", ExpiresAt: ms(60 * day)},
+		{ID: "a3", ControlID: "16031", Username: "Alice@example.com", ExpiresAt: ms(-day)},
+		{ID: "a4", ControlID: "16031", Username: "alice@example.com", ExpiresAt: "0", AcceptPeriod: "Never"},
+		{ID: "sys", ControlID: "16022", Username: "alice@example.com", ExpiresAt: ms(day), IsSystem: true},
+	}
+
+	cases := []struct {
+		name string
+		sel  Selector
+		want string
+	}{
+		{"ID", Selector{ID: "a2"}, "a2"},
+		{"コントロール", Selector{ControlID: "16022"}, "a1,a2"},
+		{"ユーザー（大文字小文字を区別しない）", Selector{Username: "alice@example.com"}, "a1,a3,a4"},
+		{"期限切れと期限間近のみ", Selector{Username: "alice@example.com", Within: 30 * day, Now: now}, "a1,a3"},
+	}
+	for _, c := range cases {
+		selected, err := Select(acceptances, c.sel)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+		var ids []string
+		for _, a := range selected {
+			ids = append(ids, a.ID)
+		}
+		if got := strings.Join(ids, ","); got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, got)
+		}
+	}
+
+	if _, err := Select(acceptances, Selector{}); err == nil {
+		t.Error("Expected error without criteria")
+	}
+}
+
+func TestRenewRequest(t *testing.T) {
+	expires := time.Date(2027, 1, 16, 0, 0, 0, 0, time.UTC)
+	a := models.RiskAcceptance{
+		ID: "a1", ControlID: "16022", Reason: "Risk Owned", Description: "JIRA-1",
+		Filter: `name in ("bucket-a")`, ZoneID: "7", SourceID: "111111111111",
+	}
+
+	req, err := RenewRequest(a, expires)
+	if err != nil {
+		t.Fatalf("RenewRequest failed: %v", err)
+	}
+	want := models.RiskAcceptanceCreateRequest{
+		ControlID: 16022, Reason: "Risk Owned", Description: "JIRA-1", Filter: `name in ("bucket-a")`,
+		ExpiresAt: fmt.Sprint(expires.UnixMilli()), SourceID: "111111111111", ZoneID: 7,
+	}
+	if req != want {
+		t.Errorf("Expected %+v, got %+v", want, req)
+	}
+
+	a.ControlID = "ctrl"
+	if _, err := RenewRequest(a, expires); err == nil {
+		t.Error("Expected error for non-numeric control ID")
+	}
+}
+
+type fakeClient struct {
+	fakeCreator
+	failRevoke map[string]bool
+	revoked    []string
+}
+
+func (f *fakeClient) DeleteRiskAcceptance(id string) error {
+	if f.failRevoke[id] {
+		return errors.New("boom")
+	}
+	f.revoked = append(f.revoked, id)
+	return nil
+}
+
+func TestRenew(t *testing.T) {
+	acceptances := []models.RiskAcceptance{
+		{ID: "a1", ControlID: "1", Filter: "a"},
+		{ID: "a2", ControlID: "1", Filter: "b"},
+		{ID: "a3", ControlID: "2", Filter: "c"},
+	}
+	c := &fakeClient{fakeCreator: fakeCreator{fail: map[string]bool{"b": true}}, failRevoke: map[string]bool{"a3": true}}
+
+	renewals := Renew(c, acceptances, time.Now().Add(90*24*time.Hour))
+	if len(renewals) != 3 {
+		t.Fatalf("Expected 3 renewals, got %d", len(renewals))
+	}
+	if r := renewals[0]; r.Err != nil || r.RevokeErr != nil || r.New == nil || r.New.ID != "ra-a" {
+		t.Errorf("Unexpected first renewal: %+v", r)
+	}
+	// 再作成に失敗した受容は取り消さない
+	if r := renewals[1]; r.Err == nil || r.New != nil {
+		t.Errorf("Expected the second renewal to fail, got %+v", r)
+	}
+	if r := renewals[2]; r.New == nil || r.RevokeErr == nil {
+		t.Errorf("Expected the third revoke to fail, got %+v", r)
+	}
+	if strings.Join(c.revoked, ",") != "a1" {
+		t.Errorf("Expected only a1 to be revoked, got %v", c.revoked)
+	}
+}
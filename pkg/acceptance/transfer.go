@@ -0,0 +1,323 @@
+package acceptance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/filter"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+// ExportVersion is the version of the export file written by this build
+const ExportVersion = 1
+
+// Export sources
+const (
+	SourceDatabase = "database"
+	SourceAPI      = "api"
+)
+
+// Export is the file written by risk-export and read by risk-import
+type Export struct {
+	Version    int       `json:"version" yaml:"version"`
+	ExportedAt time.Time `json:"exported_at" yaml:"exported_at"`
+	// Source is database or api
+	Source      string               `json:"source" yaml:"source"`
+	Acceptances []ExportedAcceptance `json:"acceptances" yaml:"acceptances"`
+}
+
+// ExportedAcceptance is a risk acceptance with the control and zone names used to map IDs
+// in the target tenant
+type ExportedAcceptance struct {
+	ID             string `json:"id" yaml:"id"`
+	ControlID      string `json:"control_id" yaml:"control_id"`
+	ControlName    string `json:"control_name,omitempty" yaml:"control_name,omitempty"`
+	ZoneID         string `json:"zone_id,omitempty" yaml:"zone_id,omitempty"`
+	ZoneName       string `json:"zone_name,omitempty" yaml:"zone_name,omitempty"`
+	Filter         string `json:"filter,omitempty" yaml:"filter,omitempty"`
+	SourceID       string `json:"source_id,omitempty" yaml:"source_id,omitempty"`
+	Reason         string `json:"reason" yaml:"reason"`
+	Description    string `json:"description,omitempty" yaml:"description,omitempty"`
+	AcceptPeriod   string `json:"accept_period,omitempty" yaml:"accept_period,omitempty"`
+	ExpiresAt      string `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+	AcceptanceDate string `json:"acceptance_date,omitempty" yaml:"acceptance_date,omitempty"`
+	Username       string `json:"username,omitempty" yaml:"username,omitempty"`
+}
+
+// Names maps control and zone IDs to their names
+type Names struct {
+	Controls map[string]string
+	Zones    map[string]string
+}
+
+// NewExport builds an export of the acceptances. System acceptances cannot be recreated
+// and are left out; the number skipped is returned.
+func NewExport(acceptances []models.RiskAcceptance, names Names, source string, now time.Time) (*Export, int) {
+	e := &Export{Version: ExportVersion, ExportedAt: now.UTC(), Source: source, Acceptances: []ExportedAcceptance{}}
+	skipped := 0
+	for _, a := range acceptances {
+		if a.IsSystem {
+			skipped++
+			continue
+		}
+		e.Acceptances = append(e.Acceptances, ExportedAcceptance{
+			ID:             a.ID,
+			ControlID:      a.ControlID,
+			ControlName:    names.Controls[a.ControlID],
+			ZoneID:         a.ZoneID,
+			ZoneName:       names.Zones[a.ZoneID],
+			Filter:         a.Filter,
+			SourceID:       a.SourceID,
+			Reason:         a.Reason,
+			Description:    a.Description,
+			AcceptPeriod:   a.AcceptPeriod,
+			ExpiresAt:      a.ExpiresAt,
+			AcceptanceDate: a.AcceptanceDate,
+			Username:       a.Username,
+		})
+	}
+	return e, skipped
+}
+
+// isYAML reports whether the path has a YAML extension; other files are JSON
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// WriteExport writes the export as YAML or JSON depending on the extension of path
+func WriteExport(w io.Writer, path string, e *Export) error {
+	if isYAML(path) {
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e)
+}
+
+// ReadExport reads an export written by WriteExport
+func ReadExport(r io.Reader, path string) (*Export, error) {
+	var e Export
+	var err error
+	if isYAML(path) {
+		err = yaml.NewDecoder(r).Decode(&e)
+	} else {
+		err = json.NewDecoder(r).Decode(&e)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if e.Version != ExportVersion {
+		return nil, fmt.Errorf("unsupported export version %d in %s (this build reads version %d)", e.Version, path, ExportVersion)
+	}
+	return &e, nil
+}
+
+// Import item statuses
+const (
+	ImportCreate   = "create"
+	ImportCreated  = "created"
+	ImportExisting = "skipped-existing"
+	ImportExpired  = "skipped-expired"
+	ImportFailed   = "failed"
+)
+
+// ImportItem is one exported acceptance and what importing it does
+type ImportItem struct {
+	Source  ExportedAcceptance
+	Request models.RiskAcceptanceCreateRequest
+	Status  string
+	// NewID is the ID of the created acceptance
+	NewID string
+	Err   error
+}
+
+// ImportOptions holds what is known about the target tenant
+type ImportOptions struct {
+	// Names are the control and zone names of the target tenant; with no names of a kind
+	// the exported IDs are kept (e.g. restoring into the same tenant)
+	Names Names
+	// Existing are the acceptances already in the target tenant
+	Existing []models.RiskAcceptance
+	Now      time.Time
+}
+
+// PlanImport maps the exported acceptances to create requests for the target tenant.
+// Acceptances that already exist (same control, zone, source and filter) or have expired are
+// skipped, and acceptances whose control or zone cannot be mapped fail.
+func PlanImport(e *Export, opts ImportOptions) []ImportItem {
+	controls, controlErr := invert(opts.Names.Controls)
+	zones, zoneErr := invert(opts.Names.Zones)
+
+	existing := make(map[string]bool)
+	for _, a := range opts.Existing {
+		controlID, _ := strconv.Atoi(a.ControlID)
+		zoneID, _ := strconv.Atoi(a.ZoneID)
+		existing[importKey(controlID, zoneID, a.SourceID, a.Filter)] = true
+	}
+
+	items := make([]ImportItem, 0, len(e.Acceptances))
+	for _, a := range e.Acceptances {
+		item := ImportItem{Source: a}
+		item.Request, item.Err = importRequest(a, controls, controlErr, zones, zoneErr)
+		switch {
+		case item.Err != nil:
+			item.Status = ImportFailed
+		case existing[importKey(item.Request.ControlID, item.Request.ZoneID, item.Request.SourceID, item.Request.Filter)]:
+			item.Status = ImportExisting
+		case isPast(item.Request.ExpiresAt, opts.Now):
+			item.Status = ImportExpired
+		default:
+			item.Status = ImportCreate
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// Import creates the items planned for creation; a failure does not stop the remaining ones
+func Import(c Creator, items []ImportItem) {
+	for i := range items {
+		item := &items[i]
+		if item.Status != ImportCreate {
+			continue
+		}
+		created, err := c.CreateRiskAcceptance(item.Request)
+		if err != nil {
+			item.Status, item.Err = ImportFailed, err
+			continue
+		}
+		item.Status, item.NewID = ImportCreated, created.ID
+	}
+}
+
+func importRequest(a ExportedAcceptance, controls map[string]string, controlErr map[string]bool,
+	zones map[string]string, zoneErr map[string]bool) (models.RiskAcceptanceCreateRequest, error) {
+	controlID, err := mapID("control", a.ControlID, a.ControlName, controls, controlErr)
+	if err != nil {
+		return models.RiskAcceptanceCreateRequest{}, err
+	}
+	control, err := strconv.Atoi(controlID)
+	if err != nil {
+		return models.RiskAcceptanceCreateRequest{}, fmt.Errorf("control ID %q is not numeric", controlID)
+	}
+
+	zone := 0
+	if a.ZoneID != "" && a.ZoneID != "0" {
+		zoneID, err := mapID("zone", a.ZoneID, a.ZoneName, zones, zoneErr)
+		if err != nil {
+			return models.RiskAcceptanceCreateRequest{}, err
+		}
+		// ゾーンIDが数値でない場合はゾーン指定なし
+		zone, _ = strconv.Atoi(zoneID)
+	}
+
+	req := models.RiskAcceptanceCreateRequest{
+		ControlID:   control,
+		Reason:      a.Reason,
+		Description: a.Description,
+		Filter:      a.Filter,
+		SourceID:    a.SourceID,
+		ZoneID:      zone,
+	}
+	expires, ok, err := ExpiryOf(models.RiskAcceptance{ID: a.ID, ExpiresAt: a.ExpiresAt, AcceptanceDate: a.AcceptanceDate, AcceptPeriod: a.AcceptPeriod})
+	if err != nil {
+		return models.RiskAcceptanceCreateRequest{}, err
+	}
+	if ok {
+		req.ExpiresAt = strconv.FormatInt(expires.UnixMilli(), 10)
+	}
+	return req, nil
+}
+
+// mapID returns the ID of the named object in the target tenant. Without target names
+// of this kind, or without a name in the export, the exported ID is kept.
+func mapID(kind, id, name string, byName map[string]string, ambiguous map[string]bool) (string, error) {
+	if len(byName) == 0 || name == "" {
+		return id, nil
+	}
+	if ambiguous[name] {
+		return "", fmt.Errorf("%s name %q matches several %ss in the target", kind, name, kind)
+	}
+	target, ok := byName[name]
+	if !ok {
+		return "", fmt.Errorf("%s %q (%s) not found in the target", kind, name, id)
+	}
+	return target, nil
+}
+
+// invert maps names to IDs; names shared by several IDs are returned as ambiguous
+func invert(names map[string]string) (map[string]string, map[string]bool) {
+	byName := make(map[string]string)
+	ambiguous := make(map[string]bool)
+	for id, name := range names {
+		if name == "" {
+			continue
+		}
+		if other, ok := byName[name]; ok && other != id {
+			ambiguous[name] = true
+		}
+		byName[name] = id
+	}
+	return byName, ambiguous
+}
+
+// importKey identifies an acceptance by what it accepts; filters are compared after normalization
+func importKey(controlID, zoneID int, sourceID, filterExpr string) string {
+	if e, err := filter.Parse(filterExpr); err == nil && e != nil {
+		filterExpr = e.String()
+	}
+	return fmt.Sprintf("%d\x00%d\x00%s\x00%s", controlID, zoneID, sourceID, strings.TrimSpace(filterExpr))
+}
+
+func isPast(expiresAt string, now time.Time) bool {
+	t, ok, err := ParseTimestamp(expiresAt)
+	return err == nil && ok && !t.After(now)
+}
+
+// ImportResult is one line of the import result log
+type ImportResult struct {
+	Index     int    `json:"index"`
+	SourceID  string `json:"source_id"`
+	ControlID int    `json:"control_id"`
+	ZoneID    int    `json:"zone_id"`
+	Filter    string `json:"filter"`
+	Status    string `json:"status"`
+	NewID     string `json:"new_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// WriteImportLog writes one JSON line per item
+func WriteImportLog(w io.Writer, items []ImportItem) error {
+	enc := json.NewEncoder(w)
+	for i, item := range items {
+		r := ImportResult{
+			Index:     i,
+			SourceID:  item.Source.ID,
+			ControlID: item.Request.ControlID,
+			ZoneID:    item.Request.ZoneID,
+			Filter:    item.Request.Filter,
+			Status:    item.Status,
+			NewID:     item.NewID,
+		}
+		if item.Err != nil {
+			r.Error = item.Err.Error()
+		}
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package acceptance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	acceptances := []models.RiskAcceptance{
+		{ID: "a1", ControlID: "16022", ZoneID: "7", Filter: `name in ("bucket-a")`, SourceID: "111", Reason: "Risk Owned", ExpiresAt: "0", AcceptPeriod: "Never"},
+		{ID: "sys", ControlID: "16022", IsSystem: true},
+	}
+	names := Names{Controls: map[string]string{"16022": "Bucket ACL"}, Zones: map[string]string{"7": "Prod"}}
+
+	e, skipped := NewExport(acceptances, names, SourceDatabase, now)
+	if skipped != 1 || len(e.Acceptances) != 1 {
+		t.Fatalf("Expected the system acceptance to be skipped, got %d / %+v", skipped, e.Acceptances)
+	}
+	if a := e.Acceptances[0]; a.ControlName != "Bucket ACL" || a.ZoneName != "Prod" {
+		t.Errorf("Expected control and zone names, got %+v", a)
+	}
+
+	for _, path := range []string{"export.json", "export.yaml"} {
+		var buf bytes.Buffer
+		if err := WriteExport(&buf, path, e); err != nil {
+			t.Fatalf("%s: WriteExport failed: %v", path, err)
+		}
+		got, err := ReadExport(&buf, path)
+		if err != nil {
+			t.Fatalf("%s: ReadExport failed: %v", path, err)
+		}
+		if got.Version != ExportVersion || !got.ExportedAt.Equal(now) || len(got.Acceptances) != 1 || got.Acceptances[0] != e.Acceptances[0] {
+			t.Errorf("%s: round trip mismatch: %+v", path, got)
+		}
+	}
+
+	if _, err := ReadExport(strings.NewReader(`{"version": 2}`), "export.json"); err == nil {
+		t.Error("Expected error for an unsupported version")
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	future := fmt.Sprint(now.AddDate(0, 1, 0).UnixMilli())
+	e := &Export{Version: ExportVersion, Acceptances: []ExportedAcceptance{
+		{ID: "a1", ControlID: "16022", ControlName: "Bucket ACL", ZoneID: "7", ZoneName: "Prod", Filter: `name in ("bucket-a")`, SourceID: "111", Reason: "Risk Owned", ExpiresAt: future},
+		{ID: "a2", ControlID: "16022", ControlName: "Bucket ACL", ZoneID: "7", ZoneName: "Prod", Filter: `name  in ( "bucket-b" )`, SourceID: "111", Reason: "Risk Owned"},
+		{ID: "a3", ControlID: "16022", ControlName: "Bucket ACL", Reason: "Risk Owned", ExpiresAt: fmt.Sprint(now.AddDate(0, 0, -1).UnixMilli())},
+		{ID: "a4", ControlID: "16031", ControlName: "Missing control", Reason: "Risk Owned"},
+		{ID: "a5", ControlID: "16040", Reason: "Risk Owned"},
+	}}
+	opts := ImportOptions{
+		Names: Names{
+			Controls: map[string]string{"90022": "Bucket ACL"},
+			Zones:    map[string]string{"3": "Prod"},
+		},
+		// 正規化したフィルタで既存の受容を検出する
+		Existing: []models.RiskAcceptance{{ID: "t1", ControlID: "90022", ZoneID: "3", SourceID: "111", Filter: `name in ("bucket-b")`}},
+		Now:      now,
+	}
+
+	items := PlanImport(e, opts)
+	var statuses []string
+	for _, item := range items {
+		statuses = append(statuses, item.Source.ID+":"+item.Status)
+	}
+	want := "a1:create a2:skipped-existing a3:skipped-expired a4:failed a5:create"
+	if got := strings.Join(statuses, " "); got != want {
+		t.Fatalf("Expected %s, got %s", want, got)
+	}
+	if r := items[0].Request; r.ControlID != 90022 || r.ZoneID != 3 || r.ExpiresAt != future {
+		t.Errorf("Expected mapped IDs, got %+v", r)
+	}
+	// 名前のない受容はIDをそのまま使う
+	if items[4].Request.ControlID != 16040 {
+		t.Errorf("Expected the exported control ID, got %+v", items[4].Request)
+	}
+
+	Import(&fakeCreator{}, items)
+	if items[0].Status != ImportCreated || items[0].NewID == "" || items[1].Status != ImportExisting {
+		t.Errorf("Unexpected import results: %+v", items[:2])
+	}
+
+	var buf bytes.Buffer
+	if err := WriteImportLog(&buf, items); err != nil {
+		t.Fatalf("WriteImportLog failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var last ImportResult
+	if err := json.Unmarshal([]byte(lines[3]), &last); err != nil || len(lines) != 5 {
+		t.Fatalf("Unexpected log: %s", buf.String())
+	}
+	if last.Status != ImportFailed || !strings.Contains(last.Error, "Missing control") {
+		t.Errorf("Unexpected log line: %+v", last)
+	}
+}
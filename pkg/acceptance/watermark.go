@@ -0,0 +1,42 @@
+package acceptance
+
+import (
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+// Watermark is the newest risk acceptance already collected.
+// An incremental collection pages by acceptanceDate (newest first) and stops once it is reached.
+type Watermark struct {
+	ID             string
+	AcceptanceDate time.Time
+}
+
+// NewWatermark returns the watermark of the latest stored acceptance; nil means nothing is stored yet
+func NewWatermark(latest *models.RiskAcceptance) (*Watermark, error) {
+	if latest == nil {
+		return nil, nil
+	}
+	accepted, _, err := ParseTimestamp(latest.AcceptanceDate)
+	if err != nil {
+		return nil, err
+	}
+	return &Watermark{ID: latest.ID, AcceptanceDate: accepted}, nil
+}
+
+// Reached reports whether a is the watermark or older than it.
+// 同時刻に作成された別の受容は取りこぼさないよう収集を続ける
+func (w *Watermark) Reached(a models.RiskAcceptance) bool {
+	if w == nil {
+		return false
+	}
+	if a.ID == w.ID {
+		return true
+	}
+	accepted, ok, err := ParseTimestamp(a.AcceptanceDate)
+	if err != nil || !ok || w.AcceptanceDate.IsZero() {
+		return false
+	}
+	return accepted.Before(w.AcceptanceDate)
+}
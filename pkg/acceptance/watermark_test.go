@@ -0,0 +1,46 @@
+package acceptance
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestWatermark(t *testing.T) {
+	at := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	ms := func(d time.Duration) string { return fmt.Sprint(at.Add(d).UnixMilli()) }
+
+	w, err := NewWatermark(&models.RiskAcceptance{ID: "a1", AcceptanceDate: ms(0)})
+	if err != nil {
+		t.Fatalf("NewWatermark failed: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		a    models.RiskAcceptance
+		want bool
+	}{
+		{"新しい受容", models.RiskAcceptance{ID: "a2", AcceptanceDate: ms(time.Minute)}, false},
+		{"同時刻の別の受容", models.RiskAcceptance{ID: "a3", AcceptanceDate: ms(0)}, false},
+		{"最後に収集した受容", models.RiskAcceptance{ID: "a1", AcceptanceDate: ms(0)}, true},
+		{"古い受容", models.RiskAcceptance{ID: "a0", AcceptanceDate: ms(-time.Minute)}, true},
+		{"日時なし", models.RiskAcceptance{ID: "a4"}, false},
+	}
+	for _, c := range cases {
+		if got := w.Reached(c.a); got != c.want {
+			t.Errorf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+
+	t.Run("空のDBでは最後まで収集する", func(t *testing.T) {
+		w, err := NewWatermark(nil)
+		if err != nil {
+			t.Fatalf("NewWatermark failed: %v", err)
+		}
+		if w.Reached(models.RiskAcceptance{ID: "a0", AcceptanceDate: ms(-time.Hour)}) {
+			t.Error("Expected a nil watermark to never be reached")
+		}
+	})
+}
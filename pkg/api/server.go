@@ -0,0 +1,272 @@
+package api
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// Page size limits
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// OpenAPI is the OpenAPI document describing the API
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
+
+// Server serves a collected database as a read-only JSON API
+type Server struct {
+	db *database.Database
+	// keys maps an API key to its name
+	keys map[string]string
+}
+
+// NewServer creates a server; keys maps each accepted API key to the name used in logs.
+// Without keys the API is not authenticated (the ui command on a loopback address).
+func NewServer(db *database.Database, keys map[string]string) *Server {
+	return &Server{db: db, keys: keys}
+}
+
+// Page is the response of a list endpoint
+type Page struct {
+	Items  []database.Row `json:"items"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+// PolicyList is the response of /api/v1/policies
+type PolicyList struct {
+	Items []database.PolicySummary `json:"items"`
+	Total int                      `json:"total"`
+}
+
+// Error is the response body of a failed request
+type Error struct {
+	Error string `json:"error"`
+}
+
+// Handler serves /api/v1/<listing>, /api/v1/<listing>/{key}, /openapi.yaml and /healthz.
+// Only /api/v1/ requires an API key.
+func (s *Server) Handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/v1/policies", s.policies)
+	for _, l := range database.Listings() {
+		api.HandleFunc("GET /api/v1/"+l.Name, s.listHandler(l))
+		api.HandleFunc("GET /api/v1/"+l.Name+"/{key}", s.getHandler(l))
+	}
+	api.HandleFunc("/api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "the API is read-only")
+			return
+		}
+		writeError(w, http.StatusNotFound, "unknown endpoint "+r.URL.Path)
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/", s.authenticate(api))
+	mux.HandleFunc("GET /openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write(OpenAPI)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	return logRequests(mux)
+}
+
+// policies returns the pass/fail counts per policy and zone
+func (s *Server) policies(w http.ResponseWriter, r *http.Request) {
+	summaries, err := s.db.GetPolicySummaries()
+	if err != nil {
+		log.Printf("[ERROR] %s: %v", r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, "failed to query policies")
+		return
+	}
+	if summaries == nil {
+		summaries = []database.PolicySummary{}
+	}
+	writeJSON(w, http.StatusOK, PolicyList{Items: summaries, Total: len(summaries)})
+}
+
+func (s *Server) listHandler(l *database.Listing) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseListQuery(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		result, err := s.db.List(l, q)
+		if errors.Is(err, database.ErrInvalidQuery) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("[ERROR] %s: %v", r.URL.Path, err)
+			writeError(w, http.StatusInternalServerError, "failed to query "+l.Name)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, Page{Items: result.Rows, Total: result.Total, Limit: q.Limit, Offset: q.Offset})
+	}
+}
+
+func (s *Server) getHandler(l *database.Listing) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		row, err := s.db.Get(l, key)
+		if err != nil {
+			log.Printf("[ERROR] %s: %v", r.URL.Path, err)
+			writeError(w, http.StatusInternalServerError, "failed to query "+l.Name)
+			return
+		}
+		if row == nil {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("%s %q not found", l.Name, key))
+			return
+		}
+		writeJSON(w, http.StatusOK, row)
+	}
+}
+
+// reservedParams are query parameters that are not filters
+var reservedParams = map[string]bool{"limit": true, "offset": true, "sort": true}
+
+// parseListQuery reads limit, offset, sort and filters; filters and sort keys are validated by the database
+func parseListQuery(values url.Values) (database.ListQuery, error) {
+	q := database.ListQuery{Limit: DefaultLimit}
+
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+		}
+		q.Limit = n
+	}
+	if v := values.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("offset must be a non-negative integer")
+		}
+		q.Offset = n
+	}
+	if v := values.Get("sort"); v != "" {
+		for _, key := range strings.Split(v, ",") {
+			key = strings.TrimSpace(key)
+			q.Sort = append(q.Sort, database.SortField{Field: strings.TrimPrefix(key, "-"), Desc: strings.HasPrefix(key, "-")})
+		}
+	}
+
+	// 並び順を固定してエラーメッセージとSQLを決定的にする
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if !reservedParams[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range values[name] {
+			q.Filters = append(q.Filters, database.FilterValue{Name: name, Value: v})
+		}
+	}
+
+	return q, nil
+}
+
+// authenticate accepts "Authorization: Bearer <key>" or "X-API-Key: <key>"
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if len(s.keys) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := s.keyName(requestKey(r))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sysdig-cspm-utils"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid API key")
+			return
+		}
+		if rec, ok := w.(*statusRecorder); ok {
+			rec.keyName = name
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func requestKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// keyName compares the key against every configured key in constant time
+func (s *Server) keyName(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	var name string
+	found := false
+	for k, n := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			name = n
+			found = true
+		}
+	}
+	return name, found
+}
+
+// statusRecorder captures the response status and the API key name for the access log
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	keyName string
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests writes one access log line per request (the key name, never the key)
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		key := rec.keyName
+		if key == "" {
+			key = "-"
+		}
+		log.Printf("%s %s %d key=%s %s", r.Method, r.URL.RequestURI(), rec.status, key, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, Error{Error: message})
+}
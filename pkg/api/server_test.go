@@ -0,0 +1,277 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+const testKey = "test-secret"
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := database.NewDatabase(path)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	requirements := []models.ComplianceRequirementWithControls{
+		{
+			RequirementID: "req-1",
+			Name:          "Ensure buckets are private",
+			PolicyID:      "policy-1",
+			PolicyName:    "CIS AWS",
+			Severity:      "High",
+			Zone:          models.Zone{ID: "zone-1", Name: "Prod"},
+			Controls: []models.Control{
+				{ID: "ctrl-1", Name: "Bucket ACL", Severity: "High"},
+				{ID: "ctrl-2", Name: "Bucket policy", Severity: "Medium", Pass: true},
+				{ID: "ctrl-3", Name: "Bucket logging", Severity: "Low"},
+			},
+		},
+	}
+	if err := db.SaveComplianceRequirementsWithControls(requirements); err != nil {
+		t.Fatalf("Failed to save requirements: %v", err)
+	}
+	if err := db.SaveRiskAcceptances([]models.RiskAcceptance{
+		{ID: "ra-1", TenantID: "t", ControlID: "ctrl-1", Reason: "Risk Owned", AcceptanceDate: "2026-01-01"},
+	}); err != nil {
+		t.Fatalf("Failed to save risk acceptances: %v", err)
+	}
+	_ = db.Close()
+
+	// serveと同じく読み取り専用で開く
+	ro, err := database.OpenReadOnly(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = ro.Close() })
+
+	server := httptest.NewServer(NewServer(ro, map[string]string{testKey: "tests"}).Handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+func get(t *testing.T, url string, key string, v interface{}) int {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestServer(t *testing.T) {
+	server := newTestServer(t)
+
+	t.Run("APIキーなしは401", func(t *testing.T) {
+		var e Error
+		if status := get(t, server.URL+"/api/v1/controls", "", &e); status != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", status)
+		}
+		if status := get(t, server.URL+"/api/v1/controls", "wrong", nil); status != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for wrong key, got %d", status)
+		}
+	})
+
+	t.Run("X-API-Keyヘッダ", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/controls", nil)
+		req.Header.Set("X-API-Key", testKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("一覧のフィルタ・ソート・ページング", func(t *testing.T) {
+		var page Page
+		status := get(t, server.URL+"/api/v1/controls?pass=false&sort=-severity&limit=1&offset=1", testKey, &page)
+		if status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", status)
+		}
+		if page.Total != 2 || page.Limit != 1 || page.Offset != 1 || len(page.Items) != 1 {
+			t.Fatalf("Unexpected page: %+v", page)
+		}
+		if page.Items[0]["control_id"] != "ctrl-3" {
+			t.Errorf("Expected ctrl-3 on the second page, got %v", page.Items[0]["control_id"])
+		}
+	})
+
+	t.Run("ポリシー一覧", func(t *testing.T) {
+		var list PolicyList
+		if status := get(t, server.URL+"/api/v1/policies", testKey, &list); status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", status)
+		}
+		if list.Total != 1 || list.Items[0].ControlsFailed != 2 || list.Items[0].ControlsPassed != 1 {
+			t.Errorf("Unexpected policies: %+v", list)
+		}
+	})
+
+	t.Run("個別取得", func(t *testing.T) {
+		var row map[string]interface{}
+		if status := get(t, server.URL+"/api/v1/risk-acceptances/ra-1", testKey, &row); status != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", status)
+		}
+		if row["control_id"] != "ctrl-1" || row["is_expired"] != false {
+			t.Errorf("Unexpected risk acceptance: %v", row)
+		}
+
+		if status := get(t, server.URL+"/api/v1/controls/missing", testKey, nil); status != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", status)
+		}
+	})
+
+	t.Run("不正なパラメータは400", func(t *testing.T) {
+		for _, query := range []string{"bogus=1", "sort=description", "limit=0", "limit=5000", "offset=-1", "pass=maybe"} {
+			var e Error
+			if status := get(t, server.URL+"/api/v1/controls?"+query, testKey, &e); status != http.StatusBadRequest {
+				t.Errorf("%s: expected 400, got %d", query, status)
+			}
+			if e.Error == "" {
+				t.Errorf("%s: expected error message", query)
+			}
+		}
+	})
+
+	t.Run("読み取り専用", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodDelete, server.URL+"/api/v1/risk-acceptances/ra-1", nil)
+		req.Header.Set("Authorization", "Bearer "+testKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("Expected 405, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("認証不要のエンドポイント", func(t *testing.T) {
+		for _, path := range []string{"/healthz", "/openapi.yaml"} {
+			resp, err := http.Get(server.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("%s: expected 200, got %d", path, resp.StatusCode)
+			}
+		}
+	})
+}
+
+func TestServer_WithoutKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := database.NewDatabase(path)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	server := httptest.NewServer(NewServer(db, nil).Handler())
+	defer server.Close()
+
+	var list PolicyList
+	if status := get(t, server.URL+"/api/v1/policies", "", &list); status != http.StatusOK {
+		t.Fatalf("Expected 200 without keys, got %d", status)
+	}
+	if list.Items == nil || list.Total != 0 {
+		t.Errorf("Expected empty policy list, got %+v", list)
+	}
+}
+
+// openAPIDoc is the subset of the OpenAPI document checked against the listings
+type openAPIDoc struct {
+	Paths map[string]map[string]struct {
+		Parameters []struct {
+			Name string `yaml:"name"`
+		} `yaml:"parameters"`
+	} `yaml:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]interface{} `yaml:"properties"`
+		} `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+func TestOpenAPI_MatchesListings(t *testing.T) {
+	var doc openAPIDoc
+	if err := yaml.Unmarshal(OpenAPI, &doc); err != nil {
+		t.Fatalf("Invalid OpenAPI document: %v", err)
+	}
+
+	schemas := map[string]string{
+		"requirements":     "Requirement",
+		"controls":         "Control",
+		"resources":        "Resource",
+		"relations":        "Relation",
+		"risk-acceptances": "RiskAcceptance",
+	}
+
+	for _, l := range database.Listings() {
+		t.Run(l.Name, func(t *testing.T) {
+			list, ok := doc.Paths["/api/v1/"+l.Name]["get"]
+			if !ok {
+				t.Fatalf("Path /api/v1/%s is not documented", l.Name)
+			}
+			if _, ok := doc.Paths["/api/v1/"+l.Name+"/{key}"]["get"]; !ok {
+				t.Errorf("Path /api/v1/%s/{key} is not documented", l.Name)
+			}
+
+			var documented []string
+			for _, p := range list.Parameters {
+				if p.Name != "" && p.Name != "sort" {
+					documented = append(documented, p.Name)
+				}
+			}
+			sort.Strings(documented)
+			if got, want := strings.Join(documented, ","), strings.Join(l.FilterNames(), ","); got != want {
+				t.Errorf("Documented filters %s, implemented %s", got, want)
+			}
+
+			schema := doc.Components.Schemas[schemas[l.Name]]
+			var properties []string
+			for name := range schema.Properties {
+				properties = append(properties, name)
+			}
+			var fields []string
+			for _, f := range l.Fields {
+				fields = append(fields, f.Name)
+			}
+			sort.Strings(properties)
+			sort.Strings(fields)
+			if got, want := strings.Join(properties, ","), strings.Join(fields, ","); got != want {
+				t.Errorf("Documented fields %s, implemented %s", got, want)
+			}
+		})
+	}
+}
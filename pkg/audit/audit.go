@@ -0,0 +1,166 @@
+// Package audit records the write operations this tool sends to the Sysdig API.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// Logger records the changes of a client in the audit_log table and, optionally, as JSON lines.
+// It implements client.Auditor.
+type Logger struct {
+	db *database.Database
+	// JSONL receives every entry as one JSON line as well
+	JSONL io.Writer
+
+	OSUser  string
+	Profile string
+	APIURL  string
+	Command string
+
+	now func() time.Time
+}
+
+// NewLogger creates a logger writing to db; the OS user defaults to the current user
+func NewLogger(db *database.Database, profile, apiURL, command string) *Logger {
+	return &Logger{
+		db:      db,
+		OSUser:  CurrentUser(),
+		Profile: profile,
+		APIURL:  apiURL,
+		Command: command,
+		now:     time.Now,
+	}
+}
+
+// CurrentUser returns the name of the OS user running the tool
+func CurrentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	for _, env := range []string{"USER", "USERNAME"} {
+		if name := os.Getenv(env); name != "" {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// RecordChange appends the change to the audit log. Failures to record are logged and do not
+// affect the change, which has already been sent.
+func (l *Logger) RecordChange(change client.Change) {
+	entry, err := l.entry(change)
+	if err != nil {
+		log.Printf("[WARN] Failed to build audit entry for %s %s: %v", change.Method, change.Endpoint, err)
+	}
+	if err := l.db.AppendAuditEntry(entry); err != nil {
+		log.Printf("[WARN] %v", err)
+	}
+	if l.JSONL != nil {
+		if err := json.NewEncoder(l.JSONL).Encode(entry); err != nil {
+			log.Printf("[WARN] Failed to write audit entry: %v", err)
+		}
+	}
+}
+
+// entry converts a change; the returned entry is usable even when err is set
+func (l *Logger) entry(change client.Change) (*database.AuditEntry, error) {
+	entry := &database.AuditEntry{
+		RecordedAt:   l.now(),
+		OSUser:       l.OSUser,
+		Profile:      l.Profile,
+		APIURL:       l.APIURL,
+		Command:      l.Command,
+		Method:       change.Method,
+		Endpoint:     change.Endpoint,
+		StatusCode:   change.StatusCode,
+		AcceptanceID: change.AcceptanceID,
+	}
+	if change.Err != nil {
+		entry.Error = change.Err.Error()
+	}
+
+	request, err := json.Marshal(change.Request)
+	if err != nil {
+		return entry, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	entry.Request = string(request)
+
+	// 変更前の状態はDBに収集済みの受容から記録する（作成した受容はまだ保存されていない）
+	if change.AcceptanceID == "" {
+		return entry, nil
+	}
+	before, err := l.db.GetRiskAcceptance(change.AcceptanceID)
+	if err != nil || before == nil {
+		return entry, err
+	}
+	data, err := json.Marshal(before)
+	if err != nil {
+		return entry, fmt.Errorf("failed to marshal risk acceptance %s: %w", change.AcceptanceID, err)
+	}
+	entry.Before = string(data)
+	return entry, nil
+}
+
+// WriteTable prints the entries as a table, oldest first
+func WriteTable(w io.Writer, entries []database.AuditEntry) error {
+	if len(entries) == 0 {
+		_, err := fmt.Fprintln(w, "No audit entries found")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tOS USER\tPROFILE\tCOMMAND\tOPERATION\tACCEPTANCE\tSTATUS")
+	for _, e := range entries {
+		status := fmt.Sprint(e.StatusCode)
+		if e.Error != "" {
+			status += " " + e.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.RecordedAt.Local().Format("2006-01-02 15:04:05"), e.OSUser, orDash(e.Profile), orDash(e.Command),
+			Operation(e.Endpoint), orDash(e.AcceptanceID), status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\nTotal: %d entries\n", len(entries))
+	return err
+}
+
+// WriteJSON writes the entries as a JSON array
+func WriteJSON(w io.Writer, entries []database.AuditEntry) error {
+	if entries == nil {
+		entries = []database.AuditEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// Operation names the change made by a request to an endpoint
+func Operation(endpoint string) string {
+	switch {
+	case strings.HasSuffix(endpoint, "/violations/revoke"):
+		return "revoke"
+	case strings.HasSuffix(endpoint, "/violations/acceptances"):
+		return "create"
+	}
+	return endpoint
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
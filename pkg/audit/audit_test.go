@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/internal/testutil"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestLogger(t *testing.T) {
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	existing := models.RiskAcceptance{ID: "ra-1", TenantID: "t1", ControlID: "16022", AcceptanceDate: "1760000000000", Reason: "Risk Owned", Username: "alice@example.com"}
+	if err := db.SaveRiskAcceptances([]models.RiskAcceptance{existing}); err != nil {
+		t.Fatalf("SaveRiskAcceptances failed: %v", err)
+	}
+
+	server := testutil.NewMockServer(testutil.DefaultMockServerConfig())
+	defer server.Close()
+
+	var jsonl bytes.Buffer
+	logger := NewLogger(db, "prod", server.URL, "risk-renew")
+	logger.OSUser = "operator"
+	logger.JSONL = &jsonl
+	at := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	logger.now = func() time.Time { return at }
+
+	c := client.NewCSPMClient(server.URL, "test-token")
+	c.Auditor = logger
+
+	created, err := c.CreateRiskAcceptance(models.RiskAcceptanceCreateRequest{ControlID: 16022, Reason: "Risk Owned"})
+	if err != nil {
+		t.Fatalf("CreateRiskAcceptance failed: %v", err)
+	}
+	if err := c.DeleteRiskAcceptance("ra-1"); err != nil {
+		t.Fatalf("DeleteRiskAcceptance failed: %v", err)
+	}
+	// 失敗したリクエストも記録する
+	if err := c.DeleteRiskAcceptance(""); err == nil {
+		t.Fatal("Expected error without id")
+	}
+
+	entries, err := db.GetAuditEntries(database.AuditQuery{})
+	if err != nil {
+		t.Fatalf("GetAuditEntries failed: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 audit entries, got %d", len(entries))
+	}
+
+	create := entries[0]
+	if Operation(create.Endpoint) != "create" || create.AcceptanceID != created.ID || create.StatusCode != 200 || create.Before != "" {
+		t.Errorf("Unexpected create entry: %+v", create)
+	}
+	if create.OSUser != "operator" || create.Profile != "prod" || create.Command != "risk-renew" || !create.RecordedAt.Equal(at) {
+		t.Errorf("Unexpected context of the create entry: %+v", create)
+	}
+	if !strings.Contains(create.Request, `"controlId":16022`) {
+		t.Errorf("Expected the request payload, got %s", create.Request)
+	}
+
+	revoke := entries[1]
+	var before models.RiskAcceptance
+	if err := json.Unmarshal([]byte(revoke.Before), &before); err != nil {
+		t.Fatalf("Expected the snapshot before the change, got %q: %v", revoke.Before, err)
+	}
+	if Operation(revoke.Endpoint) != "revoke" || revoke.AcceptanceID != "ra-1" || before != existing {
+		t.Errorf("Unexpected revoke entry: %+v", revoke)
+	}
+
+	if failed := entries[2]; failed.StatusCode != 400 || failed.Error == "" {
+		t.Errorf("Expected the failed request to be recorded, got %+v", failed)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(jsonl.String()), "\n"); len(lines) != 3 {
+		t.Errorf("Expected 3 JSON lines, got %d:\n%s", len(lines), jsonl.String())
+	}
+
+	t.Run("ドライランは記録しない", func(t *testing.T) {
+		c.DryRun = &bytes.Buffer{}
+		defer func() { c.DryRun = nil }()
+		if err := c.DeleteRiskAcceptance("ra-1"); err != nil {
+			t.Fatalf("DeleteRiskAcceptance failed: %v", err)
+		}
+		entries, err := db.GetAuditEntries(database.AuditQuery{AcceptanceID: "ra-1"})
+		if err != nil {
+			t.Fatalf("GetAuditEntries failed: %v", err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("Expected only the real revoke, got %d entries", len(entries))
+		}
+	})
+
+	t.Run("表形式", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := WriteTable(&out, entries); err != nil {
+			t.Fatalf("WriteTable failed: %v", err)
+		}
+		for _, want := range []string{"operator", "revoke", "ra-1", "Total: 3 entries"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("Expected %q in output:\n%s", want, out.String())
+			}
+		}
+	})
+}
@@ -3,8 +3,10 @@ package client
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,8 +17,34 @@ import (
 // CSPMClient wraps the base Sysdig client for CSPM-specific operations
 type CSPMClient struct {
 	*sysdig.Client
+
+	// DryRun receives the requests that would change the tenant (creating and revoking risk
+	// acceptances) instead of sending them. Read requests are still sent.
+	DryRun io.Writer
+	// Auditor is notified of every request sent to change the tenant, successful or not
+	Auditor Auditor
+}
+
+// Change is a request sent to change the tenant
+type Change struct {
+	Method   string
+	Endpoint string
+	Request  interface{}
+	// AcceptanceID is the revoked risk acceptance, or the created one when the request succeeded
+	AcceptanceID string
+	// StatusCode is 0 when no response was received
+	StatusCode int
+	Err        error
+}
+
+// Auditor records the changes made through a CSPMClient
+type Auditor interface {
+	RecordChange(change Change)
 }
 
+// DryRunAcceptanceID is the ID of the acceptances returned by CreateRiskAcceptance in dry-run mode
+const DryRunAcceptanceID = "dry-run"
+
 // NewCSPMClient creates a new CSPM client
 func NewCSPMClient(apiURL, apiToken string) *CSPMClient {
 	return &CSPMClient{
@@ -24,6 +52,13 @@ func NewCSPMClient(apiURL, apiToken string) *CSPMClient {
 	}
 }
 
+// NewCSPMClientWithEndpoints creates a new CSPM client with explicit base URLs
+func NewCSPMClientWithEndpoints(endpoints sysdig.Endpoints, apiToken string) *CSPMClient {
+	return &CSPMClient{
+		Client: sysdig.NewClientWithEndpoints(endpoints, apiToken),
+	}
+}
+
 // GetComplianceRequirements retrieves compliance requirements with violations
 func (c *CSPMClient) GetComplianceRequirements(filter string) (*models.ComplianceResponse, error) {
 	return c.GetComplianceRequirementsPaginated(filter, 0, 0)
@@ -461,9 +496,14 @@ func (c *CSPMClient) GetAllCloudResources(endpoint string, pageSize, batchSize,
 
 // ListRiskAcceptances retrieves all risk acceptances with automatic pagination
 func (c *CSPMClient) ListRiskAcceptances() ([]models.RiskAcceptance, error) {
+	return c.ListRiskAcceptancesUntil(nil, 3) // 3秒間隔
+}
+
+// ListRiskAcceptancesUntil pages risk acceptances newest first and stops at the first one for which
+// stop returns true; that acceptance and the older ones are not returned. A nil stop lists all of them.
+func (c *CSPMClient) ListRiskAcceptancesUntil(stop func(models.RiskAcceptance) bool, apiDelay int) ([]models.RiskAcceptance, error) {
 	endpoint := "/api/cspm/v1/compliance/violations/acceptances/search"
 	pageSize := 50
-	apiDelay := 3 // 3秒間隔
 
 	// 最初のページを取得してtotalCountを確認
 	firstRequest := models.RiskAcceptanceSearchRequest{
@@ -487,15 +527,20 @@ func (c *CSPMClient) ListRiskAcceptances() ([]models.RiskAcceptance, error) {
 
 	// 全データを格納するスライス
 	allData := make([]models.RiskAcceptance, 0, totalCount)
-	allData = append(allData, firstResponse.Data...)
+	allData, stopped := appendUntil(allData, firstResponse.Data, stop)
 
-	if totalPages <= 1 {
+	if stopped || totalPages <= 1 {
 		fmt.Println()
 		return allData, nil
 	}
 
 	// ページ2以降を順次取得（Rate Limit対策で並列処理はしない）
 	for page := 2; page <= totalPages; page++ {
+		// Rate Limit対策の遅延
+		if apiDelay > 0 {
+			time.Sleep(time.Duration(apiDelay) * time.Second)
+		}
+
 		request := models.RiskAcceptanceSearchRequest{
 			Filter:     "",
 			PageNumber: page,
@@ -509,12 +554,10 @@ func (c *CSPMClient) ListRiskAcceptances() ([]models.RiskAcceptance, error) {
 			return nil, fmt.Errorf("failed to get page %d: %w", page, err)
 		}
 
-		allData = append(allData, response.Data...)
+		allData, stopped = appendUntil(allData, response.Data, stop)
 		fmt.Printf("\r  Progress: %d/%d pages processed, %d risk acceptances collected", page, totalPages, len(allData))
-
-		// Rate Limit対策の遅延
-		if page < totalPages {
-			time.Sleep(time.Duration(apiDelay) * time.Second)
+		if stopped {
+			break
 		}
 	}
 
@@ -522,6 +565,17 @@ func (c *CSPMClient) ListRiskAcceptances() ([]models.RiskAcceptance, error) {
 	return allData, nil
 }
 
+// appendUntil appends the acceptances before the first one matching stop and reports whether one matched
+func appendUntil(dst, page []models.RiskAcceptance, stop func(models.RiskAcceptance) bool) ([]models.RiskAcceptance, bool) {
+	for _, a := range page {
+		if stop != nil && stop(a) {
+			return dst, true
+		}
+		dst = append(dst, a)
+	}
+	return dst, false
+}
+
 // searchRiskAcceptances performs a single risk acceptance search request
 func (c *CSPMClient) searchRiskAcceptances(endpoint string, request models.RiskAcceptanceSearchRequest) (*models.RiskAcceptanceSearchResponse, error) {
 	resp, err := c.Client.MakeRequest("POST", endpoint, request)
@@ -542,19 +596,71 @@ func (c *CSPMClient) searchRiskAcceptances(endpoint string, request models.RiskA
 	return &response, nil
 }
 
+// CreateRiskAcceptance creates a risk acceptance for a control
+func (c *CSPMClient) CreateRiskAcceptance(request models.RiskAcceptanceCreateRequest) (created *models.RiskAcceptance, err error) {
+	endpoint := "/api/cspm/v1/compliance/violations/acceptances"
+
+	if c.DryRun != nil {
+		if err := c.printDryRun("POST", endpoint, request); err != nil {
+			return nil, err
+		}
+		return dryRunAcceptance(request), nil
+	}
+
+	change := Change{Method: "POST", Endpoint: endpoint, Request: request}
+	defer func() {
+		if created != nil {
+			change.AcceptanceID = created.ID
+		}
+		change.Err = err
+		c.audit(change)
+	}()
+
+	resp, err := c.Client.MakeRequest("POST", endpoint, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create risk acceptance: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	change.StatusCode = resp.StatusCode
+
+	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var response models.RiskAcceptanceCreateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to parse risk acceptance response: %w", err)
+	}
+
+	acceptance := response.RiskAcceptance()
+	return &acceptance, nil
+}
+
 // DeleteRiskAcceptance deletes a risk acceptance by ID
-func (c *CSPMClient) DeleteRiskAcceptance(id string) error {
+func (c *CSPMClient) DeleteRiskAcceptance(id string) (err error) {
 	endpoint := "/api/cspm/v1/compliance/violations/revoke"
 
 	request := models.RiskAcceptanceDeleteRequest{
 		ID: id,
 	}
 
+	if c.DryRun != nil {
+		return c.printDryRun("POST", endpoint, request)
+	}
+
+	change := Change{Method: "POST", Endpoint: endpoint, Request: request, AcceptanceID: id}
+	defer func() {
+		change.Err = err
+		c.audit(change)
+	}()
+
 	resp, err := c.Client.MakeRequest("POST", endpoint, request)
 	if err != nil {
 		return fmt.Errorf("failed to delete risk acceptance: %w", err)
 	}
 	defer func() { _ = resp.Body.Close() }()
+	change.StatusCode = resp.StatusCode
 
 	if resp.StatusCode != 200 {
 		return fmt.Errorf("API request failed with status %d", resp.StatusCode)
@@ -562,3 +668,39 @@ func (c *CSPMClient) DeleteRiskAcceptance(id string) error {
 
 	return nil
 }
+
+// audit passes a change to the auditor, if any
+func (c *CSPMClient) audit(change Change) {
+	if c.Auditor != nil {
+		c.Auditor.RecordChange(change)
+	}
+}
+
+// printDryRun writes a request that is not sent because of dry-run mode
+func (c *CSPMClient) printDryRun(method, endpoint string, body interface{}) error {
+	data, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	if _, err := fmt.Fprintf(c.DryRun, "[DRY RUN] %s %s%s\n%s\n", method, c.Endpoints().APIURL, endpoint, data); err != nil {
+		return fmt.Errorf("failed to write dry-run request: %w", err)
+	}
+	return nil
+}
+
+// dryRunAcceptance is the acceptance a create request would return, with DryRunAcceptanceID as its ID
+func dryRunAcceptance(request models.RiskAcceptanceCreateRequest) *models.RiskAcceptance {
+	acceptance := models.RiskAcceptance{
+		ID:          DryRunAcceptanceID,
+		ControlID:   strconv.Itoa(request.ControlID),
+		Description: request.Description,
+		Reason:      request.Reason,
+		Filter:      request.Filter,
+		ExpiresAt:   request.ExpiresAt,
+		SourceID:    request.SourceID,
+	}
+	if request.ZoneID != 0 {
+		acceptance.ZoneID = strconv.Itoa(request.ZoneID)
+	}
+	return &acceptance
+}
@@ -1,9 +1,13 @@
 package client
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/internal/testutil"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
 )
 
 func TestNewCSPMClient(t *testing.T) {
@@ -161,3 +165,133 @@ func TestGetAllComplianceRequirements(t *testing.T) {
 		t.Error("Expected data but got empty array")
 	}
 }
+
+func TestCreateRiskAcceptance(t *testing.T) {
+	config := testutil.DefaultMockServerConfig()
+	server := testutil.NewMockServer(config)
+	defer server.Close()
+
+	client := NewCSPMClient(server.URL, "test-token")
+
+	acceptance, err := client.CreateRiskAcceptance(models.RiskAcceptanceCreateRequest{
+		ControlID: 16022,
+		Reason:    "Risk Owned",
+		Filter:    `name in ("bucket-a")`,
+		SourceID:  "123456789012",
+		ZoneID:    7,
+	})
+	if err != nil {
+		t.Fatalf("CreateRiskAcceptance failed: %v", err)
+	}
+	if acceptance.ID == "" || acceptance.ControlID != "16022" || acceptance.ZoneID != "7" {
+		t.Errorf("Unexpected acceptance: %+v", acceptance)
+	}
+
+	requests := config.RiskAcceptanceRequests()
+	if len(requests) != 1 || requests[0]["filter"] != `name in ("bucket-a")` {
+		t.Errorf("Unexpected requests: %v", requests)
+	}
+
+	t.Run("必須項目なし", func(t *testing.T) {
+		if _, err := client.CreateRiskAcceptance(models.RiskAcceptanceCreateRequest{ControlID: 16022}); err == nil {
+			t.Error("Expected error without reason")
+		}
+	})
+}
+
+func TestDeleteRiskAcceptance(t *testing.T) {
+	config := testutil.DefaultMockServerConfig()
+	server := testutil.NewMockServer(config)
+	defer server.Close()
+
+	client := NewCSPMClient(server.URL, "test-token")
+
+	if err := client.DeleteRiskAcceptance("mock-acceptance-1"); err != nil {
+		t.Fatalf("DeleteRiskAcceptance failed: %v", err)
+	}
+	if revoked := config.RevokedRiskAcceptances(); len(revoked) != 1 || revoked[0] != "mock-acceptance-1" {
+		t.Errorf("Unexpected revoked acceptances: %v", revoked)
+	}
+
+	t.Run("IDなし", func(t *testing.T) {
+		if err := client.DeleteRiskAcceptance(""); err == nil {
+			t.Error("Expected error without id")
+		}
+	})
+}
+
+func TestListRiskAcceptancesUntil(t *testing.T) {
+	config := testutil.DefaultMockServerConfig()
+	for i := 0; i < 120; i++ {
+		config.RiskAcceptances = append(config.RiskAcceptances, models.RiskAcceptance{
+			ID:             fmt.Sprintf("ra-%03d", i),
+			ControlID:      "16022",
+			AcceptanceDate: fmt.Sprint(1760000000000 - int64(i)*1000),
+		})
+	}
+	server := testutil.NewMockServer(config)
+	defer server.Close()
+
+	client := NewCSPMClient(server.URL, "test-token")
+
+	t.Run("全件", func(t *testing.T) {
+		acceptances, err := client.ListRiskAcceptancesUntil(nil, 0)
+		if err != nil {
+			t.Fatalf("ListRiskAcceptancesUntil failed: %v", err)
+		}
+		if len(acceptances) != 120 || acceptances[119].ID != "ra-119" {
+			t.Errorf("Expected all 120 acceptances, got %d", len(acceptances))
+		}
+	})
+
+	t.Run("既知の受容で停止", func(t *testing.T) {
+		before := len(config.RiskAcceptanceSearchPages())
+		acceptances, err := client.ListRiskAcceptancesUntil(func(a models.RiskAcceptance) bool { return a.ID == "ra-055" }, 0)
+		if err != nil {
+			t.Fatalf("ListRiskAcceptancesUntil failed: %v", err)
+		}
+		if len(acceptances) != 55 || acceptances[54].ID != "ra-054" {
+			t.Errorf("Expected the 55 acceptances newer than ra-055, got %d", len(acceptances))
+		}
+		// 3ページ目は取得しない
+		if pages := config.RiskAcceptanceSearchPages()[before:]; len(pages) != 2 {
+			t.Errorf("Expected 2 pages to be requested, got %v", pages)
+		}
+	})
+}
+
+func TestDryRun(t *testing.T) {
+	config := testutil.DefaultMockServerConfig()
+	server := testutil.NewMockServer(config)
+	defer server.Close()
+
+	var out bytes.Buffer
+	client := NewCSPMClient(server.URL, "test-token")
+	client.DryRun = &out
+
+	created, err := client.CreateRiskAcceptance(models.RiskAcceptanceCreateRequest{ControlID: 16022, Reason: "Risk Owned", Filter: `name in ("bucket-a")`, ZoneID: 7})
+	if err != nil {
+		t.Fatalf("CreateRiskAcceptance failed: %v", err)
+	}
+	if created.ID != DryRunAcceptanceID || created.ControlID != "16022" || created.ZoneID != "7" {
+		t.Errorf("Unexpected dry-run acceptance: %+v", created)
+	}
+	if err := client.DeleteRiskAcceptance("mock-acceptance-1"); err != nil {
+		t.Fatalf("DeleteRiskAcceptance failed: %v", err)
+	}
+
+	// APIには何も送信されない
+	if len(config.RiskAcceptanceRequests()) != 0 || len(config.RevokedRiskAcceptances()) != 0 {
+		t.Error("Expected no requests to reach the API in dry-run mode")
+	}
+	for _, want := range []string{
+		"[DRY RUN] POST " + server.URL + "/api/cspm/v1/compliance/violations/acceptances\n",
+		`"filter": "name in (\"bucket-a\")"`,
+		"[DRY RUN] POST " + server.URL + "/api/cspm/v1/compliance/violations/revoke\n",
+		`"id": "mock-acceptance-1"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected dry-run output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
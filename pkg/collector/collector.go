@@ -23,27 +23,47 @@ func NewComplianceCollector(cspmClient *client.CSPMClient, db *database.Database
 	}
 }
 
+// CollectionResult holds the outcome of a single collection run
+type CollectionResult struct {
+	Requirements      int
+	ControlsProcessed int
+	Resources         int
+	FailedResources   int
+	PassedResources   int
+	AcceptedResources int
+	// FailedControls is the number of controls whose resources could not be retrieved (API errors)
+	FailedControls int
+	Duration       time.Duration
+}
+
 // CollectComplianceData collects compliance requirements and associated resources
 func (cc *ComplianceCollector) CollectComplianceData(policyFilter string, pageSize, batchSize, apiDelay int) error {
+	_, err := cc.Collect(policyFilter, pageSize, batchSize, apiDelay)
+	return err
+}
+
+// Collect collects compliance requirements and associated resources and returns the run result
+func (cc *ComplianceCollector) Collect(policyFilter string, pageSize, batchSize, apiDelay int) (*CollectionResult, error) {
+	start := time.Now()
+	result := &CollectionResult{}
+
 	// Step 1: Get compliance requirements with controls
 	fmt.Println("Step 1: Getting compliance requirements with controls...")
 	complianceResp, err := cc.client.GetAllComplianceRequirementsWithControls(policyFilter, pageSize, batchSize, apiDelay)
 	if err != nil {
-		return fmt.Errorf("failed to get compliance requirements: %w", err)
+		return nil, fmt.Errorf("failed to get compliance requirements: %w", err)
 	}
 
 	fmt.Printf("  Retrieved %d requirements\n", len(complianceResp.Data))
+	result.Requirements = len(complianceResp.Data)
 
 	// Save requirements and controls to DB
 	if err := cc.db.SaveComplianceRequirementsWithControls(complianceResp.Data); err != nil {
-		return fmt.Errorf("failed to save compliance requirements: %w", err)
+		return nil, fmt.Errorf("failed to save compliance requirements: %w", err)
 	}
 
 	// Step 2: Get resources for each control
 	fmt.Println("\nStep 2: Getting resources for each control...")
-	totalControls := 0
-	totalResources := 0
-	failedControlsCount := 0
 
 	for reqIdx, req := range complianceResp.Data {
 		fmt.Printf("\n[%d/%d] Processing requirement: %s\n", reqIdx+1, len(complianceResp.Data), req.Name)
@@ -54,7 +74,7 @@ func (cc *ComplianceCollector) CollectComplianceData(policyFilter string, pageSi
 		}
 
 		for ctrlIdx, ctrl := range req.Controls {
-			totalControls++
+			result.ControlsProcessed++
 			fmt.Printf("  [%d/%d] Control %s: %s\n", ctrlIdx+1, len(req.Controls), ctrl.ID, ctrl.Name)
 
 			// Skip controls with no resourceApiEndpoint
@@ -68,7 +88,7 @@ func (cc *ComplianceCollector) CollectComplianceData(policyFilter string, pageSi
 			resources, err := cc.client.GetAllCloudResources(ctrl.ResourceAPIEndpoint, pageSize, batchSize, apiDelay)
 			if err != nil {
 				fmt.Printf("    [WARN] Failed to get resources: %v\n", err)
-				failedControlsCount++
+				result.FailedControls++
 				continue
 			}
 
@@ -88,20 +108,23 @@ func (cc *ComplianceCollector) CollectComplianceData(policyFilter string, pageSi
 
 			fmt.Printf("    Retrieved %d resources in %s (Failed: %d, Passed: %d, Accepted: %d)\n",
 				len(resources.Data), duration.Round(time.Millisecond), failed, passed, accepted)
+			result.FailedResources += failed
+			result.PassedResources += passed
+			result.AcceptedResources += accepted
 
 			// Save resources to DB
 			if len(resources.Data) > 0 {
 				if err := cc.db.SaveCloudResources(resources.Data); err != nil {
-					return fmt.Errorf("failed to save resources: %w", err)
+					return nil, fmt.Errorf("failed to save resources: %w", err)
 				}
 
 				// Save control-resource relations
 				if err := cc.db.SaveControlResourceRelations(ctrl.ID, resources.Data); err != nil {
-					return fmt.Errorf("failed to save control-resource relations: %w", err)
+					return nil, fmt.Errorf("failed to save control-resource relations: %w", err)
 				}
 			}
 
-			totalResources += len(resources.Data)
+			result.Resources += len(resources.Data)
 
 			// Delay between API calls to avoid rate limiting
 			if apiDelay > 0 {
@@ -110,15 +133,17 @@ func (cc *ComplianceCollector) CollectComplianceData(policyFilter string, pageSi
 		}
 	}
 
+	result.Duration = time.Since(start)
+
 	fmt.Printf("\n=== Summary ===\n")
-	fmt.Printf("Total requirements: %d\n", len(complianceResp.Data))
-	fmt.Printf("Total controls processed: %d\n", totalControls)
-	fmt.Printf("Total resources collected: %d\n", totalResources)
-	if failedControlsCount > 0 {
-		fmt.Printf("Failed controls (warnings): %d\n", failedControlsCount)
+	fmt.Printf("Total requirements: %d\n", result.Requirements)
+	fmt.Printf("Total controls processed: %d\n", result.ControlsProcessed)
+	fmt.Printf("Total resources collected: %d\n", result.Resources)
+	if result.FailedControls > 0 {
+		fmt.Printf("Failed controls (warnings): %d\n", result.FailedControls)
 	}
 
-	return nil
+	return result, nil
 }
 
 // CollectComplianceDataWithStats collects compliance data and returns statistics
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BuildFilter constructs a Sysdig CSPM API filter string from CLI parameters
+func BuildFilter(policies, platform, zoneName string, includePass bool) string {
+	conditions := []string{}
+
+	// passフィルター（include-passフラグがfalseの場合のみ追加）
+	if !includePass {
+		conditions = append(conditions, `pass = "false"`)
+	}
+
+	// ポリシーフィルター（複数対応、部分一致）
+	if policies != "" {
+		policyList := strings.Split(policies, ",")
+		policyConditions := []string{}
+		for _, p := range policyList {
+			p = strings.TrimSpace(p)
+			if p != "" {
+				policyConditions = append(policyConditions,
+					fmt.Sprintf(`policy.name contains "%s"`, p))
+			}
+		}
+		if len(policyConditions) > 0 {
+			conditions = append(conditions,
+				fmt.Sprintf("(%s)", strings.Join(policyConditions, " or ")))
+		}
+	}
+
+	// プラットフォームフィルター（完全一致）
+	if platform != "" {
+		conditions = append(conditions,
+			fmt.Sprintf(`platform = "%s"`, platform))
+	}
+
+	// ゾーンフィルター（完全一致、in演算子）
+	if zoneName != "" {
+		conditions = append(conditions,
+			fmt.Sprintf(`zone.name in ("%s")`, zoneName))
+	}
+
+	return strings.Join(conditions, " and ")
+}
@@ -0,0 +1,36 @@
+package collector
+
+import "testing"
+
+func TestBuildFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		policies    string
+		platform    string
+		zone        string
+		includePass bool
+		want        string
+	}{
+		{"条件なし", "", "", "", true, ""},
+		{"failedのみ", "", "", "", false, `pass = "false"`},
+		{
+			"複数ポリシー",
+			"CIS, SOC 2", "", "", false,
+			`pass = "false" and (policy.name contains "CIS" or policy.name contains "SOC 2")`,
+		},
+		{
+			"全条件",
+			"CIS", "AWS", "Entire Infrastructure", false,
+			`pass = "false" and (policy.name contains "CIS") and platform = "AWS" and zone.name in ("Entire Infrastructure")`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildFilter(tt.policies, tt.platform, tt.zone, tt.includePass)
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
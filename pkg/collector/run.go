@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/notify"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/ownership"
+)
+
+// Options describes a collection into a database file
+type Options struct {
+	// Target is the plan target name (empty for a single collect)
+	Target    string
+	Policy    string
+	Platform  string
+	Zone      string
+	PageSize  int
+	BatchSize int
+	APIDelay  int
+	// Ownership assigns the collected resources to teams (nil keeps the owners table unchanged)
+	Ownership *ownership.Config
+	// Notify posts the new and resolved violations to webhooks (nil sends nothing)
+	Notify *notify.Config
+}
+
+// CollectToDatabase collects into dbPath while holding the database's collection lock
+// and records the run in collection_runs, whether it succeeded or not.
+// It fails with database.ErrLocked when another collection into the same file is running.
+func CollectToDatabase(cspmClient *client.CSPMClient, dbPath string, opts Options) (*CollectionResult, error) {
+	if dir := filepath.Dir(dbPath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	lock, err := database.AcquireLock(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = lock.Release() }()
+
+	db, err := database.NewDatabase(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	// Build filter (failed only)
+	filter := BuildFilter(opts.Policy, opts.Platform, opts.Zone, false)
+	fmt.Printf("API Filter: %s\n\n", filter)
+
+	run := &database.CollectionRun{
+		Target:    opts.Target,
+		Policy:    opts.Policy,
+		Platform:  opts.Platform,
+		Zone:      opts.Zone,
+		StartedAt: time.Now(),
+	}
+
+	result, collectErr := NewComplianceCollector(cspmClient, db).Collect(filter, opts.PageSize, opts.BatchSize, opts.APIDelay)
+
+	run.FinishedAt = time.Now()
+	run.Status = database.RunStatusSuccess
+	if collectErr != nil {
+		run.Status = database.RunStatusFailed
+		run.Error = collectErr.Error()
+	}
+	if result != nil {
+		run.Requirements = result.Requirements
+		run.Controls = result.ControlsProcessed
+		run.Resources = result.Resources
+		run.APIErrors = result.FailedControls
+	}
+
+	if err := db.RecordCollectionRun(run); err != nil {
+		// 収集自体は完了しているため履歴の保存失敗は警告に留める
+		fmt.Printf("[WARN] %v\n", err)
+	} else if collectErr == nil {
+		// チーム別の件数もスナップショットに含めるため先に所有者を更新する
+		if opts.Ownership != nil {
+			if _, err := ownership.Resolve(db, opts.Ownership, run.FinishedAt); err != nil {
+				fmt.Printf("[WARN] %v\n", err)
+			}
+		}
+		// trendコマンド用に収集後の件数を保存する
+		if err := db.SavePostureSnapshot(run.ID, run.FinishedAt); err != nil {
+			fmt.Printf("[WARN] %v\n", err)
+		}
+		// 同じDBにリスク受容がある場合は対象リソースを更新する
+		if _, err := db.ResolveAcceptanceMatches(""); err != nil {
+			fmt.Printf("[WARN] %v\n", err)
+		}
+		// 通知の失敗で収集を失敗させない（失敗したWebhookの基準は更新されないため次回に再通知される）
+		if opts.Notify != nil {
+			result, err := notify.New(*opts.Notify).Run(db, dbPath)
+			if result != nil {
+				fmt.Printf("Notifications: %s\n", result.Summary())
+			}
+			if err != nil {
+				fmt.Printf("[WARN] Notification failed: %v\n", err)
+			}
+		}
+	}
+
+	if collectErr != nil {
+		return nil, fmt.Errorf("failed to collect compliance data: %w", collectErr)
+	}
+
+	return result, nil
+}
@@ -4,27 +4,212 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/sysdig"
 )
 
+// DefaultAPIURL is the Sysdig API base URL used when nothing else is configured
+const DefaultAPIURL = "https://us2.app.sysdig.com"
+
 // Config holds the configuration for the Sysdig vulnerability tool
 type Config struct {
 	APIToken string `json:"api_token"`
-	APIURL   string `json:"api_url"`
+	// TokenFile is a file containing the token
+	TokenFile string `json:"token_file,omitempty"`
+	// TokenCommand is a shell command printing the token on stdout (e.g. a password manager CLI)
+	TokenCommand string `json:"token_command,omitempty"`
+	APIURL       string `json:"api_url"`
+	// SecureAPIURL is the public API URL for /secure/ endpoints (derived from APIURL when empty)
+	SecureAPIURL string `json:"secure_api_url,omitempty"`
+	// Region selects APIURL and SecureAPIURL from the Sysdig SaaS region table (us1, us2, eu1, ...)
+	Region   string   `json:"region,omitempty"`
+	DBPath   string   `json:"db_path,omitempty"`
+	Zone     string   `json:"zone,omitempty"`
+	Policies []string `json:"policies,omitempty"`
+
+	// DefaultProfile is used when no profile is selected explicitly
+	DefaultProfile string             `json:"default_profile,omitempty"`
+	Profiles       map[string]Profile `json:"profiles,omitempty"`
+
+	// Daemon configures scheduled collection for the daemon command
+	Daemon *DaemonConfig `json:"daemon,omitempty"`
+
+	// Server configures the serve command
+	Server *ServerConfig `json:"server,omitempty"`
+
+	// RiskLint configures the rules of the risk-lint command (defaults when omitted); decoded by
+	// the command with DecodeSection
+	RiskLint json.RawMessage `json:"risk_lint,omitempty"`
+
+	// AuditLog is a JSON lines file receiving the audit log of write operations as well
+	AuditLog string `json:"audit_log,omitempty"`
+
+	// Scorecard configures the severity weights and grades of the scorecard command (defaults when
+	// omitted); decoded by the command with DecodeSection
+	Scorecard json.RawMessage `json:"scorecard,omitempty"`
+
+	// Ownership maps the collected resources to teams (no owners when omitted); decoded by the
+	// commands with DecodeSection
+	Ownership json.RawMessage `json:"ownership,omitempty"`
+
+	// Notify posts new and resolved violations to webhooks after every collection (none when
+	// omitted); decoded by the commands with DecodeSection
+	Notify json.RawMessage `json:"notify,omitempty"`
+
+	// Profile is the name of the profile applied by LoadProfile (empty when none)
+	Profile string `json:"-"`
+	// TokenSource describes where APIToken was taken from
+	TokenSource string `json:"-"`
+
+	// pendingTokenFile is read by ResolveToken when the token comes from a file
+	pendingTokenFile string
+	// pendingTokenCommand is run by ResolveToken when the token comes from a command
+	pendingTokenCommand string
+}
+
+// Profile holds the settings for one Sysdig tenant/region
+type Profile struct {
+	APIURL       string `json:"api_url,omitempty"`
+	SecureAPIURL string `json:"secure_api_url,omitempty"`
+	Region       string `json:"region,omitempty"`
+	APIToken     string `json:"api_token,omitempty"`
+	// APITokenEnv is the name of the environment variable holding the token
+	APITokenEnv string `json:"api_token_env,omitempty"`
+	// TokenFile is a file containing the token
+	TokenFile string `json:"token_file,omitempty"`
+	// TokenCommand is a shell command printing the token on stdout (e.g. a password manager CLI)
+	TokenCommand string   `json:"token_command,omitempty"`
+	DBPath       string   `json:"db_path,omitempty"`
+	Zone         string   `json:"zone,omitempty"`
+	Policies     []string `json:"policies,omitempty"`
+}
+
+// DaemonConfig holds the jobs run by the daemon command
+type DaemonConfig struct {
+	// HealthAddr is the listen address of the health endpoint (e.g. ":8080"); empty disables it
+	HealthAddr string          `json:"health_addr,omitempty"`
+	Jobs       []DaemonJob     `json:"jobs"`
+	Retention  RetentionConfig `json:"retention,omitempty"`
+}
+
+// DaemonJob runs a collection plan on a cron-style schedule
+type DaemonJob struct {
+	Name string `json:"name"`
+	// Plan is the path of the collection plan YAML file (re-read on every run)
+	Plan string `json:"plan"`
+	// Schedule is a cron expression ("0 2 * * *") or a descriptor ("@daily", "@every 6h")
+	Schedule string `json:"schedule"`
+	// RunOnStart also runs the job once when the daemon starts
+	RunOnStart bool `json:"run_on_start,omitempty"`
+}
+
+// RetentionConfig controls what the daemon prunes after each run
+type RetentionConfig struct {
+	// KeepSnapshots keeps the newest N snapshot directories of plans using {timestamp} (0 keeps all)
+	KeepSnapshots int `json:"keep_snapshots,omitempty"`
+	// MaxAgeDays removes snapshot directories and collection_runs rows older than this (0 keeps all)
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+}
+
+// ServerConfig holds the settings of the serve command
+type ServerConfig struct {
+	// APIKeys are the static keys accepted by the REST API
+	APIKeys []APIKey `json:"api_keys"`
+}
+
+// APIKey is a named static API key; the name is logged instead of the key
+type APIKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key,omitempty"`
+	// KeyEnv is the name of the environment variable holding the key
+	KeyEnv string `json:"key_env,omitempty"`
+}
+
+// ResolveAPIKeys returns the configured keys mapped to their names.
+// Keys from key_env are read from the environment; an unset variable is an error.
+func (s *ServerConfig) ResolveAPIKeys() (map[string]string, error) {
+	keys := make(map[string]string)
+	if s == nil {
+		return keys, nil
+	}
+
+	names := make(map[string]bool)
+	for i, k := range s.APIKeys {
+		if k.Name == "" {
+			return nil, fmt.Errorf("api_keys[%d]: name is required", i)
+		}
+		if names[k.Name] {
+			return nil, fmt.Errorf("api_keys[%d]: duplicate name %q", i, k.Name)
+		}
+		names[k.Name] = true
+
+		key := k.Key
+		if k.KeyEnv != "" {
+			if key != "" {
+				return nil, fmt.Errorf("api key %q: key and key_env are mutually exclusive", k.Name)
+			}
+			key = os.Getenv(k.KeyEnv)
+			if key == "" {
+				return nil, fmt.Errorf("api key %q: environment variable %s is not set", k.Name, k.KeyEnv)
+			}
+		}
+		if key == "" {
+			return nil, fmt.Errorf("api key %q: key or key_env is required", k.Name)
+		}
+		if _, dup := keys[key]; dup {
+			return nil, fmt.Errorf("api key %q: same key as %q", k.Name, keys[key])
+		}
+		keys[key] = k.Name
+	}
+
+	return keys, nil
+}
+
+// Overrides holds values given on the command line; empty fields are ignored
+type Overrides struct {
+	Profile      string
+	APIToken     string
+	APIURL       string
+	SecureAPIURL string
+	Region       string
 }
 
 // Load configuration from file, command line flags, or environment variables
 // Priority: command line flags > config file > environment variables > defaults
 func Load(configFile, apiToken, apiURL string) (*Config, error) {
+	return LoadProfile(configFile, "", apiToken, apiURL)
+}
+
+// LoadProfile loads configuration like Load and applies the named profile from the config file.
+// Priority: command line flags > profile > config file > environment variables > defaults
+// When profileName is empty, SYSDIG_PROFILE and then default_profile from the file are used.
+func LoadProfile(configFile, profileName, apiToken, apiURL string) (*Config, error) {
+	return LoadWithOverrides(configFile, Overrides{
+		Profile:  profileName,
+		APIToken: apiToken,
+		APIURL:   apiURL,
+	})
+}
+
+// LoadWithOverrides loads configuration and applies command line overrides.
+// Within each layer a region sets both base URLs, and explicit URLs take precedence over the region.
+func LoadWithOverrides(configFile string, o Overrides) (*Config, error) {
 	cfg := &Config{
-		APIURL: "https://us2.app.sysdig.com", // default
+		APIURL: DefaultAPIURL, // default
 	}
 
 	// Load from environment variables first
 	if token := os.Getenv("SYSDIG_API_TOKEN"); token != "" {
-		cfg.APIToken = token
+		cfg.setToken(token, "environment (SYSDIG_API_TOKEN)")
+	}
+	if err := cfg.applyEndpoints(os.Getenv("SYSDIG_REGION"), os.Getenv("SYSDIG_API_URL"), os.Getenv("SYSDIG_SECURE_API_URL")); err != nil {
+		return nil, fmt.Errorf("invalid environment: %w", err)
 	}
-	if url := os.Getenv("SYSDIG_API_URL"); url != "" {
-		cfg.APIURL = url
+	profileName := o.Profile
+	if profileName == "" {
+		profileName = os.Getenv("SYSDIG_PROFILE")
 	}
 
 	// Load from config file if provided
@@ -33,25 +218,140 @@ func Load(configFile, apiToken, apiURL string) (*Config, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to load config file: %w", err)
 		}
-		if fileConfig.APIToken != "" {
-			cfg.APIToken = fileConfig.APIToken
+		cfg.applyTokenSources("config file", fileConfig.APIToken, fileConfig.TokenFile, fileConfig.TokenCommand, "")
+		if err := cfg.applyEndpoints(fileConfig.Region, fileConfig.APIURL, fileConfig.SecureAPIURL); err != nil {
+			return nil, fmt.Errorf("invalid config file: %w", err)
 		}
-		if fileConfig.APIURL != "" {
-			cfg.APIURL = fileConfig.APIURL
+		if fileConfig.DBPath != "" {
+			cfg.DBPath = fileConfig.DBPath
+		}
+		if fileConfig.Zone != "" {
+			cfg.Zone = fileConfig.Zone
+		}
+		if len(fileConfig.Policies) > 0 {
+			cfg.Policies = fileConfig.Policies
+		}
+		cfg.DefaultProfile = fileConfig.DefaultProfile
+		cfg.Profiles = fileConfig.Profiles
+		cfg.Daemon = fileConfig.Daemon
+		cfg.Server = fileConfig.Server
+		cfg.RiskLint = fileConfig.RiskLint
+		cfg.AuditLog = fileConfig.AuditLog
+		cfg.Scorecard = fileConfig.Scorecard
+		cfg.Ownership = fileConfig.Ownership
+		cfg.Notify = fileConfig.Notify
+
+		if profileName == "" {
+			profileName = fileConfig.DefaultProfile
+		}
+	}
+
+	// Apply the selected profile
+	if profileName != "" {
+		profile, ok := cfg.Profiles[profileName]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q (available: %s)", profileName, strings.Join(cfg.ProfileNames(), ", "))
+		}
+		if err := cfg.applyProfile(profileName, profile); err != nil {
+			return nil, fmt.Errorf("invalid profile %q: %w", profileName, err)
 		}
 	}
 
 	// Override with command line flags if provided
-	if apiToken != "" {
-		cfg.APIToken = apiToken
+	if o.APIToken != "" {
+		cfg.setToken(o.APIToken, "command line (-token)")
 	}
-	if apiURL != "" {
-		cfg.APIURL = apiURL
+	if err := cfg.applyEndpoints(o.Region, o.APIURL, o.SecureAPIURL); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
 	return cfg, nil
 }
 
+// applyEndpoints applies one configuration layer of region and base URLs
+func (c *Config) applyEndpoints(region, apiURL, secureAPIURL string) error {
+	if region != "" {
+		r, err := sysdig.LookupRegion(region)
+		if err != nil {
+			return err
+		}
+		c.Region = r.Name
+		c.APIURL = r.APIURL
+		c.SecureAPIURL = r.SecureAPIURL
+	}
+	if apiURL != "" {
+		c.APIURL = apiURL
+		// 同じレイヤーでsecure_api_urlが指定されていなければURLから再導出する
+		if region == "" {
+			c.Region = ""
+			c.SecureAPIURL = ""
+		}
+	}
+	if secureAPIURL != "" {
+		c.SecureAPIURL = secureAPIURL
+	}
+	return nil
+}
+
+// Validate checks that the configured base URLs are usable
+func (c *Config) Validate() error {
+	if _, err := sysdig.ResolveEndpoints(c.APIURL, c.SecureAPIURL); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+// Endpoints returns the resolved base URLs for the Sysdig API client
+func (c *Config) Endpoints() (sysdig.Endpoints, error) {
+	return sysdig.ResolveEndpoints(c.APIURL, c.SecureAPIURL)
+}
+
+// applyProfile overrides the configuration with non-empty profile settings
+func (c *Config) applyProfile(name string, p Profile) error {
+	c.Profile = name
+
+	if err := c.applyEndpoints(p.Region, p.APIURL, p.SecureAPIURL); err != nil {
+		return err
+	}
+	c.applyTokenSources(fmt.Sprintf("profile %q", name), p.APIToken, p.TokenFile, p.TokenCommand, p.APITokenEnv)
+	if p.DBPath != "" {
+		c.DBPath = p.DBPath
+	}
+	if p.Zone != "" {
+		c.Zone = p.Zone
+	}
+	if len(p.Policies) > 0 {
+		c.Policies = p.Policies
+	}
+	return nil
+}
+
+// ProfileNames returns the configured profile names in sorted order
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DecodeSection decodes a feature section of the config file into v, so that this package does
+// not depend on the feature packages. It returns false when the section is omitted.
+func DecodeSection(name string, raw json.RawMessage, v interface{}) (bool, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return false, nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return false, fmt.Errorf("invalid %s section: %w", name, err)
+	}
+	return true, nil
+}
+
 func loadFromFile(filename string) (*Config, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// tokenCommandTimeout limits how long a token_command may run
+const tokenCommandTimeout = 30 * time.Second
+
+// applyTokenSources applies the token settings of one configuration layer.
+// Within a layer the first configured source wins: api_token, token_file, token_command, api_token_env.
+// token_file and token_command are not read here but deferred to ResolveToken so that local
+// commands neither invoke the command nor fail on a missing file.
+func (c *Config) applyTokenSources(layer, apiToken, tokenFile, tokenCommand, tokenEnv string) {
+	switch {
+	case apiToken != "":
+		c.setToken(apiToken, layer)
+	case tokenFile != "":
+		c.setPending(tokenFile, "", fmt.Sprintf("token_file %s (%s)", tokenFile, layer))
+	case tokenCommand != "":
+		c.setPending("", tokenCommand, fmt.Sprintf("token_command (%s)", layer))
+	case tokenEnv != "":
+		if token := os.Getenv(tokenEnv); token != "" {
+			c.setToken(token, fmt.Sprintf("environment (%s)", tokenEnv))
+		}
+	}
+}
+
+// setToken sets a resolved token and cancels any pending token file or command
+func (c *Config) setToken(token, source string) {
+	c.APIToken = token
+	c.TokenSource = source
+	c.pendingTokenFile = ""
+	c.pendingTokenCommand = ""
+}
+
+// setPending defers the token to a file or command read by ResolveToken
+func (c *Config) setPending(tokenFile, tokenCommand, source string) {
+	c.APIToken = ""
+	c.TokenSource = source
+	c.pendingTokenFile = tokenFile
+	c.pendingTokenCommand = tokenCommand
+}
+
+// ResolveToken reads the configured token_file or runs the token_command if the token has not
+// been resolved yet
+func (c *Config) ResolveToken() error {
+	var token string
+	var err error
+	switch {
+	case c.pendingTokenFile != "":
+		token, err = readTokenFile(c.pendingTokenFile)
+	case c.pendingTokenCommand != "":
+		token, err = runTokenCommand(c.pendingTokenCommand)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	c.APIToken = token
+	c.pendingTokenFile = ""
+	c.pendingTokenCommand = ""
+	return nil
+}
+
+// HasPendingToken reports whether the token will be obtained from token_file or token_command
+func (c *Config) HasPendingToken() bool {
+	return c.pendingTokenFile != "" || c.pendingTokenCommand != ""
+}
+
+// readTokenFile reads a token from a file, ignoring surrounding whitespace
+func readTokenFile(filename string) (string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token_file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token_file %s is empty", filename)
+	}
+
+	return token, nil
+}
+
+// runTokenCommand runs a credential helper through the shell and returns its stdout
+func runTokenCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// 標準出力にトークンが含まれている可能性があるため、エラーには標準エラーのみを含める
+		detail := strings.TrimSpace(stderr.String())
+		if len(detail) > 200 {
+			detail = detail[:200] + "..."
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("token_command timed out after %s", tokenCommandTimeout)
+		}
+		if detail != "" {
+			return "", fmt.Errorf("token_command failed: %v: %s", err, RedactSecrets(detail, stdout.String()))
+		}
+		return "", fmt.Errorf("token_command failed: %w", err)
+	}
+
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("token_command printed no token")
+	}
+	if strings.ContainsAny(token, "\r\n") {
+		return "", fmt.Errorf("token_command printed multiple lines, expected only the token")
+	}
+
+	return token, nil
+}
@@ -0,0 +1,340 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/plan"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/schedule"
+)
+
+// Job run statuses
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	// StatusSkipped is recorded when a job is due while its previous run is still in progress
+	StatusSkipped = "skipped"
+)
+
+// historySize is the number of job runs kept in memory for the health endpoint
+const historySize = 100
+
+// JobRun is one execution of a daemon job
+type JobRun struct {
+	Job           string    `json:"job"`
+	StartedAt     time.Time `json:"started_at"`
+	FinishedAt    time.Time `json:"finished_at"`
+	Status        string    `json:"status"`
+	Targets       int       `json:"targets"`
+	FailedTargets int       `json:"failed_targets"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// job holds the state of one configured job
+type job struct {
+	config.DaemonJob
+	schedule schedule.Schedule
+	next     time.Time
+	running  bool
+	lastRun  *JobRun
+}
+
+// ClientFactory creates the API client used for one job run
+type ClientFactory func() *client.CSPMClient
+
+// Daemon runs collection plans on schedules
+type Daemon struct {
+	cfg       config.DaemonConfig
+	newClient ClientFactory
+	base      plan.ResolvedTarget
+	startedAt time.Time
+
+	mu      sync.Mutex
+	jobs    []*job
+	history []JobRun
+	wg      sync.WaitGroup
+}
+
+// New validates the daemon configuration and creates a daemon.
+// base holds the fallback settings for plan targets (zone, page size, batch size, API delay).
+func New(cfg config.DaemonConfig, newClient ClientFactory, base plan.ResolvedTarget) (*Daemon, error) {
+	if len(cfg.Jobs) == 0 {
+		return nil, fmt.Errorf("no daemon jobs configured")
+	}
+
+	d := &Daemon{cfg: cfg, newClient: newClient, base: base}
+	seen := make(map[string]bool)
+	for i, j := range cfg.Jobs {
+		if j.Name == "" {
+			return nil, fmt.Errorf("jobs[%d]: name is required", i)
+		}
+		if seen[j.Name] {
+			return nil, fmt.Errorf("jobs[%d]: duplicate job name %q", i, j.Name)
+		}
+		seen[j.Name] = true
+
+		s, err := schedule.Parse(j.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("job %q: %w", j.Name, err)
+		}
+		// プランの誤りは起動時に検出する（実行時にも再読み込みする）
+		if _, err := plan.Load(j.Plan); err != nil {
+			return nil, fmt.Errorf("job %q: %w", j.Name, err)
+		}
+
+		d.jobs = append(d.jobs, &job{DaemonJob: j, schedule: s})
+	}
+
+	return d, nil
+}
+
+// Run schedules jobs until ctx is cancelled, then waits for running jobs to finish
+func (d *Daemon) Run(ctx context.Context) error {
+	d.startedAt = time.Now()
+
+	var server *http.Server
+	if d.cfg.HealthAddr != "" {
+		server = &http.Server{Addr: d.cfg.HealthAddr, Handler: d.Handler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			log.Printf("Health endpoint listening on %s/healthz", d.cfg.HealthAddr)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("[ERROR] Health endpoint stopped: %v", err)
+			}
+		}()
+	}
+
+	d.mu.Lock()
+	now := time.Now()
+	for _, j := range d.jobs {
+		j.next = j.schedule.Next(now)
+		log.Printf("Job %s scheduled (%s), next run at %s", j.Name, j.Schedule, j.next.Format(time.RFC3339))
+	}
+	d.mu.Unlock()
+
+	for _, j := range d.jobs {
+		if j.RunOnStart {
+			d.startJob(j)
+		}
+	}
+
+	for {
+		timer := time.NewTimer(time.Until(d.nextWakeup()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Println("Shutting down, waiting for running jobs to finish...")
+			d.wg.Wait()
+			if server != nil {
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				_ = server.Shutdown(shutdownCtx)
+			}
+			return nil
+		case <-timer.C:
+			d.startDueJobs(time.Now())
+		}
+	}
+}
+
+// nextWakeup returns the earliest next run time of all jobs
+func (d *Daemon) nextWakeup() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var next time.Time
+	for _, j := range d.jobs {
+		if j.next.IsZero() {
+			continue
+		}
+		if next.IsZero() || j.next.Before(next) {
+			next = j.next
+		}
+	}
+	if next.IsZero() {
+		// 実行可能なスケジュールがない場合も定期的に起きる
+		return time.Now().Add(time.Hour)
+	}
+	return next
+}
+
+// startDueJobs starts every job whose next run time has passed
+func (d *Daemon) startDueJobs(now time.Time) {
+	var due []*job
+	d.mu.Lock()
+	for _, j := range d.jobs {
+		if !j.next.IsZero() && !j.next.After(now) {
+			due = append(due, j)
+			j.next = j.schedule.Next(now)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, j := range due {
+		d.startJob(j)
+	}
+}
+
+// startJob runs the job in the background unless its previous run is still in progress
+func (d *Daemon) startJob(j *job) {
+	d.mu.Lock()
+	if j.running {
+		d.mu.Unlock()
+		log.Printf("[WARN] Job %s is still running, skipping this run", j.Name)
+		now := time.Now()
+		d.record(j, JobRun{Job: j.Name, StartedAt: now, FinishedAt: now, Status: StatusSkipped, Error: "previous run still in progress"})
+		return
+	}
+	j.running = true
+	d.wg.Add(1)
+	d.mu.Unlock()
+
+	go func() {
+		defer d.wg.Done()
+		run := d.RunJob(j.DaemonJob)
+
+		d.mu.Lock()
+		j.running = false
+		d.mu.Unlock()
+		d.record(j, run)
+	}()
+}
+
+// RunJob runs a job once: the plan is re-read, collected and retention is applied afterwards
+func (d *Daemon) RunJob(j config.DaemonJob) JobRun {
+	started := time.Now()
+	run := JobRun{Job: j.Name, StartedAt: started, Status: StatusSuccess}
+	log.Printf("Job %s started (plan %s)", j.Name, j.Plan)
+
+	p, err := plan.Load(j.Plan)
+	if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+		run.FinishedAt = time.Now()
+		log.Printf("[ERROR] Job %s: %v", j.Name, err)
+		return run
+	}
+
+	summary := p.Run(d.newClient(), d.base, started)
+	run.Targets = len(summary.Results)
+	run.FailedTargets = summary.Failed()
+	if run.FailedTargets > 0 {
+		run.Status = StatusFailed
+		run.Error = fmt.Sprintf("%d of %d targets failed", run.FailedTargets, run.Targets)
+	}
+
+	retention, err := ApplyRetention(p, p.Resolve(d.base, started), d.cfg.Retention, started)
+	if err != nil {
+		log.Printf("[WARN] Job %s retention: %v", j.Name, err)
+	}
+	if retention != nil {
+		for _, path := range retention.RemovedSnapshots {
+			log.Printf("Job %s retention: removed snapshot %s", j.Name, path)
+		}
+		if retention.PrunedRuns > 0 {
+			log.Printf("Job %s retention: pruned %d collection runs", j.Name, retention.PrunedRuns)
+		}
+	}
+
+	run.FinishedAt = time.Now()
+	log.Printf("Job %s finished: %s (%d/%d targets succeeded) in %s", j.Name, run.Status,
+		run.Targets-run.FailedTargets, run.Targets, run.FinishedAt.Sub(started).Round(time.Second))
+	return run
+}
+
+// record stores a finished run in the job state and the in-memory history
+func (d *Daemon) record(j *job, run JobRun) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if run.Status != StatusSkipped {
+		j.lastRun = &run
+	}
+	d.history = append(d.history, run)
+	if len(d.history) > historySize {
+		d.history = d.history[len(d.history)-historySize:]
+	}
+}
+
+// JobStatus is the health view of one job
+type JobStatus struct {
+	Name     string     `json:"name"`
+	Plan     string     `json:"plan"`
+	Schedule string     `json:"schedule"`
+	Running  bool       `json:"running"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	LastRun  *JobRun    `json:"last_run,omitempty"`
+}
+
+// Health is the response of the health endpoint
+type Health struct {
+	// Status is "ok", or "degraded" when the last run of any job failed
+	Status    string      `json:"status"`
+	StartedAt time.Time   `json:"started_at"`
+	Jobs      []JobStatus `json:"jobs"`
+}
+
+// Health returns the current job states
+func (d *Daemon) Health() Health {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	h := Health{Status: "ok", StartedAt: d.startedAt}
+	for _, j := range d.jobs {
+		s := JobStatus{Name: j.Name, Plan: j.Plan, Schedule: j.Schedule, Running: j.running}
+		if !j.next.IsZero() {
+			next := j.next
+			s.NextRun = &next
+		}
+		if j.lastRun != nil {
+			last := *j.lastRun
+			s.LastRun = &last
+			if last.Status == StatusFailed {
+				h.Status = "degraded"
+			}
+		}
+		h.Jobs = append(h.Jobs, s)
+	}
+
+	return h
+}
+
+// History returns the most recent job runs, oldest first
+func (d *Daemon) History() []JobRun {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	history := make([]JobRun, len(d.history))
+	copy(history, d.history)
+	return history
+}
+
+// Handler serves /healthz (503 when degraded) and /runs
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		h := d.Health()
+		status := http.StatusOK
+		if h.Status != "ok" {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, h)
+	})
+	mux.HandleFunc("/runs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, d.History())
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
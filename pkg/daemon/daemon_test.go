@@ -0,0 +1,166 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/internal/testutil"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/plan"
+)
+
+// writePlan writes a single-target plan collecting into dir/cis.db
+func writePlan(t *testing.T, dir string) string {
+	t.Helper()
+	path := filepath.Join(dir, "plan.yaml")
+	content := "output_dir: " + dir + "\ndefaults:\n  page_size: 10\n  batch_size: 2\n  api_delay: 0\ntargets:\n  - name: cis\n    policy: CIS\n    db: cis.db\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write plan: %v", err)
+	}
+	return path
+}
+
+func newTestDaemon(t *testing.T, jobs []config.DaemonJob) *Daemon {
+	t.Helper()
+	mockServer := testutil.NewMockServer(testutil.DefaultMockServerConfig())
+	t.Cleanup(mockServer.Close)
+
+	d, err := New(config.DaemonConfig{Jobs: jobs}, func() *client.CSPMClient {
+		return client.NewCSPMClient(mockServer.URL, "test-token")
+	}, plan.ResolvedTarget{Zone: "Entire Infrastructure"})
+	if err != nil {
+		t.Fatalf("Failed to create daemon: %v", err)
+	}
+	return d
+}
+
+func TestNew_Invalid(t *testing.T) {
+	planPath := writePlan(t, t.TempDir())
+
+	tests := []struct {
+		name    string
+		jobs    []config.DaemonJob
+		wantErr string
+	}{
+		{"ジョブなし", nil, "no daemon jobs"},
+		{"名前なし", []config.DaemonJob{{Plan: planPath, Schedule: "@daily"}}, "name is required"},
+		{"名前の重複", []config.DaemonJob{{Name: "a", Plan: planPath, Schedule: "@daily"}, {Name: "a", Plan: planPath, Schedule: "@daily"}}, "duplicate"},
+		{"不正なスケジュール", []config.DaemonJob{{Name: "a", Plan: planPath, Schedule: "every day"}}, "schedule"},
+		{"存在しないプラン", []config.DaemonJob{{Name: "a", Plan: "missing.yaml", Schedule: "@daily"}}, "plan"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(config.DaemonConfig{Jobs: tt.jobs}, nil, plan.ResolvedTarget{})
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	dir := t.TempDir()
+	job := config.DaemonJob{Name: "nightly", Plan: writePlan(t, dir), Schedule: "@daily"}
+	d := newTestDaemon(t, []config.DaemonJob{job})
+
+	run := d.RunJob(job)
+	if run.Status != StatusSuccess {
+		t.Fatalf("Expected success, got %s (%s)", run.Status, run.Error)
+	}
+	if run.Targets != 1 {
+		t.Errorf("Expected 1 target, got %d", run.Targets)
+	}
+
+	// 実行履歴がDBに記録されていること
+	db, err := database.NewDatabase(filepath.Join(dir, "cis.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	last, err := db.GetLastCollectionRun()
+	if err != nil {
+		t.Fatalf("Failed to get last run: %v", err)
+	}
+	if last == nil || last.Target != "cis" || last.Status != database.RunStatusSuccess {
+		t.Errorf("Unexpected recorded run: %+v", last)
+	}
+}
+
+func TestRunJob_LockedDatabase(t *testing.T) {
+	dir := t.TempDir()
+	job := config.DaemonJob{Name: "nightly", Plan: writePlan(t, dir), Schedule: "@daily"}
+	d := newTestDaemon(t, []config.DaemonJob{job})
+
+	// 別プロセスの収集が実行中の状態を再現
+	lock, err := database.AcquireLock(filepath.Join(dir, "cis.db"))
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	defer lock.Release()
+
+	run := d.RunJob(job)
+	if run.Status != StatusFailed || run.FailedTargets != 1 {
+		t.Errorf("Expected the locked target to fail, got %+v", run)
+	}
+}
+
+func TestStartJob_SkipsOverlappingRun(t *testing.T) {
+	job := config.DaemonJob{Name: "nightly", Plan: writePlan(t, t.TempDir()), Schedule: "@daily"}
+	d := newTestDaemon(t, []config.DaemonJob{job})
+
+	// 実行中フラグを立てた状態で起動すると、スキップとして記録される
+	d.jobs[0].running = true
+	d.startJob(d.jobs[0])
+
+	history := d.History()
+	if len(history) != 1 || history[0].Status != StatusSkipped {
+		t.Errorf("Expected a skipped run, got %+v", history)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	job := config.DaemonJob{Name: "nightly", Plan: writePlan(t, t.TempDir()), Schedule: "@daily"}
+	d := newTestDaemon(t, []config.DaemonJob{job})
+	d.jobs[0].next = time.Now().Add(time.Hour)
+
+	server := httptest.NewServer(d.Handler())
+	defer server.Close()
+
+	check := func(wantCode int, wantStatus string) {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/healthz")
+		if err != nil {
+			t.Fatalf("Failed to get /healthz: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var h Health
+		if err := json.NewDecoder(resp.Body).Decode(&h); err != nil {
+			t.Fatalf("Failed to decode health: %v", err)
+		}
+		if resp.StatusCode != wantCode || h.Status != wantStatus {
+			t.Errorf("Expected %d/%s, got %d/%s", wantCode, wantStatus, resp.StatusCode, h.Status)
+		}
+		if len(h.Jobs) != 1 || h.Jobs[0].NextRun == nil {
+			t.Errorf("Expected job with next run, got %+v", h.Jobs)
+		}
+	}
+
+	check(http.StatusOK, "ok")
+
+	d.record(d.jobs[0], JobRun{Job: "nightly", Status: StatusFailed, Error: "boom"})
+	check(http.StatusServiceUnavailable, "degraded")
+}
@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/plan"
+)
+
+// RetentionResult reports what retention removed
+type RetentionResult struct {
+	RemovedSnapshots []string
+	PrunedRuns       int64
+}
+
+// snapshot is a directory created from an output_dir containing {timestamp}
+type snapshot struct {
+	path string
+	time time.Time
+}
+
+// ApplyRetention prunes old snapshot directories of a plan and old collection_runs rows of its databases.
+// The snapshot of the current run (started at now) is never removed.
+func ApplyRetention(p *plan.Plan, targets []plan.ResolvedTarget, retention config.RetentionConfig, now time.Time) (*RetentionResult, error) {
+	result := &RetentionResult{}
+	if retention.KeepSnapshots <= 0 && retention.MaxAgeDays <= 0 {
+		return result, nil
+	}
+
+	var cutoff time.Time
+	if retention.MaxAgeDays > 0 {
+		cutoff = now.AddDate(0, 0, -retention.MaxAgeDays)
+	}
+
+	if strings.Contains(p.OutputDir, plan.TimestampPlaceholder) {
+		snapshots, err := findSnapshots(p.OutputDir)
+		if err != nil {
+			return result, err
+		}
+
+		current := filepath.Clean(p.ResolveOutputDir(now))
+		for i, s := range snapshots {
+			if s.path == current {
+				continue
+			}
+			tooMany := retention.KeepSnapshots > 0 && i >= retention.KeepSnapshots
+			tooOld := !cutoff.IsZero() && s.time.Before(cutoff)
+			if !tooMany && !tooOld {
+				continue
+			}
+			if err := os.RemoveAll(s.path); err != nil {
+				return result, fmt.Errorf("failed to remove snapshot %s: %w", s.path, err)
+			}
+			result.RemovedSnapshots = append(result.RemovedSnapshots, s.path)
+		}
+	}
+
+	if !cutoff.IsZero() {
+		seen := make(map[string]bool)
+		for _, t := range targets {
+			if seen[t.DBPath] {
+				continue
+			}
+			seen[t.DBPath] = true
+
+			if _, err := os.Stat(t.DBPath); err != nil {
+				continue
+			}
+			pruned, err := pruneRuns(t.DBPath, cutoff)
+			if err != nil {
+				return result, err
+			}
+			result.PrunedRuns += pruned
+		}
+	}
+
+	return result, nil
+}
+
+// findSnapshots lists the existing snapshot directories for an output_dir pattern, newest first
+func findSnapshots(outputDir string) ([]snapshot, error) {
+	pattern := filepath.Clean(outputDir)
+	matcher, err := snapshotMatcher(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(strings.ReplaceAll(pattern, plan.TimestampPlaceholder, "*"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid output_dir %q: %w", outputDir, err)
+	}
+
+	var snapshots []snapshot
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+
+		// {timestamp}部分がタイムスタンプ形式でないディレクトリは対象外
+		groups := matcher.FindStringSubmatch(m)
+		if groups == nil {
+			continue
+		}
+		t, err := time.ParseInLocation(plan.TimestampFormat, groups[1], time.Local)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot{path: m, time: t})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].time.After(snapshots[j].time)
+	})
+
+	return snapshots, nil
+}
+
+// snapshotMatcher builds a regexp capturing the timestamp of a snapshot path
+func snapshotMatcher(pattern string) (*regexp.Regexp, error) {
+	parts := strings.Split(pattern, plan.TimestampPlaceholder)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("output_dir %q must contain %s exactly once for retention", pattern, plan.TimestampPlaceholder)
+	}
+	return regexp.Compile("^" + regexp.QuoteMeta(parts[0]) + `(\d{8}_\d{6})` + regexp.QuoteMeta(parts[1]) + "$")
+}
+
+func pruneRuns(dbPath string, before time.Time) (int64, error) {
+	db, err := database.NewDatabase(dbPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s for retention: %w", dbPath, err)
+	}
+	defer func() { _ = db.Close() }()
+
+	return db.PruneCollectionRuns(before)
+}
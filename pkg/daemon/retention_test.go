@@ -0,0 +1,89 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/config"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/plan"
+)
+
+func TestApplyRetention_Snapshots(t *testing.T) {
+	root := t.TempDir()
+	now := time.Date(2025, 3, 1, 2, 0, 0, 0, time.Local)
+
+	// 5日分のスナップショットと無関係なディレクトリを作成
+	var dirs []string
+	for i := 0; i < 5; i++ {
+		dir := filepath.Join(root, now.AddDate(0, 0, -i).Format(plan.TimestampFormat))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create snapshot: %v", err)
+		}
+		dirs = append(dirs, dir)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "manual"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	p := &plan.Plan{OutputDir: filepath.Join(root, plan.TimestampPlaceholder)}
+
+	tests := []struct {
+		name      string
+		retention config.RetentionConfig
+		wantKept  int
+	}{
+		{"保持数", config.RetentionConfig{KeepSnapshots: 3}, 3},
+		{"保持期間", config.RetentionConfig{MaxAgeDays: 1}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, dir := range dirs {
+				_ = os.MkdirAll(dir, 0755)
+			}
+
+			result, err := ApplyRetention(p, nil, tt.retention, now)
+			if err != nil {
+				t.Fatalf("Failed to apply retention: %v", err)
+			}
+			if len(result.RemovedSnapshots) != len(dirs)-tt.wantKept {
+				t.Errorf("Expected %d removed snapshots, got %v", len(dirs)-tt.wantKept, result.RemovedSnapshots)
+			}
+
+			// 最新のスナップショットと無関係なディレクトリは残る
+			for _, keep := range []string{dirs[0], filepath.Join(root, "manual")} {
+				if _, err := os.Stat(keep); err != nil {
+					t.Errorf("Expected %s to be kept", keep)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyRetention_CollectionRuns(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cis.db")
+	db, err := database.NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	now := time.Now()
+	for _, started := range []time.Time{now.AddDate(0, 0, -10), now} {
+		if err := db.RecordCollectionRun(&database.CollectionRun{StartedAt: started, FinishedAt: started, Status: database.RunStatusSuccess}); err != nil {
+			t.Fatalf("Failed to record run: %v", err)
+		}
+	}
+	db.Close()
+
+	p := &plan.Plan{}
+	targets := []plan.ResolvedTarget{{Name: "cis", DBPath: dbPath}}
+	result, err := ApplyRetention(p, targets, config.RetentionConfig{MaxAgeDays: 7}, now)
+	if err != nil {
+		t.Fatalf("Failed to apply retention: %v", err)
+	}
+	if result.PrunedRuns != 1 {
+		t.Errorf("Expected 1 pruned run, got %d", result.PrunedRuns)
+	}
+}
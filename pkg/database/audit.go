@@ -0,0 +1,139 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const createAuditLogTable = `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		recorded_at TIMESTAMP NOT NULL,
+		os_user TEXT,                         -- ツールを実行したOSユーザー
+		profile TEXT,
+		api_url TEXT,                         -- 変更したテナントのAPI URL
+		command TEXT,                         -- risk-delete, risk-renew など
+		method TEXT NOT NULL,
+		endpoint TEXT NOT NULL,
+		request_json TEXT,
+		status_code INTEGER,                  -- レスポンスがない場合は0
+		error TEXT,
+		acceptance_id TEXT,
+		before_json TEXT                      -- 変更前の受容（DBに収集済みの場合）
+	)`
+
+// audit_log is append-only; the triggers reject changes to recorded entries
+const createAuditLogTriggers = `
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_log is append-only');
+	END;
+	CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit_log is append-only');
+	END;`
+
+// AuditEntry is one write operation sent to the Sysdig API
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	RecordedAt time.Time `json:"recorded_at"`
+	OSUser     string    `json:"os_user"`
+	Profile    string    `json:"profile,omitempty"`
+	APIURL     string    `json:"api_url"`
+	Command    string    `json:"command"`
+	Method     string    `json:"method"`
+	Endpoint   string    `json:"endpoint"`
+	// Request is the JSON payload that was sent
+	Request      string `json:"request,omitempty"`
+	StatusCode   int    `json:"status_code"`
+	Error        string `json:"error,omitempty"`
+	AcceptanceID string `json:"acceptance_id,omitempty"`
+	// Before is the JSON of the acceptance as collected before the change (empty when unknown)
+	Before string `json:"before,omitempty"`
+}
+
+// AuditQuery selects audit entries; zero fields match everything
+type AuditQuery struct {
+	AcceptanceID string
+	OSUser       string
+	Since        time.Time
+}
+
+// AppendAuditEntry adds an entry to audit_log and sets its ID
+func (d *Database) AppendAuditEntry(e *AuditEntry) error {
+	result, err := d.db.Exec(`
+		INSERT INTO audit_log (
+			recorded_at, os_user, profile, api_url, command, method, endpoint,
+			request_json, status_code, error, acceptance_id, before_json
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.RecordedAt.UTC(), nullString(e.OSUser), nullString(e.Profile), nullString(e.APIURL), nullString(e.Command),
+		e.Method, e.Endpoint, nullString(e.Request), e.StatusCode, nullString(e.Error),
+		nullString(e.AcceptanceID), nullString(e.Before),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get audit entry ID: %w", err)
+	}
+	e.ID = id
+	return nil
+}
+
+// GetAuditEntries returns the matching audit entries, oldest first
+func (d *Database) GetAuditEntries(q AuditQuery) ([]AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	if q.AcceptanceID != "" {
+		conditions = append(conditions, "acceptance_id = ?")
+		args = append(args, q.AcceptanceID)
+	}
+	if q.OSUser != "" {
+		conditions = append(conditions, "os_user = ?")
+		args = append(args, q.OSUser)
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "recorded_at >= ?")
+		args = append(args, q.Since.UTC())
+	}
+
+	query := `
+		SELECT id, recorded_at, os_user, profile, api_url, command, method, endpoint,
+		       request_json, status_code, error, acceptance_id, before_json
+		FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY recorded_at, id"
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var osUser, profile, apiURL, command, request, errText, acceptanceID, before sql.NullString
+		if err := rows.Scan(&e.ID, &e.RecordedAt, &osUser, &profile, &apiURL, &command, &e.Method, &e.Endpoint,
+			&request, &e.StatusCode, &errText, &acceptanceID, &before); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		e.OSUser = osUser.String
+		e.Profile = profile.String
+		e.APIURL = apiURL.String
+		e.Command = command.String
+		e.Request = request.String
+		e.Error = errText.String
+		e.AcceptanceID = acceptanceID.String
+		e.Before = before.String
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now().Truncate(time.Second)
+	for _, e := range []*AuditEntry{
+		{RecordedAt: now.Add(-48 * time.Hour), OSUser: "alice", Method: "POST", Endpoint: "/revoke", AcceptanceID: "a1", StatusCode: 200},
+		{RecordedAt: now, OSUser: "bob", Method: "POST", Endpoint: "/acceptances", AcceptanceID: "a2", StatusCode: 200},
+	} {
+		if err := db.AppendAuditEntry(e); err != nil {
+			t.Fatalf("AppendAuditEntry failed: %v", err)
+		}
+	}
+
+	entries, err := db.GetAuditEntries(AuditQuery{Since: now.Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("GetAuditEntries failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].OSUser != "bob" || !entries[0].RecordedAt.Equal(now) {
+		t.Errorf("Expected only the recent entry, got %+v", entries)
+	}
+
+	entries, err = db.GetAuditEntries(AuditQuery{OSUser: "alice", AcceptanceID: "a1"})
+	if err != nil {
+		t.Fatalf("GetAuditEntries failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Endpoint != "/revoke" {
+		t.Errorf("Expected alice's entry, got %+v", entries)
+	}
+
+	t.Run("追記のみ", func(t *testing.T) {
+		if _, err := db.db.Exec("UPDATE audit_log SET os_user = 'mallory'"); err == nil {
+			t.Error("Expected updating the audit log to fail")
+		}
+		if _, err := db.db.Exec("DELETE FROM audit_log"); err == nil {
+			t.Error("Expected deleting from the audit log to fail")
+		}
+	})
+}
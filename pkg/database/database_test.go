@@ -394,6 +394,45 @@ func TestSaveComplianceRequirementsWithControls(t *testing.T) {
 	}
 }
 
+func TestGetControlAndZoneNames(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	requirements := []models.ComplianceRequirementWithControls{
+		{
+			RequirementID: "req-1",
+			Name:          "Test Requirement",
+			PolicyName:    "CIS Amazon Web Services Foundations Benchmark v3.0.0",
+			Severity:      "High",
+			Zone:          models.Zone{ID: "zone-1", Name: "Test Zone"},
+			Controls:      []models.Control{{ID: "ctrl-1", Name: "Test Control 1", Severity: "High"}},
+		},
+	}
+	if err := db.SaveComplianceRequirementsWithControls(requirements); err != nil {
+		t.Fatalf("Failed to save compliance requirements with controls: %v", err)
+	}
+
+	severities, err := db.GetControlSeverities()
+	if err != nil {
+		t.Fatalf("GetControlSeverities failed: %v", err)
+	}
+	if len(severities) != 1 || severities["ctrl-1"] != "High" {
+		t.Errorf("Unexpected control severities: %v", severities)
+	}
+
+	names, err := db.GetControlNames()
+	if err != nil || names["ctrl-1"] != "Test Control 1" {
+		t.Errorf("Unexpected control names: %v, %v", names, err)
+	}
+	zones, err := db.GetZoneNames()
+	if err != nil || len(zones) != 1 || zones["zone-1"] != "Test Zone" {
+		t.Errorf("Unexpected zone names: %v, %v", zones, err)
+	}
+}
+
 func TestSaveCloudResources(t *testing.T) {
 	dbPath := filepath.Join(t.TempDir(), "test.db")
 	db, err := NewDatabase(dbPath)
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/filter"
+)
+
+// inventoryColumns maps the fields of the inventory command to SQL over cloud_resources cr and owners o
+var inventoryColumns = map[string]string{
+	"hash":         "cr.hash",
+	"name":         "cr.name",
+	"type":         "cr.type",
+	"kind":         "cr.type",
+	"platform":     "COALESCE(cr.platform, '')",
+	"account":      "COALESCE(NULLIF(cr.account, ''), cr.platform_account_id, '')",
+	"location":     "COALESCE(NULLIF(cr.location, ''), cr.cloud_region, '')",
+	"region":       "COALESCE(NULLIF(cr.location, ''), cr.cloud_region, '')",
+	"organization": "COALESCE(cr.organization, '')",
+	"cluster":      "COALESCE(cr.cluster_name, '')",
+	"os":           "COALESCE(cr.os_name, '')",
+	"osImage":      "COALESCE(cr.os_image, '')",
+	"team":         "COALESCE(o.team, '')",
+}
+
+// severityNames maps the ranks of severityRank back to severities
+var severityNames = map[int]string{4: "Critical", 3: "High", 2: "Medium", 1: "Low"}
+
+// InventoryRow is one resource with the number of controls it fails, passes and has accepted
+type InventoryRow struct {
+	Hash     string `json:"hash"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Platform string `json:"platform"`
+	Account  string `json:"account"`
+	Location string `json:"location"`
+	Cluster  string `json:"cluster"`
+	OS       string `json:"os"`
+	Team     string `json:"team"`
+	Failed   int    `json:"failed"`
+	Passed   int    `json:"passed"`
+	Accepted int    `json:"accepted"`
+	// HighestSeverity is the severity of the most severe failing control; empty when nothing fails
+	HighestSeverity string `json:"highest_severity"`
+}
+
+// GetInventory returns the resources matching a filter such as platform = "AWS" and os = "linux",
+// worst first: by highest failing severity, then by number of failing controls.
+// An empty filter returns every resource.
+func (d *Database) GetInventory(filterExpr string) ([]InventoryRow, error) {
+	expr, err := filter.Parse(filterExpr)
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := filter.ToSQL(expr, inventoryColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	// 失敗しているコントロールの重要度のみを最大重要度に数える
+	query := fmt.Sprintf(`
+		SELECT cr.hash, cr.name, cr.type, %s, %s, %s, %s, %s, %s,
+		       COALESCE(s.failed, 0), COALESCE(s.passed, 0), COALESCE(s.accepted, 0), COALESCE(s.worst, 0)
+		FROM cloud_resources cr
+		LEFT JOIN (
+			SELECT rel.resource_hash,
+			       SUM(rel.acceptance_status = 'failed') AS failed,
+			       SUM(rel.acceptance_status = 'passed') AS passed,
+			       SUM(rel.acceptance_status = 'accepted') AS accepted,
+			       MAX(CASE WHEN rel.acceptance_status = 'failed' THEN %s ELSE 0 END) AS worst
+			FROM control_resource_relations rel
+			LEFT JOIN controls c ON c.control_id = rel.control_id
+			GROUP BY rel.resource_hash
+		) s ON s.resource_hash = cr.hash
+		LEFT JOIN owners o ON o.resource_hash = cr.hash`,
+		inventoryColumns["platform"], inventoryColumns["account"], inventoryColumns["location"],
+		inventoryColumns["cluster"], inventoryColumns["os"], inventoryColumns["team"], fmt.Sprintf(severityRank, "c.severity"))
+	if where != "" {
+		query += "\n\t\tWHERE " + where
+	}
+	query += "\n\t\tORDER BY COALESCE(s.worst, 0) DESC, COALESCE(s.failed, 0) DESC, COALESCE(s.accepted, 0) DESC, cr.name, cr.hash"
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query inventory: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var result []InventoryRow
+	for rows.Next() {
+		var r InventoryRow
+		var worst int
+		if err := rows.Scan(&r.Hash, &r.Name, &r.Type, &r.Platform, &r.Account, &r.Location, &r.Cluster, &r.OS, &r.Team,
+			&r.Failed, &r.Passed, &r.Accepted, &worst); err != nil {
+			return nil, fmt.Errorf("failed to scan resource: %w", err)
+		}
+		r.HighestSeverity = severityNames[worst]
+		result = append(result, r)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestGetInventory(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	requirements := []models.ComplianceRequirementWithControls{
+		{
+			RequirementID: "req-1",
+			Name:          "Ensure buckets are private",
+			PolicyName:    "CIS AWS",
+			Severity:      "High",
+			Controls: []models.Control{
+				{ID: "16022", Name: "Bucket ACL", Severity: "High"},
+				{ID: "16031", Name: "Bucket logging", Severity: "Low"},
+				{ID: "16040", Name: "Bucket versioning", Severity: "Medium"},
+			},
+		},
+	}
+	if err := db.SaveComplianceRequirementsWithControls(requirements); err != nil {
+		t.Fatalf("Failed to save requirements: %v", err)
+	}
+	resources := []models.CloudResource{
+		{Hash: "h1", Name: "bucket-a", Type: "S3 Bucket", Platform: "AWS", Account: "111", Location: "ap-northeast-1"},
+		{Hash: "h2", Name: "bucket-b", Type: "S3 Bucket", Platform: "AWS", Account: "111", Location: "us-east-1"},
+		{Hash: "h3", Name: "node-1", Type: "Host", Platform: "Kubernetes", PlatformAccountID: "222", CloudRegion: "ap-northeast-1", OSName: "linux", Passed: true},
+		{Hash: "h4", Name: "bucket-c", Type: "S3 Bucket", Platform: "AWS", Account: "333", Location: "us-east-1"},
+	}
+	if err := db.SaveCloudResources(resources); err != nil {
+		t.Fatalf("Failed to save resources: %v", err)
+	}
+	// h1: High と Low で失敗、h2: Low と Medium で失敗、h3: 合格、h4: 評価なし
+	if err := db.SaveControlResourceRelations("16022", resources[:1]); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+	if err := db.SaveControlResourceRelations("16031", resources[:3]); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+	if err := db.SaveControlResourceRelations("16040", resources[1:2]); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+
+	rows, err := db.GetInventory("")
+	if err != nil {
+		t.Fatalf("GetInventory failed: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("Expected 4 resources, got %+v", rows)
+	}
+	order := ""
+	for _, r := range rows {
+		order += r.Hash + " "
+	}
+	if order != "h1 h2 h4 h3 " {
+		t.Errorf("Expected the worst resources first, got %s", order)
+	}
+	if r := rows[0]; r.Failed != 2 || r.HighestSeverity != "High" {
+		t.Errorf("Unexpected summary of h1: %+v", r)
+	}
+	if r := rows[1]; r.Failed != 2 || r.HighestSeverity != "Medium" {
+		t.Errorf("Unexpected summary of h2: %+v", r)
+	}
+	if r := rows[3]; r.Passed != 1 || r.Failed != 0 || r.HighestSeverity != "" || r.Account != "222" || r.OS != "linux" {
+		t.Errorf("Unexpected summary of h3: %+v", r)
+	}
+
+	cases := map[string]int{
+		`platform = "AWS"`:                              3,
+		`account = "222" and os = "linux"`:              1,
+		`location = "us-east-1" and type = "S3 Bucket"`: 2,
+		`cluster = ""`:                                  4,
+	}
+	for filter, want := range cases {
+		rows, err := db.GetInventory(filter)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", filter, err)
+			continue
+		}
+		if len(rows) != want {
+			t.Errorf("%s: expected %d resources, got %d", filter, want, len(rows))
+		}
+	}
+
+	if _, err := db.GetInventory(`status = "failed"`); err == nil {
+		t.Error("Expected error for a field that is not a resource attribute")
+	}
+}
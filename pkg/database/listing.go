@@ -0,0 +1,561 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery is returned for unknown filters, non-sortable fields and invalid filter values
+var ErrInvalidQuery = errors.New("invalid query")
+
+// FieldKind is the JSON type of a listing field
+type FieldKind string
+
+// Field kinds
+const (
+	KindString FieldKind = "string"
+	KindInt    FieldKind = "integer"
+	KindBool   FieldKind = "boolean"
+	KindTime   FieldKind = "time"
+	// KindJSON is a column holding a JSON document, returned as-is
+	KindJSON FieldKind = "json"
+)
+
+// FilterMode is how a filter value is compared
+type FilterMode string
+
+// Filter modes
+const (
+	// FilterExact matches any of the comma-separated values
+	FilterExact FilterMode = "exact"
+	// FilterContains is a case-insensitive substring match
+	FilterContains FilterMode = "contains"
+	// FilterBool accepts true/false
+	FilterBool FilterMode = "bool"
+)
+
+// ListField is a column returned by a listing
+type ListField struct {
+	Name     string
+	Kind     FieldKind
+	Sortable bool
+	column   string
+	// sortColumn overrides column for ORDER BY (e.g. severity rank)
+	sortColumn string
+}
+
+// ListFilter is a query filter supported by a listing
+type ListFilter struct {
+	Name   string
+	Mode   FilterMode
+	column string
+	// subquery wraps the condition, e.g. "hash IN (SELECT resource_hash FROM ... WHERE %s)"
+	subquery string
+}
+
+// Listing describes a browsable table: its fields, filters and sort keys.
+// Only the columns declared here end up in SQL; filter values are always bound as parameters.
+type Listing struct {
+	Name        string
+	Key         string
+	DefaultSort []SortField
+	Fields      []ListField
+	Filters     []ListFilter
+	from        string
+}
+
+// SortField is one ORDER BY key
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// FilterValue is a filter given by the caller
+type FilterValue struct {
+	Name  string
+	Value string
+}
+
+// ListQuery selects a page of a listing
+type ListQuery struct {
+	Filters []FilterValue
+	Sort    []SortField
+	// Limit 0 returns all rows
+	Limit  int
+	Offset int
+}
+
+// Row is one listing row keyed by field name
+type Row map[string]interface{}
+
+// ListResult is a page of rows and the number of rows matching the filters
+type ListResult struct {
+	Rows  []Row
+	Total int
+}
+
+// severityRank orders severities from most to least severe
+const severityRank = "CASE lower(%s) WHEN 'critical' THEN 4 WHEN 'high' THEN 3 WHEN 'medium' THEN 2 WHEN 'low' THEN 1 ELSE 0 END"
+
+// Listings of the collected data
+var (
+	RequirementsListing = &Listing{
+		Name: "requirements",
+		Key:  "id",
+		from: "compliance_requirements",
+		DefaultSort: []SortField{
+			{Field: "policy_name"}, {Field: "severity", Desc: true}, {Field: "requirement_id"},
+		},
+		Fields: []ListField{
+			{Name: "id", Kind: KindInt, Sortable: true, column: "id"},
+			{Name: "requirement_id", Kind: KindString, Sortable: true, column: "requirement_id"},
+			{Name: "name", Kind: KindString, Sortable: true, column: "name"},
+			{Name: "policy_id", Kind: KindString, column: "policy_id"},
+			{Name: "policy_name", Kind: KindString, Sortable: true, column: "policy_name"},
+			{Name: "policy_type", Kind: KindString, column: "policy_type"},
+			{Name: "platform", Kind: KindString, Sortable: true, column: "platform"},
+			{Name: "severity", Kind: KindString, Sortable: true, column: "severity", sortColumn: fmt.Sprintf(severityRank, "severity")},
+			{Name: "pass", Kind: KindBool, Sortable: true, column: "pass"},
+			{Name: "zone_id", Kind: KindString, column: "zone_id"},
+			{Name: "zone_name", Kind: KindString, Sortable: true, column: "zone_name"},
+			{Name: "failed_controls", Kind: KindInt, Sortable: true, column: "failed_controls"},
+			{Name: "high_severity_count", Kind: KindInt, Sortable: true, column: "high_severity_count"},
+			{Name: "medium_severity_count", Kind: KindInt, Sortable: true, column: "medium_severity_count"},
+			{Name: "low_severity_count", Kind: KindInt, Sortable: true, column: "low_severity_count"},
+			{Name: "accepted_count", Kind: KindInt, Sortable: true, column: "accepted_count"},
+			{Name: "passing_count", Kind: KindInt, Sortable: true, column: "passing_count"},
+			{Name: "description", Kind: KindString, column: "description"},
+			{Name: "updated_at", Kind: KindTime, Sortable: true, column: "updated_at"},
+		},
+		Filters: []ListFilter{
+			{Name: "requirement_id", Mode: FilterExact, column: "requirement_id"},
+			{Name: "name", Mode: FilterContains, column: "name"},
+			{Name: "policy_id", Mode: FilterExact, column: "policy_id"},
+			{Name: "policy_name", Mode: FilterContains, column: "policy_name"},
+			{Name: "platform", Mode: FilterExact, column: "platform"},
+			{Name: "severity", Mode: FilterExact, column: "severity"},
+			{Name: "pass", Mode: FilterBool, column: "pass"},
+			{Name: "zone_name", Mode: FilterExact, column: "zone_name"},
+		},
+	}
+
+	ControlsListing = &Listing{
+		Name: "controls",
+		Key:  "control_id",
+		from: "controls",
+		DefaultSort: []SortField{
+			{Field: "requirement_id"}, {Field: "severity", Desc: true}, {Field: "control_id"},
+		},
+		Fields: []ListField{
+			{Name: "control_id", Kind: KindString, Sortable: true, column: "control_id"},
+			{Name: "name", Kind: KindString, Sortable: true, column: "name"},
+			{Name: "description", Kind: KindString, column: "description"},
+			{Name: "requirement_id", Kind: KindString, Sortable: true, column: "requirement_id"},
+			{Name: "severity", Kind: KindString, Sortable: true, column: "severity", sortColumn: fmt.Sprintf(severityRank, "severity")},
+			{Name: "pass", Kind: KindBool, Sortable: true, column: "pass"},
+			// objects_countはFailed数
+			{Name: "failed_count", Kind: KindInt, Sortable: true, column: "objects_count"},
+			{Name: "passing_count", Kind: KindInt, Sortable: true, column: "passing_count"},
+			{Name: "accepted_count", Kind: KindInt, Sortable: true, column: "accepted_count"},
+			{Name: "resource_kind", Kind: KindString, Sortable: true, column: "resource_kind"},
+			{Name: "target", Kind: KindString, Sortable: true, column: "target"},
+			{Name: "platform", Kind: KindString, Sortable: true, column: "platform"},
+		},
+		Filters: []ListFilter{
+			{Name: "control_id", Mode: FilterExact, column: "control_id"},
+			{Name: "name", Mode: FilterContains, column: "name"},
+			{Name: "requirement_id", Mode: FilterExact, column: "requirement_id"},
+			{Name: "severity", Mode: FilterExact, column: "severity"},
+			{Name: "pass", Mode: FilterBool, column: "pass"},
+			{Name: "resource_kind", Mode: FilterExact, column: "resource_kind"},
+			{Name: "target", Mode: FilterExact, column: "target"},
+			{Name: "platform", Mode: FilterExact, column: "platform"},
+			{Name: "policy_name", Mode: FilterContains, column: "policy_name",
+				subquery: "requirement_id IN (SELECT requirement_id FROM compliance_requirements WHERE %s)"},
+		},
+	}
+
+	ResourcesListing = &Listing{
+		Name:        "resources",
+		Key:         "hash",
+		from:        "cloud_resources",
+		DefaultSort: []SortField{{Field: "platform"}, {Field: "account"}, {Field: "name"}, {Field: "hash"}},
+		Fields: []ListField{
+			{Name: "hash", Kind: KindString, Sortable: true, column: "hash"},
+			{Name: "name", Kind: KindString, Sortable: true, column: "name"},
+			{Name: "type", Kind: KindString, Sortable: true, column: "type"},
+			{Name: "platform", Kind: KindString, Sortable: true, column: "platform"},
+			// Cluster Analysisのリソースはplatform_account_idをアカウントとして扱う
+			{Name: "account", Kind: KindString, Sortable: true, column: "COALESCE(NULLIF(account, ''), platform_account_id)"},
+			{Name: "location", Kind: KindString, Sortable: true, column: "COALESCE(NULLIF(location, ''), cloud_region)"},
+			{Name: "organization", Kind: KindString, column: "organization"},
+			{Name: "cluster_name", Kind: KindString, Sortable: true, column: "cluster_name"},
+			{Name: "team", Kind: KindString, Sortable: true, column: "(SELECT team FROM owners WHERE resource_hash = cloud_resources.hash)"},
+			{Name: "os_name", Kind: KindString, column: "os_name"},
+			{Name: "os_image", Kind: KindString, column: "os_image"},
+			{Name: "distribution_name", Kind: KindString, column: "distribution_name"},
+			{Name: "distribution_version", Kind: KindString, column: "distribution_version"},
+			{Name: "cloud_resource_id", Kind: KindString, column: "cloud_resource_id"},
+			{Name: "global_id", Kind: KindString, column: "global_id"},
+			{Name: "last_seen_date", Kind: KindString, Sortable: true, column: "last_seen_date"},
+			{Name: "zones", Kind: KindJSON, column: "zones_json"},
+			{Name: "labels", Kind: KindJSON, column: "label_values_json"},
+			{Name: "agent_tags", Kind: KindJSON, column: "agent_tags_json"},
+			{Name: "metadata", Kind: KindJSON, column: "additional_metadata_json"},
+		},
+		Filters: []ListFilter{
+			{Name: "hash", Mode: FilterExact, column: "hash"},
+			{Name: "name", Mode: FilterContains, column: "name"},
+			{Name: "type", Mode: FilterExact, column: "type"},
+			{Name: "platform", Mode: FilterExact, column: "platform"},
+			{Name: "account", Mode: FilterExact, column: "COALESCE(NULLIF(account, ''), platform_account_id)"},
+			{Name: "location", Mode: FilterExact, column: "COALESCE(NULLIF(location, ''), cloud_region)"},
+			{Name: "cluster_name", Mode: FilterExact, column: "cluster_name"},
+			{Name: "team", Mode: FilterExact, column: "team",
+				subquery: "hash IN (SELECT resource_hash FROM owners WHERE %s)"},
+			{Name: "control_id", Mode: FilterExact, column: "control_id",
+				subquery: "hash IN (SELECT resource_hash FROM control_resource_relations WHERE %s)"},
+			{Name: "status", Mode: FilterExact, column: "acceptance_status",
+				subquery: "hash IN (SELECT resource_hash FROM control_resource_relations WHERE %s)"},
+		},
+	}
+
+	RelationsListing = &Listing{
+		Name: "relations",
+		Key:  "id",
+		from: `control_resource_relations rel
+			LEFT JOIN controls c ON c.control_id = rel.control_id
+			LEFT JOIN cloud_resources cr ON cr.hash = rel.resource_hash
+			LEFT JOIN owners o ON o.resource_hash = rel.resource_hash`,
+		DefaultSort: []SortField{{Field: "control_id"}, {Field: "resource_name"}, {Field: "id"}},
+		Fields: []ListField{
+			{Name: "id", Kind: KindInt, Sortable: true, column: "rel.id"},
+			{Name: "control_id", Kind: KindString, Sortable: true, column: "rel.control_id"},
+			{Name: "control_name", Kind: KindString, Sortable: true, column: "c.name"},
+			{Name: "requirement_id", Kind: KindString, Sortable: true, column: "c.requirement_id"},
+			{Name: "resource_hash", Kind: KindString, Sortable: true, column: "rel.resource_hash"},
+			{Name: "resource_name", Kind: KindString, Sortable: true, column: "cr.name"},
+			{Name: "resource_type", Kind: KindString, Sortable: true, column: "cr.type"},
+			{Name: "account", Kind: KindString, Sortable: true, column: "COALESCE(NULLIF(cr.account, ''), cr.platform_account_id)"},
+			{Name: "location", Kind: KindString, Sortable: true, column: "COALESCE(NULLIF(cr.location, ''), cr.cloud_region)"},
+			{Name: "team", Kind: KindString, Sortable: true, column: "o.team"},
+			{Name: "passed", Kind: KindBool, Sortable: true, column: "rel.passed"},
+			{Name: "status", Kind: KindString, Sortable: true, column: "rel.acceptance_status"},
+			{Name: "acceptance_justification", Kind: KindString, column: "rel.acceptance_justification"},
+		},
+		Filters: []ListFilter{
+			{Name: "control_id", Mode: FilterExact, column: "rel.control_id"},
+			{Name: "requirement_id", Mode: FilterExact, column: "c.requirement_id"},
+			{Name: "resource_hash", Mode: FilterExact, column: "rel.resource_hash"},
+			{Name: "resource_name", Mode: FilterContains, column: "cr.name"},
+			{Name: "resource_type", Mode: FilterExact, column: "cr.type"},
+			{Name: "account", Mode: FilterExact, column: "COALESCE(NULLIF(cr.account, ''), cr.platform_account_id)"},
+			{Name: "location", Mode: FilterExact, column: "COALESCE(NULLIF(cr.location, ''), cr.cloud_region)"},
+			{Name: "team", Mode: FilterExact, column: "o.team"},
+			{Name: "passed", Mode: FilterBool, column: "rel.passed"},
+			{Name: "status", Mode: FilterExact, column: "rel.acceptance_status"},
+		},
+	}
+
+	RiskAcceptancesListing = &Listing{
+		Name:        "risk-acceptances",
+		Key:         "id",
+		from:        "risk_acceptances",
+		DefaultSort: []SortField{{Field: "acceptance_date", Desc: true}, {Field: "id"}},
+		Fields: []ListField{
+			{Name: "id", Kind: KindString, Sortable: true, column: "id"},
+			{Name: "control_id", Kind: KindString, Sortable: true, column: "control_id"},
+			{Name: "description", Kind: KindString, column: "description"},
+			{Name: "reason", Kind: KindString, Sortable: true, column: "reason"},
+			{Name: "acceptance_date", Kind: KindString, Sortable: true, column: "acceptance_date"},
+			{Name: "username", Kind: KindString, Sortable: true, column: "username"},
+			{Name: "user_display_name", Kind: KindString, column: "user_display_name"},
+			{Name: "filter", Kind: KindString, column: "filter"},
+			{Name: "zone_id", Kind: KindString, column: "zone_id"},
+			{Name: "accept_period", Kind: KindString, column: "accept_period"},
+			{Name: "expires_at", Kind: KindString, Sortable: true, column: "expires_at"},
+			{Name: "is_expired", Kind: KindBool, Sortable: true, column: "is_expired"},
+			{Name: "is_system", Kind: KindBool, column: "is_system"},
+			{Name: "type", Kind: KindInt, column: "type"},
+			{Name: "revoked_at", Kind: KindTime, Sortable: true, column: "revoked_at"},
+		},
+		Filters: []ListFilter{
+			{Name: "id", Mode: FilterExact, column: "id"},
+			{Name: "control_id", Mode: FilterExact, column: "control_id"},
+			{Name: "reason", Mode: FilterExact, column: "reason"},
+			{Name: "username", Mode: FilterExact, column: "username"},
+			{Name: "zone_id", Mode: FilterExact, column: "zone_id"},
+			{Name: "description", Mode: FilterContains, column: "description"},
+			{Name: "is_expired", Mode: FilterBool, column: "is_expired"},
+			{Name: "is_system", Mode: FilterBool, column: "is_system"},
+			// API側で取り消された受容（risk-collectで検出）
+			{Name: "revoked", Mode: FilterBool, column: "(revoked_at IS NOT NULL)"},
+		},
+	}
+)
+
+// Listings returns all listings in API order
+func Listings() []*Listing {
+	return []*Listing{RequirementsListing, ControlsListing, ResourcesListing, RelationsListing, RiskAcceptancesListing}
+}
+
+// Field returns the field with the given name
+func (l *Listing) Field(name string) (ListField, bool) {
+	for _, f := range l.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return ListField{}, false
+}
+
+// Filter returns the filter with the given name
+func (l *Listing) Filter(name string) (ListFilter, bool) {
+	for _, f := range l.Filters {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return ListFilter{}, false
+}
+
+// SortableFields returns the names of the fields usable as sort keys, sorted
+func (l *Listing) SortableFields() []string {
+	var names []string
+	for _, f := range l.Fields {
+		if f.Sortable {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FilterNames returns the names of the supported filters, sorted
+func (l *Listing) FilterNames() []string {
+	names := make([]string, 0, len(l.Filters))
+	for _, f := range l.Filters {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// List returns the rows of a listing matching the query
+func (d *Database) List(l *Listing, q ListQuery) (*ListResult, error) {
+	where, args, err := l.where(q.Filters)
+	if err != nil {
+		return nil, err
+	}
+	orderBy, err := l.orderBy(q.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ListResult{Rows: []Row{}}
+	countQuery := "SELECT COUNT(*) FROM " + l.from + where
+	if err := d.db.QueryRow(countQuery, args...).Scan(&result.Total); err != nil {
+		return nil, fmt.Errorf("failed to count %s: %w", l.Name, err)
+	}
+
+	columns := make([]string, len(l.Fields))
+	for i, f := range l.Fields {
+		columns[i] = f.column
+	}
+	query := "SELECT " + strings.Join(columns, ", ") + " FROM " + l.from + where + orderBy
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	} else if q.Offset > 0 {
+		query += " LIMIT -1 OFFSET ?"
+		args = append(args, q.Offset)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", l.Name, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		values := make([]interface{}, len(l.Fields))
+		ptrs := make([]interface{}, len(l.Fields))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", l.Name, err)
+		}
+
+		row := make(Row, len(l.Fields))
+		for i, f := range l.Fields {
+			row[f.Name] = convertValue(f.Kind, values[i])
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	return result, rows.Err()
+}
+
+// Get returns the row whose key field equals key, or nil when there is none
+func (d *Database) Get(l *Listing, key string) (Row, error) {
+	keyField, _ := l.Field(l.Key)
+	rows, err := d.List(&Listing{
+		Name:    l.Name,
+		Key:     l.Key,
+		Fields:  l.Fields,
+		Filters: []ListFilter{{Name: l.Key, Mode: FilterExact, column: keyField.column}},
+		from:    l.from,
+	}, ListQuery{Filters: []FilterValue{{Name: l.Key, Value: key}}, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows.Rows) == 0 {
+		return nil, nil
+	}
+	return rows.Rows[0], nil
+}
+
+// where builds the WHERE clause; filters are AND-ed and exact filters match any comma-separated value
+func (l *Listing) where(values []FilterValue) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+
+	for _, v := range values {
+		f, ok := l.Filter(v.Name)
+		if !ok {
+			return "", nil, fmt.Errorf("%w: unknown filter %q for %s (available: %s)", ErrInvalidQuery, v.Name, l.Name, strings.Join(l.FilterNames(), ", "))
+		}
+
+		var cond string
+		switch f.Mode {
+		case FilterExact:
+			items := strings.Split(v.Value, ",")
+			placeholders := make([]string, len(items))
+			for i, item := range items {
+				placeholders[i] = "?"
+				args = append(args, strings.TrimSpace(item))
+			}
+			cond = fmt.Sprintf("%s IN (%s)", f.column, strings.Join(placeholders, ", "))
+		case FilterContains:
+			cond = fmt.Sprintf(`%s LIKE ? ESCAPE '\'`, f.column)
+			args = append(args, "%"+likeEscaper.Replace(v.Value)+"%")
+		case FilterBool:
+			b, err := parseBool(v.Value)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: filter %q: %v", ErrInvalidQuery, v.Name, err)
+			}
+			cond = f.column + " = ?"
+			args = append(args, b)
+		}
+		if f.subquery != "" {
+			cond = fmt.Sprintf(f.subquery, cond)
+		}
+		conditions = append(conditions, "("+cond+")")
+	}
+
+	if len(conditions) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// orderBy builds the ORDER BY clause; the key is always appended so pages are stable
+func (l *Listing) orderBy(keys []SortField) (string, error) {
+	if len(keys) == 0 {
+		keys = l.DefaultSort
+	}
+
+	var parts []string
+	hasKey := false
+	for _, k := range keys {
+		f, ok := l.Field(k.Field)
+		if !ok || !f.Sortable {
+			return "", fmt.Errorf("%w: cannot sort %s by %q (sortable: %s)", ErrInvalidQuery, l.Name, k.Field, strings.Join(l.SortableFields(), ", "))
+		}
+		column := f.column
+		if f.sortColumn != "" {
+			column = f.sortColumn
+		}
+		direction := "ASC"
+		if k.Desc {
+			direction = "DESC"
+		}
+		parts = append(parts, column+" "+direction)
+		hasKey = hasKey || k.Field == l.Key
+	}
+	if !hasKey {
+		keyField, _ := l.Field(l.Key)
+		parts = append(parts, keyField.column+" ASC")
+	}
+
+	return " ORDER BY " + strings.Join(parts, ", "), nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "1", "yes":
+		return true, nil
+	case "false", "0", "no":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q (use true or false)", s)
+}
+
+// convertValue converts a scanned SQLite value to the JSON type of the field
+func convertValue(kind FieldKind, v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+
+	switch kind {
+	case KindString:
+		if v == nil {
+			return ""
+		}
+		if t, ok := v.(time.Time); ok {
+			return t.Format(time.RFC3339)
+		}
+		return fmt.Sprint(v)
+	case KindInt:
+		switch n := v.(type) {
+		case int64:
+			return n
+		case bool:
+			if n {
+				return int64(1)
+			}
+			return int64(0)
+		}
+		return nil
+	case KindBool:
+		switch b := v.(type) {
+		case bool:
+			return b
+		case int64:
+			return b != 0
+		}
+		return false
+	case KindTime:
+		switch t := v.(type) {
+		case time.Time:
+			return t.UTC().Format(time.RFC3339)
+		case string:
+			return t
+		}
+		return nil
+	case KindJSON:
+		s, ok := v.(string)
+		if !ok || s == "" || !json.Valid([]byte(s)) {
+			return nil
+		}
+		return json.RawMessage(s)
+	}
+	return v
+}
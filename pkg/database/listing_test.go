@@ -0,0 +1,200 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func newListingTestDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	requirements := []models.ComplianceRequirementWithControls{
+		{
+			RequirementID: "req-1",
+			Name:          "Ensure buckets are private",
+			PolicyID:      "policy-1",
+			PolicyName:    "CIS Amazon Web Services Foundations Benchmark",
+			Severity:      "Medium",
+			Zone:          models.Zone{ID: "zone-1", Name: "Prod"},
+			Controls: []models.Control{
+				{ID: "ctrl-1", Name: "Bucket ACL", Severity: "High", Pass: false},
+				{ID: "ctrl-2", Name: "Bucket policy", Severity: "Low", Pass: true},
+			},
+		},
+		{
+			RequirementID: "req-2",
+			Name:          "Ensure 100% MFA",
+			PolicyID:      "policy-1",
+			PolicyName:    "CIS Amazon Web Services Foundations Benchmark",
+			Severity:      "High",
+			Pass:          true,
+			Zone:          models.Zone{ID: "zone-1", Name: "Prod"},
+		},
+	}
+	if err := db.SaveComplianceRequirementsWithControls(requirements); err != nil {
+		t.Fatalf("Failed to save requirements: %v", err)
+	}
+
+	resources := []models.CloudResource{
+		{Hash: "hash-1", Name: "bucket-b", Type: "bucket", Platform: "AWS", Account: "prod", Zones: []models.Zone{{ID: "zone-1", Name: "Prod"}}},
+		{Hash: "hash-2", Name: "bucket-a", Type: "bucket", Platform: "AWS", Account: "prod", Acceptance: &models.Acceptance{Justification: "Risk Owned"}},
+		{Hash: "hash-3", Name: "host-1", Type: "host", PlatformAccountID: "123456789012", Passed: true},
+	}
+	if err := db.SaveCloudResources(resources); err != nil {
+		t.Fatalf("Failed to save resources: %v", err)
+	}
+	if err := db.SaveControlResourceRelations("ctrl-1", resources); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+
+	return db
+}
+
+func TestList(t *testing.T) {
+	db := newListingTestDatabase(t)
+
+	t.Run("既定のソートと型変換", func(t *testing.T) {
+		result, err := db.List(RequirementsListing, ListQuery{})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if result.Total != 2 || len(result.Rows) != 2 {
+			t.Fatalf("Expected 2 requirements, got %+v", result)
+		}
+		// 同じポリシー内ではseverityの高い順
+		if result.Rows[0]["requirement_id"] != "req-2" {
+			t.Errorf("Expected High severity first, got %v", result.Rows[0]["requirement_id"])
+		}
+		if result.Rows[0]["pass"] != true || result.Rows[1]["pass"] != false {
+			t.Errorf("Expected boolean pass values, got %v / %v", result.Rows[0]["pass"], result.Rows[1]["pass"])
+		}
+		if _, ok := result.Rows[0]["id"].(int64); !ok {
+			t.Errorf("Expected integer id, got %T", result.Rows[0]["id"])
+		}
+	})
+
+	t.Run("完全一致フィルタは複数値", func(t *testing.T) {
+		result, err := db.List(ControlsListing, ListQuery{Filters: []FilterValue{{Name: "severity", Value: "High, Low"}}})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if result.Total != 2 {
+			t.Errorf("Expected 2 controls, got %d", result.Total)
+		}
+	})
+
+	t.Run("部分一致フィルタはワイルドカードをエスケープ", func(t *testing.T) {
+		result, err := db.List(RequirementsListing, ListQuery{Filters: []FilterValue{{Name: "name", Value: "100%"}}})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if result.Total != 1 || result.Rows[0]["requirement_id"] != "req-2" {
+			t.Errorf("Expected only req-2, got %+v", result.Rows)
+		}
+
+		result, err = db.List(RequirementsListing, ListQuery{Filters: []FilterValue{{Name: "name", Value: "%"}}})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if result.Total != 1 {
+			t.Errorf("Expected %% to match literally, got %d rows", result.Total)
+		}
+	})
+
+	t.Run("サブクエリフィルタ", func(t *testing.T) {
+		result, err := db.List(ResourcesListing, ListQuery{Filters: []FilterValue{
+			{Name: "control_id", Value: "ctrl-1"},
+			{Name: "status", Value: "accepted"},
+		}})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if result.Total != 1 || result.Rows[0]["hash"] != "hash-2" {
+			t.Errorf("Expected only hash-2, got %+v", result.Rows)
+		}
+
+		result, err = db.List(ControlsListing, ListQuery{Filters: []FilterValue{{Name: "policy_name", Value: "amazon"}}})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if result.Total != 2 {
+			t.Errorf("Expected 2 controls of the policy, got %d", result.Total)
+		}
+	})
+
+	t.Run("真偽値フィルタ", func(t *testing.T) {
+		result, err := db.List(RelationsListing, ListQuery{Filters: []FilterValue{{Name: "passed", Value: "true"}}})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if result.Total != 1 || result.Rows[0]["resource_name"] != "host-1" {
+			t.Errorf("Expected only host-1, got %+v", result.Rows)
+		}
+
+		if _, err := db.List(RelationsListing, ListQuery{Filters: []FilterValue{{Name: "passed", Value: "maybe"}}}); err == nil {
+			t.Error("Expected error for invalid boolean")
+		}
+	})
+
+	t.Run("ソートとページング", func(t *testing.T) {
+		result, err := db.List(ResourcesListing, ListQuery{
+			Sort:   []SortField{{Field: "name", Desc: true}},
+			Limit:  2,
+			Offset: 1,
+		})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if result.Total != 3 || len(result.Rows) != 2 {
+			t.Fatalf("Expected total 3 and 2 rows, got %d/%d", result.Total, len(result.Rows))
+		}
+		if result.Rows[0]["name"] != "bucket-b" || result.Rows[1]["name"] != "bucket-a" {
+			t.Errorf("Unexpected page: %v, %v", result.Rows[0]["name"], result.Rows[1]["name"])
+		}
+	})
+
+	t.Run("アカウントのフォールバックとJSON列", func(t *testing.T) {
+		result, err := db.List(ResourcesListing, ListQuery{Filters: []FilterValue{{Name: "account", Value: "123456789012"}}})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		if result.Total != 1 || result.Rows[0]["account"] != "123456789012" {
+			t.Errorf("Expected cluster resource by platform account, got %+v", result.Rows)
+		}
+
+		row, err := db.Get(ResourcesListing, "hash-1")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		zones, ok := row["zones"].(json.RawMessage)
+		if !ok || string(zones) != `[{"id":"zone-1","name":"Prod"}]` {
+			t.Errorf("Expected raw JSON zones, got %#v", row["zones"])
+		}
+	})
+
+	t.Run("不明なフィルタとソート", func(t *testing.T) {
+		if _, err := db.List(ControlsListing, ListQuery{Filters: []FilterValue{{Name: "bogus", Value: "x"}}}); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Expected ErrInvalidQuery for unknown filter, got %v", err)
+		}
+		if _, err := db.List(ControlsListing, ListQuery{Sort: []SortField{{Field: "description"}}}); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Expected ErrInvalidQuery for non-sortable field, got %v", err)
+		}
+	})
+
+	t.Run("存在しないキー", func(t *testing.T) {
+		row, err := db.Get(ControlsListing, "missing")
+		if err != nil || row != nil {
+			t.Errorf("Expected nil row, got %v, %v", row, err)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrLocked is returned when another collection holds the lock of a database
+var ErrLocked = errors.New("database is locked by another collection")
+
+// Lock is an exclusive lock preventing overlapping collections into the same database file.
+// The lock is held on "<db path>.lock" and released by the OS when the process exits.
+type Lock struct {
+	path string
+	file *os.File
+}
+
+// LockPath returns the lock file path for a database
+func LockPath(dbPath string) string {
+	return dbPath + ".lock"
+}
+
+// AcquireLock takes the collection lock for dbPath without blocking.
+// It returns an error wrapping ErrLocked when the lock is held by another process or goroutine.
+func AcquireLock(dbPath string) (*Lock, error) {
+	path := LockPath(dbPath)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lockFile(file); err != nil {
+		_ = file.Close()
+		if errors.Is(err, ErrLocked) {
+			if pid := readLockOwner(path); pid != "" {
+				return nil, fmt.Errorf("%w: %s (pid %s)", ErrLocked, dbPath, pid)
+			}
+			return nil, fmt.Errorf("%w: %s", ErrLocked, dbPath)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+
+	// 調査用にロック保持プロセスのPIDを書き込む
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return &Lock{path: path, file: file}, nil
+}
+
+// Release releases the lock
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	_ = l.file.Truncate(0)
+	err := unlockFile(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
+
+func readLockOwner(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
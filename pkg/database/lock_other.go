@@ -0,0 +1,31 @@
+//go:build !unix
+
+package database
+
+import (
+	"os"
+	"sync"
+)
+
+// flockが使えない環境ではプロセス内のロックのみ提供する
+var (
+	lockedFilesMu sync.Mutex
+	lockedFiles   = make(map[string]bool)
+)
+
+func lockFile(f *os.File) error {
+	lockedFilesMu.Lock()
+	defer lockedFilesMu.Unlock()
+	if lockedFiles[f.Name()] {
+		return ErrLocked
+	}
+	lockedFiles[f.Name()] = true
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	lockedFilesMu.Lock()
+	defer lockedFilesMu.Unlock()
+	delete(lockedFiles, f.Name())
+	return nil
+}
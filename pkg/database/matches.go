@@ -0,0 +1,269 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/filter"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+const createAcceptanceResourceMatchesTable = `
+	CREATE TABLE IF NOT EXISTS acceptance_resource_matches (
+		acceptance_id TEXT NOT NULL,          -- risk_acceptances.id
+		control_id TEXT NOT NULL,
+		resource_hash TEXT NOT NULL,          -- cloud_resources.hash
+		matched_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (acceptance_id, resource_hash)
+	)`
+
+// AcceptanceMatchResult summarizes the resolution of one risk acceptance
+type AcceptanceMatchResult struct {
+	AcceptanceID string
+	ControlID    string
+	// Candidates is the number of resources of the control in the acceptance's zone and source
+	Candidates int
+	Matched    int
+	// Err is set when the filter could not be parsed; no matches are stored then
+	Err error
+}
+
+// AcceptanceMatch is a resource covered by a risk acceptance
+type AcceptanceMatch struct {
+	Hash     string
+	Name     string
+	Type     string
+	Account  string
+	Location string
+	// Status is the acceptance status of the resource for the control ('failed', 'passed', 'accepted')
+	Status    string
+	MatchedAt time.Time
+}
+
+// candidate is a resource linked to a control, with the fields available to filters
+type candidate struct {
+	hash   string
+	record filter.Record
+	zones  []models.Zone
+	// sources are the values a sourceId may refer to (account, platform account ID, cluster)
+	sources []string
+}
+
+// ResolveAcceptanceMatches evaluates the filters of the risk acceptances against the resources
+// linked to their control and stores the matches in acceptance_resource_matches.
+// An empty acceptanceID resolves every acceptance.
+func (d *Database) ResolveAcceptanceMatches(acceptanceID string) ([]AcceptanceMatchResult, error) {
+	var acceptances []models.RiskAcceptance
+	all, err := d.GetRiskAcceptances("")
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range all {
+		if acceptanceID == "" || a.ID == acceptanceID {
+			acceptances = append(acceptances, a)
+		}
+	}
+
+	candidates := make(map[string][]candidate)
+	var results []AcceptanceMatchResult
+	matches := make(map[string][]string)
+	for _, a := range acceptances {
+		result := AcceptanceMatchResult{AcceptanceID: a.ID, ControlID: a.ControlID}
+
+		expr, err := filter.Parse(a.Filter)
+		if err != nil {
+			result.Err = fmt.Errorf("risk acceptance %s: %w", a.ID, err)
+			results = append(results, result)
+			continue
+		}
+
+		if _, ok := candidates[a.ControlID]; !ok {
+			if candidates[a.ControlID], err = d.acceptanceCandidates(a.ControlID); err != nil {
+				return nil, err
+			}
+		}
+		for _, c := range candidates[a.ControlID] {
+			if !c.inScope(a) {
+				continue
+			}
+			result.Candidates++
+			// フィルタのない受容はコントロールの全リソースが対象
+			if expr == nil || expr.Eval(c.record) {
+				result.Matched++
+				matches[a.ID] = append(matches[a.ID], c.hash)
+			}
+		}
+		results = append(results, result)
+	}
+
+	if err := d.saveAcceptanceMatches(results, matches, time.Now()); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// inScope reports whether the resource is in the zone and source of the acceptance.
+// Resources without zones are not excluded by the zone.
+func (c candidate) inScope(a models.RiskAcceptance) bool {
+	if a.ZoneID != "" && a.ZoneID != "0" && len(c.zones) > 0 {
+		found := false
+		for _, z := range c.zones {
+			if z.ID == a.ZoneID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if a.SourceID != "" {
+		for _, s := range c.sources {
+			if s == a.SourceID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// acceptanceCandidates returns the resources linked to a control
+func (d *Database) acceptanceCandidates(controlID string) ([]candidate, error) {
+	rows, err := d.db.Query(`
+		SELECT cr.hash, cr.name, cr.type,
+		       COALESCE(cr.platform, ''), COALESCE(cr.account, ''), COALESCE(cr.platform_account_id, ''),
+		       COALESCE(cr.location, ''), COALESCE(cr.cloud_region, ''), COALESCE(cr.organization, ''),
+		       COALESCE(cr.cluster_name, ''), COALESCE(cr.cloud_resource_id, ''), COALESCE(cr.zones_json, '')
+		FROM control_resource_relations rel
+		JOIN cloud_resources cr ON cr.hash = rel.resource_hash
+		WHERE rel.control_id = ?
+		ORDER BY cr.name, cr.hash`, controlID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resources of control %s: %w", controlID, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var candidates []candidate
+	for rows.Next() {
+		var hash, name, typ, platform, account, platformAccountID, location, cloudRegion, organization, cluster, resourceID, zonesJSON string
+		if err := rows.Scan(&hash, &name, &typ, &platform, &account, &platformAccountID,
+			&location, &cloudRegion, &organization, &cluster, &resourceID, &zonesJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan resource: %w", err)
+		}
+
+		c := candidate{hash: hash, record: resourceRecord(hash, name, typ, platform, account, platformAccountID, location, cloudRegion, organization, cluster, resourceID)}
+		if zonesJSON != "" {
+			// 壊れたJSONはゾーンなしとして扱う
+			_ = json.Unmarshal([]byte(zonesJSON), &c.zones)
+		}
+		for _, s := range []string{account, platformAccountID, cluster} {
+			if s != "" {
+				c.sources = append(c.sources, s)
+			}
+		}
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// resourceRecord returns the filter fields of a resource. account and location fall back to
+// the Cluster Analysis columns; kind is an alias of type and region of location.
+func resourceRecord(hash, name, typ, platform, account, platformAccountID, location, cloudRegion, organization, cluster, resourceID string) filter.Record {
+	if account == "" {
+		account = platformAccountID
+	}
+	if location == "" {
+		location = cloudRegion
+	}
+	return filter.Record{
+		"hash":         hash,
+		"name":         name,
+		"type":         typ,
+		"kind":         typ,
+		"platform":     platform,
+		"account":      account,
+		"location":     location,
+		"region":       location,
+		"organization": organization,
+		"cluster":      cluster,
+		"resourceId":   resourceID,
+	}
+}
+
+// saveAcceptanceMatches replaces the stored matches of the resolved acceptances
+func (d *Database) saveAcceptanceMatches(results []AcceptanceMatchResult, matches map[string][]string, now time.Time) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, r := range results {
+		if _, err := tx.Exec("DELETE FROM acceptance_resource_matches WHERE acceptance_id = ?", r.AcceptanceID); err != nil {
+			return fmt.Errorf("failed to clear matches of %s: %w", r.AcceptanceID, err)
+		}
+		for _, hash := range matches[r.AcceptanceID] {
+			if _, err := tx.Exec(`
+				INSERT OR REPLACE INTO acceptance_resource_matches (acceptance_id, control_id, resource_hash, matched_at)
+				VALUES (?, ?, ?, ?)`, r.AcceptanceID, r.ControlID, hash, now.UTC()); err != nil {
+				return fmt.Errorf("failed to save match of %s: %w", r.AcceptanceID, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetAcceptanceMatches returns the resources covered by a risk acceptance, ordered by name
+func (d *Database) GetAcceptanceMatches(acceptanceID string) ([]AcceptanceMatch, error) {
+	rows, err := d.db.Query(`
+		SELECT m.resource_hash, COALESCE(cr.name, ''), COALESCE(cr.type, ''),
+		       COALESCE(NULLIF(cr.account, ''), cr.platform_account_id, ''),
+		       COALESCE(NULLIF(cr.location, ''), cr.cloud_region, ''),
+		       COALESCE(rel.acceptance_status, ''), m.matched_at
+		FROM acceptance_resource_matches m
+		LEFT JOIN cloud_resources cr ON cr.hash = m.resource_hash
+		LEFT JOIN control_resource_relations rel ON rel.control_id = m.control_id AND rel.resource_hash = m.resource_hash
+		WHERE m.acceptance_id = ?
+		ORDER BY 2, 1`, acceptanceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query acceptance matches: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var matches []AcceptanceMatch
+	for rows.Next() {
+		var m AcceptanceMatch
+		if err := rows.Scan(&m.Hash, &m.Name, &m.Type, &m.Account, &m.Location, &m.Status, &m.MatchedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan acceptance match: %w", err)
+		}
+		matches = append(matches, m)
+	}
+
+	return matches, rows.Err()
+}
+
+// GetAcceptanceMatchCounts returns the number of stored matches per risk acceptance;
+// acceptances without matches are absent
+func (d *Database) GetAcceptanceMatchCounts() (map[string]int, error) {
+	rows, err := d.db.Query("SELECT acceptance_id, COUNT(*) FROM acceptance_resource_matches GROUP BY acceptance_id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count acceptance matches: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan acceptance match count: %w", err)
+		}
+		counts[id] = n
+	}
+
+	return counts, rows.Err()
+}
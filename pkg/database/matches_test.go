@@ -0,0 +1,99 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestResolveAcceptanceMatches(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	requirements := []models.ComplianceRequirementWithControls{
+		{
+			RequirementID: "req-1",
+			Name:          "Ensure buckets are private",
+			PolicyName:    "CIS AWS",
+			Severity:      "High",
+			Controls:      []models.Control{{ID: "16022", Name: "Bucket ACL", Severity: "High"}},
+		},
+	}
+	if err := db.SaveComplianceRequirementsWithControls(requirements); err != nil {
+		t.Fatalf("Failed to save requirements: %v", err)
+	}
+	zone := []models.Zone{{ID: "7", Name: "Prod"}}
+	resources := []models.CloudResource{
+		{Hash: "h1", Name: "bucket-a", Account: "111", Location: "us-west-2", Zones: zone, Acceptance: &models.Acceptance{Justification: "Risk Owned"}},
+		{Hash: "h2", Name: "bucket-b", Account: "111", Location: "us-east-1", Zones: zone},
+		{Hash: "h3", Name: "bucket-a", Account: "222", Location: "us-west-2", Zones: []models.Zone{{ID: "8"}}},
+	}
+	if err := db.SaveCloudResources(resources); err != nil {
+		t.Fatalf("Failed to save resources: %v", err)
+	}
+	if err := db.SaveControlResourceRelations("16022", resources); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+
+	acceptances := []models.RiskAcceptance{
+		{ID: "by-name", ControlID: "16022", Filter: `name in ("bucket-a") and location in ("us-west-2")`, ZoneID: "7", SourceID: "111", AcceptanceDate: "2"},
+		{ID: "whole-control", ControlID: "16022", ZoneID: "0", AcceptanceDate: "1"},
+		{ID: "nothing", ControlID: "16022", Filter: `name in ("gone")`, AcceptanceDate: "3"},
+		{ID: "broken", ControlID: "16022", Filter: `name in (`, AcceptanceDate: "4"},
+	}
+	if err := db.SaveRiskAcceptances(acceptances); err != nil {
+		t.Fatalf("Failed to save acceptances: %v", err)
+	}
+
+	results, err := db.ResolveAcceptanceMatches("")
+	if err != nil {
+		t.Fatalf("ResolveAcceptanceMatches failed: %v", err)
+	}
+	got := make(map[string]AcceptanceMatchResult)
+	for _, r := range results {
+		got[r.AcceptanceID] = r
+	}
+	if r := got["by-name"]; r.Candidates != 2 || r.Matched != 1 || r.Err != nil {
+		t.Errorf("Expected 1 of 2 candidates in zone 7 and account 111, got %+v", r)
+	}
+	if r := got["whole-control"]; r.Matched != 3 {
+		t.Errorf("Expected an empty filter to match every resource, got %+v", r)
+	}
+	if r := got["nothing"]; r.Matched != 0 || r.Candidates != 3 {
+		t.Errorf("Expected no matches, got %+v", r)
+	}
+	if r := got["broken"]; r.Err == nil {
+		t.Errorf("Expected a parse error, got %+v", r)
+	}
+
+	matches, err := db.GetAcceptanceMatches("by-name")
+	if err != nil {
+		t.Fatalf("GetAcceptanceMatches failed: %v", err)
+	}
+	if len(matches) != 1 || matches[0].Hash != "h1" || matches[0].Status != "accepted" || matches[0].Location != "us-west-2" {
+		t.Errorf("Unexpected matches: %+v", matches)
+	}
+
+	counts, err := db.GetAcceptanceMatchCounts()
+	if err != nil {
+		t.Fatalf("GetAcceptanceMatchCounts failed: %v", err)
+	}
+	if counts["by-name"] != 1 || counts["whole-control"] != 3 || counts["nothing"] != 0 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+
+	// 再解決では以前の結果を置き換える
+	if err := db.SaveRiskAcceptances([]models.RiskAcceptance{{ID: "whole-control", ControlID: "16022", Filter: `name = "bucket-b"`, AcceptanceDate: "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ResolveAcceptanceMatches("whole-control"); err != nil {
+		t.Fatalf("ResolveAcceptanceMatches failed: %v", err)
+	}
+	if counts, _ := db.GetAcceptanceMatchCounts(); counts["whole-control"] != 1 || counts["by-name"] != 1 {
+		t.Errorf("Expected only whole-control to be re-resolved, got %v", counts)
+	}
+}
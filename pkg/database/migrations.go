@@ -0,0 +1,380 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSchemaTooNew is returned when a database was written by a newer version of this tool
+var ErrSchemaTooNew = errors.New("database schema is newer than supported")
+
+// ErrSchemaOutdated is returned when a database opened read-only has pending migrations
+var ErrSchemaOutdated = errors.New("database schema is outdated")
+
+const createSchemaMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+
+// Migration represents a single versioned schema change.
+// Up must be idempotent so that databases created before schema_migrations existed can be upgraded safely.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+}
+
+// AppliedMigration represents a migration recorded in schema_migrations
+type AppliedMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// migrations lists all schema migrations in ascending version order.
+// 新しいマイグレーションは末尾に追加し、既存のものは変更しないこと
+var migrations = []Migration{
+	{Version: 1, Name: "initial schema", Up: migrateInitialSchema},
+	{Version: 2, Name: "cluster analysis columns", Up: migrateClusterAnalysisColumns},
+	{Version: 3, Name: "collection runs", Up: migrateCollectionRuns},
+	{Version: 4, Name: "posture snapshots", Up: migratePostureSnapshots},
+	{Version: 5, Name: "risk acceptance renewals", Up: migrateRiskAcceptanceRenewals},
+	{Version: 6, Name: "acceptance resource matches", Up: migrateAcceptanceResourceMatches},
+	{Version: 7, Name: "risk acceptance revocation", Up: migrateRiskAcceptanceRevocation},
+	{Version: 8, Name: "audit log", Up: migrateAuditLog},
+	{Version: 9, Name: "resource owners", Up: migrateOwners},
+}
+
+// Migrations returns all known migrations in ascending version order
+func Migrations() []Migration {
+	result := make([]Migration, len(migrations))
+	copy(result, migrations)
+	return result
+}
+
+// LatestSchemaVersion returns the schema version this build supports
+func LatestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// migrateInitialSchema creates the base tables and indexes
+func migrateInitialSchema(tx *sql.Tx) error {
+	queries := []string{
+		createComplianceRequirementsTable,
+		createControlsTable,
+		createCloudResourcesTable,
+		createControlResourceRelationsTable,
+		createRiskAcceptancesTable,
+		createIndexes,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute schema query: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrateClusterAnalysisColumns adds columns introduced after the first releases.
+// 初期リリースで作成されたDBファイルにはCluster Analysis API用のカラムが存在しない
+func migrateClusterAnalysisColumns(tx *sql.Tx) error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"controls", "target", "TEXT"},
+		{"controls", "platform", "TEXT"},
+		{"cloud_resources", "platform_account_id", "TEXT"},
+		{"cloud_resources", "cloud_resource_id", "TEXT"},
+		{"cloud_resources", "cloud_region", "TEXT"},
+		{"cloud_resources", "agent_tags_json", "TEXT"},
+	}
+
+	for _, c := range columns {
+		if err := addColumnIfNotExists(tx, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateCollectionRuns adds the run history written by collect and the daemon
+func migrateCollectionRuns(tx *sql.Tx) error {
+	queries := []string{
+		createCollectionRunsTable,
+		`CREATE INDEX IF NOT EXISTS idx_collection_runs_started_at ON collection_runs(started_at)`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to create collection_runs table: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migratePostureSnapshots adds the per-run posture counts used by the trend command
+func migratePostureSnapshots(tx *sql.Tx) error {
+	queries := []string{
+		createPostureSnapshotsTable,
+		`CREATE INDEX IF NOT EXISTS idx_posture_snapshots_taken_at ON posture_snapshots(taken_at)`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to create posture_snapshots table: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrateRiskAcceptanceRenewals adds the links between revoked and renewed risk acceptances
+func migrateRiskAcceptanceRenewals(tx *sql.Tx) error {
+	queries := []string{
+		createRiskAcceptanceRenewalsTable,
+		`CREATE INDEX IF NOT EXISTS idx_risk_acceptance_renewals_old_id ON risk_acceptance_renewals(old_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_risk_acceptance_renewals_new_id ON risk_acceptance_renewals(new_id)`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to create risk_acceptance_renewals table: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrateAcceptanceResourceMatches adds the resources covered by each risk acceptance
+func migrateAcceptanceResourceMatches(tx *sql.Tx) error {
+	queries := []string{
+		createAcceptanceResourceMatchesTable,
+		`CREATE INDEX IF NOT EXISTS idx_acceptance_resource_matches_resource ON acceptance_resource_matches(resource_hash)`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to create acceptance_resource_matches table: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrateRiskAcceptanceRevocation marks risk acceptances that disappeared from the API instead of deleting them
+func migrateRiskAcceptanceRevocation(tx *sql.Tx) error {
+	if err := addColumnIfNotExists(tx, "risk_acceptances", "revoked_at", "TIMESTAMP"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_risk_revoked_at ON risk_acceptances(revoked_at)`); err != nil {
+		return fmt.Errorf("failed to create risk_acceptances revoked_at index: %w", err)
+	}
+	return nil
+}
+
+// migrateAuditLog adds the append-only log of write operations sent to the API
+func migrateAuditLog(tx *sql.Tx) error {
+	queries := []string{
+		createAuditLogTable,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_acceptance_id ON audit_log(acceptance_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_recorded_at ON audit_log(recorded_at)`,
+		createAuditLogTriggers,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to create audit_log table: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// migrateOwners creates the table of the teams owning the resources
+func migrateOwners(tx *sql.Tx) error {
+	queries := []string{
+		createOwnersTable,
+		`CREATE INDEX IF NOT EXISTS idx_owners_team ON owners(team)`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to create owners table: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Migrate applies all pending migrations and returns the ones that were applied
+func (d *Database) Migrate() ([]Migration, error) {
+	if _, err := d.db.Exec(createSchemaMigrationsTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	current, err := d.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	if latest := LatestSchemaVersion(); current > latest {
+		return nil, fmt.Errorf("%w: database is at version %d but this build supports up to version %d, please upgrade sysdig-cspm-utils",
+			ErrSchemaTooNew, current, latest)
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := d.applyMigration(m); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// applyMigration runs a single migration and records it in one transaction
+func (d *Database) applyMigration(m Migration) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := m.Up(tx); err != nil {
+		return fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Name, err)
+	}
+
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+
+	return tx.Commit()
+}
+
+// SchemaVersion returns the highest applied migration version (0 for an unversioned database)
+func (d *Database) SchemaVersion() (int, error) {
+	exists, err := d.tableExists("schema_migrations")
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version sql.NullInt64
+	if err := d.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	return int(version.Int64), nil
+}
+
+// AppliedMigrations returns the migrations recorded in schema_migrations
+func (d *Database) AppliedMigrations() ([]AppliedMigration, error) {
+	exists, err := d.tableExists("schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	rows, err := d.db.Query("SELECT version, name, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema migrations: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var m AppliedMigration
+		if err := rows.Scan(&m.Version, &m.Name, &m.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema migration: %w", err)
+		}
+		applied = append(applied, m)
+	}
+
+	return applied, rows.Err()
+}
+
+// PendingMigrations returns the migrations that have not been applied yet
+func (d *Database) PendingMigrations() ([]Migration, error) {
+	current, err := d.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > current {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending, nil
+}
+
+// tableExists checks whether a table exists in the database
+func (d *Database) tableExists(table string) (bool, error) {
+	var count int
+	err := d.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check table %s: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+// addColumnIfNotExists adds a column unless the table already has it
+func addColumnIfNotExists(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to get table info for %s: %w", table, err)
+	}
+
+	found := false
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("failed to scan table info for %s: %w", table, err)
+		}
+		if name == column {
+			found = true
+		}
+	}
+	_ = rows.Close()
+
+	if found {
+		return nil
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestNewDatabase_AppliesAllMigrations(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatalf("Failed to get schema version: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("Expected schema version %d, got %d", LatestSchemaVersion(), version)
+	}
+
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		t.Fatalf("Failed to get pending migrations: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending migrations, got %d", len(pending))
+	}
+
+	applied, err := db.AppliedMigrations()
+	if err != nil {
+		t.Fatalf("Failed to get applied migrations: %v", err)
+	}
+	if len(applied) != len(Migrations()) {
+		t.Errorf("Expected %d applied migrations, got %d", len(Migrations()), len(applied))
+	}
+}
+
+func TestNewDatabase_ReopenIsIdempotent(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	for i := 0; i < 2; i++ {
+		db, err := NewDatabase(dbPath)
+		if err != nil {
+			t.Fatalf("Failed to open database (attempt %d): %v", i+1, err)
+		}
+
+		applied, err := db.AppliedMigrations()
+		if err != nil {
+			t.Fatalf("Failed to get applied migrations: %v", err)
+		}
+		if len(applied) != len(Migrations()) {
+			t.Errorf("Expected %d applied migrations, got %d", len(Migrations()), len(applied))
+		}
+		db.Close()
+	}
+}
+
+func TestMigrate_UpgradesLegacyDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+
+	// schema_migrations導入前のDBを再現（Cluster Analysis用カラムなし）
+	legacy, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	_, err = legacy.Exec(`
+		CREATE TABLE cloud_resources (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			hash TEXT NOT NULL UNIQUE,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			platform TEXT,
+			account TEXT,
+			location TEXT,
+			organization TEXT,
+			os_name TEXT,
+			os_image TEXT,
+			cluster_name TEXT,
+			distribution_name TEXT,
+			distribution_version TEXT,
+			zones_json TEXT,
+			label_values_json TEXT,
+			additional_metadata_json TEXT,
+			last_seen_date TEXT,
+			global_id TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy table: %v", err)
+	}
+	legacy.Close()
+
+	db, err := OpenDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatalf("Failed to get schema version: %v", err)
+	}
+	if version != 0 {
+		t.Errorf("Expected legacy schema version 0, got %d", version)
+	}
+
+	applied, err := db.Migrate()
+	if err != nil {
+		t.Fatalf("Failed to migrate legacy database: %v", err)
+	}
+	if len(applied) != len(Migrations()) {
+		t.Errorf("Expected %d migrations to be applied, got %d", len(Migrations()), len(applied))
+	}
+
+	// 追加されたカラムに書き込めることを確認
+	resources := []models.CloudResource{
+		{
+			Hash:              "hash-1",
+			Name:              "host-1",
+			Type:              "host",
+			PlatformAccountID: "123456789012",
+			CloudRegion:       "ap-northeast-1",
+			AgentTags:         []string{"env:prod"},
+		},
+	}
+	if err := db.SaveCloudResources(resources); err != nil {
+		t.Fatalf("Failed to save resources after migration: %v", err)
+	}
+
+	var region string
+	if err := db.db.QueryRow("SELECT cloud_region FROM cloud_resources WHERE hash = ?", "hash-1").Scan(&region); err != nil {
+		t.Fatalf("Failed to query migrated column: %v", err)
+	}
+	if region != "ap-northeast-1" {
+		t.Errorf("Expected cloud_region 'ap-northeast-1', got '%s'", region)
+	}
+}
+
+func TestNewDatabase_RejectsNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+
+	// 将来バージョンで適用されたマイグレーションを記録
+	futureVersion := LatestSchemaVersion() + 1
+	if _, err := db.db.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", futureVersion, "future"); err != nil {
+		t.Fatalf("Failed to insert future migration: %v", err)
+	}
+	db.Close()
+
+	_, err = NewDatabase(dbPath)
+	if err == nil {
+		t.Fatal("Expected error for newer schema, got nil")
+	}
+	if !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Expected ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	previous := 0
+	for _, m := range Migrations() {
+		if m.Version <= previous {
+			t.Errorf("Migration %d (%s) is not in ascending order", m.Version, m.Name)
+		}
+		if m.Name == "" {
+			t.Errorf("Migration %d has no name", m.Version)
+		}
+		previous = m.Version
+	}
+}
@@ -434,7 +434,10 @@ func findSubstring(haystack, needle string) bool {
 	return false
 }
 
-// SaveRiskAcceptances saves risk acceptances to the database
+// SaveRiskAcceptances saves risk acceptances to the database. It is only meant for acceptances
+// just created through the API (risk-renew, tui): rerunning it on a revoked row would clear its
+// revoked_at, so collections reconcile with SyncRiskAcceptances and revocations use
+// MarkRiskAcceptanceRevoked.
 func (d *Database) SaveRiskAcceptances(acceptances []models.RiskAcceptance) error {
 	tx, err := d.db.Begin()
 	if err != nil {
@@ -482,19 +485,21 @@ func (d *Database) SaveRiskAcceptances(acceptances []models.RiskAcceptance) erro
 	return tx.Commit()
 }
 
-// GetRiskAcceptances retrieves risk acceptances from the database
+// GetRiskAcceptances retrieves risk acceptances from the database.
+// Acceptances revoked outside this tool (see SyncRiskAcceptances) are not returned.
 func (d *Database) GetRiskAcceptances(controlID string) ([]models.RiskAcceptance, error) {
 	query := `
 		SELECT id, tenant_id, control_id, description, reason,
 		       acceptance_date, username, user_display_name,
 		       filter, zone_id, accept_period, expires_at,
 		       is_expired, is_system, type, source_id
-		FROM risk_acceptances`
+		FROM risk_acceptances
+		WHERE revoked_at IS NULL`
 
 	args := []interface{}{}
 
 	if controlID != "" {
-		query += " WHERE control_id = ?"
+		query += " AND control_id = ?"
 		args = append(args, controlID)
 	}
 
@@ -506,12 +511,38 @@ func (d *Database) GetRiskAcceptances(controlID string) ([]models.RiskAcceptance
 	}
 	defer func() { _ = rows.Close() }()
 
+	return scanRiskAcceptances(rows)
+}
+
+// GetRiskAcceptance returns a stored risk acceptance by ID, revoked or not, or nil when it is unknown
+func (d *Database) GetRiskAcceptance(id string) (*models.RiskAcceptance, error) {
+	rows, err := d.db.Query(`
+		SELECT id, tenant_id, control_id, description, reason,
+		       acceptance_date, username, user_display_name,
+		       filter, zone_id, accept_period, expires_at,
+		       is_expired, is_system, type, source_id
+		FROM risk_acceptances
+		WHERE id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query risk acceptance %s: %w", id, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	acceptances, err := scanRiskAcceptances(rows)
+	if err != nil || len(acceptances) == 0 {
+		return nil, err
+	}
+	return &acceptances[0], nil
+}
+
+// scanRiskAcceptances reads rows selected with the column list of GetRiskAcceptances
+func scanRiskAcceptances(rows *sql.Rows) ([]models.RiskAcceptance, error) {
 	var acceptances []models.RiskAcceptance
 	for rows.Next() {
 		var acc models.RiskAcceptance
 		var description, reason, username, userDisplayName, filter, zoneID, acceptPeriod, expiresAt, sourceID sql.NullString
 
-		err = rows.Scan(
+		err := rows.Scan(
 			&acc.ID,
 			&acc.TenantID,
 			&acc.ControlID,
@@ -547,24 +578,57 @@ func (d *Database) GetRiskAcceptances(controlID string) ([]models.RiskAcceptance
 		acceptances = append(acceptances, acc)
 	}
 
-	return acceptances, nil
+	return acceptances, rows.Err()
 }
 
-// DeleteRiskAcceptanceFromDB deletes a risk acceptance from the database by ID
-func (d *Database) DeleteRiskAcceptanceFromDB(id string) error {
-	result, err := d.db.Exec("DELETE FROM risk_acceptances WHERE id = ?", id)
+// GetControlSeverities returns the severity of every collected control keyed by control ID
+func (d *Database) GetControlSeverities() (map[string]string, error) {
+	rows, err := d.db.Query("SELECT control_id, severity FROM controls")
 	if err != nil {
-		return fmt.Errorf("failed to delete risk acceptance %s: %w", id, err)
+		return nil, fmt.Errorf("failed to query controls: %w", err)
 	}
+	defer func() { _ = rows.Close() }()
+
+	severities := make(map[string]string)
+	for rows.Next() {
+		var id, severity string
+		if err := rows.Scan(&id, &severity); err != nil {
+			return nil, fmt.Errorf("failed to scan control: %w", err)
+		}
+		severities[id] = severity
+	}
+
+	return severities, rows.Err()
+}
 
-	rowsAffected, err := result.RowsAffected()
+// GetControlNames returns the name of every collected control keyed by control ID
+func (d *Database) GetControlNames() (map[string]string, error) {
+	return d.queryNames("SELECT control_id, name FROM controls")
+}
+
+// GetZoneNames returns the name of every zone of the collected requirements keyed by zone ID
+func (d *Database) GetZoneNames() (map[string]string, error) {
+	return d.queryNames(`
+		SELECT DISTINCT zone_id, zone_name FROM compliance_requirements
+		WHERE zone_id IS NOT NULL AND zone_id != '' AND zone_name IS NOT NULL`)
+}
+
+// queryNames reads (id, name) rows into a map
+func (d *Database) queryNames(query string) (map[string]string, error) {
+	rows, err := d.db.Query(query)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to query names: %w", err)
 	}
+	defer func() { _ = rows.Close() }()
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("risk acceptance %s not found", id)
+	names := make(map[string]string)
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan name: %w", err)
+		}
+		names[id] = name
 	}
 
-	return nil
+	return names, rows.Err()
 }
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const createOwnersTable = `
+	CREATE TABLE IF NOT EXISTS owners (
+		resource_hash TEXT PRIMARY KEY,       -- cloud_resources.hash
+		team TEXT NOT NULL,
+		contacts TEXT,                        -- カンマ区切りの連絡先
+		rule TEXT,                            -- 一致した ownership ルール（例: rules[2]）
+		resolved_at TIMESTAMP NOT NULL
+	)`
+
+// OwnedResource holds the resource attributes ownership rules match on
+type OwnedResource struct {
+	Hash string
+	Name string
+	// Account is the account or project ID (platform account ID for cluster resources)
+	Account     string
+	ClusterName string
+	LabelValues []string
+	AgentTags   []string
+}
+
+// Owner is the team owning a resource
+type Owner struct {
+	ResourceHash string
+	Team         string
+	Contacts     []string
+	Rule         string
+}
+
+// TeamSummary holds the resources and evaluations of one team; an empty team holds the unowned resources
+type TeamSummary struct {
+	Team      string   `json:"team"`
+	Contacts  []string `json:"contacts"`
+	Resources int      `json:"resources"`
+	// FailedResources are the resources failing at least one control
+	FailedResources int `json:"failed_resources"`
+	// Failed, Passed and Accepted count control/resource evaluations
+	Failed   int `json:"failed"`
+	Passed   int `json:"passed"`
+	Accepted int `json:"accepted"`
+}
+
+// GetOwnedResources returns the attributes of every collected resource
+func (d *Database) GetOwnedResources() ([]OwnedResource, error) {
+	rows, err := d.db.Query(`
+		SELECT hash, name, COALESCE(NULLIF(account, ''), platform_account_id, ''), COALESCE(cluster_name, ''),
+		       COALESCE(label_values_json, ''), COALESCE(agent_tags_json, '')
+		FROM cloud_resources
+		ORDER BY hash`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resources: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var resources []OwnedResource
+	for rows.Next() {
+		var r OwnedResource
+		var labels, tags string
+		if err := rows.Scan(&r.Hash, &r.Name, &r.Account, &r.ClusterName, &labels, &tags); err != nil {
+			return nil, fmt.Errorf("failed to scan resource: %w", err)
+		}
+		if labels != "" && labels != "null" {
+			if err := json.Unmarshal([]byte(labels), &r.LabelValues); err != nil {
+				return nil, fmt.Errorf("failed to parse label values of %s: %w", r.Hash, err)
+			}
+		}
+		if tags != "" && tags != "null" {
+			if err := json.Unmarshal([]byte(tags), &r.AgentTags); err != nil {
+				return nil, fmt.Errorf("failed to parse agent tags of %s: %w", r.Hash, err)
+			}
+		}
+		resources = append(resources, r)
+	}
+
+	return resources, rows.Err()
+}
+
+// ReplaceOwners replaces the contents of the owners table
+func (d *Database) ReplaceOwners(owners []Owner, resolvedAt time.Time) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec("DELETE FROM owners"); err != nil {
+		return fmt.Errorf("failed to clear owners: %w", err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO owners (resource_hash, team, contacts, rule, resolved_at) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, o := range owners {
+		if _, err := stmt.Exec(o.ResourceHash, o.Team, nullString(strings.Join(o.Contacts, ",")), nullString(o.Rule), resolvedAt.UTC()); err != nil {
+			return fmt.Errorf("failed to insert owner of %s: %w", o.ResourceHash, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTeamSummaries returns the resources and evaluations per owning team, unowned resources last
+func (d *Database) GetTeamSummaries() ([]TeamSummary, error) {
+	rows, err := d.db.Query(`
+		SELECT
+			COALESCE(o.team, ''),
+			COALESCE(MAX(o.contacts), ''),
+			COUNT(DISTINCT cr.hash),
+			COUNT(DISTINCT CASE WHEN rel.acceptance_status = 'failed' THEN cr.hash END),
+			SUM(CASE WHEN rel.acceptance_status = 'failed' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN rel.acceptance_status = 'passed' THEN 1 ELSE 0 END),
+			SUM(CASE WHEN rel.acceptance_status = 'accepted' THEN 1 ELSE 0 END)
+		FROM cloud_resources cr
+		LEFT JOIN owners o ON o.resource_hash = cr.hash
+		LEFT JOIN control_resource_relations rel ON rel.resource_hash = cr.hash
+		GROUP BY 1
+		ORDER BY CASE WHEN COALESCE(o.team, '') = '' THEN 1 ELSE 0 END, 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query team summaries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var summaries []TeamSummary
+	for rows.Next() {
+		var s TeamSummary
+		var contacts string
+		var failed, passed, accepted sql.NullInt64
+		if err := rows.Scan(&s.Team, &contacts, &s.Resources, &s.FailedResources, &failed, &passed, &accepted); err != nil {
+			return nil, fmt.Errorf("failed to scan team summary: %w", err)
+		}
+		s.Failed, s.Passed, s.Accepted = int(failed.Int64), int(passed.Int64), int(accepted.Int64)
+		s.Contacts = []string{}
+		if contacts != "" {
+			s.Contacts = strings.Split(contacts, ",")
+		}
+		summaries = append(summaries, s)
+	}
+
+	return summaries, rows.Err()
+}
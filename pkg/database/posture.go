@@ -0,0 +1,242 @@
+package database
+
+import (
+	"fmt"
+)
+
+// PostureCount is the number of requirements, controls or resources sharing the same labels
+type PostureCount struct {
+	Policy   string
+	Platform string
+	Severity string
+	// Account is only set for resources (account, or platform_account_id for cluster resources)
+	Account string
+	Zone    string
+	// Team is the team owning the resources (resources only, empty when unowned)
+	Team string
+	// Status is 'failed' or 'passed' ('accepted' is also used for resources)
+	Status string
+	Count  int
+	// Evaluations is the number of control/resource pairs (resources only)
+	Evaluations int
+}
+
+// GetRequirementPosture returns requirement counts grouped by policy, platform, severity, zone and status
+func (d *Database) GetRequirementPosture() ([]PostureCount, error) {
+	rows, err := d.db.Query(`
+		SELECT
+			policy_name,
+			COALESCE(platform, ''),
+			severity,
+			COALESCE(zone_name, ''),
+			CASE WHEN pass = 1 THEN 'passed' ELSE 'failed' END AS status,
+			COUNT(*)
+		FROM compliance_requirements
+		GROUP BY 1, 2, 3, 4, 5
+		ORDER BY 1, 2, 3, 4, 5
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query requirement posture: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var counts []PostureCount
+	for rows.Next() {
+		var c PostureCount
+		if err := rows.Scan(&c.Policy, &c.Platform, &c.Severity, &c.Zone, &c.Status, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan requirement posture: %w", err)
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// GetControlPosture returns control counts grouped by policy, platform, severity, zone and status
+func (d *Database) GetControlPosture() ([]PostureCount, error) {
+	rows, err := d.db.Query(`
+		SELECT
+			r.policy_name,
+			COALESCE(NULLIF(c.platform, ''), NULLIF(c.target, ''), r.platform, ''),
+			c.severity,
+			COALESCE(r.zone_name, ''),
+			CASE WHEN c.pass = 1 THEN 'passed' ELSE 'failed' END AS status,
+			COUNT(DISTINCT c.control_id)
+		FROM controls c
+		JOIN compliance_requirements r ON r.requirement_id = c.requirement_id
+		GROUP BY 1, 2, 3, 4, 5
+		ORDER BY 1, 2, 3, 4, 5
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query control posture: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var counts []PostureCount
+	for rows.Next() {
+		var c PostureCount
+		if err := rows.Scan(&c.Policy, &c.Platform, &c.Severity, &c.Zone, &c.Status, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan control posture: %w", err)
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// GetResourcePosture returns resource counts grouped by policy, platform, severity, account, zone, team and status.
+// Severity is the severity of the control evaluating the resource.
+func (d *Database) GetResourcePosture() ([]PostureCount, error) {
+	rows, err := d.db.Query(`
+		SELECT
+			r.policy_name,
+			COALESCE(NULLIF(cr.platform, ''), NULLIF(c.platform, ''), NULLIF(c.target, ''), r.platform, ''),
+			c.severity,
+			COALESCE(NULLIF(cr.account, ''), cr.platform_account_id, ''),
+			COALESCE(r.zone_name, ''),
+			COALESCE(o.team, ''),
+			rel.acceptance_status,
+			COUNT(DISTINCT rel.resource_hash),
+			COUNT(*)
+		FROM control_resource_relations rel
+		JOIN controls c ON c.control_id = rel.control_id
+		JOIN compliance_requirements r ON r.requirement_id = c.requirement_id
+		LEFT JOIN cloud_resources cr ON cr.hash = rel.resource_hash
+		LEFT JOIN owners o ON o.resource_hash = rel.resource_hash
+		GROUP BY 1, 2, 3, 4, 5, 6, 7
+		ORDER BY 1, 2, 3, 4, 5, 6, 7
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resource posture: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var counts []PostureCount
+	for rows.Next() {
+		var c PostureCount
+		if err := rows.Scan(&c.Policy, &c.Platform, &c.Severity, &c.Account, &c.Zone, &c.Team, &c.Status, &c.Count, &c.Evaluations); err != nil {
+			return nil, fmt.Errorf("failed to scan resource posture: %w", err)
+		}
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// PolicySummary holds the pass/fail counts of one policy in one zone
+type PolicySummary struct {
+	PolicyID           string `json:"policy_id"`
+	PolicyName         string `json:"policy_name"`
+	Zone               string `json:"zone_name"`
+	RequirementsFailed int    `json:"requirements_failed"`
+	RequirementsPassed int    `json:"requirements_passed"`
+	ControlsFailed     int    `json:"controls_failed"`
+	ControlsPassed     int    `json:"controls_passed"`
+	// Resources are distinct resources per evaluation status (a resource can fail one control and pass another)
+	ResourcesFailed   int `json:"resources_failed"`
+	ResourcesPassed   int `json:"resources_passed"`
+	ResourcesAccepted int `json:"resources_accepted"`
+}
+
+// GetPolicySummaries returns requirement, control and resource counts per policy and zone
+func (d *Database) GetPolicySummaries() ([]PolicySummary, error) {
+	rows, err := d.db.Query(`
+		SELECT
+			policy_id,
+			policy_name,
+			COALESCE(zone_name, ''),
+			SUM(CASE WHEN pass = 1 THEN 0 ELSE 1 END),
+			SUM(CASE WHEN pass = 1 THEN 1 ELSE 0 END)
+		FROM compliance_requirements
+		GROUP BY 1, 2, 3
+		ORDER BY 2, 3
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy summaries: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	type key struct{ policyID, zone string }
+	var summaries []PolicySummary
+	index := make(map[key]int)
+	for rows.Next() {
+		var s PolicySummary
+		if err := rows.Scan(&s.PolicyID, &s.PolicyName, &s.Zone, &s.RequirementsFailed, &s.RequirementsPassed); err != nil {
+			return nil, fmt.Errorf("failed to scan policy summary: %w", err)
+		}
+		index[key{s.PolicyID, s.Zone}] = len(summaries)
+		summaries = append(summaries, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	controls, err := d.db.Query(`
+		SELECT
+			r.policy_id,
+			COALESCE(r.zone_name, ''),
+			COUNT(DISTINCT CASE WHEN c.pass = 1 THEN NULL ELSE c.control_id END),
+			COUNT(DISTINCT CASE WHEN c.pass = 1 THEN c.control_id END)
+		FROM controls c
+		JOIN compliance_requirements r ON r.requirement_id = c.requirement_id
+		GROUP BY 1, 2
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy controls: %w", err)
+	}
+	defer func() { _ = controls.Close() }()
+
+	for controls.Next() {
+		var k key
+		var failed, passed int
+		if err := controls.Scan(&k.policyID, &k.zone, &failed, &passed); err != nil {
+			return nil, fmt.Errorf("failed to scan policy controls: %w", err)
+		}
+		if i, ok := index[k]; ok {
+			summaries[i].ControlsFailed = failed
+			summaries[i].ControlsPassed = passed
+		}
+	}
+	if err := controls.Err(); err != nil {
+		return nil, err
+	}
+
+	resources, err := d.db.Query(`
+		SELECT
+			r.policy_id,
+			COALESCE(r.zone_name, ''),
+			rel.acceptance_status,
+			COUNT(DISTINCT rel.resource_hash)
+		FROM control_resource_relations rel
+		JOIN controls c ON c.control_id = rel.control_id
+		JOIN compliance_requirements r ON r.requirement_id = c.requirement_id
+		GROUP BY 1, 2, 3
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query policy resources: %w", err)
+	}
+	defer func() { _ = resources.Close() }()
+
+	for resources.Next() {
+		var k key
+		var status string
+		var count int
+		if err := resources.Scan(&k.policyID, &k.zone, &status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan policy resources: %w", err)
+		}
+		i, ok := index[k]
+		if !ok {
+			continue
+		}
+		switch status {
+		case "failed":
+			summaries[i].ResourcesFailed = count
+		case "passed":
+			summaries[i].ResourcesPassed = count
+		case "accepted":
+			summaries[i].ResourcesAccepted = count
+		}
+	}
+
+	return summaries, resources.Err()
+}
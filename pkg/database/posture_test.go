@@ -0,0 +1,171 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestGetPosture(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	requirements := []models.ComplianceRequirementWithControls{
+		{
+			RequirementID: "req-1",
+			Name:          "Failed Requirement",
+			PolicyID:      "policy-1",
+			PolicyName:    "CIS AWS",
+			Severity:      "High",
+			Pass:          false,
+			Zone:          models.Zone{ID: "zone-1", Name: "Prod"},
+			Controls: []models.Control{
+				{ID: "ctrl-1", Name: "Control 1", Severity: "High", Pass: false},
+				{ID: "ctrl-2", Name: "Control 2", Severity: "Medium", Pass: true},
+			},
+		},
+		{
+			RequirementID: "req-2",
+			Name:          "Passed Requirement",
+			PolicyID:      "policy-1",
+			PolicyName:    "CIS AWS",
+			Severity:      "High",
+			Pass:          true,
+			Zone:          models.Zone{ID: "zone-1", Name: "Prod"},
+		},
+	}
+	if err := db.SaveComplianceRequirementsWithControls(requirements); err != nil {
+		t.Fatalf("Failed to save requirements: %v", err)
+	}
+
+	resources := []models.CloudResource{
+		{Hash: "hash-1", Name: "res-1", Type: "bucket", Platform: "AWS", Account: "prod", Passed: false},
+		{Hash: "hash-2", Name: "res-2", Type: "bucket", Platform: "AWS", Account: "prod", Passed: false},
+		{Hash: "hash-3", Name: "host-1", Type: "host", PlatformAccountID: "123456789012", Passed: true},
+	}
+	if err := db.SaveCloudResources(resources); err != nil {
+		t.Fatalf("Failed to save resources: %v", err)
+	}
+	if err := db.SaveControlResourceRelations("ctrl-1", resources); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+
+	t.Run("要件", func(t *testing.T) {
+		counts, err := db.GetRequirementPosture()
+		if err != nil {
+			t.Fatalf("Failed to get requirement posture: %v", err)
+		}
+		if len(counts) != 2 {
+			t.Fatalf("Expected 2 groups, got %+v", counts)
+		}
+		for _, c := range counts {
+			if c.Count != 1 || c.Zone != "Prod" || c.Policy != "CIS AWS" {
+				t.Errorf("Unexpected requirement group: %+v", c)
+			}
+		}
+	})
+
+	t.Run("コントロール", func(t *testing.T) {
+		counts, err := db.GetControlPosture()
+		if err != nil {
+			t.Fatalf("Failed to get control posture: %v", err)
+		}
+		want := map[string]string{"High": "failed", "Medium": "passed"}
+		if len(counts) != len(want) {
+			t.Fatalf("Expected %d groups, got %+v", len(want), counts)
+		}
+		for _, c := range counts {
+			if want[c.Severity] != c.Status || c.Platform != "AWS" {
+				t.Errorf("Unexpected control group: %+v", c)
+			}
+		}
+	})
+
+	t.Run("リソース", func(t *testing.T) {
+		counts, err := db.GetResourcePosture()
+		if err != nil {
+			t.Fatalf("Failed to get resource posture: %v", err)
+		}
+		got := make(map[string]int)
+		for _, c := range counts {
+			got[c.Account+"/"+c.Status] = c.Count
+			if c.Severity != "High" {
+				t.Errorf("Expected control severity High, got %+v", c)
+			}
+		}
+		if got["prod/failed"] != 2 {
+			t.Errorf("Expected 2 failed resources in prod, got %v", got)
+		}
+		// クラスタリソースはplatform_account_idで集計される
+		if got["123456789012/passed"] != 1 {
+			t.Errorf("Expected 1 passed resource in 123456789012, got %v", got)
+		}
+	})
+
+	t.Run("ポリシー別サマリー", func(t *testing.T) {
+		summaries, err := db.GetPolicySummaries()
+		if err != nil {
+			t.Fatalf("Failed to get policy summaries: %v", err)
+		}
+		if len(summaries) != 1 {
+			t.Fatalf("Expected 1 policy, got %+v", summaries)
+		}
+		s := summaries[0]
+		if s.PolicyName != "CIS AWS" || s.Zone != "Prod" {
+			t.Errorf("Unexpected policy: %+v", s)
+		}
+		if s.RequirementsFailed != 1 || s.RequirementsPassed != 1 || s.ControlsFailed != 1 || s.ControlsPassed != 1 {
+			t.Errorf("Unexpected requirement/control counts: %+v", s)
+		}
+		if s.ResourcesFailed != 2 || s.ResourcesPassed != 1 || s.ResourcesAccepted != 0 {
+			t.Errorf("Unexpected resource counts: %+v", s)
+		}
+	})
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := OpenReadOnly(filepath.Join(dir, "missing.db")); err == nil {
+		t.Error("Expected error for missing database")
+	}
+
+	dbPath := filepath.Join(dir, "test.db")
+	db, err := NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	db.Close()
+
+	ro, err := OpenReadOnly(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open read-only: %v", err)
+	}
+	defer ro.Close()
+
+	if err := ro.SaveRiskAcceptances([]models.RiskAcceptance{{ID: "a"}}); err == nil {
+		t.Error("Expected write to fail on read-only database")
+	}
+
+	t.Run("URIの特殊文字を含むパス", func(t *testing.T) {
+		// NewDatabase は素のパスを使うため別名で作成してから改名する
+		dbPath := filepath.Join(dir, "a?b#c%20d.db")
+		if err := os.Rename(filepath.Join(dir, "test.db"), dbPath); err != nil {
+			t.Fatalf("Failed to rename database: %v", err)
+		}
+
+		ro, err := OpenReadOnly(dbPath)
+		if err != nil {
+			t.Fatalf("Failed to open read-only: %v", err)
+		}
+		defer ro.Close()
+		if _, err := ro.SchemaVersion(); err != nil {
+			t.Errorf("SchemaVersion failed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/filter"
+)
+
+// queryColumns maps the fields of the query command to SQL over
+// cloud_resources cr, control_resource_relations rel, controls c and owners o
+var queryColumns = map[string]string{
+	"hash":          "cr.hash",
+	"name":          "cr.name",
+	"type":          "cr.type",
+	"kind":          "cr.type",
+	"platform":      "COALESCE(cr.platform, '')",
+	"account":       "COALESCE(NULLIF(cr.account, ''), cr.platform_account_id, '')",
+	"location":      "COALESCE(NULLIF(cr.location, ''), cr.cloud_region, '')",
+	"region":        "COALESCE(NULLIF(cr.location, ''), cr.cloud_region, '')",
+	"organization":  "COALESCE(cr.organization, '')",
+	"cluster":       "COALESCE(cr.cluster_name, '')",
+	"resourceId":    "COALESCE(cr.cloud_resource_id, '')",
+	"team":          "COALESCE(o.team, '')",
+	"status":        "rel.acceptance_status",
+	"justification": "COALESCE(rel.acceptance_justification, '')",
+	"control":       "c.control_id",
+	"controlId":     "c.control_id",
+	"controlName":   "c.name",
+	"severity":      "c.severity",
+}
+
+// QueryRow is one resource evaluated by one control
+type QueryRow struct {
+	ControlID   string `json:"control_id"`
+	ControlName string `json:"control_name"`
+	Severity    string `json:"severity"`
+	Hash        string `json:"hash"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Platform    string `json:"platform"`
+	Account     string `json:"account"`
+	Location    string `json:"location"`
+	// Team is the owning team; empty when no ownership rule matches
+	Team string `json:"team"`
+	// Status is 'failed', 'passed' or 'accepted'
+	Status        string `json:"status"`
+	Justification string `json:"justification"`
+}
+
+// QueryResources returns the control/resource pairs matching a filter such as
+// platform = "AWS" and location in ("ap-northeast-1") and status = "failed".
+// An empty filter returns every pair.
+func (d *Database) QueryResources(filterExpr string) ([]QueryRow, error) {
+	expr, err := filter.Parse(filterExpr)
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := filter.ToSQL(expr, queryColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT c.control_id, c.name, c.severity, cr.hash, cr.name, cr.type,
+		       %s, %s, %s, %s, rel.acceptance_status, %s
+		FROM control_resource_relations rel
+		JOIN cloud_resources cr ON cr.hash = rel.resource_hash
+		JOIN controls c ON c.control_id = rel.control_id
+		LEFT JOIN owners o ON o.resource_hash = cr.hash`,
+		queryColumns["platform"], queryColumns["account"], queryColumns["location"], queryColumns["team"], queryColumns["justification"])
+	if where != "" {
+		query += "\n\t\tWHERE " + where
+	}
+	query += "\n\t\tORDER BY c.control_id, cr.name, cr.hash"
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resources: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var result []QueryRow
+	for rows.Next() {
+		var r QueryRow
+		if err := rows.Scan(&r.ControlID, &r.ControlName, &r.Severity, &r.Hash, &r.Name, &r.Type,
+			&r.Platform, &r.Account, &r.Location, &r.Team, &r.Status, &r.Justification); err != nil {
+			return nil, fmt.Errorf("failed to scan resource: %w", err)
+		}
+		result = append(result, r)
+	}
+
+	return result, rows.Err()
+}
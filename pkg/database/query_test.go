@@ -0,0 +1,76 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestQueryResources(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	requirements := []models.ComplianceRequirementWithControls{
+		{
+			RequirementID: "req-1",
+			Name:          "Ensure buckets are private",
+			PolicyName:    "CIS AWS",
+			Severity:      "High",
+			Controls: []models.Control{
+				{ID: "16022", Name: "Bucket ACL", Severity: "High"},
+				{ID: "16031", Name: "Bucket logging", Severity: "Low"},
+			},
+		},
+	}
+	if err := db.SaveComplianceRequirementsWithControls(requirements); err != nil {
+		t.Fatalf("Failed to save requirements: %v", err)
+	}
+	resources := []models.CloudResource{
+		{Hash: "h1", Name: "bucket-a", Platform: "AWS", Account: "111", Location: "ap-northeast-1"},
+		{Hash: "h2", Name: "bucket-b", Platform: "AWS", Account: "111", Location: "us-east-1"},
+		{Hash: "h3", Name: "node-1", Platform: "Kubernetes", PlatformAccountID: "222", CloudRegion: "ap-northeast-1", Passed: true},
+	}
+	if err := db.SaveCloudResources(resources); err != nil {
+		t.Fatalf("Failed to save resources: %v", err)
+	}
+	if err := db.SaveControlResourceRelations("16022", resources); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+	if err := db.SaveControlResourceRelations("16031", resources[:1]); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+
+	cases := map[string]int{
+		"": 4,
+		`platform = "AWS" and location in ("ap-northeast-1") and status = "failed"`: 2,
+		`location = "ap-northeast-1" and severity = "High"`:                         2,
+		`account = "222" or name contains "-b"`:                                     2,
+		`not (status = "failed")`:                                                   1,
+		`controlName startsWith "Bucket l"`:                                         1,
+	}
+	for filter, want := range cases {
+		rows, err := db.QueryResources(filter)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", filter, err)
+			continue
+		}
+		if len(rows) != want {
+			t.Errorf("%s: expected %d rows, got %d: %+v", filter, want, len(rows), rows)
+		}
+	}
+
+	rows, _ := db.QueryResources(`hash = "h3"`)
+	if len(rows) != 1 || rows[0].Account != "222" || rows[0].Location != "ap-northeast-1" || rows[0].Status != "passed" {
+		t.Errorf("Expected Cluster Analysis columns as fallback, got %+v", rows)
+	}
+
+	for _, bad := range []string{`bogus = "x"`, `name in (`} {
+		if _, err := db.QueryResources(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
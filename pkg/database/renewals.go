@@ -0,0 +1,115 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const createRiskAcceptanceRenewalsTable = `
+	CREATE TABLE IF NOT EXISTS risk_acceptance_renewals (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		old_id TEXT NOT NULL,                 -- 取り消した受容のID
+		new_id TEXT NOT NULL,                 -- 再作成した受容のID
+		control_id TEXT,
+		original_id TEXT NOT NULL,            -- 更新の連鎖の最初の受容
+		original_username TEXT,               -- 最初に受容したユーザー
+		previous_expires_at TEXT,
+		new_expires_at TEXT,
+		renewed_at TIMESTAMP NOT NULL
+	)`
+
+// RiskAcceptanceRenewal links a revoked risk acceptance to the one that replaced it
+type RiskAcceptanceRenewal struct {
+	ID        int64
+	OldID     string
+	NewID     string
+	ControlID string
+	// OriginalID and OriginalUsername carry the first acceptance through repeated renewals
+	OriginalID        string
+	OriginalUsername  string
+	PreviousExpiresAt string
+	NewExpiresAt      string
+	RenewedAt         time.Time
+}
+
+// RecordRiskAcceptanceRenewal saves a renewal and sets its ID. When the old acceptance was itself
+// a renewal, the original acceptance and user are taken from that earlier renewal.
+func (d *Database) RecordRiskAcceptanceRenewal(r *RiskAcceptanceRenewal) error {
+	var originalID, originalUsername sql.NullString
+	err := d.db.QueryRow(`
+		SELECT original_id, original_username FROM risk_acceptance_renewals
+		WHERE new_id = ? ORDER BY id DESC LIMIT 1`, r.OldID).Scan(&originalID, &originalUsername)
+	switch {
+	case err == nil:
+		r.OriginalID = originalID.String
+		r.OriginalUsername = originalUsername.String
+	case errors.Is(err, sql.ErrNoRows):
+		if r.OriginalID == "" {
+			r.OriginalID = r.OldID
+		}
+	default:
+		return fmt.Errorf("failed to look up previous renewal of %s: %w", r.OldID, err)
+	}
+
+	result, err := d.db.Exec(`
+		INSERT INTO risk_acceptance_renewals (
+			old_id, new_id, control_id, original_id, original_username,
+			previous_expires_at, new_expires_at, renewed_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.OldID, r.NewID, nullString(r.ControlID), r.OriginalID, nullString(r.OriginalUsername),
+		nullString(r.PreviousExpiresAt), nullString(r.NewExpiresAt), r.RenewedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record renewal of risk acceptance %s: %w", r.OldID, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get renewal id: %w", err)
+	}
+	r.ID = id
+
+	return nil
+}
+
+// GetRiskAcceptanceRenewals returns renewals in the order they were recorded.
+// A non-empty acceptanceID limits the result to renewals that revoked or created that acceptance.
+func (d *Database) GetRiskAcceptanceRenewals(acceptanceID string) ([]RiskAcceptanceRenewal, error) {
+	query := `
+		SELECT id, old_id, new_id, control_id, original_id, original_username,
+		       previous_expires_at, new_expires_at, renewed_at
+		FROM risk_acceptance_renewals`
+	args := []interface{}{}
+	if acceptanceID != "" {
+		query += " WHERE old_id = ? OR new_id = ?"
+		args = append(args, acceptanceID, acceptanceID)
+	}
+	query += " ORDER BY renewed_at, id"
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query risk acceptance renewals: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var renewals []RiskAcceptanceRenewal
+	for rows.Next() {
+		var r RiskAcceptanceRenewal
+		var controlID, originalUsername, previousExpiresAt, newExpiresAt sql.NullString
+		if err := rows.Scan(
+			&r.ID, &r.OldID, &r.NewID, &controlID, &r.OriginalID, &originalUsername,
+			&previousExpiresAt, &newExpiresAt, &r.RenewedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan risk acceptance renewal: %w", err)
+		}
+		r.ControlID = controlID.String
+		r.OriginalUsername = originalUsername.String
+		r.PreviousExpiresAt = previousExpiresAt.String
+		r.NewExpiresAt = newExpiresAt.String
+		renewals = append(renewals, r)
+	}
+
+	return renewals, rows.Err()
+}
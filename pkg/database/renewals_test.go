@@ -0,0 +1,45 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRiskAcceptanceRenewals(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now().Truncate(time.Second)
+	first := &RiskAcceptanceRenewal{OldID: "a1", NewID: "a2", ControlID: "16022", OriginalUsername: "alice@example.com", NewExpiresAt: "1", RenewedAt: now.Add(-time.Hour)}
+	if err := db.RecordRiskAcceptanceRenewal(first); err != nil {
+		t.Fatalf("RecordRiskAcceptanceRenewal failed: %v", err)
+	}
+	// 2回目の更新では最初の受容とユーザーを引き継ぐ
+	second := &RiskAcceptanceRenewal{OldID: "a2", NewID: "a3", ControlID: "16022", OriginalUsername: "renewer@example.com", RenewedAt: now}
+	if err := db.RecordRiskAcceptanceRenewal(second); err != nil {
+		t.Fatalf("RecordRiskAcceptanceRenewal failed: %v", err)
+	}
+	if first.OriginalID != "a1" || second.OriginalID != "a1" || second.OriginalUsername != "alice@example.com" {
+		t.Errorf("Expected the original acceptance to carry through, got %+v / %+v", first, second)
+	}
+
+	renewals, err := db.GetRiskAcceptanceRenewals("")
+	if err != nil {
+		t.Fatalf("GetRiskAcceptanceRenewals failed: %v", err)
+	}
+	if len(renewals) != 2 || renewals[0].NewID != "a2" || !renewals[1].RenewedAt.Equal(now) {
+		t.Fatalf("Unexpected renewals: %+v", renewals)
+	}
+
+	renewals, err = db.GetRiskAcceptanceRenewals("a3")
+	if err != nil {
+		t.Fatalf("GetRiskAcceptanceRenewals failed: %v", err)
+	}
+	if len(renewals) != 1 || renewals[0].OldID != "a2" {
+		t.Errorf("Expected the renewal that created a3, got %+v", renewals)
+	}
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const createCollectionRunsTable = `
+	CREATE TABLE IF NOT EXISTS collection_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		target TEXT,                          -- プランのターゲット名（単発のcollectでは空）
+		policy TEXT,
+		platform TEXT,
+		zone TEXT,
+		started_at TIMESTAMP NOT NULL,
+		finished_at TIMESTAMP NOT NULL,
+		status TEXT NOT NULL,                 -- 'success', 'failed'
+		requirements INTEGER DEFAULT 0,
+		controls INTEGER DEFAULT 0,
+		resources INTEGER DEFAULT 0,
+		api_errors INTEGER DEFAULT 0,         -- リソース取得に失敗したコントロール数
+		error TEXT
+	)`
+
+// Collection run statuses
+const (
+	RunStatusSuccess = "success"
+	RunStatusFailed  = "failed"
+)
+
+// CollectionRun is one collection recorded in collection_runs
+type CollectionRun struct {
+	ID           int64
+	Target       string
+	Policy       string
+	Platform     string
+	Zone         string
+	StartedAt    time.Time
+	FinishedAt   time.Time
+	Status       string
+	Requirements int
+	Controls     int
+	Resources    int
+	APIErrors    int
+	Error        string
+}
+
+// Duration returns how long the run took
+func (r CollectionRun) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// RecordCollectionRun saves a run to collection_runs and sets its ID
+func (d *Database) RecordCollectionRun(run *CollectionRun) error {
+	result, err := d.db.Exec(`
+		INSERT INTO collection_runs (
+			target, policy, platform, zone, started_at, finished_at, status,
+			requirements, controls, resources, api_errors, error
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.Target, run.Policy, run.Platform, run.Zone,
+		run.StartedAt.UTC(), run.FinishedAt.UTC(), run.Status,
+		run.Requirements, run.Controls, run.Resources, run.APIErrors, nullString(run.Error),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record collection run: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get collection run id: %w", err)
+	}
+	run.ID = id
+
+	return nil
+}
+
+// GetCollectionRuns returns the most recent runs first (limit <= 0 returns all)
+func (d *Database) GetCollectionRuns(limit int) ([]CollectionRun, error) {
+	query := `
+		SELECT id, target, policy, platform, zone, started_at, finished_at, status,
+		       requirements, controls, resources, api_errors, error
+		FROM collection_runs
+		ORDER BY started_at DESC, id DESC`
+	args := []interface{}{}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collection runs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var runs []CollectionRun
+	for rows.Next() {
+		var run CollectionRun
+		var target, policy, platform, zone, runErr sql.NullString
+		if err := rows.Scan(
+			&run.ID, &target, &policy, &platform, &zone,
+			&run.StartedAt, &run.FinishedAt, &run.Status,
+			&run.Requirements, &run.Controls, &run.Resources, &run.APIErrors, &runErr,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan collection run: %w", err)
+		}
+		run.Target = target.String
+		run.Policy = policy.String
+		run.Platform = platform.String
+		run.Zone = zone.String
+		run.Error = runErr.String
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// GetLastCollectionRun returns the most recent run, or nil when none has been recorded
+func (d *Database) GetLastCollectionRun() (*CollectionRun, error) {
+	runs, err := d.GetCollectionRuns(1)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, nil
+	}
+	return &runs[0], nil
+}
+
+// PruneCollectionRuns deletes runs and posture snapshots from before the given time
+// and returns the number of runs deleted
+func (d *Database) PruneCollectionRuns(before time.Time) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.Exec("DELETE FROM collection_runs WHERE started_at < ?", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune collection runs: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM posture_snapshots WHERE taken_at < ?", before.UTC()); err != nil {
+		return 0, fmt.Errorf("failed to prune posture snapshots: %w", err)
+	}
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return pruned, tx.Commit()
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCollectionRuns(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	last, err := db.GetLastCollectionRun()
+	if err != nil {
+		t.Fatalf("Failed to get last run: %v", err)
+	}
+	if last != nil {
+		t.Fatalf("Expected no runs, got %+v", last)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	runs := []*CollectionRun{
+		{Target: "aws", Policy: "CIS", StartedAt: now.AddDate(0, 0, -40), FinishedAt: now.AddDate(0, 0, -40).Add(time.Minute), Status: RunStatusSuccess},
+		{Target: "aws", Policy: "CIS", StartedAt: now.Add(-time.Hour), FinishedAt: now, Status: RunStatusFailed, APIErrors: 2, Error: "boom"},
+	}
+	for _, r := range runs {
+		if err := db.RecordCollectionRun(r); err != nil {
+			t.Fatalf("Failed to record run: %v", err)
+		}
+		if r.ID == 0 {
+			t.Error("Expected run ID to be set")
+		}
+	}
+
+	last, err = db.GetLastCollectionRun()
+	if err != nil {
+		t.Fatalf("Failed to get last run: %v", err)
+	}
+	if last.Status != RunStatusFailed || last.APIErrors != 2 || last.Error != "boom" {
+		t.Errorf("Unexpected last run: %+v", last)
+	}
+	if last.Duration() != time.Hour {
+		t.Errorf("Expected duration 1h, got %s", last.Duration())
+	}
+
+	pruned, err := db.PruneCollectionRuns(now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("Failed to prune runs: %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("Expected 1 pruned run, got %d", pruned)
+	}
+
+	remaining, err := db.GetCollectionRuns(0)
+	if err != nil {
+		t.Fatalf("Failed to get runs: %v", err)
+	}
+	if len(remaining) != 1 {
+		t.Errorf("Expected 1 remaining run, got %d", len(remaining))
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	lock, err := AcquireLock(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	// 同じDBへの2つ目のロックは失敗する
+	if _, err := AcquireLock(dbPath); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+
+	lock, err = AcquireLock(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock after release: %v", err)
+	}
+	_ = lock.Release()
+}
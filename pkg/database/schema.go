@@ -3,6 +3,9 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
 
 	// SQLite3ドライバーを読み込む
 	_ "github.com/mattn/go-sqlite3"
@@ -166,40 +169,65 @@ type Database struct {
 	db *sql.DB
 }
 
-// NewDatabase creates a new database connection and initializes the schema
+// NewDatabase creates a new database connection and applies pending schema migrations
 func NewDatabase(dbPath string) (*Database, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	database, err := OpenDatabase(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	database := &Database{db: db}
-	if err := database.initialize(); err != nil {
-		_ = db.Close()
+	if _, err := database.Migrate(); err != nil {
+		_ = database.Close()
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
 	return database, nil
 }
 
-// initialize creates the database schema
-func (d *Database) initialize() error {
-	queries := []string{
-		createComplianceRequirementsTable,
-		createControlsTable,
-		createCloudResourcesTable,
-		createControlResourceRelationsTable,
-		createRiskAcceptancesTable,
-		createIndexes,
+// OpenDatabase opens a database connection without applying migrations
+func OpenDatabase(dbPath string) (*Database, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &Database{db: db}, nil
+}
+
+// OpenReadOnly opens an existing database for reading only.
+// The database must be at the schema version of this build; run db-migrate for older files.
+func OpenReadOnly(dbPath string) (*Database, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// パスに ? や # を含む場合に備えてURIとしてエスケープする
+	absPath, err := filepath.Abs(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	// 収集中の書き込みと競合した場合に備えてbusy_timeoutを設定する
+	dsn := url.URL{Scheme: "file", Path: filepath.ToSlash(absPath), RawQuery: "mode=ro&_busy_timeout=5000"}
+	db, err := sql.Open("sqlite3", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	database := &Database{db: db}
 
-	for _, query := range queries {
-		if _, err := d.db.Exec(query); err != nil {
-			return fmt.Errorf("failed to execute schema query: %w", err)
-		}
+	version, err := database.SchemaVersion()
+	if err != nil {
+		_ = database.Close()
+		return nil, err
+	}
+	if latest := LatestSchemaVersion(); version > latest {
+		_ = database.Close()
+		return nil, fmt.Errorf("%w: %s is at version %d but this build supports up to version %d", ErrSchemaTooNew, dbPath, version, latest)
+	} else if version < latest {
+		_ = database.Close()
+		return nil, fmt.Errorf("%w: %s is at version %d, run db-migrate to upgrade it to version %d", ErrSchemaOutdated, dbPath, version, latest)
 	}
 
-	return nil
+	return database, nil
 }
 
 // Close closes the database connection
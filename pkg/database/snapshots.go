@@ -0,0 +1,204 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const createPostureSnapshotsTable = `
+	CREATE TABLE IF NOT EXISTS posture_snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id INTEGER,                       -- collection_runs.id
+		taken_at TIMESTAMP NOT NULL,
+		dimension TEXT NOT NULL,              -- 'total', 'policy', 'requirement', 'severity', 'account', 'team'
+		value TEXT NOT NULL,
+		level TEXT NOT NULL,                  -- 'requirements', 'controls', 'resources'
+		status TEXT NOT NULL,                 -- 'failed', 'passed', 'accepted'
+		count INTEGER NOT NULL
+	)`
+
+// Trend dimensions; DimensionTotal has an empty value
+const (
+	DimensionTotal       = "total"
+	DimensionPolicy      = "policy"
+	DimensionRequirement = "requirement"
+	DimensionSeverity    = "severity"
+	DimensionAccount     = "account"
+	DimensionTeam        = "team"
+)
+
+// Dimensions lists the trend dimensions in display order
+var Dimensions = []string{DimensionTotal, DimensionPolicy, DimensionRequirement, DimensionSeverity, DimensionAccount, DimensionTeam}
+
+// TrendCount is the number of distinct requirements, controls or resources with one status
+// for one value of a dimension (e.g. failed resources of account "prod")
+type TrendCount struct {
+	Dimension string `json:"dimension"`
+	Value     string `json:"value"`
+	// Level is requirements, controls or resources
+	Level  string `json:"level"`
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+}
+
+// PostureSnapshot is the posture recorded at the end of a collection run
+type PostureSnapshot struct {
+	RunID   int64
+	TakenAt time.Time
+	Counts  []TrendCount
+}
+
+// trendQuery counts one level by status
+type trendQuery struct {
+	level  string
+	status string
+	count  string
+	from   string
+	// dimensions maps a dimension to its expression; dimensions without one do not apply to the level
+	dimensions map[string]string
+}
+
+var trendQueries = []trendQuery{
+	{
+		level:  "requirements",
+		status: "CASE WHEN r.pass = 1 THEN 'passed' ELSE 'failed' END",
+		count:  "COUNT(DISTINCT r.requirement_id)",
+		from:   "compliance_requirements r",
+		dimensions: map[string]string{
+			DimensionTotal:       "''",
+			DimensionPolicy:      "COALESCE(r.policy_name, '')",
+			DimensionRequirement: "COALESCE(r.name, '')",
+			DimensionSeverity:    "COALESCE(r.severity, '')",
+		},
+	},
+	{
+		level:  "controls",
+		status: "CASE WHEN c.pass = 1 THEN 'passed' ELSE 'failed' END",
+		count:  "COUNT(DISTINCT c.control_id)",
+		from:   "controls c JOIN compliance_requirements r ON r.requirement_id = c.requirement_id",
+		dimensions: map[string]string{
+			DimensionTotal:       "''",
+			DimensionPolicy:      "COALESCE(r.policy_name, '')",
+			DimensionRequirement: "COALESCE(r.name, '')",
+			DimensionSeverity:    "COALESCE(c.severity, '')",
+		},
+	},
+	{
+		// リソースは評価ステータスごとの重複なし件数（あるコントロールでFailed、別のコントロールでPassedの場合は両方に数える）
+		level:  "resources",
+		status: "rel.acceptance_status",
+		count:  "COUNT(DISTINCT rel.resource_hash)",
+		from: `control_resource_relations rel
+			JOIN controls c ON c.control_id = rel.control_id
+			JOIN compliance_requirements r ON r.requirement_id = c.requirement_id
+			LEFT JOIN cloud_resources cr ON cr.hash = rel.resource_hash
+			LEFT JOIN owners o ON o.resource_hash = rel.resource_hash`,
+		dimensions: map[string]string{
+			DimensionTotal:       "''",
+			DimensionPolicy:      "COALESCE(r.policy_name, '')",
+			DimensionRequirement: "COALESCE(r.name, '')",
+			DimensionSeverity:    "COALESCE(c.severity, '')",
+			DimensionAccount:     "COALESCE(NULLIF(cr.account, ''), cr.platform_account_id, '')",
+			DimensionTeam:        "COALESCE(o.team, '')",
+		},
+	},
+}
+
+// GetTrendCounts returns the current counts of every dimension
+func (d *Database) GetTrendCounts() ([]TrendCount, error) {
+	var counts []TrendCount
+	for _, dimension := range Dimensions {
+		for _, q := range trendQueries {
+			expr, ok := q.dimensions[dimension]
+			if !ok {
+				continue
+			}
+
+			rows, err := d.db.Query(fmt.Sprintf(`
+				SELECT %s, %s, %s
+				FROM %s
+				GROUP BY 1, 2
+				ORDER BY 1, 2`, expr, q.status, q.count, q.from))
+			if err != nil {
+				return nil, fmt.Errorf("failed to query %s counts by %s: %w", q.level, dimension, err)
+			}
+
+			for rows.Next() {
+				c := TrendCount{Dimension: dimension, Level: q.level}
+				if err := rows.Scan(&c.Value, &c.Status, &c.Count); err != nil {
+					_ = rows.Close()
+					return nil, fmt.Errorf("failed to scan %s counts: %w", q.level, err)
+				}
+				counts = append(counts, c)
+			}
+			err = rows.Err()
+			_ = rows.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return counts, nil
+}
+
+// SavePostureSnapshot records the current counts of every dimension for a collection run
+func (d *Database) SavePostureSnapshot(runID int64, takenAt time.Time) error {
+	counts, err := d.GetTrendCounts()
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO posture_snapshots (run_id, taken_at, dimension, value, level, status, count)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for _, c := range counts {
+		if _, err := stmt.Exec(runID, takenAt.UTC(), c.Dimension, c.Value, c.Level, c.Status, c.Count); err != nil {
+			return fmt.Errorf("failed to save posture snapshot: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetPostureSnapshots returns the recorded snapshots, oldest first
+func (d *Database) GetPostureSnapshots() ([]PostureSnapshot, error) {
+	rows, err := d.db.Query(`
+		SELECT run_id, taken_at, dimension, value, level, status, count
+		FROM posture_snapshots
+		ORDER BY taken_at, run_id, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query posture snapshots: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var snapshots []PostureSnapshot
+	for rows.Next() {
+		var runID sql.NullInt64
+		var takenAt time.Time
+		var c TrendCount
+		if err := rows.Scan(&runID, &takenAt, &c.Dimension, &c.Value, &c.Level, &c.Status, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan posture snapshot: %w", err)
+		}
+
+		last := len(snapshots) - 1
+		if last < 0 || snapshots[last].RunID != runID.Int64 || !snapshots[last].TakenAt.Equal(takenAt) {
+			snapshots = append(snapshots, PostureSnapshot{RunID: runID.Int64, TakenAt: takenAt})
+			last++
+		}
+		snapshots[last].Counts = append(snapshots[last].Counts, c)
+	}
+
+	return snapshots, rows.Err()
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestPostureSnapshots(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	requirements := []models.ComplianceRequirementWithControls{
+		{
+			RequirementID: "req-1",
+			Name:          "Ensure buckets are private",
+			PolicyName:    "CIS AWS",
+			Severity:      "High",
+			Controls: []models.Control{
+				{ID: "ctrl-1", Name: "Bucket ACL", Severity: "High"},
+				{ID: "ctrl-2", Name: "Bucket policy", Severity: "Medium", Pass: true},
+			},
+		},
+	}
+	if err := db.SaveComplianceRequirementsWithControls(requirements); err != nil {
+		t.Fatalf("Failed to save requirements: %v", err)
+	}
+	resources := []models.CloudResource{
+		{Hash: "hash-1", Name: "bucket-a", Account: "prod"},
+		{Hash: "hash-2", Name: "bucket-b", Account: "prod", Acceptance: &models.Acceptance{Justification: "Risk Owned"}},
+		{Hash: "hash-3", Name: "host-1", PlatformAccountID: "123456789012"},
+	}
+	if err := db.SaveCloudResources(resources); err != nil {
+		t.Fatalf("Failed to save resources: %v", err)
+	}
+	if err := db.SaveControlResourceRelations("ctrl-1", resources); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+
+	t.Run("現在の件数", func(t *testing.T) {
+		counts, err := db.GetTrendCounts()
+		if err != nil {
+			t.Fatalf("GetTrendCounts failed: %v", err)
+		}
+		got := make(map[TrendCount]bool)
+		for _, c := range counts {
+			got[c] = true
+		}
+		for _, want := range []TrendCount{
+			{Dimension: DimensionTotal, Value: "", Level: "requirements", Status: "failed", Count: 1},
+			{Dimension: DimensionSeverity, Value: "Medium", Level: "controls", Status: "passed", Count: 1},
+			{Dimension: DimensionPolicy, Value: "CIS AWS", Level: "resources", Status: "failed", Count: 2},
+			{Dimension: DimensionAccount, Value: "prod", Level: "resources", Status: "accepted", Count: 1},
+			{Dimension: DimensionAccount, Value: "123456789012", Level: "resources", Status: "failed", Count: 1},
+		} {
+			if !got[want] {
+				t.Errorf("Missing count %+v in %+v", want, counts)
+			}
+		}
+		// アカウントはリソースのみ
+		for _, c := range counts {
+			if c.Dimension == DimensionAccount && c.Level != "resources" {
+				t.Errorf("Unexpected account count for %s", c.Level)
+			}
+		}
+	})
+
+	t.Run("スナップショットの保存と削除", func(t *testing.T) {
+		now := time.Now().Truncate(time.Second)
+		if err := db.SavePostureSnapshot(1, now.AddDate(0, 0, -40)); err != nil {
+			t.Fatalf("SavePostureSnapshot failed: %v", err)
+		}
+		if err := db.SavePostureSnapshot(2, now); err != nil {
+			t.Fatalf("SavePostureSnapshot failed: %v", err)
+		}
+
+		snapshots, err := db.GetPostureSnapshots()
+		if err != nil {
+			t.Fatalf("GetPostureSnapshots failed: %v", err)
+		}
+		if len(snapshots) != 2 || snapshots[0].RunID != 1 || !snapshots[1].TakenAt.Equal(now) {
+			t.Fatalf("Unexpected snapshots: %+v", snapshots)
+		}
+		if len(snapshots[0].Counts) != len(snapshots[1].Counts) || len(snapshots[0].Counts) == 0 {
+			t.Errorf("Expected the same counts in both snapshots")
+		}
+
+		if _, err := db.PruneCollectionRuns(now.AddDate(0, 0, -30)); err != nil {
+			t.Fatalf("PruneCollectionRuns failed: %v", err)
+		}
+		snapshots, err = db.GetPostureSnapshots()
+		if err != nil {
+			t.Fatalf("GetPostureSnapshots failed: %v", err)
+		}
+		if len(snapshots) != 1 || snapshots[0].RunID != 2 {
+			t.Errorf("Expected only the recent snapshot, got %+v", snapshots)
+		}
+	})
+}
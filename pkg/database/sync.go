@@ -0,0 +1,215 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+// RiskAcceptanceSyncResult counts the changes made by SyncRiskAcceptances
+type RiskAcceptanceSyncResult struct {
+	// Added includes acceptances that were marked revoked and are returned by the API again
+	Added     int
+	Changed   int
+	Removed   int
+	Unchanged int
+}
+
+// SyncRiskAcceptances reconciles the stored risk acceptances with the ones returned by the API.
+// New acceptances are inserted and changed ones updated. When complete is true, acceptances is the
+// full API state and stored acceptances missing from it are marked revoked at now; an incremental
+// collection only sees the newest acceptances and never marks anything revoked.
+func (d *Database) SyncRiskAcceptances(acceptances []models.RiskAcceptance, complete bool, now time.Time) (*RiskAcceptanceSyncResult, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.Query(`
+		SELECT id, tenant_id, control_id, description, reason,
+		       acceptance_date, username, user_display_name,
+		       filter, zone_id, accept_period, expires_at,
+		       is_expired, is_system, type, source_id
+		FROM risk_acceptances
+		WHERE revoked_at IS NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query risk acceptances: %w", err)
+	}
+	stored, err := scanRiskAcceptances(rows)
+	_ = rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[string]models.RiskAcceptance, len(stored))
+	for _, a := range stored {
+		active[a.ID] = a
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO risk_acceptances (
+			id, tenant_id, control_id, description, reason,
+			acceptance_date, username, user_display_name,
+			filter, zone_id, accept_period, expires_at,
+			is_expired, is_system, type, source_id, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			tenant_id = excluded.tenant_id,
+			control_id = excluded.control_id,
+			description = excluded.description,
+			reason = excluded.reason,
+			acceptance_date = excluded.acceptance_date,
+			username = excluded.username,
+			user_display_name = excluded.user_display_name,
+			filter = excluded.filter,
+			zone_id = excluded.zone_id,
+			accept_period = excluded.accept_period,
+			expires_at = excluded.expires_at,
+			is_expired = excluded.is_expired,
+			is_system = excluded.is_system,
+			type = excluded.type,
+			source_id = excluded.source_id,
+			updated_at = excluded.updated_at,
+			revoked_at = NULL
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	result := &RiskAcceptanceSyncResult{}
+	seen := make(map[string]bool, len(acceptances))
+	for _, acc := range acceptances {
+		// ページング中に受容が追加されると同じ受容が2ページに現れることがある
+		if seen[acc.ID] {
+			continue
+		}
+		seen[acc.ID] = true
+
+		old, ok := active[acc.ID]
+		switch {
+		case !ok:
+			result.Added++
+		case old != acc:
+			result.Changed++
+		default:
+			result.Unchanged++
+			continue
+		}
+
+		_, err = stmt.Exec(
+			acc.ID,
+			acc.TenantID,
+			acc.ControlID,
+			nullString(acc.Description),
+			nullString(acc.Reason),
+			acc.AcceptanceDate,
+			nullString(acc.Username),
+			nullString(acc.UserDisplayName),
+			nullString(acc.Filter),
+			nullString(acc.ZoneID),
+			nullString(acc.AcceptPeriod),
+			nullString(acc.ExpiresAt),
+			acc.IsExpired,
+			acc.IsSystem,
+			acc.Type,
+			nullString(acc.SourceID),
+			now.UTC(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save risk acceptance %s: %w", acc.ID, err)
+		}
+	}
+
+	if complete {
+		for id := range active {
+			if seen[id] {
+				continue
+			}
+			if _, err := tx.Exec("UPDATE risk_acceptances SET revoked_at = ?, updated_at = ? WHERE id = ?", now.UTC(), now.UTC(), id); err != nil {
+				return nil, fmt.Errorf("failed to mark risk acceptance %s revoked: %w", id, err)
+			}
+			if _, err := tx.Exec("DELETE FROM acceptance_resource_matches WHERE acceptance_id = ?", id); err != nil {
+				return nil, fmt.Errorf("failed to delete resource matches of %s: %w", id, err)
+			}
+			result.Removed++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit risk acceptances: %w", err)
+	}
+	return result, nil
+}
+
+// GetLatestRiskAcceptance returns the stored risk acceptance with the newest acceptanceDate,
+// including revoked ones, or nil when there is none
+func (d *Database) GetLatestRiskAcceptance() (*models.RiskAcceptance, error) {
+	rows, err := d.db.Query(`
+		SELECT id, tenant_id, control_id, description, reason,
+		       acceptance_date, username, user_display_name,
+		       filter, zone_id, accept_period, expires_at,
+		       is_expired, is_system, type, source_id
+		FROM risk_acceptances
+		ORDER BY CAST(acceptance_date AS INTEGER) DESC, id DESC
+		LIMIT 1`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest risk acceptance: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	acceptances, err := scanRiskAcceptances(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(acceptances) == 0 {
+		return nil, nil
+	}
+	return &acceptances[0], nil
+}
+
+// GetRiskAcceptanceRevokedAt returns when a risk acceptance was marked revoked; false means it is active
+func (d *Database) GetRiskAcceptanceRevokedAt(id string) (time.Time, bool, error) {
+	var revokedAt sql.NullTime
+	err := d.db.QueryRow("SELECT revoked_at FROM risk_acceptances WHERE id = ?", id).Scan(&revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, fmt.Errorf("risk acceptance %s not found", id)
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to query risk acceptance %s: %w", id, err)
+	}
+	return revokedAt.Time, revokedAt.Valid, nil
+}
+
+// MarkRiskAcceptanceRevoked marks a risk acceptance revoked at now and drops its resource matches,
+// keeping the row as history like SyncRiskAcceptances does. An already revoked acceptance keeps
+// its original timestamp.
+func (d *Database) MarkRiskAcceptanceRevoked(id string, now time.Time) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM risk_acceptances WHERE id = ?)", id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to query risk acceptance %s: %w", id, err)
+	}
+	if !exists {
+		return fmt.Errorf("risk acceptance %s not found", id)
+	}
+	if _, err := tx.Exec("UPDATE risk_acceptances SET revoked_at = ?, updated_at = ? WHERE id = ? AND revoked_at IS NULL", now.UTC(), now.UTC(), id); err != nil {
+		return fmt.Errorf("failed to mark risk acceptance %s revoked: %w", id, err)
+	}
+	if _, err := tx.Exec("DELETE FROM acceptance_resource_matches WHERE acceptance_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete resource matches of %s: %w", id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit risk acceptance %s: %w", id, err)
+	}
+	return nil
+}
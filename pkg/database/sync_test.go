@@ -0,0 +1,119 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestSyncRiskAcceptances(t *testing.T) {
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	acceptance := func(id, date, reason string) models.RiskAcceptance {
+		return models.RiskAcceptance{ID: id, TenantID: "t1", ControlID: "16022", AcceptanceDate: date, Reason: reason, Type: 1}
+	}
+	now := time.Now().Truncate(time.Second)
+
+	result, err := db.SyncRiskAcceptances([]models.RiskAcceptance{
+		acceptance("a1", "1760000001000", "Risk Owned"),
+		acceptance("a2", "1760000002000", "Risk Owned"),
+		acceptance("a3", "1760000003000", "Risk Owned"),
+	}, true, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("SyncRiskAcceptances failed: %v", err)
+	}
+	if result.Added != 3 || result.Changed != 0 || result.Removed != 0 {
+		t.Errorf("Unexpected first sync result: %+v", result)
+	}
+
+	// a1はUIで取り消され、a2は理由が変更され、a4が追加された
+	result, err = db.SyncRiskAcceptances([]models.RiskAcceptance{
+		acceptance("a2", "1760000002000", "Mitigated"),
+		acceptance("a3", "1760000003000", "Risk Owned"),
+		acceptance("a4", "1760000004000", "Risk Owned"),
+	}, true, now)
+	if err != nil {
+		t.Fatalf("SyncRiskAcceptances failed: %v", err)
+	}
+	if *result != (RiskAcceptanceSyncResult{Added: 1, Changed: 1, Removed: 1, Unchanged: 1}) {
+		t.Errorf("Unexpected second sync result: %+v", result)
+	}
+
+	active, err := db.GetRiskAcceptances("")
+	if err != nil {
+		t.Fatalf("GetRiskAcceptances failed: %v", err)
+	}
+	if len(active) != 3 || active[0].ID != "a4" || active[1].Reason != "Risk Owned" || active[2].Reason != "Mitigated" {
+		t.Errorf("Unexpected active acceptances: %+v", active)
+	}
+
+	revokedAt, revoked, err := db.GetRiskAcceptanceRevokedAt("a1")
+	if err != nil {
+		t.Fatalf("GetRiskAcceptanceRevokedAt failed: %v", err)
+	}
+	if !revoked || !revokedAt.Equal(now) {
+		t.Errorf("Expected a1 to be revoked at %v, got %v/%v", now, revokedAt, revoked)
+	}
+
+	listed, err := db.List(RiskAcceptancesListing, ListQuery{Filters: []FilterValue{{Name: "revoked", Value: "true"}}})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if listed.Total != 1 || listed.Rows[0]["id"] != "a1" || listed.Rows[0]["revoked_at"] == nil {
+		t.Errorf("Expected a1 to be listed as revoked, got %+v", listed.Rows)
+	}
+
+	t.Run("差分収集では取り消しを判定しない", func(t *testing.T) {
+		result, err := db.SyncRiskAcceptances([]models.RiskAcceptance{acceptance("a5", "1760000005000", "Risk Owned")}, false, now)
+		if err != nil {
+			t.Fatalf("SyncRiskAcceptances failed: %v", err)
+		}
+		if *result != (RiskAcceptanceSyncResult{Added: 1}) {
+			t.Errorf("Unexpected incremental sync result: %+v", result)
+		}
+
+		latest, err := db.GetLatestRiskAcceptance()
+		if err != nil {
+			t.Fatalf("GetLatestRiskAcceptance failed: %v", err)
+		}
+		if latest == nil || latest.ID != "a5" {
+			t.Errorf("Expected a5 to be the latest acceptance, got %+v", latest)
+		}
+	})
+
+	t.Run("再び返された受容は復活する", func(t *testing.T) {
+		result, err := db.SyncRiskAcceptances([]models.RiskAcceptance{acceptance("a1", "1760000001000", "Risk Owned")}, false, now)
+		if err != nil {
+			t.Fatalf("SyncRiskAcceptances failed: %v", err)
+		}
+		if result.Added != 1 {
+			t.Errorf("Expected a1 to be added again, got %+v", result)
+		}
+		if _, revoked, err := db.GetRiskAcceptanceRevokedAt("a1"); err != nil || revoked {
+			t.Errorf("Expected a1 to be active, got %v/%v", revoked, err)
+		}
+	})
+	t.Run("このツールでの取り消しも履歴を残す", func(t *testing.T) {
+		later := now.Add(time.Hour)
+		if err := db.MarkRiskAcceptanceRevoked("a3", later); err != nil {
+			t.Fatalf("MarkRiskAcceptanceRevoked failed: %v", err)
+		}
+		// 取り消し済みの受容は最初の日時を保つ
+		if err := db.MarkRiskAcceptanceRevoked("a3", later.Add(time.Hour)); err != nil {
+			t.Fatalf("MarkRiskAcceptanceRevoked failed: %v", err)
+		}
+		revokedAt, revoked, err := db.GetRiskAcceptanceRevokedAt("a3")
+		if err != nil || !revoked || !revokedAt.Equal(later) {
+			t.Errorf("Expected a3 to be revoked at %v, got %v/%v/%v", later, revokedAt, revoked, err)
+		}
+		if err := db.MarkRiskAcceptanceRevoked("missing", later); err == nil {
+			t.Error("Expected error for unknown acceptance")
+		}
+	})
+}
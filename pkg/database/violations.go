@@ -0,0 +1,71 @@
+package database
+
+import "fmt"
+
+// Violation is a resource failing a control
+type Violation struct {
+	ControlID    string `json:"control_id"`
+	ControlName  string `json:"control_name"`
+	Severity     string `json:"severity"`
+	Policy       string `json:"policy"`
+	ResourceHash string `json:"resource_hash"`
+	ResourceName string `json:"resource_name"`
+	ResourceType string `json:"resource_type"`
+	// Account is the account or project (platform account ID for cluster resources)
+	Account string `json:"account"`
+	// Team is the owning team; empty when no ownership rule matches
+	Team string `json:"team"`
+}
+
+// Key identifies the control/resource pair of a violation
+func (v Violation) Key() string {
+	return v.ControlID + "/" + v.ResourceHash
+}
+
+// GetFailingViolations returns the control/resource pairs currently failing (accepted ones excluded)
+func (d *Database) GetFailingViolations() ([]Violation, error) {
+	return d.getViolations("failed")
+}
+
+// GetAcceptedViolations returns the control/resource pairs failing under a risk acceptance
+func (d *Database) GetAcceptedViolations() ([]Violation, error) {
+	return d.getViolations("accepted")
+}
+
+func (d *Database) getViolations(status string) ([]Violation, error) {
+	rows, err := d.db.Query(`
+		SELECT
+			rel.control_id,
+			COALESCE(c.name, ''),
+			COALESCE(c.severity, ''),
+			COALESCE(MIN(r.policy_name), ''),
+			rel.resource_hash,
+			COALESCE(cr.name, ''),
+			COALESCE(cr.type, ''),
+			COALESCE(NULLIF(cr.account, ''), cr.platform_account_id, ''),
+			COALESCE(o.team, '')
+		FROM control_resource_relations rel
+		LEFT JOIN controls c ON c.control_id = rel.control_id
+		LEFT JOIN compliance_requirements r ON r.requirement_id = c.requirement_id
+		LEFT JOIN cloud_resources cr ON cr.hash = rel.resource_hash
+		LEFT JOIN owners o ON o.resource_hash = rel.resource_hash
+		WHERE rel.acceptance_status = ?
+		GROUP BY rel.control_id, rel.resource_hash
+		ORDER BY rel.control_id, rel.resource_hash`, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s violations: %w", status, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var violations []Violation
+	for rows.Next() {
+		var v Violation
+		if err := rows.Scan(&v.ControlID, &v.ControlName, &v.Severity, &v.Policy, &v.ResourceHash,
+			&v.ResourceName, &v.ResourceType, &v.Account, &v.Team); err != nil {
+			return nil, fmt.Errorf("failed to scan violation: %w", err)
+		}
+		violations = append(violations, v)
+	}
+
+	return violations, rows.Err()
+}
@@ -0,0 +1,364 @@
+// Package filter parses the Sysdig CSPM filter syntax used by risk acceptances,
+// e.g. name in ("bucket-a") and location in ("us-west-2"), and evaluates it locally
+// or compiles it to SQL.
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Operators of a condition
+const (
+	OpIn         = "in"
+	OpNotIn      = "not in"
+	OpEqual      = "="
+	OpNotEqual   = "!="
+	OpContains   = "contains"
+	OpStartsWith = "startsWith"
+)
+
+// Record holds the field values an expression is evaluated against; missing fields are empty
+type Record map[string]string
+
+// Expr is a parsed filter expression
+type Expr interface {
+	// Eval reports whether the record matches
+	Eval(r Record) bool
+	// String returns the expression in filter syntax
+	String() string
+	fields(seen map[string]bool)
+}
+
+// And matches when both sides match
+type And struct{ Left, Right Expr }
+
+// Or matches when either side matches
+type Or struct{ Left, Right Expr }
+
+// Not negates an expression
+type Not struct{ Expr Expr }
+
+// Condition compares one field with one or more values
+type Condition struct {
+	Field  string
+	Op     string
+	Values []string
+}
+
+// Eval reports whether the record matches both sides
+func (e And) Eval(r Record) bool { return e.Left.Eval(r) && e.Right.Eval(r) }
+
+// Eval reports whether the record matches either side
+func (e Or) Eval(r Record) bool { return e.Left.Eval(r) || e.Right.Eval(r) }
+
+// Eval reports whether the record does not match the expression
+func (e Not) Eval(r Record) bool { return !e.Expr.Eval(r) }
+
+// Eval reports whether the field value satisfies the condition
+func (c Condition) Eval(r Record) bool {
+	v := r[c.Field]
+	switch c.Op {
+	case OpIn, OpEqual:
+		return contains(c.Values, v)
+	case OpNotIn, OpNotEqual:
+		return !contains(c.Values, v)
+	case OpContains:
+		return strings.Contains(v, c.Values[0])
+	case OpStartsWith:
+		return strings.HasPrefix(v, c.Values[0])
+	}
+	return false
+}
+
+func (e And) String() string { return group(e.Left) + " and " + group(e.Right) }
+func (e Or) String() string  { return e.Left.String() + " or " + e.Right.String() }
+func (e Not) String() string { return "not (" + e.Expr.String() + ")" }
+
+func (c Condition) String() string {
+	quoted := make([]string, len(c.Values))
+	for i, v := range c.Values {
+		quoted[i] = Quote(v)
+	}
+	if c.Op == OpIn || c.Op == OpNotIn {
+		return c.Field + " " + c.Op + " (" + strings.Join(quoted, ",") + ")"
+	}
+	return c.Field + " " + c.Op + " " + quoted[0]
+}
+
+// group parenthesizes or-expressions inside an and-expression
+func group(e Expr) string {
+	if _, ok := e.(Or); ok {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+func (e And) fields(seen map[string]bool)       { e.Left.fields(seen); e.Right.fields(seen) }
+func (e Or) fields(seen map[string]bool)        { e.Left.fields(seen); e.Right.fields(seen) }
+func (e Not) fields(seen map[string]bool)       { e.Expr.fields(seen) }
+func (c Condition) fields(seen map[string]bool) { seen[c.Field] = true }
+
+// Fields returns the sorted field names referenced by the expression
+func Fields(e Expr) []string {
+	seen := make(map[string]bool)
+	e.fields(seen)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Quote returns s as a double-quoted filter string
+func Quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses a filter. Conditions are combined with and, or, not and parentheses;
+// and binds tighter than or. An empty filter returns a nil expression.
+func Parse(s string) (Expr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	if tokens[0].kind == tokenEOF {
+		return nil, nil
+	}
+
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return e, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("filter: "+format+" at offset %d", append(args, t.offset)...)
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = And{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	t := p.peek()
+	switch {
+	case t.isKeyword("not"):
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{e}, nil
+	case t.kind == tokenPunct && t.text == "(":
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenPunct || t.text != ")" {
+			return nil, p.errorf(t, "expected ) but got %s", t)
+		}
+		return e, nil
+	case t.kind == tokenIdent:
+		return p.parseCondition()
+	}
+	return nil, p.errorf(t, "expected a condition but got %s", t)
+}
+
+func (p *parser) parseCondition() (Expr, error) {
+	field := p.next().text
+
+	t := p.next()
+	var op string
+	switch {
+	case t.isKeyword("in"):
+		op = OpIn
+	case t.isKeyword("not"):
+		if in := p.next(); !in.isKeyword("in") {
+			return nil, p.errorf(in, "expected in after not but got %s", in)
+		}
+		op = OpNotIn
+	case t.isKeyword("contains"):
+		op = OpContains
+	case t.isKeyword("startswith"):
+		op = OpStartsWith
+	case t.kind == tokenPunct && (t.text == "=" || t.text == "!="):
+		op = t.text
+	default:
+		return nil, p.errorf(t, "expected an operator after %s but got %s", field, t)
+	}
+
+	if op != OpIn && op != OpNotIn {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return Condition{Field: field, Op: op, Values: []string{v}}, nil
+	}
+
+	if t := p.next(); t.kind != tokenPunct || t.text != "(" {
+		return nil, p.errorf(t, "expected ( after %s but got %s", op, t)
+	}
+	var values []string
+	for {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+
+		t := p.next()
+		if t.kind == tokenPunct && t.text == ")" {
+			break
+		}
+		if t.kind != tokenPunct || t.text != "," {
+			return nil, p.errorf(t, "expected , or ) but got %s", t)
+		}
+	}
+	return Condition{Field: field, Op: op, Values: values}, nil
+}
+
+func (p *parser) parseValue() (string, error) {
+	t := p.next()
+	if t.kind != tokenString && t.kind != tokenIdent {
+		return "", p.errorf(t, "expected a value but got %s", t)
+	}
+	return t.text, nil
+}
+
+// ToSQL compiles the expression to a parameterized SQL condition. columns maps each field name
+// to its SQL expression, which should not be NULL (wrap nullable columns in COALESCE) so that the
+// result matches Eval; unknown fields are an error. A nil expression returns an empty condition.
+func ToSQL(e Expr, columns map[string]string) (string, []interface{}, error) {
+	if e == nil {
+		return "", nil, nil
+	}
+	var args []interface{}
+	sql, err := toSQL(e, columns, &args)
+	if err != nil {
+		return "", nil, err
+	}
+	return sql, args, nil
+}
+
+func toSQL(e Expr, columns map[string]string, args *[]interface{}) (string, error) {
+	switch e := e.(type) {
+	case And:
+		return binarySQL(e.Left, e.Right, "AND", columns, args)
+	case Or:
+		return binarySQL(e.Left, e.Right, "OR", columns, args)
+	case Not:
+		inner, err := toSQL(e.Expr, columns, args)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + inner + ")", nil
+	case Condition:
+		column, ok := columns[e.Field]
+		if !ok {
+			names := make([]string, 0, len(columns))
+			for name := range columns {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return "", fmt.Errorf("filter: unknown field %q (%s)", e.Field, strings.Join(names, ", "))
+		}
+		switch e.Op {
+		case OpIn, OpNotIn:
+			placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(e.Values)), ", ")
+			for _, v := range e.Values {
+				*args = append(*args, v)
+			}
+			op := "IN"
+			if e.Op == OpNotIn {
+				op = "NOT IN"
+			}
+			return column + " " + op + " (" + placeholders + ")", nil
+		case OpEqual, OpNotEqual:
+			*args = append(*args, e.Values[0])
+			return column + " " + e.Op + " ?", nil
+		case OpContains:
+			// LIKEは大文字小文字を区別しないため、Evalと同じ結果になるinstrを使う
+			*args = append(*args, e.Values[0])
+			return "instr(" + column + ", ?) > 0", nil
+		case OpStartsWith:
+			*args = append(*args, e.Values[0], e.Values[0])
+			return "substr(" + column + ", 1, length(?)) = ?", nil
+		}
+		return "", fmt.Errorf("filter: unsupported operator %q", e.Op)
+	}
+	return "", fmt.Errorf("filter: unsupported expression %T", e)
+}
+
+func binarySQL(left, right Expr, op string, columns map[string]string, args *[]interface{}) (string, error) {
+	l, err := toSQL(left, columns, args)
+	if err != nil {
+		return "", err
+	}
+	r, err := toSQL(right, columns, args)
+	if err != nil {
+		return "", err
+	}
+	return "(" + l + " " + op + " " + r + ")", nil
+}
@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		filter string
+		want   string
+	}{
+		{`name in ("bucket-a")`, `name in ("bucket-a")`},
+		{`name in ("a","b") and location in ("us-west-2")`, `name in ("a","b") and location in ("us-west-2")`},
+		{`NAME IN ('a', "b")`, `NAME in ("a","b")`},
+		{`kind = "AWS_S3_BUCKET" or account != 123`, `kind = "AWS_S3_BUCKET" or account != "123"`},
+		{`name startsWith "prod-" and (location = "a" or location = "b")`, `name startsWith "prod-" and (location = "a" or location = "b")`},
+		{`not name contains "tmp" and name not in ("x")`, `not (name contains "tmp") and name not in ("x")`},
+		{`name in ("say \"hi\"")`, `name in ("say \"hi\"")`},
+	}
+	for _, c := range cases {
+		e, err := Parse(c.filter)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.filter, err)
+			continue
+		}
+		if got := e.String(); got != c.want {
+			t.Errorf("%s: expected %s, got %s", c.filter, c.want, got)
+		}
+	}
+
+	if e, err := Parse("  "); err != nil || e != nil {
+		t.Errorf("Expected nil expression for an empty filter, got %v, %v", e, err)
+	}
+
+	for _, bad := range []string{`name in "a"`, `name in ("a"`, `name ~ "a"`, `name in ("a") and`, `(name = "a"`, `name = "a`, `name not "a"`, `name = "a" name = "b"`, `!name`} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		} else if !strings.HasPrefix(err.Error(), "filter: ") {
+			t.Errorf("%s: unexpected error format: %v", bad, err)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	r := Record{"name": "prod-bucket", "location": "us-west-2"}
+	cases := map[string]bool{
+		`name in ("prod-bucket") and location in ("us-west-2")`: true,
+		`name in ("prod-bucket") and location in ("us-east-1")`: false,
+		`name in ("x") or location = "us-west-2"`:               true,
+		`name not in ("x")`: true,
+		`name startsWith "prod-" and not name contains "tmp"`: true,
+		`account = ""`:  true,
+		`account != ""`: false,
+	}
+	for filter, want := range cases {
+		e, err := Parse(filter)
+		if err != nil {
+			t.Fatalf("%s: %v", filter, err)
+		}
+		if got := e.Eval(r); got != want {
+			t.Errorf("%s: expected %v, got %v", filter, want, got)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	e, err := Parse(`name in ("a") and (location = "b" or name = "c")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(Fields(e), ","); got != "location,name" {
+		t.Errorf("Expected location,name, got %s", got)
+	}
+}
+
+func TestToSQL(t *testing.T) {
+	columns := map[string]string{"name": "cr.name", "location": "COALESCE(cr.location, '')", "status": "rel.acceptance_status"}
+
+	e, err := Parse(`location in ("ap-northeast-1", "us-east-1") and (status = "failed" or not name contains "tmp") and name startsWith "prod-"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql, args, err := ToSQL(e, columns)
+	if err != nil {
+		t.Fatalf("ToSQL failed: %v", err)
+	}
+	want := `((COALESCE(cr.location, '') IN (?, ?) AND (rel.acceptance_status = ? OR NOT (instr(cr.name, ?) > 0))) AND substr(cr.name, 1, length(?)) = ?)`
+	if sql != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, sql)
+	}
+	if got := fmt.Sprint(args); got != "[ap-northeast-1 us-east-1 failed tmp prod- prod-]" {
+		t.Errorf("Unexpected args: %s", got)
+	}
+
+	e, _ = Parse(`account = "1"`)
+	if _, _, err := ToSQL(e, columns); err == nil || !strings.Contains(err.Error(), "location, name, status") {
+		t.Errorf("Expected an unknown field error listing the fields, got %v", err)
+	}
+	if sql, args, err := ToSQL(nil, columns); sql != "" || args != nil || err != nil {
+		t.Errorf("Expected an empty condition, got %q %v %v", sql, args, err)
+	}
+}
@@ -0,0 +1,84 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenPunct
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+func (t token) isKeyword(k string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, k)
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of filter"
+	case tokenString:
+		return Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits a filter into tokens; bare words (field names, keywords, numbers) are identifiers
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == ',' || c == '=':
+			tokens = append(tokens, token{tokenPunct, string(c), i})
+			i++
+		case c == '!':
+			if i+1 >= len(s) || s[i+1] != '=' {
+				return nil, fmt.Errorf("filter: unexpected ! at offset %d", i)
+			}
+			tokens = append(tokens, token{tokenPunct, "!=", i})
+			i += 2
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			start := i
+			i++
+			for {
+				if i >= len(s) {
+					return nil, fmt.Errorf("filter: unterminated string at offset %d", start)
+				}
+				if s[i] == '\\' && i+1 < len(s) {
+					b.WriteByte(s[i+1])
+					i += 2
+					continue
+				}
+				if s[i] == c {
+					i++
+					break
+				}
+				b.WriteByte(s[i])
+				i++
+			}
+			tokens = append(tokens, token{tokenString, b.String(), start})
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r(),=!\"'", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, s[start:i], start})
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(s)}), nil
+}
@@ -0,0 +1,243 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// Source is a database file exported under a db label
+type Source struct {
+	Name string
+	Path string
+}
+
+// Exporter reads the collected databases on every scrape and exposes them as Prometheus gauges
+type Exporter struct {
+	patterns []string
+}
+
+// NewExporter creates an exporter for the given database paths.
+// A path may be a glob pattern; the most recently modified match is used (e.g. "data/*/cis_aws.db"
+// follows the newest snapshot of a collection plan).
+func NewExporter(patterns []string) *Exporter {
+	return &Exporter{patterns: patterns}
+}
+
+// ResolveSources resolves the database paths and glob patterns to database files.
+// The db label is the file name without extension, or the path when names collide.
+func (e *Exporter) ResolveSources() ([]Source, error) {
+	var sources []Source
+	names := make(map[string]bool)
+
+	for _, pattern := range e.patterns {
+		path := pattern
+		if strings.ContainsAny(pattern, "*?[") {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid database pattern %q: %w", pattern, err)
+			}
+			path = newestFile(matches)
+			if path == "" {
+				return nil, fmt.Errorf("no database matches %q", pattern)
+			}
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if names[name] {
+			name = path
+		}
+		names[name] = true
+		sources = append(sources, Source{Name: name, Path: path})
+	}
+
+	return sources, nil
+}
+
+// newestFile returns the most recently modified file
+func newestFile(paths []string) string {
+	var newest string
+	var newestTime time.Time
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if newest == "" || info.ModTime().After(newestTime) {
+			newest = p
+			newestTime = info.ModTime()
+		}
+	}
+	return newest
+}
+
+// families holds the metric families of one scrape
+type families struct {
+	up                 *Family
+	requirements       *Family
+	controls           *Family
+	resources          *Family
+	resourceEvals      *Family
+	resourcesEvaluated *Family
+	lastRunTimestamp   *Family
+	lastRunDuration    *Family
+	lastRunSuccess     *Family
+	lastRunAPIErrors   *Family
+	lastSuccessfulRun  *Family
+	exporterErrors     *Family
+}
+
+func newFamilies() *families {
+	return &families{
+		up:                 NewGauge("cspm_up", "Whether the database could be read (1) or not (0)."),
+		requirements:       NewGauge("cspm_requirements", "Number of compliance requirements by status."),
+		controls:           NewGauge("cspm_controls", "Number of controls by status."),
+		resources:          NewGauge("cspm_resources", "Number of distinct resources by evaluation status (a resource can fail one control and pass another)."),
+		resourceEvals:      NewGauge("cspm_resource_evaluations", "Number of control/resource evaluations by status."),
+		resourcesEvaluated: NewGauge("cspm_resources_evaluated", "Number of distinct resources evaluated by any control."),
+		lastRunTimestamp:   NewGauge("cspm_collection_last_run_timestamp_seconds", "Finish time of the last collection run."),
+		lastRunDuration:    NewGauge("cspm_collection_last_run_duration_seconds", "Duration of the last collection run."),
+		lastRunSuccess:     NewGauge("cspm_collection_last_run_success", "Whether the last collection run succeeded (1) or failed (0)."),
+		lastRunAPIErrors:   NewGauge("cspm_collection_last_run_api_errors", "Number of controls whose resources could not be retrieved in the last collection run."),
+		lastSuccessfulRun:  NewGauge("cspm_collection_last_success_timestamp_seconds", "Finish time of the last successful collection run."),
+		exporterErrors:     NewGauge("cspm_exporter_errors", "Number of errors while reading the database in this scrape."),
+	}
+}
+
+func (f *families) all() []*Family {
+	return []*Family{
+		f.up, f.requirements, f.controls, f.resources, f.resourceEvals, f.resourcesEvaluated,
+		f.lastRunTimestamp, f.lastRunDuration, f.lastRunSuccess, f.lastRunAPIErrors, f.lastSuccessfulRun,
+		f.exporterErrors,
+	}
+}
+
+// Gather reads all databases and returns the metric families.
+// Databases that cannot be read are reported with cspm_up 0 instead of failing the scrape.
+func (e *Exporter) Gather() []*Family {
+	f := newFamilies()
+
+	sources, err := e.ResolveSources()
+	if err != nil {
+		log.Printf("[WARN] %v", err)
+		f.exporterErrors.Add(1, "db", "", "step", "resolve")
+	}
+
+	for _, src := range sources {
+		if err := gatherDatabase(f, src); err != nil {
+			log.Printf("[WARN] Failed to read %s: %v", src.Path, err)
+			f.up.Add(0, "db", src.Name)
+			f.exporterErrors.Add(1, "db", src.Name, "step", "read")
+			continue
+		}
+		f.up.Add(1, "db", src.Name)
+	}
+
+	return f.all()
+}
+
+// gatherDatabase adds the samples of one database; nothing is added when any query fails
+func gatherDatabase(f *families, src Source) error {
+	db, err := database.OpenReadOnly(src.Path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+
+	requirements, err := db.GetRequirementPosture()
+	if err != nil {
+		return err
+	}
+	controls, err := db.GetControlPosture()
+	if err != nil {
+		return err
+	}
+	resources, err := db.GetResourcePosture()
+	if err != nil {
+		return err
+	}
+	stats, err := db.GetComplianceStats()
+	if err != nil {
+		return err
+	}
+	runs, err := db.GetCollectionRuns(0)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range requirements {
+		f.requirements.Add(float64(c.Count), "db", src.Name, "policy", c.Policy, "platform", c.Platform,
+			"severity", c.Severity, "zone", c.Zone, "status", c.Status)
+	}
+	for _, c := range controls {
+		f.controls.Add(float64(c.Count), "db", src.Name, "policy", c.Policy, "platform", c.Platform,
+			"severity", c.Severity, "zone", c.Zone, "status", c.Status)
+	}
+	for _, c := range resources {
+		labels := []string{"db", src.Name, "policy", c.Policy, "platform", c.Platform,
+			"severity", c.Severity, "account", c.Account, "zone", c.Zone, "team", c.Team, "status", c.Status}
+		f.resources.Add(float64(c.Count), labels...)
+		f.resourceEvals.Add(float64(c.Evaluations), labels...)
+	}
+	f.resourcesEvaluated.Add(float64(stats.TotalResources), "db", src.Name)
+	addRunMetrics(f, src.Name, runs)
+
+	return nil
+}
+
+// addRunMetrics adds the last run and last success per target/policy (runs are newest first)
+func addRunMetrics(f *families, dbName string, runs []database.CollectionRun) {
+	type key struct{ target, policy string }
+	seen := make(map[key]bool)
+	seenSuccess := make(map[key]bool)
+
+	for _, run := range runs {
+		k := key{run.Target, run.Policy}
+		labels := []string{"db", dbName, "target", run.Target, "policy", run.Policy}
+
+		if !seen[k] {
+			seen[k] = true
+			success := 0.0
+			if run.Status == database.RunStatusSuccess {
+				success = 1
+			}
+			f.lastRunTimestamp.Add(float64(run.FinishedAt.Unix()), labels...)
+			f.lastRunDuration.Add(run.Duration().Seconds(), labels...)
+			f.lastRunSuccess.Add(success, labels...)
+			f.lastRunAPIErrors.Add(float64(run.APIErrors), labels...)
+		}
+		if !seenSuccess[k] && run.Status == database.RunStatusSuccess {
+			seenSuccess[k] = true
+			f.lastSuccessfulRun.Add(float64(run.FinishedAt.Unix()), labels...)
+		}
+	}
+}
+
+// ServeHTTP writes the metrics of all databases
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := WriteText(w, e.Gather()); err != nil {
+		log.Printf("[WARN] Failed to write metrics: %v", err)
+	}
+}
+
+// Handler serves /metrics and a landing page
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprint(w, `<html><head><title>sysdig-cspm-utils exporter</title></head><body><h1>sysdig-cspm-utils exporter</h1><p><a href="/metrics">Metrics</a></p></body></html>`)
+	})
+	return mux
+}
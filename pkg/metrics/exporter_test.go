@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/internal/testutil"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/collector"
+)
+
+// collectTestDatabase collects the mock server data into dir/name
+func collectTestDatabase(t *testing.T, dir, name string) string {
+	t.Helper()
+	mockServer := testutil.NewMockServer(testutil.DefaultMockServerConfig())
+	defer mockServer.Close()
+
+	dbPath := filepath.Join(dir, name)
+	cspmClient := client.NewCSPMClient(mockServer.URL, "test-token")
+	if _, err := collector.CollectToDatabase(cspmClient, dbPath, collector.Options{
+		Target: "cis", Policy: "CIS", Zone: "Entire Infrastructure", PageSize: 10, BatchSize: 2,
+	}); err != nil {
+		t.Fatalf("Failed to collect test data: %v", err)
+	}
+	return dbPath
+}
+
+func TestExporter(t *testing.T) {
+	dir := t.TempDir()
+	collectTestDatabase(t, filepath.Join(dir, "20250101_000000"), "cis_aws.db")
+
+	// 存在しないDBはcspm_up 0として報告される
+	exporter := NewExporter([]string{filepath.Join(dir, "*", "cis_aws.db"), filepath.Join(dir, "missing.db")})
+	server := httptest.NewServer(exporter.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to get metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type: %s", ct)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	output := string(body)
+
+	for _, want := range []string{
+		`cspm_up{db="cis_aws"} 1`,
+		`cspm_up{db="missing"} 0`,
+		`cspm_requirements{db="cis_aws",policy="CIS Amazon Web Services Foundations Benchmark v3.0.0"`,
+		`cspm_resources{db="cis_aws",`,
+		`cspm_resources_evaluated{db="cis_aws"} `,
+		`account="`,
+		`status="failed"} `,
+		`cspm_collection_last_run_success{db="cis_aws",target="cis",policy="CIS"} 1`,
+		`cspm_collection_last_run_api_errors{db="cis_aws",target="cis",policy="CIS"}`,
+		`cspm_collection_last_success_timestamp_seconds{db="cis_aws"`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+	// _total はカウンタ用に予約されているため、ゲージには使わない
+	if strings.Contains(output, "_total{") {
+		t.Errorf("Expected no gauge with the _total suffix")
+	}
+	if t.Failed() {
+		t.Logf("Metrics output:\n%s", output)
+	}
+}
+
+func TestResolveSources_NameCollision(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exporter := NewExporter([]string{filepath.Join(dir, "a", "cis.db"), filepath.Join(dir, "b", "cis.db")})
+	sources, err := exporter.ResolveSources()
+	if err != nil {
+		t.Fatalf("Failed to resolve sources: %v", err)
+	}
+	if sources[0].Name != "cis" || sources[1].Name != filepath.Join(dir, "b", "cis.db") {
+		t.Errorf("Unexpected source names: %+v", sources)
+	}
+}
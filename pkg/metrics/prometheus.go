@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Family is a Prometheus metric family with its samples
+type Family struct {
+	Name    string
+	Help    string
+	Type    string // "gauge" or "counter"
+	Samples []Sample
+}
+
+// Sample is one labelled value of a metric family
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Label is a metric label pair
+type Label struct {
+	Name  string
+	Value string
+}
+
+// NewGauge creates an empty gauge family
+func NewGauge(name, help string) *Family {
+	return &Family{Name: name, Help: help, Type: "gauge"}
+}
+
+// Add appends a sample; labels are given as name/value pairs
+func (f *Family) Add(value float64, labelPairs ...string) {
+	if len(labelPairs)%2 != 0 {
+		panic(fmt.Sprintf("metrics: odd number of label arguments for %s", f.Name))
+	}
+	labels := make([]Label, 0, len(labelPairs)/2)
+	for i := 0; i < len(labelPairs); i += 2 {
+		labels = append(labels, Label{Name: labelPairs[i], Value: labelPairs[i+1]})
+	}
+	f.Samples = append(f.Samples, Sample{Labels: labels, Value: value})
+}
+
+// WriteText writes the families in the Prometheus text exposition format (version 0.0.4).
+// Families without samples are omitted, and families are sorted by name for stable output.
+func WriteText(w io.Writer, families []*Family) error {
+	sorted := make([]*Family, 0, len(families))
+	for _, f := range families {
+		if len(f.Samples) > 0 {
+			sorted = append(sorted, f)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	bw := bufio.NewWriter(w)
+	for _, f := range sorted {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.Name, f.Type)
+		for _, s := range f.Samples {
+			bw.WriteString(f.Name)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					fmt.Fprintf(bw, "%s=\"%s\"", l.Name, escapeLabelValue(l.Value))
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteByte(' ')
+			bw.WriteString(formatValue(s.Value))
+			bw.WriteByte('\n')
+		}
+	}
+
+	return bw.Flush()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
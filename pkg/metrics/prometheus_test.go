@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	g := NewGauge("cspm_test", "Test gauge\nwith newline.")
+	g.Add(3, "policy", `CIS "AWS"`, "zone", `a\b`)
+	g.Add(0.5)
+	empty := NewGauge("cspm_empty", "Not written.")
+	nan := NewGauge("cspm_another", "Another gauge.")
+	nan.Add(math.NaN(), "db", "x")
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, []*Family{g, empty, nan}); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+
+	want := `# HELP cspm_another Another gauge.
+# TYPE cspm_another gauge
+cspm_another{db="x"} NaN
+# HELP cspm_test Test gauge\nwith newline.
+# TYPE cspm_test gauge
+cspm_test{policy="CIS \"AWS\"",zone="a\\b"} 3
+cspm_test 0.5
+`
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -217,6 +217,61 @@ type RiskAcceptanceSearchResponse struct {
 	TotalCount int              `json:"totalCount"`
 }
 
+// RiskAcceptanceCreateRequest represents the request payload for creating a risk acceptance
+type RiskAcceptanceCreateRequest struct {
+	ControlID   int    `json:"controlId"`
+	Reason      string `json:"reason"`
+	Description string `json:"description,omitempty"`
+	// Filter limits the acceptance to matching resources, e.g. name in ("bucket-a")
+	Filter string `json:"filter,omitempty"`
+	// ExpiresAt is a Unix timestamp in milliseconds; empty means no expiry
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	// SourceID is the account ID, project ID or cluster name
+	SourceID string `json:"sourceId,omitempty"`
+	ZoneID   int    `json:"zoneId,omitempty"`
+}
+
+// RiskAcceptanceCreateResponse represents the API response for a created risk acceptance
+// (controlId and zoneId are integers here, unlike the search response)
+type RiskAcceptanceCreateResponse struct {
+	ID              string  `json:"id"`
+	ControlID       FlexInt `json:"controlId"`
+	Description     string  `json:"description"`
+	Reason          string  `json:"reason"`
+	AcceptanceDate  string  `json:"acceptanceDate"`
+	Username        string  `json:"username"`
+	UserDisplayName string  `json:"userDisplayName"`
+	Filter          string  `json:"filter"`
+	ZoneID          FlexInt `json:"zoneId"`
+	AcceptPeriod    string  `json:"acceptPeriod"`
+	ExpiresAt       string  `json:"expiresAt"`
+	IsExpired       bool    `json:"isExpired"`
+	SourceID        string  `json:"sourceId"`
+}
+
+// RiskAcceptance converts the response to the stored representation
+func (r RiskAcceptanceCreateResponse) RiskAcceptance() RiskAcceptance {
+	zoneID := ""
+	if r.ZoneID != 0 {
+		zoneID = strconv.Itoa(r.ZoneID.Int())
+	}
+	return RiskAcceptance{
+		ID:              r.ID,
+		ControlID:       strconv.Itoa(r.ControlID.Int()),
+		Description:     r.Description,
+		Reason:          r.Reason,
+		AcceptanceDate:  r.AcceptanceDate,
+		Username:        r.Username,
+		UserDisplayName: r.UserDisplayName,
+		Filter:          r.Filter,
+		ZoneID:          zoneID,
+		AcceptPeriod:    r.AcceptPeriod,
+		ExpiresAt:       r.ExpiresAt,
+		IsExpired:       r.IsExpired,
+		SourceID:        r.SourceID,
+	}
+}
+
 // RiskAcceptanceDeleteRequest represents the request payload for deleting a risk acceptance
 type RiskAcceptanceDeleteRequest struct {
 	ID string `json:"id"`
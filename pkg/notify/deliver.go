@@ -0,0 +1,291 @@
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// Notifier computes the changes of a database against the previous run and delivers them
+type Notifier struct {
+	Config Config
+	// PayloadDir overrides the payload_dir of the config (test mode)
+	PayloadDir string
+	Client     *http.Client
+	// Now returns the current time (time.Now when nil)
+	Now func() time.Time
+
+	// backoff is the wait before the first retry; it doubles with every retry
+	backoff time.Duration
+}
+
+// New creates a notifier for a validated config
+func New(cfg Config) *Notifier {
+	return &Notifier{
+		Config:  cfg,
+		Client:  &http.Client{Timeout: 30 * time.Second},
+		backoff: 2 * time.Second,
+	}
+}
+
+// Result is the outcome of Run
+type Result struct {
+	// TestMode is set when the payloads were written to files instead of being posted
+	TestMode bool
+	Webhooks []WebhookResult
+}
+
+// WebhookResult is the outcome of one webhook
+type WebhookResult struct {
+	Name string
+	// Initial is set when the webhook had no previous run of the series; the current violations
+	// become its baseline (test mode records nothing)
+	Initial  bool
+	New      int
+	Resolved int
+	Accepted int
+	// Delivered is set when the message was posted
+	Delivered bool
+	// File is the payload file of the test mode
+	File string
+	Err  error
+}
+
+// Summary describes the result in one line
+func (r *Result) Summary() string {
+	parts := make([]string, 0, len(r.Webhooks))
+	for _, w := range r.Webhooks {
+		var s string
+		switch {
+		case w.Err != nil:
+			s = "failed"
+		case w.Initial && r.TestMode:
+			s = "no baseline yet (run without test mode first)"
+		case w.Initial:
+			s = "recorded the current violations as the baseline"
+		case w.Delivered:
+			s = fmt.Sprintf("notified %d new, %d resolved, %d risk-accepted", w.New, w.Resolved, w.Accepted)
+		case w.File != "":
+			s = fmt.Sprintf("wrote %d new, %d resolved, %d risk-accepted (test mode)", w.New, w.Resolved, w.Accepted)
+		default:
+			s = "no changes"
+		}
+		parts = append(parts, w.Name+": "+s)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Written returns the payload files of the test mode
+func (r *Result) Written() []string {
+	var files []string
+	for _, w := range r.Webhooks {
+		if w.File != "" {
+			files = append(files, w.File)
+		}
+	}
+	return files
+}
+
+// timestampDir matches the {timestamp} directories of collection plans (20060102_150405)
+var timestampDir = regexp.MustCompile(`^[0-9]{8}_[0-9]{6}$`)
+
+// SeriesName returns the series of a database: its absolute path with the {timestamp}
+// directories of collection plans kept as "{timestamp}", so that the snapshots of one plan
+// target compare against each other while databases with the same file name in different
+// directories do not
+func SeriesName(dbPath string) string {
+	abs, err := filepath.Abs(dbPath)
+	if err != nil {
+		abs = filepath.Clean(dbPath)
+	}
+	parts := strings.Split(filepath.ToSlash(abs), "/")
+	for i, p := range parts[:len(parts)-1] {
+		if timestampDir.MatchString(p) {
+			parts[i] = "{timestamp}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// seriesLabel names the series in messages and payload files: the file name without extension
+func seriesLabel(dbPath string) string {
+	return strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+}
+
+// Run compares the failing violations of the database at dbPath with the ones last notified to
+// each webhook and sends the routed changes. Every webhook keeps its own record, which is
+// updated only after its delivery succeeds, so that a failed webhook gets the changes again on
+// the next run without resending them to the others. The test mode writes the payloads to files
+// and leaves the records unchanged.
+func (n *Notifier) Run(db *database.Database, dbPath string) (*Result, error) {
+	violations, err := db.GetFailingViolations()
+	if err != nil {
+		return nil, err
+	}
+	accepted, err := db.GetAcceptedViolations()
+	if err != nil {
+		return nil, err
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	path := n.Config.stateFile()
+	s, err := loadState(path)
+	if err != nil {
+		return nil, err
+	}
+	now := n.now()
+	series, label := SeriesName(dbPath), seriesLabel(dbPath)
+	dir := n.payloadDir()
+	result := &Result{TestMode: dir != ""}
+
+	var errs []error
+	for _, w := range n.Config.Webhooks {
+		wr := n.runWebhook(w, s, series, label, dir, now, violations, accepted)
+		result.Webhooks = append(result.Webhooks, wr)
+		if wr.Err != nil {
+			errs = append(errs, wr.Err)
+			continue
+		}
+		if result.TestMode {
+			continue
+		}
+		// 配信ごとに保存し、後続のWebhookが失敗しても成功済みの記録を残す
+		s.set(series, w.Name, webhookState{RecordedAt: now, Violations: violations})
+		if err := s.save(path); err != nil {
+			return result, err
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// runWebhook sends the changes of one webhook since its last record
+func (n *Notifier) runWebhook(w Webhook, s *state, series, label, dir string, now time.Time, violations, accepted []database.Violation) WebhookResult {
+	wr := WebhookResult{Name: w.Name}
+	prev, ok := s.get(series, w.Name)
+	if !ok {
+		// 初回は全件が新規になるため通知せず基準だけを記録する
+		wr.Initial = true
+		return wr
+	}
+
+	routed := w.route(Diff(prev.Violations, violations, accepted))
+	wr.New, wr.Resolved, wr.Accepted = len(routed.New), len(routed.Resolved), len(routed.Accepted)
+	if routed.Empty() {
+		return wr
+	}
+	body, err := w.payload(newMessage(label, w.Name, routed))
+	if err != nil {
+		wr.Err = err
+		return wr
+	}
+	if dir != "" {
+		wr.File, wr.Err = writePayload(dir, label, w.Name, now, body)
+		return wr
+	}
+	if err := n.post(w, body); err != nil {
+		wr.Err = err
+		return wr
+	}
+	wr.Delivered = true
+	return wr
+}
+
+func (n *Notifier) now() time.Time {
+	if n.Now != nil {
+		return n.Now()
+	}
+	return time.Now()
+}
+
+func (n *Notifier) payloadDir() string {
+	if n.PayloadDir != "" {
+		return n.PayloadDir
+	}
+	return n.Config.PayloadDir
+}
+
+// errPermanent marks responses that are not worth retrying
+var errPermanent = errors.New("permanent failure")
+
+// post sends the payload, retrying network errors, 429 and 5xx responses with exponential backoff
+func (n *Notifier) post(w Webhook, body []byte) error {
+	attempts := w.retries() + 1
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(n.backoff << (i - 1))
+		}
+		err = n.postOnce(w, body)
+		if err == nil || errors.Is(err, errPermanent) {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("webhook %s: %w", w.Name, err)
+	}
+	return nil
+}
+
+func (n *Notifier) postOnce(w Webhook, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPermanent, withoutURL(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return withoutURL(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("unexpected status %s", resp.Status)
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	return err
+}
+
+// withoutURL drops the URL from the errors of net/http. The URL of Slack and Teams incoming
+// webhooks is the credential, and the errors end up in logs and cron mails.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// writePayload writes the payload of the test mode to <dir>/<series>_<webhook>_<time>.json
+func writePayload(dir, series, webhook string, now time.Time, body []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	name := fmt.Sprintf("%s_%s_%s.json", series, webhook, now.UTC().Format("20060102_150405"))
+	path := filepath.Join(dir, unsafeFileChars.ReplaceAllString(name, "_"))
+	if err := os.WriteFile(path, append(body, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
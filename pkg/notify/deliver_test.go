@@ -0,0 +1,35 @@
+package notify
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPost_ErrorHidesURL(t *testing.T) {
+	// 閉じたリスナーのアドレスに送信して接続エラーを起こす
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	n := New(Config{})
+	n.backoff = time.Millisecond
+	retries := 1
+	w := Webhook{Name: "slack", URL: "http://" + addr + "/services/T000/B000/secret-token?key=secret-key", Retries: &retries}
+	err = n.post(w, []byte(`{"text":"test"}`))
+	if err == nil {
+		t.Fatal("Expected the post to fail")
+	}
+	for _, secret := range []string{"/services/T000/B000/secret-token", "secret-key"} {
+		if strings.Contains(err.Error(), secret) {
+			t.Errorf("Expected the error to hide the webhook URL, got %v", err)
+		}
+	}
+	if !strings.HasPrefix(err.Error(), "webhook slack: ") {
+		t.Errorf("Expected the error to name the webhook, got %v", err)
+	}
+}
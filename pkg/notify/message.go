@@ -0,0 +1,278 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// DefaultTemplate renders one line per severity, account and team, e.g.
+// "• 3 new high-severity failures in account 111111111111 (payments): S3 Bucket ACL, ..."
+const DefaultTemplate = `{{.Title}}
+{{- range .NewGroups}}
+• {{.Count}} new {{lower .Severity}}-severity {{plural .Count "failure" "failures"}} in account {{.Account}}{{if .Team}} ({{.Team}}){{end}}: {{.ControlList}}
+{{- end}}
+{{- range .ResolvedGroups}}
+• {{.Count}} resolved {{lower .Severity}}-severity {{plural .Count "failure" "failures"}} in account {{.Account}}{{if .Team}} ({{.Team}}){{end}}: {{.ControlList}}
+{{- end}}
+{{- range .AcceptedGroups}}
+• {{.Count}} risk-accepted {{lower .Severity}}-severity {{plural .Count "failure" "failures"}} in account {{.Account}}{{if .Team}} ({{.Team}}){{end}}: {{.ControlList}}
+{{- end}}
+`
+
+var templateFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"plural": func(n int, one, many string) string {
+		if n == 1 {
+			return one
+		}
+		return many
+	},
+}
+
+// noAccount labels the violations of resources without an account
+const noAccount = "(no account)"
+
+// maxControls is the number of control names listed per group
+const maxControls = 3
+
+// Changes are the violations that appeared and disappeared since the previous run
+type Changes struct {
+	New      []database.Violation
+	Resolved []database.Violation
+	// Accepted stopped failing because a risk acceptance now covers them
+	Accepted []database.Violation
+}
+
+// Empty reports whether there are no changes
+func (c Changes) Empty() bool {
+	return len(c.New) == 0 && len(c.Resolved) == 0 && len(c.Accepted) == 0
+}
+
+// Diff compares the failing violations of the previous and the current run. Violations that
+// disappeared but are in accepted (the current risk-accepted violations) are reported as
+// accepted rather than resolved.
+func Diff(prev, cur, accepted []database.Violation) Changes {
+	prevKeys := make(map[string]bool, len(prev))
+	for _, v := range prev {
+		prevKeys[v.Key()] = true
+	}
+	curKeys := make(map[string]bool, len(cur))
+	var changes Changes
+	for _, v := range cur {
+		curKeys[v.Key()] = true
+		if !prevKeys[v.Key()] {
+			changes.New = append(changes.New, v)
+		}
+	}
+	acceptedKeys := make(map[string]bool, len(accepted))
+	for _, v := range accepted {
+		acceptedKeys[v.Key()] = true
+	}
+	for _, v := range prev {
+		switch {
+		case curKeys[v.Key()]:
+		case acceptedKeys[v.Key()]:
+			changes.Accepted = append(changes.Accepted, v)
+		default:
+			changes.Resolved = append(changes.Resolved, v)
+		}
+	}
+	return changes
+}
+
+// route returns the changes a webhook receives
+func (w Webhook) route(c Changes) Changes {
+	keep := func(v database.Violation) bool {
+		if w.MinSeverity != "" && severityRank(v.Severity) < severityRank(w.MinSeverity) {
+			return false
+		}
+		if len(w.Teams) == 0 {
+			return true
+		}
+		team := v.Team
+		if team == "" {
+			team = NoTeam
+		}
+		for _, t := range w.Teams {
+			if t == team {
+				return true
+			}
+		}
+		return false
+	}
+
+	var routed Changes
+	for _, v := range c.New {
+		if keep(v) {
+			routed.New = append(routed.New, v)
+		}
+	}
+	if !w.OnlyNew {
+		for _, v := range c.Resolved {
+			if keep(v) {
+				routed.Resolved = append(routed.Resolved, v)
+			}
+		}
+		for _, v := range c.Accepted {
+			if keep(v) {
+				routed.Accepted = append(routed.Accepted, v)
+			}
+		}
+	}
+	return routed
+}
+
+// Group is the violations of one severity in one account and team
+type Group struct {
+	Severity string
+	Account  string
+	Team     string
+	Count    int
+	// Controls are the names of the controls, most violations first
+	Controls []string
+}
+
+// ControlList returns the first control names of the group
+func (g Group) ControlList() string {
+	if len(g.Controls) <= maxControls {
+		return strings.Join(g.Controls, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(g.Controls[:maxControls], ", "), len(g.Controls)-maxControls)
+}
+
+// groupViolations groups violations by severity, account and team, most severe and largest first
+func groupViolations(violations []database.Violation) []Group {
+	type key struct{ severity, account, team string }
+	groups := make(map[key]*Group)
+	controls := make(map[key]map[string]int)
+	for _, v := range violations {
+		account := v.Account
+		if account == "" {
+			account = noAccount
+		}
+		k := key{v.Severity, account, v.Team}
+		g, ok := groups[k]
+		if !ok {
+			g = &Group{Severity: v.Severity, Account: account, Team: v.Team}
+			groups[k] = g
+			controls[k] = make(map[string]int)
+		}
+		g.Count++
+		name := v.ControlName
+		if name == "" {
+			name = v.ControlID
+		}
+		controls[k][name]++
+	}
+
+	result := make([]Group, 0, len(groups))
+	for k, g := range groups {
+		counts := controls[k]
+		for name := range counts {
+			g.Controls = append(g.Controls, name)
+		}
+		sort.Slice(g.Controls, func(i, j int) bool {
+			ci, cj := counts[g.Controls[i]], counts[g.Controls[j]]
+			if ci != cj {
+				return ci > cj
+			}
+			return g.Controls[i] < g.Controls[j]
+		})
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		ri, rj := severityRank(result[i].Severity), severityRank(result[j].Severity)
+		if ri != rj {
+			return ri > rj
+		}
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		if result[i].Account != result[j].Account {
+			return result[i].Account < result[j].Account
+		}
+		return result[i].Team < result[j].Team
+	})
+	return result
+}
+
+// Message is the data of a message template
+type Message struct {
+	Title string
+	// Series is the database the changes come from (file name without extension)
+	Series         string
+	Webhook        string
+	New            []database.Violation
+	Resolved       []database.Violation
+	Accepted       []database.Violation
+	NewGroups      []Group
+	ResolvedGroups []Group
+	AcceptedGroups []Group
+}
+
+func newMessage(series, webhook string, c Changes) Message {
+	title := fmt.Sprintf("[%s] %d new and %d resolved CSPM violations", series, len(c.New), len(c.Resolved))
+	if len(c.Accepted) > 0 {
+		title = fmt.Sprintf("[%s] %d new, %d resolved and %d risk-accepted CSPM violations", series, len(c.New), len(c.Resolved), len(c.Accepted))
+	}
+	return Message{
+		Title:          title,
+		Series:         series,
+		Webhook:        webhook,
+		New:            c.New,
+		Resolved:       c.Resolved,
+		Accepted:       c.Accepted,
+		NewGroups:      groupViolations(c.New),
+		ResolvedGroups: groupViolations(c.Resolved),
+		AcceptedGroups: groupViolations(c.Accepted),
+	}
+}
+
+// payload renders the message of a webhook in its format
+func (w Webhook) payload(m Message) ([]byte, error) {
+	tmpl, err := w.template()
+	if err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	if err := tmpl.Execute(&text, m); err != nil {
+		return nil, fmt.Errorf("failed to render the message of webhook %s: %w", w.Name, err)
+	}
+
+	var body interface{}
+	switch w.format() {
+	case FormatTeams:
+		// Teamsは単一の改行を無視するため段落に分ける
+		body = map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  m.Title,
+			"text":     strings.ReplaceAll(strings.TrimSpace(text.String()), "\n", "\n\n"),
+		}
+	case FormatJSON:
+		body = struct {
+			Series   string               `json:"series"`
+			Title    string               `json:"title"`
+			Text     string               `json:"text"`
+			New      []database.Violation `json:"new"`
+			Resolved []database.Violation `json:"resolved"`
+			Accepted []database.Violation `json:"accepted"`
+		}{m.Series, m.Title, strings.TrimSpace(text.String()), nonNil(m.New), nonNil(m.Resolved), nonNil(m.Accepted)}
+	default:
+		body = map[string]string{"text": strings.TrimSpace(text.String())}
+	}
+	return json.MarshalIndent(body, "", "  ")
+}
+
+// nonNil keeps empty lists as [] rather than null in the JSON payload
+func nonNil(violations []database.Violation) []database.Violation {
+	if violations == nil {
+		return []database.Violation{}
+	}
+	return violations
+}
@@ -0,0 +1,138 @@
+// Package notify posts messages about new and resolved violations to webhooks.
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// DefaultStateFile keeps the notified violations when the config has no state_file
+const DefaultStateFile = "data/notify-state.json"
+
+// DefaultRetries is the number of retries of a failed delivery when a webhook has no retries
+const DefaultRetries = 3
+
+// NoTeam routes the violations of resources no ownership rule assigns to a team
+const NoTeam = "(no team)"
+
+// Payload formats
+const (
+	FormatSlack = "slack"
+	FormatTeams = "teams"
+	FormatJSON  = "json"
+)
+
+// Config configures the notifications (the notify section of the config file)
+type Config struct {
+	// StateFile keeps the failing violations last notified to each webhook per series
+	StateFile string `json:"state_file,omitempty"`
+	// PayloadDir enables the test mode: payloads are written to this directory instead of being
+	// posted, and the state file is left unchanged
+	PayloadDir string    `json:"payload_dir,omitempty"`
+	Webhooks   []Webhook `json:"webhooks"`
+}
+
+// Webhook is one receiver of the messages
+type Webhook struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Format is slack (default; {"text": ...}), teams (MessageCard) or json (the changes)
+	Format string `json:"format,omitempty"`
+	// Teams only routes the violations of resources owned by these teams (NoTeam for unowned
+	// resources); empty routes every violation
+	Teams []string `json:"teams,omitempty"`
+	// MinSeverity drops the violations of less severe controls (critical, high, medium, low)
+	MinSeverity string `json:"min_severity,omitempty"`
+	// OnlyNew does not report resolved violations
+	OnlyNew bool `json:"only_new,omitempty"`
+	// Template is a text/template of the message text (DefaultTemplate when empty)
+	Template string            `json:"template,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	// Retries is the number of retries after a failed delivery (DefaultRetries when omitted)
+	Retries *int `json:"retries,omitempty"`
+}
+
+// stateFile returns the state file with the default applied
+func (c *Config) stateFile() string {
+	if c.StateFile == "" {
+		return DefaultStateFile
+	}
+	return c.StateFile
+}
+
+// Validate checks the webhooks of the config
+func (c *Config) Validate() error {
+	if len(c.Webhooks) == 0 {
+		return errors.New("notify needs at least one webhook")
+	}
+	names := make(map[string]bool)
+	for i, w := range c.Webhooks {
+		if w.Name == "" {
+			return fmt.Errorf("notify webhook %d has no name", i+1)
+		}
+		if names[w.Name] {
+			return fmt.Errorf("notify webhook %q is defined twice", w.Name)
+		}
+		names[w.Name] = true
+
+		u, err := url.Parse(w.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("notify webhook %q: url must be an http(s) URL", w.Name)
+		}
+		switch w.Format {
+		case "", FormatSlack, FormatTeams, FormatJSON:
+		default:
+			return fmt.Errorf("notify webhook %q: unknown format %q (%s, %s, %s)", w.Name, w.Format, FormatSlack, FormatTeams, FormatJSON)
+		}
+		if w.MinSeverity != "" && severityRank(w.MinSeverity) == 0 {
+			return fmt.Errorf("notify webhook %q: unknown min_severity %q (critical, high, medium, low)", w.Name, w.MinSeverity)
+		}
+		if w.Retries != nil && *w.Retries < 0 {
+			return fmt.Errorf("notify webhook %q: retries must not be negative", w.Name)
+		}
+		if _, err := w.template(); err != nil {
+			return fmt.Errorf("notify webhook %q: invalid template: %w", w.Name, err)
+		}
+	}
+	return nil
+}
+
+func (w Webhook) format() string {
+	if w.Format == "" {
+		return FormatSlack
+	}
+	return w.Format
+}
+
+func (w Webhook) retries() int {
+	if w.Retries == nil {
+		return DefaultRetries
+	}
+	return *w.Retries
+}
+
+func (w Webhook) template() (*template.Template, error) {
+	text := w.Template
+	if text == "" {
+		text = DefaultTemplate
+	}
+	return template.New(w.Name).Funcs(templateFuncs).Parse(text)
+}
+
+// severityRank orders severities from most (4) to least (1) severe; unknown severities are 0
+func severityRank(severity string) int {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return 4
+	case "high":
+		return 3
+	case "medium":
+		return 2
+	case "low":
+		return 1
+	}
+	return 0
+}
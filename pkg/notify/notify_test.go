@@ -0,0 +1,364 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func TestMessage(t *testing.T) {
+	prev := []database.Violation{
+		{ControlID: "1", ControlName: "Bucket ACL", Severity: "High", ResourceHash: "h1", Account: "111", Team: "payments"},
+		{ControlID: "2", ControlName: "Root MFA", Severity: "Low", ResourceHash: "h9", Account: "222"},
+	}
+	cur := []database.Violation{
+		prev[0],
+		{ControlID: "1", ControlName: "Bucket ACL", Severity: "High", ResourceHash: "h2", Account: "111", Team: "payments"},
+		{ControlID: "3", ControlName: "Bucket logging", Severity: "High", ResourceHash: "h3", Account: "111", Team: "payments"},
+		{ControlID: "4", ControlName: "Public AMI", Severity: "Critical", ResourceHash: "h4", Account: "111", Team: "payments"},
+		{ControlID: "5", ControlName: "Node image", Severity: "Medium", ResourceHash: "h5", Team: "platform"},
+	}
+
+	changes := Diff(prev, cur, nil)
+	if len(changes.New) != 4 || len(changes.Resolved) != 1 || changes.Resolved[0].ResourceHash != "h9" {
+		t.Fatalf("Unexpected changes: %+v", changes)
+	}
+
+	w := Webhook{Name: "slack"}
+	body, err := w.payload(newMessage("cis_aws", w.Name, changes))
+	if err != nil {
+		t.Fatalf("payload failed: %v", err)
+	}
+	var slack map[string]string
+	if err := json.Unmarshal(body, &slack); err != nil {
+		t.Fatalf("Invalid payload %s: %v", body, err)
+	}
+	want := strings.Join([]string{
+		"[cis_aws] 4 new and 1 resolved CSPM violations",
+		"• 1 new critical-severity failure in account 111 (payments): Public AMI",
+		"• 2 new high-severity failures in account 111 (payments): Bucket ACL, Bucket logging",
+		"• 1 new medium-severity failure in account (no account) (platform): Node image",
+		"• 1 resolved low-severity failure in account 222: Root MFA",
+	}, "\n")
+	if slack["text"] != want {
+		t.Errorf("Unexpected text:\n%s\nwant:\n%s", slack["text"], want)
+	}
+
+	t.Run("チームと重要度のルーティング", func(t *testing.T) {
+		routed := Webhook{Teams: []string{"payments"}, MinSeverity: "high", OnlyNew: true}.route(changes)
+		if len(routed.New) != 3 || len(routed.Resolved) != 0 {
+			t.Errorf("Unexpected payments changes: %+v", routed)
+		}
+		routed = Webhook{Teams: []string{NoTeam}}.route(changes)
+		if len(routed.New) != 0 || len(routed.Resolved) != 1 {
+			t.Errorf("Expected the unowned changes, got %+v", routed)
+		}
+	})
+
+	t.Run("リスク受容は解消と分けて報告する", func(t *testing.T) {
+		accepted := Diff(prev, cur, []database.Violation{prev[1]})
+		if len(accepted.Resolved) != 0 || len(accepted.Accepted) != 1 {
+			t.Fatalf("Expected h9 to be accepted, got %+v", accepted)
+		}
+		body, err := Webhook{Name: "slack"}.payload(newMessage("cis_aws", "slack", accepted))
+		if err != nil {
+			t.Fatalf("payload failed: %v", err)
+		}
+		if !strings.Contains(string(body), "4 new, 0 resolved and 1 risk-accepted CSPM violations") ||
+			!strings.Contains(string(body), "• 1 risk-accepted low-severity failure in account 222: Root MFA") {
+			t.Errorf("Unexpected payload: %s", body)
+		}
+		if routed := (Webhook{OnlyNew: true}).route(accepted); len(routed.Accepted) != 0 {
+			t.Errorf("Expected only_new to drop accepted violations, got %+v", routed)
+		}
+	})
+
+	t.Run("テンプレートとTeams形式", func(t *testing.T) {
+		w := Webhook{Name: "teams", Format: FormatTeams, Template: "{{.Title}}\n{{len .New}} new in {{.Series}}"}
+		body, err := w.payload(newMessage("cis_aws", w.Name, changes))
+		if err != nil {
+			t.Fatalf("payload failed: %v", err)
+		}
+		var card map[string]string
+		if err := json.Unmarshal(body, &card); err != nil {
+			t.Fatalf("Invalid payload %s: %v", body, err)
+		}
+		if card["@type"] != "MessageCard" || card["text"] != "[cis_aws] 4 new and 1 resolved CSPM violations\n\n4 new in cis_aws" {
+			t.Errorf("Unexpected card: %+v", card)
+		}
+	})
+
+	t.Run("サンプル設定", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join("..", "..", "examples", "notify-config.json"))
+		if err != nil {
+			t.Fatalf("Failed to read example: %v", err)
+		}
+		var file struct {
+			Notify Config `json:"notify"`
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			t.Fatalf("Failed to parse example: %v", err)
+		}
+		if err := file.Notify.Validate(); err != nil {
+			t.Errorf("Expected the example notify section to be valid: %v", err)
+		}
+		if w := file.Notify.Webhooks[2]; w.Retries == nil || *w.Retries != 5 || w.format() != FormatJSON {
+			t.Errorf("Unexpected archive webhook: %+v", w)
+		}
+	})
+
+	t.Run("設定の検証", func(t *testing.T) {
+		for _, bad := range []Config{
+			{},
+			{Webhooks: []Webhook{{URL: "https://example.com"}}},
+			{Webhooks: []Webhook{{Name: "a", URL: "https://example.com"}, {Name: "a", URL: "https://example.com"}}},
+			{Webhooks: []Webhook{{Name: "a", URL: "example.com/hook"}}},
+			{Webhooks: []Webhook{{Name: "a", URL: "https://example.com", Format: "xml"}}},
+			{Webhooks: []Webhook{{Name: "a", URL: "https://example.com", MinSeverity: "urgent"}}},
+			{Webhooks: []Webhook{{Name: "a", URL: "https://example.com", Template: "{{.Title"}}},
+		} {
+			if err := bad.Validate(); err == nil {
+				t.Errorf("Expected error for %+v", bad)
+			}
+		}
+	})
+}
+
+// receiver records the payloads posted to it and fails the first failures requests
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	payloads []string
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	if r.requests <= r.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	r.payloads = append(r.payloads, string(body))
+}
+
+func TestSeriesName(t *testing.T) {
+	a := SeriesName(filepath.Join("output", "20251018_090000", "cis_aws.db"))
+	b := SeriesName(filepath.Join("output", "20251019_090000", "cis_aws.db"))
+	if a != b || !filepath.IsAbs(filepath.FromSlash(a)) || !strings.HasSuffix(a, "/output/{timestamp}/cis_aws.db") {
+		t.Errorf("Expected the plan runs to share a series, got %s and %s", a, b)
+	}
+	if SeriesName(filepath.Join("data", "cspm.db")) == SeriesName(filepath.Join("other", "cspm.db")) {
+		t.Error("Expected databases in different directories to have different series")
+	}
+}
+
+func setupDB(t *testing.T, dbPath string) (*database.Database, []models.CloudResource) {
+	t.Helper()
+	db, err := database.NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	requirements := []models.ComplianceRequirementWithControls{
+		{
+			RequirementID: "req-1",
+			Name:          "Ensure buckets are private",
+			PolicyName:    "CIS AWS",
+			Severity:      "High",
+			Controls:      []models.Control{{ID: "16022", Name: "Bucket ACL", Severity: "High"}},
+		},
+	}
+	if err := db.SaveComplianceRequirementsWithControls(requirements); err != nil {
+		t.Fatalf("Failed to save requirements: %v", err)
+	}
+	resources := []models.CloudResource{
+		{Hash: "h1", Name: "bucket-a", Platform: "AWS", Account: "111"},
+		{Hash: "h2", Name: "bucket-b", Platform: "AWS", Account: "111", Passed: true},
+	}
+	if err := db.SaveCloudResources(resources); err != nil {
+		t.Fatalf("Failed to save resources: %v", err)
+	}
+	saveRelations(t, db, resources)
+	return db, resources
+}
+
+func saveRelations(t *testing.T, db *database.Database, resources []models.CloudResource) {
+	t.Helper()
+	if err := db.SaveControlResourceRelations("16022", resources); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "cis_aws.db")
+	db, resources := setupDB(t, dbPath)
+
+	recv := &receiver{failures: 2}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	cfg := Config{
+		StateFile: filepath.Join(dir, "state.json"),
+		Webhooks:  []Webhook{{Name: "security", URL: server.URL}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	n := New(cfg)
+	n.backoff = time.Millisecond
+
+	// 初回は基準を記録するだけ
+	result, err := n.Run(db, dbPath)
+	if err != nil || !result.Webhooks[0].Initial || recv.requests != 0 {
+		t.Fatalf("Expected the first run to record the baseline, got %+v, %v (requests %d)", result, err, recv.requests)
+	}
+
+	// h1 が解消し h2 が新たに失敗
+	resources[0].Passed, resources[1].Passed = true, false
+	saveRelations(t, db, resources)
+	result, err = n.Run(db, dbPath)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if w := result.Webhooks[0]; w.New != 1 || w.Resolved != 1 || !w.Delivered {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if recv.requests != 3 || len(recv.payloads) != 1 || !strings.Contains(recv.payloads[0], "1 new high-severity failure in account 111") {
+		t.Errorf("Expected a delivery after 2 retries, got %d requests: %v", recv.requests, recv.payloads)
+	}
+
+	t.Run("変更がなければ送信しない", func(t *testing.T) {
+		before := recv.requests
+		result, err := n.Run(db, dbPath)
+		if err != nil || result.Webhooks[0].New != 0 || result.Webhooks[0].Resolved != 0 || recv.requests != before {
+			t.Errorf("Expected nothing to be sent, got %+v, %v", result, err)
+		}
+	})
+
+	t.Run("テストモードは基準を更新しない", func(t *testing.T) {
+		resources[0].Passed = false
+		saveRelations(t, db, resources)
+
+		// テストモードでは送信せずにペイロードをファイルに書き出す
+		test := New(cfg)
+		test.PayloadDir = filepath.Join(dir, "payloads")
+		result, err := test.Run(db, dbPath)
+		if err != nil || !result.TestMode || len(result.Written()) != 1 {
+			t.Fatalf("Expected the changes to be written, got %+v, %v", result, err)
+		}
+		data, err := os.ReadFile(result.Written()[0])
+		if err != nil {
+			t.Fatalf("Failed to read payload: %v", err)
+		}
+		if !strings.Contains(string(data), "1 new high-severity failure in account 111") {
+			t.Errorf("Unexpected payload: %s", data)
+		}
+		if !strings.HasPrefix(filepath.Base(result.Written()[0]), "cis_aws_security_") {
+			t.Errorf("Unexpected payload file: %s", result.Written()[0])
+		}
+
+		// 本番の送信は同じ変更を受け取る
+		before := len(recv.payloads)
+		result, err = n.Run(db, dbPath)
+		if err != nil || !result.Webhooks[0].Delivered || len(recv.payloads) != before+1 ||
+			!strings.Contains(recv.payloads[before], "1 new high-severity failure in account 111") {
+			t.Errorf("Expected the real webhook to receive the changes, got %+v, %v: %v", result, err, recv.payloads)
+		}
+	})
+
+	t.Run("リスク受容は解消として報告しない", func(t *testing.T) {
+		resources[0].Acceptance = &models.Acceptance{Justification: "Risk Owned"}
+		saveRelations(t, db, resources)
+
+		before := len(recv.payloads)
+		result, err := n.Run(db, dbPath)
+		if err != nil || result.Webhooks[0].Accepted != 1 || result.Webhooks[0].Resolved != 0 {
+			t.Fatalf("Expected h1 to be reported as accepted, got %+v, %v", result, err)
+		}
+		if len(recv.payloads) != before+1 || !strings.Contains(recv.payloads[before], "1 risk-accepted high-severity failure") {
+			t.Errorf("Unexpected payloads: %v", recv.payloads[before:])
+		}
+	})
+}
+
+// switchable answers with status until it is changed
+type switchable struct {
+	receiver
+	status int
+}
+
+func (s *switchable) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	status := s.status
+	s.mu.Unlock()
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		return
+	}
+	s.receiver.ServeHTTP(w, req)
+}
+
+func TestRun_PerWebhookState(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "cis_aws.db")
+	db, resources := setupDB(t, dbPath)
+
+	good := &receiver{}
+	goodServer := httptest.NewServer(good)
+	defer goodServer.Close()
+	broken := &switchable{status: http.StatusBadRequest}
+	brokenServer := httptest.NewServer(broken)
+	defer brokenServer.Close()
+
+	n := New(Config{
+		StateFile: filepath.Join(dir, "state.json"),
+		Webhooks: []Webhook{
+			{Name: "good", URL: goodServer.URL},
+			{Name: "broken", URL: brokenServer.URL},
+		},
+	})
+	n.backoff = time.Millisecond
+	if _, err := n.Run(db, dbPath); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	resources[1].Passed = false
+	saveRelations(t, db, resources)
+	result, err := n.Run(db, dbPath)
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("Expected the broken webhook to fail, got %v", err)
+	}
+	if !result.Webhooks[0].Delivered || result.Webhooks[1].Err == nil || len(good.payloads) != 1 {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+
+	// 失敗したWebhookだけが次回に同じ変更を受け取る
+	broken.mu.Lock()
+	broken.status = http.StatusOK
+	broken.mu.Unlock()
+	result, err = n.Run(db, dbPath)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Webhooks[0].Delivered || len(good.payloads) != 1 {
+		t.Errorf("Expected no duplicate for the good webhook, got %+v: %v", result, good.payloads)
+	}
+	if !result.Webhooks[1].Delivered || len(broken.payloads) != 1 || !strings.Contains(broken.payloads[0], "1 new high-severity failure") {
+		t.Errorf("Expected the broken webhook to receive the changes, got %+v: %v", result, broken.payloads)
+	}
+}
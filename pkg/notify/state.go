@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// stateVersion is the version of the state file written by this build
+const stateVersion = 1
+
+// state holds the failing violations last notified to each webhook per series. It lives outside
+// the collected databases because collection plans usually write every run to a new database.
+// Each webhook keeps its own record, so that a failed delivery does not resend to the others.
+type state struct {
+	Version int `json:"version"`
+	// Series maps a series to the records of its webhooks
+	Series map[string]map[string]webhookState `json:"series"`
+}
+
+type webhookState struct {
+	RecordedAt time.Time            `json:"recorded_at"`
+	Violations []database.Violation `json:"violations"`
+}
+
+func (s *state) get(series, webhook string) (webhookState, bool) {
+	w, ok := s.Series[series][webhook]
+	return w, ok
+}
+
+func (s *state) set(series, webhook string, w webhookState) {
+	if s.Series[series] == nil {
+		s.Series[series] = make(map[string]webhookState)
+	}
+	s.Series[series][webhook] = w
+}
+
+// stateMu serializes the state file updates of concurrent daemon jobs
+var stateMu sync.Mutex
+
+func loadState(path string) (*state, error) {
+	s := &state{Version: stateVersion, Series: make(map[string]map[string]webhookState)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notify state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse notify state %s: %w", path, err)
+	}
+	if s.Version > stateVersion {
+		return nil, fmt.Errorf("notify state %s has version %d; this build supports up to %d", path, s.Version, stateVersion)
+	}
+	if s.Series == nil {
+		s.Series = make(map[string]map[string]webhookState)
+	}
+	return s, nil
+}
+
+// save writes the state through a temporary file so that an interrupted write keeps the old state
+func (s *state) save(path string) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create notify state directory: %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write notify state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write notify state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+// Package ownership maps the collected resources to the teams owning them.
+package ownership
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// Config configures resource ownership (the ownership section of the config file)
+type Config struct {
+	Teams []Team `json:"teams"`
+	// Rules are evaluated in order; the first matching rule assigns the team
+	Rules []Rule `json:"rules"`
+}
+
+// Team is a team and the contacts receiving its violations
+type Team struct {
+	Name     string   `json:"name"`
+	Contacts []string `json:"contacts,omitempty"`
+}
+
+// Rule assigns a team to the resources matching all of its criteria. A criterion
+// matches when any of its values matches; list values are glob patterns (e.g. "prod-*")
+// in which * also matches "/" (e.g. "*team:payments" matches "app.kubernetes.io/team:payments").
+type Rule struct {
+	Team string `json:"team"`
+	// Accounts are account or project IDs (platform account IDs for cluster resources)
+	Accounts []string `json:"accounts,omitempty"`
+	// NamePattern is a regular expression matched against the resource name
+	NamePattern string   `json:"name_pattern,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	AgentTags   []string `json:"agent_tags,omitempty"`
+	Clusters    []string `json:"clusters,omitempty"`
+}
+
+// Matcher assigns owners with compiled rules
+type Matcher struct {
+	teams map[string]Team
+	rules []rule
+}
+
+type rule struct {
+	Rule
+	name      string
+	pattern   *regexp.Regexp
+	accounts  []*regexp.Regexp
+	labels    []*regexp.Regexp
+	agentTags []*regexp.Regexp
+	clusters  []*regexp.Regexp
+}
+
+// Compile validates the config and compiles its rules
+func (c *Config) Compile() (*Matcher, error) {
+	if len(c.Rules) == 0 {
+		return nil, errors.New("ownership needs at least one rule")
+	}
+
+	m := &Matcher{teams: make(map[string]Team, len(c.Teams))}
+	for i, t := range c.Teams {
+		if t.Name == "" {
+			return nil, fmt.Errorf("ownership team %d has no name", i+1)
+		}
+		if _, ok := m.teams[t.Name]; ok {
+			return nil, fmt.Errorf("ownership team %q is defined twice", t.Name)
+		}
+		m.teams[t.Name] = t
+	}
+
+	for i, r := range c.Rules {
+		compiled := rule{Rule: r, name: fmt.Sprintf("rules[%d]", i)}
+		if _, ok := m.teams[r.Team]; !ok {
+			return nil, fmt.Errorf("ownership %s: unknown team %q", compiled.name, r.Team)
+		}
+		if len(r.Accounts) == 0 && r.NamePattern == "" && len(r.Labels) == 0 && len(r.AgentTags) == 0 && len(r.Clusters) == 0 {
+			return nil, fmt.Errorf("ownership %s: at least one of accounts, name_pattern, labels, agent_tags or clusters is required", compiled.name)
+		}
+		for _, c := range []struct {
+			patterns []string
+			compiled *[]*regexp.Regexp
+		}{
+			{r.Accounts, &compiled.accounts},
+			{r.Labels, &compiled.labels},
+			{r.AgentTags, &compiled.agentTags},
+			{r.Clusters, &compiled.clusters},
+		} {
+			for _, p := range c.patterns {
+				re, err := compileGlob(p)
+				if err != nil {
+					return nil, fmt.Errorf("ownership %s: invalid pattern %q: %w", compiled.name, p, err)
+				}
+				*c.compiled = append(*c.compiled, re)
+			}
+		}
+		if r.NamePattern != "" {
+			pattern, err := regexp.Compile(r.NamePattern)
+			if err != nil {
+				return nil, fmt.Errorf("ownership %s: invalid name_pattern: %w", compiled.name, err)
+			}
+			compiled.pattern = pattern
+		}
+		m.rules = append(m.rules, compiled)
+	}
+
+	return m, nil
+}
+
+// Match returns the owner of a resource, or false when no rule matches
+func (m *Matcher) Match(r database.OwnedResource) (database.Owner, bool) {
+	for _, rl := range m.rules {
+		if rl.matches(r) {
+			return database.Owner{
+				ResourceHash: r.Hash,
+				Team:         rl.Team,
+				Contacts:     m.teams[rl.Team].Contacts,
+				Rule:         rl.name,
+			}, true
+		}
+	}
+	return database.Owner{}, false
+}
+
+func (r rule) matches(res database.OwnedResource) bool {
+	if len(r.accounts) > 0 && !matchAny(r.accounts, res.Account) {
+		return false
+	}
+	if r.pattern != nil && !r.pattern.MatchString(res.Name) {
+		return false
+	}
+	if len(r.labels) > 0 && !matchAny(r.labels, res.LabelValues...) {
+		return false
+	}
+	if len(r.agentTags) > 0 && !matchAny(r.agentTags, res.AgentTags...) {
+		return false
+	}
+	if len(r.clusters) > 0 && !matchAny(r.clusters, res.ClusterName) {
+		return false
+	}
+	return true
+}
+
+// matchAny reports whether any pattern matches any non-empty value
+func matchAny(patterns []*regexp.Regexp, values ...string) bool {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		for _, p := range patterns {
+			if p.MatchString(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compileGlob compiles a glob pattern to an anchored regular expression. Unlike path.Match,
+// * and ? also match "/", since label and tag values often contain it. [...] classes
+// ([!...] negates) and \ escapes are supported.
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(`.`)
+		case '\\':
+			i++
+			if i == len(pattern) {
+				return nil, errors.New("trailing backslash")
+			}
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, errors.New("unterminated character class")
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString(`$`)
+	return regexp.Compile(b.String())
+}
+
+// Result is the outcome of Resolve
+type Result struct {
+	Resources int
+	Owned     int
+}
+
+// Resolve assigns the owners of all collected resources and replaces the owners table.
+// Resources matching no rule are left unowned.
+func Resolve(db *database.Database, cfg *Config, now time.Time) (*Result, error) {
+	matcher, err := cfg.Compile()
+	if err != nil {
+		return nil, err
+	}
+	resources, err := db.GetOwnedResources()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Resources: len(resources)}
+	var owners []database.Owner
+	for _, r := range resources {
+		if owner, ok := matcher.Match(r); ok {
+			owners = append(owners, owner)
+		}
+	}
+	result.Owned = len(owners)
+
+	if err := db.ReplaceOwners(owners, now); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,228 @@
+package ownership
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func testConfig() *Config {
+	return &Config{
+		Teams: []Team{
+			{Name: "platform", Contacts: []string{"platform@example.com", "#platform-alerts"}},
+			{Name: "payments", Contacts: []string{"payments@example.com"}},
+			{Name: "data"},
+		},
+		Rules: []Rule{
+			{Team: "payments", Accounts: []string{"111"}, NamePattern: "^pay-"},
+			{Team: "platform", Clusters: []string{"prod-*"}},
+			{Team: "data", Labels: []string{"team:data"}},
+			{Team: "data", AgentTags: []string{"owner=data*"}},
+			{Team: "platform", Accounts: []string{"111", "222"}},
+		},
+	}
+}
+
+func TestMatch(t *testing.T) {
+	m, err := testConfig().Compile()
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		resource database.OwnedResource
+		team     string
+		rule     string
+	}{
+		{"アカウントと名前の両方に一致", database.OwnedResource{Name: "pay-api", Account: "111"}, "payments", "rules[0]"},
+		{"名前だけ一致する場合は次のルール", database.OwnedResource{Name: "pay-api", Account: "222"}, "platform", "rules[4]"},
+		{"クラスタのglob", database.OwnedResource{Name: "node-1", ClusterName: "prod-tokyo"}, "platform", "rules[1]"},
+		{"ラベル値", database.OwnedResource{Name: "bucket", LabelValues: []string{"env:prod", "team:data"}}, "data", "rules[2]"},
+		{"エージェントタグ", database.OwnedResource{Name: "host", AgentTags: []string{"owner=data-eng"}}, "data", "rules[3]"},
+		{"一致なし", database.OwnedResource{Name: "other", Account: "999", ClusterName: "dev-1"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, ok := m.Match(tt.resource)
+			if ok != (tt.team != "") || owner.Team != tt.team || owner.Rule != tt.rule {
+				t.Errorf("Expected %q (%s), got %+v (matched %v)", tt.team, tt.rule, owner, ok)
+			}
+		})
+	}
+
+	t.Run("スラッシュを含むラベル値", func(t *testing.T) {
+		cfg := &Config{
+			Teams: []Team{{Name: "payments"}, {Name: "platform"}},
+			Rules: []Rule{
+				{Team: "payments", Labels: []string{"*team:payments"}},
+				{Team: "platform", AgentTags: []string{"*/os:linu[!s]"}},
+			},
+		}
+		m, err := cfg.Compile()
+		if err != nil {
+			t.Fatalf("Compile failed: %v", err)
+		}
+		for _, tt := range []struct {
+			resource database.OwnedResource
+			team     string
+		}{
+			{database.OwnedResource{LabelValues: []string{"app.kubernetes.io/team:payments"}}, "payments"},
+			{database.OwnedResource{AgentTags: []string{"kubernetes.io/os:linux"}}, "platform"},
+			{database.OwnedResource{AgentTags: []string{"kubernetes.io/os:linus"}}, ""},
+			{database.OwnedResource{LabelValues: []string{"app.kubernetes.io/team:payments-eu"}}, ""},
+		} {
+			if owner, _ := m.Match(tt.resource); owner.Team != tt.team {
+				t.Errorf("Expected %q for %+v, got %q", tt.team, tt.resource, owner.Team)
+			}
+		}
+	})
+
+	t.Run("設定の検証", func(t *testing.T) {
+		for _, bad := range []Config{
+			{Teams: []Team{{Name: "a"}}},
+			{Teams: []Team{{Name: "a"}, {Name: "a"}}, Rules: []Rule{{Team: "a", Accounts: []string{"1"}}}},
+			{Teams: []Team{{Name: "a"}}, Rules: []Rule{{Team: "b", Accounts: []string{"1"}}}},
+			{Teams: []Team{{Name: "a"}}, Rules: []Rule{{Team: "a"}}},
+			{Teams: []Team{{Name: "a"}}, Rules: []Rule{{Team: "a", NamePattern: "("}}},
+			{Teams: []Team{{Name: "a"}}, Rules: []Rule{{Team: "a", Clusters: []string{"["}}}},
+		} {
+			if _, err := bad.Compile(); err == nil {
+				t.Errorf("Expected error for %+v", bad)
+			}
+		}
+	})
+}
+
+func TestResolve(t *testing.T) {
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	requirements := []models.ComplianceRequirementWithControls{
+		{
+			RequirementID: "req-1",
+			Name:          "Ensure buckets are private",
+			PolicyName:    "CIS AWS",
+			Severity:      "High",
+			Controls:      []models.Control{{ID: "16022", Name: "Bucket ACL", Severity: "High"}},
+		},
+	}
+	if err := db.SaveComplianceRequirementsWithControls(requirements); err != nil {
+		t.Fatalf("Failed to save requirements: %v", err)
+	}
+	resources := []models.CloudResource{
+		{Hash: "h1", Name: "pay-bucket", Platform: "AWS", Account: "111"},
+		{Hash: "h2", Name: "logs", Platform: "AWS", Account: "222", Passed: true},
+		{Hash: "h3", Name: "node-1", Platform: "Kubernetes", PlatformAccountID: "333", ClusterName: "prod-tokyo"},
+		{Hash: "h4", Name: "scratch", Platform: "AWS", Account: "999", LabelValues: []string{"env:dev"}},
+	}
+	if err := db.SaveCloudResources(resources); err != nil {
+		t.Fatalf("Failed to save resources: %v", err)
+	}
+	if err := db.SaveControlResourceRelations("16022", resources); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+
+	result, err := Resolve(db, testConfig(), time.Now())
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if result.Resources != 4 || result.Owned != 3 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	summaries, err := db.GetTeamSummaries()
+	if err != nil {
+		t.Fatalf("GetTeamSummaries failed: %v", err)
+	}
+	// チーム名順、所有者なしは最後
+	if len(summaries) != 3 {
+		t.Fatalf("Expected 3 teams, got %+v", summaries)
+	}
+	if s := summaries[0]; s.Team != "payments" || s.Resources != 1 || s.Failed != 1 {
+		t.Errorf("Unexpected summary: %+v", s)
+	}
+	if s := summaries[1]; s.Team != "platform" || s.Resources != 2 || s.FailedResources != 1 || s.Passed != 1 || len(s.Contacts) != 2 {
+		t.Errorf("Unexpected summary: %+v", s)
+	}
+	if s := summaries[2]; s.Team != "" || s.Resources != 1 || s.Failed != 1 {
+		t.Errorf("Unexpected summary of unowned resources: %+v", s)
+	}
+
+	posture, err := db.GetResourcePosture()
+	if err != nil {
+		t.Fatalf("GetResourcePosture failed: %v", err)
+	}
+	teams := make(map[string]int)
+	for _, c := range posture {
+		teams[c.Team] += c.Evaluations
+	}
+	if teams["platform"] != 2 || teams[""] != 1 {
+		t.Errorf("Expected the posture per team, got %v", teams)
+	}
+
+	for _, l := range []*database.Listing{database.ResourcesListing, database.RelationsListing} {
+		page, err := db.List(l, database.ListQuery{Filters: []database.FilterValue{{Name: "team", Value: "platform"}}})
+		if err != nil {
+			t.Fatalf("List %s failed: %v", l.Name, err)
+		}
+		if page.Total != 2 || page.Rows[0]["team"] != "platform" {
+			t.Errorf("Expected the %s of platform, got %+v", l.Name, page.Rows)
+		}
+	}
+	inventory, err := db.GetInventory(`team = "payments"`)
+	if err != nil {
+		t.Fatalf("GetInventory failed: %v", err)
+	}
+	if len(inventory) != 1 || inventory[0].Hash != "h1" || inventory[0].Team != "payments" {
+		t.Errorf("Expected the inventory of payments, got %+v", inventory)
+	}
+	query, err := db.QueryResources(`team = ""`)
+	if err != nil {
+		t.Fatalf("QueryResources failed: %v", err)
+	}
+	if len(query) != 1 || query[0].Hash != "h4" {
+		t.Errorf("Expected the unowned resource, got %+v", query)
+	}
+
+	t.Run("再計算で置き換える", func(t *testing.T) {
+		cfg := &Config{Teams: []Team{{Name: "data"}}, Rules: []Rule{{Team: "data", Labels: []string{"env:*"}}}}
+		result, err := Resolve(db, cfg, time.Now())
+		if err != nil {
+			t.Fatalf("Resolve failed: %v", err)
+		}
+		summaries, err := db.GetTeamSummaries()
+		if err != nil {
+			t.Fatalf("GetTeamSummaries failed: %v", err)
+		}
+		if result.Owned != 1 || len(summaries) != 2 || summaries[0].Team != "data" || summaries[1].Resources != 3 {
+			t.Errorf("Expected the previous owners to be replaced, got %+v", summaries)
+		}
+	})
+}
+
+func TestExampleConfig(t *testing.T) {
+	for _, name := range []string{"ownership-config.json", "notify-config.json"} {
+		data, err := os.ReadFile(filepath.Join("..", "..", "examples", name))
+		if err != nil {
+			t.Fatalf("Failed to read example: %v", err)
+		}
+		var file struct {
+			Ownership Config `json:"ownership"`
+		}
+		if err := json.Unmarshal(data, &file); err != nil {
+			t.Fatalf("Failed to parse %s: %v", name, err)
+		}
+		if _, err := file.Ownership.Compile(); err != nil {
+			t.Errorf("Expected the ownership section of %s to be valid: %v", name, err)
+		}
+	}
+}
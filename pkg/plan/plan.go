@@ -0,0 +1,214 @@
+package plan
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/notify"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/ownership"
+)
+
+// TimestampPlaceholder is replaced with the run start time in output_dir
+const TimestampPlaceholder = "{timestamp}"
+
+// TimestampFormat is the layout used for TimestampPlaceholder (same as collect-compliance.sh)
+const TimestampFormat = "20060102_150405"
+
+// Plan describes a set of collection targets that are run in one process
+type Plan struct {
+	Version int `yaml:"version"`
+	// OutputDir is the base directory for relative DB paths; "{timestamp}" is expanded per run
+	OutputDir string `yaml:"output_dir"`
+	// SharedDB is used by targets that do not set their own db
+	SharedDB  string    `yaml:"shared_db"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	// Defaults apply to targets that do not set the value themselves
+	Defaults Settings `yaml:"defaults"`
+	Targets  []Target `yaml:"targets"`
+}
+
+// RateLimit holds the rate settings shared by all targets
+type RateLimit struct {
+	// RequestsPerSecond limits API requests across all targets (0 = unlimited)
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+}
+
+// Settings holds per-target collection settings; nil and empty values fall back to the next layer
+type Settings struct {
+	Zone      string `yaml:"zone,omitempty"`
+	PageSize  *int   `yaml:"page_size,omitempty"`
+	BatchSize *int   `yaml:"batch_size,omitempty"`
+	APIDelay  *int   `yaml:"api_delay,omitempty"`
+}
+
+// Target is a single named collection
+type Target struct {
+	Name     string `yaml:"name"`
+	Policy   string `yaml:"policy"`
+	Platform string `yaml:"platform,omitempty"`
+	// DB is the output database; relative paths are resolved against output_dir
+	DB       string `yaml:"db,omitempty"`
+	Settings `yaml:",inline"`
+}
+
+// ResolvedTarget is a target with all settings filled in
+type ResolvedTarget struct {
+	Name      string
+	Policy    string
+	Platform  string
+	Zone      string
+	DBPath    string
+	PageSize  int
+	BatchSize int
+	APIDelay  int
+	// Ownership comes from the config file and applies to every target
+	Ownership *ownership.Config
+	// Notify comes from the config file and applies to every target
+	Notify *notify.Config
+}
+
+// Load reads and validates a plan file
+func Load(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %w", err)
+	}
+
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", path, err)
+	}
+
+	return p, nil
+}
+
+// Parse decodes and validates a plan document; unknown keys are rejected to catch typos
+func Parse(data []byte) (*Plan, error) {
+	var p Plan
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("plan is empty")
+		}
+		return nil, err
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// Validate checks the plan for missing or conflicting settings
+func (p *Plan) Validate() error {
+	if p.Version != 0 && p.Version != 1 {
+		return fmt.Errorf("unsupported plan version %d", p.Version)
+	}
+	if len(p.Targets) == 0 {
+		return fmt.Errorf("no targets defined")
+	}
+	if p.RateLimit.RequestsPerSecond < 0 {
+		return fmt.Errorf("rate_limit.requests_per_second must not be negative")
+	}
+	if err := p.Defaults.validate(); err != nil {
+		return fmt.Errorf("defaults: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i, t := range p.Targets {
+		if strings.TrimSpace(t.Name) == "" {
+			return fmt.Errorf("targets[%d]: name is required", i)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("targets[%d]: duplicate target name %q", i, t.Name)
+		}
+		seen[t.Name] = true
+
+		if t.DB == "" && p.SharedDB == "" {
+			return fmt.Errorf("target %q: db is required when shared_db is not set", t.Name)
+		}
+		if err := t.Settings.validate(); err != nil {
+			return fmt.Errorf("target %q: %w", t.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s Settings) validate() error {
+	if s.PageSize != nil && *s.PageSize <= 0 {
+		return fmt.Errorf("page_size must be positive")
+	}
+	if s.BatchSize != nil && *s.BatchSize <= 0 {
+		return fmt.Errorf("batch_size must be positive")
+	}
+	if s.APIDelay != nil && *s.APIDelay < 0 {
+		return fmt.Errorf("api_delay must not be negative")
+	}
+	return nil
+}
+
+// ResolveOutputDir expands "{timestamp}" in output_dir
+func (p *Plan) ResolveOutputDir(now time.Time) string {
+	return strings.ReplaceAll(p.OutputDir, TimestampPlaceholder, now.Format(TimestampFormat))
+}
+
+// Resolve fills in every target's settings.
+// Priority: target > plan defaults > base (CLI flags / profile)
+func (p *Plan) Resolve(base ResolvedTarget, now time.Time) []ResolvedTarget {
+	outputDir := p.ResolveOutputDir(now)
+
+	resolved := make([]ResolvedTarget, 0, len(p.Targets))
+	for _, t := range p.Targets {
+		r := ResolvedTarget{
+			Name:      t.Name,
+			Policy:    t.Policy,
+			Platform:  t.Platform,
+			Zone:      firstNonEmpty(t.Zone, p.Defaults.Zone, base.Zone),
+			PageSize:  firstSet(t.PageSize, p.Defaults.PageSize, base.PageSize),
+			BatchSize: firstSet(t.BatchSize, p.Defaults.BatchSize, base.BatchSize),
+			APIDelay:  firstSet(t.APIDelay, p.Defaults.APIDelay, base.APIDelay),
+			Ownership: base.Ownership,
+			Notify:    base.Notify,
+		}
+
+		dbPath := firstNonEmpty(t.DB, p.SharedDB)
+		if outputDir != "" && !filepath.IsAbs(dbPath) {
+			dbPath = filepath.Join(outputDir, dbPath)
+		}
+		r.DBPath = dbPath
+
+		resolved = append(resolved, r)
+	}
+
+	return resolved
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstSet(target, defaults *int, base int) int {
+	if target != nil {
+		return *target
+	}
+	if defaults != nil {
+		return *defaults
+	}
+	return base
+}
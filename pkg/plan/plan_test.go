@@ -0,0 +1,198 @@
+package plan
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/internal/testutil"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+const samplePlan = `
+version: 1
+output_dir: data/{timestamp}
+rate_limit:
+  requests_per_second: 5
+defaults:
+  zone: Production
+  batch_size: 2
+targets:
+  - name: aws
+    policy: CIS Amazon Web Services Foundations Benchmark v3.0.0
+    platform: AWS
+    db: cis_aws.db
+  - name: soc2
+    policy: SOC 2
+    zone: Entire Infrastructure
+    db: /tmp/soc2.db
+    api_delay: 0
+`
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(samplePlan))
+	if err != nil {
+		t.Fatalf("Failed to parse plan: %v", err)
+	}
+
+	if len(p.Targets) != 2 {
+		t.Fatalf("Expected 2 targets, got %d", len(p.Targets))
+	}
+	if p.RateLimit.RequestsPerSecond != 5 {
+		t.Errorf("Expected requests_per_second 5, got %v", p.RateLimit.RequestsPerSecond)
+	}
+	if p.Targets[1].APIDelay == nil || *p.Targets[1].APIDelay != 0 {
+		t.Error("Expected api_delay 0 to be set explicitly on soc2")
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		plan    string
+		wantErr string
+	}{
+		{"空のプラン", "", "empty"},
+		{"ターゲットなし", "version: 1\n", "no targets"},
+		{"未知のキー", "targets:\n  - name: a\n    db: a.db\n    polcy: x\n", "polcy"},
+		{"名前なし", "targets:\n  - policy: x\n    db: a.db\n", "name is required"},
+		{"名前の重複", "targets:\n  - name: a\n    db: a.db\n  - name: a\n    db: b.db\n", "duplicate"},
+		{"DB未指定", "targets:\n  - name: a\n", "db is required"},
+		{"不正なbatch_size", "targets:\n  - name: a\n    db: a.db\n    batch_size: 0\n", "batch_size"},
+		{"未対応バージョン", "version: 2\ntargets:\n  - name: a\n    db: a.db\n", "version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.plan))
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	p, err := Parse([]byte(samplePlan))
+	if err != nil {
+		t.Fatalf("Failed to parse plan: %v", err)
+	}
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	base := ResolvedTarget{Zone: "Entire Infrastructure", PageSize: 50, BatchSize: 3, APIDelay: 1}
+	targets := p.Resolve(base, now)
+
+	aws := targets[0]
+	if aws.DBPath != filepath.Join("data", "20250102_030405", "cis_aws.db") {
+		t.Errorf("Unexpected DB path: %s", aws.DBPath)
+	}
+	if aws.Zone != "Production" {
+		t.Errorf("Expected zone from defaults, got %s", aws.Zone)
+	}
+	if aws.BatchSize != 2 || aws.PageSize != 50 || aws.APIDelay != 1 {
+		t.Errorf("Unexpected settings: page=%d batch=%d delay=%d", aws.PageSize, aws.BatchSize, aws.APIDelay)
+	}
+
+	soc2 := targets[1]
+	if soc2.DBPath != "/tmp/soc2.db" {
+		t.Errorf("Expected absolute DB path to be kept, got %s", soc2.DBPath)
+	}
+	if soc2.Zone != "Entire Infrastructure" {
+		t.Errorf("Expected zone from target, got %s", soc2.Zone)
+	}
+	if soc2.APIDelay != 0 {
+		t.Errorf("Expected api_delay 0, got %d", soc2.APIDelay)
+	}
+}
+
+func TestResolve_SharedDB(t *testing.T) {
+	p, err := Parse([]byte("shared_db: all.db\ntargets:\n  - name: a\n  - name: b\n    db: b.db\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse plan: %v", err)
+	}
+
+	targets := p.Resolve(ResolvedTarget{}, time.Now())
+	if targets[0].DBPath != "all.db" {
+		t.Errorf("Expected shared DB, got %s", targets[0].DBPath)
+	}
+	if targets[1].DBPath != "b.db" {
+		t.Errorf("Expected target DB to override shared DB, got %s", targets[1].DBPath)
+	}
+}
+
+func TestRun(t *testing.T) {
+	mockServer := testutil.NewMockServer(testutil.DefaultMockServerConfig())
+	defer mockServer.Close()
+
+	cspmClient := client.NewCSPMClient(mockServer.URL, "test-token")
+
+	dir := t.TempDir()
+	p, err := Parse([]byte(`
+output_dir: ` + dir + `
+shared_db: shared.db
+rate_limit:
+  requests_per_second: 100
+defaults:
+  page_size: 10
+  batch_size: 2
+  api_delay: 0
+targets:
+  - name: cis
+    policy: CIS
+  - name: cis-copy
+    policy: CIS
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse plan: %v", err)
+	}
+
+	summary := p.Run(cspmClient, ResolvedTarget{Zone: "Entire Infrastructure"}, time.Now())
+	if summary.Failed() != 0 {
+		for _, r := range summary.Results {
+			t.Logf("%s: %v", r.Target.Name, r.Err)
+		}
+		t.Fatalf("Expected no failed targets, got %d", summary.Failed())
+	}
+	if len(summary.Results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(summary.Results))
+	}
+	if summary.Results[0].Result.Requirements == 0 {
+		t.Error("Expected requirements to be collected")
+	}
+
+	db, err := database.NewDatabase(filepath.Join(dir, "shared.db"))
+	if err != nil {
+		t.Fatalf("Failed to open shared database: %v", err)
+	}
+	defer db.Close()
+
+	stats, err := db.GetComplianceStats()
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.TotalRequirements == 0 {
+		t.Error("Expected requirements in shared database")
+	}
+
+	var buf bytes.Buffer
+	summary.Print(&buf)
+	if !strings.Contains(buf.String(), "cis-copy") || !strings.Contains(buf.String(), "2/2") {
+		t.Errorf("Unexpected summary output:\n%s", buf.String())
+	}
+}
+
+func TestLoad_ExamplePlan(t *testing.T) {
+	p, err := Load(filepath.Join("..", "..", "examples", "collection-plan.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to load example plan: %v", err)
+	}
+	if len(p.Targets) != 3 {
+		t.Errorf("Expected 3 targets in example plan, got %d", len(p.Targets))
+	}
+}
@@ -0,0 +1,126 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/client"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/collector"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/sysdig"
+)
+
+// TargetResult holds the outcome of one target
+type TargetResult struct {
+	Target   ResolvedTarget
+	Result   *collector.CollectionResult
+	Duration time.Duration
+	Err      error
+}
+
+// Summary holds the outcome of a plan run
+type Summary struct {
+	StartedAt time.Time
+	Duration  time.Duration
+	Results   []TargetResult
+}
+
+// Failed returns the number of targets that failed
+func (s *Summary) Failed() int {
+	failed := 0
+	for _, r := range s.Results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Run collects all targets sequentially with a shared client and rate limiter.
+// A failing target does not stop the remaining ones; check Summary.Failed.
+func (p *Plan) Run(cspmClient *client.CSPMClient, base ResolvedTarget, now time.Time) *Summary {
+	cspmClient.SetRateLimiter(sysdig.NewRateLimiter(p.RateLimit.RequestsPerSecond))
+
+	targets := p.Resolve(base, now)
+	summary := &Summary{StartedAt: now}
+	start := time.Now()
+
+	for i, t := range targets {
+		fmt.Printf("\n[%d/%d] Target %s\n", i+1, len(targets), t.Name)
+		fmt.Println(strings.Repeat("=", 60))
+		fmt.Printf("Parameters: policy=%s, platform=%s, zone=%s, db=%s\n", t.Policy, t.Platform, t.Zone, t.DBPath)
+
+		targetStart := time.Now()
+		result, err := collectTarget(cspmClient, t)
+		if err != nil {
+			fmt.Printf("[WARN] Target %s failed: %v\n", t.Name, err)
+		}
+
+		summary.Results = append(summary.Results, TargetResult{
+			Target:   t,
+			Result:   result,
+			Duration: time.Since(targetStart),
+			Err:      err,
+		})
+	}
+
+	summary.Duration = time.Since(start)
+	return summary
+}
+
+// collectTarget runs a single collection into the target database
+func collectTarget(cspmClient *client.CSPMClient, t ResolvedTarget) (*collector.CollectionResult, error) {
+	return collector.CollectToDatabase(cspmClient, t.DBPath, collector.Options{
+		Target:    t.Name,
+		Policy:    t.Policy,
+		Platform:  t.Platform,
+		Zone:      t.Zone,
+		PageSize:  t.PageSize,
+		BatchSize: t.BatchSize,
+		APIDelay:  t.APIDelay,
+		Ownership: t.Ownership,
+		Notify:    t.Notify,
+	})
+}
+
+// Print writes the consolidated summary table
+func (s *Summary) Print(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "\n=== Plan Summary ===\n")
+	_, _ = fmt.Fprintf(w, "%-20s %-8s %-8s %-10s %-10s %-8s %-10s %s\n",
+		"TARGET", "STATUS", "REQS", "CONTROLS", "RESOURCES", "WARNS", "DURATION", "DB")
+	_, _ = fmt.Fprintln(w, strings.Repeat("-", 100))
+
+	totals := collector.CollectionResult{}
+	for _, r := range s.Results {
+		status := "OK"
+		if r.Err != nil {
+			status = "FAILED"
+		}
+
+		res := collector.CollectionResult{}
+		if r.Result != nil {
+			res = *r.Result
+		}
+		totals.Requirements += res.Requirements
+		totals.ControlsProcessed += res.ControlsProcessed
+		totals.Resources += res.Resources
+		totals.FailedControls += res.FailedControls
+
+		_, _ = fmt.Fprintf(w, "%-20s %-8s %-8d %-10d %-10d %-8d %-10s %s\n",
+			r.Target.Name, status, res.Requirements, res.ControlsProcessed, res.Resources,
+			res.FailedControls, r.Duration.Round(time.Second), r.Target.DBPath)
+	}
+
+	_, _ = fmt.Fprintln(w, strings.Repeat("-", 100))
+	_, _ = fmt.Fprintf(w, "%-20s %-8s %-8d %-10d %-10d %-8d %-10s\n",
+		"TOTAL", fmt.Sprintf("%d/%d", len(s.Results)-s.Failed(), len(s.Results)),
+		totals.Requirements, totals.ControlsProcessed, totals.Resources,
+		totals.FailedControls, s.Duration.Round(time.Second))
+
+	for _, r := range s.Results {
+		if r.Err != nil {
+			_, _ = fmt.Fprintf(w, "\n[FAILED] %s: %v\n", r.Target.Name, r.Err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the next activation time after a given time
+type Schedule interface {
+	Next(after time.Time) time.Time
+	String() string
+}
+
+// descriptors maps the predefined cron schedules to their five-field form
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a cron-style schedule.
+// Supported forms: five fields "minute hour day-of-month month day-of-week" with *, lists, ranges and steps,
+// the descriptors @yearly, @monthly, @weekly, @daily, @hourly, and "@every <duration>" (e.g. "@every 6h").
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("empty schedule")
+	}
+
+	if strings.HasPrefix(expr, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("invalid schedule %q: interval must be at least 1m", expr)
+		}
+		return &intervalSchedule{expr: expr, interval: d}, nil
+	}
+
+	fieldsExpr := expr
+	if strings.HasPrefix(expr, "@") {
+		var ok bool
+		if fieldsExpr, ok = descriptors[expr]; !ok {
+			return nil, fmt.Errorf("invalid schedule %q: unknown descriptor", expr)
+		}
+	}
+
+	fields := strings.Fields(fieldsExpr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields (minute hour day-of-month month day-of-week)", expr)
+	}
+
+	s := &cronSchedule{expr: expr}
+	var err error
+	if s.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: minute: %w", expr, err)
+	}
+	if s.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: hour: %w", expr, err)
+	}
+	if s.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: day-of-month: %w", expr, err)
+	}
+	if s.month, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: month: %w", expr, err)
+	}
+	if s.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: day-of-week: %w", expr, err)
+	}
+	// 日曜日は0と7の両方を許可する
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domRestricted = fields[2] != "*"
+	s.dowRestricted = fields[4] != "*"
+
+	return s, nil
+}
+
+// intervalSchedule fires at a fixed interval
+type intervalSchedule struct {
+	expr     string
+	interval time.Duration
+}
+
+func (s *intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.interval).Truncate(time.Second)
+}
+
+func (s *intervalSchedule) String() string {
+	return s.expr
+}
+
+// cronSchedule holds one bit per allowed value of each field
+type cronSchedule struct {
+	expr                         string
+	minute, hour, dom, month     uint64
+	dow                          uint64
+	domRestricted, dowRestricted bool
+}
+
+func (s *cronSchedule) String() string {
+	return s.expr
+}
+
+// Next returns the first matching minute strictly after the given time (in its location)
+func (s *cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+
+	// 最悪でも数年以内に一致する（2/30のような不成立の組み合わせはゼロ値を返す）
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches follows cron semantics: when both day fields are restricted, either may match
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// parseField parses a comma-separated list of values, ranges (a-b) and steps (*/n, a-b/n)
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		if part == "" {
+			return 0, fmt.Errorf("empty list element in %q", field)
+		}
+
+		rangePart, step := part, 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			rangePart = part[:idx]
+			n, err := strconv.Atoi(part[idx+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if lo, err = parseValue(bounds[0], min, max); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(bounds[1], min, max); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			v, err := parseValue(rangePart, min, max)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// "5/15" は5から最大値まで15刻み
+			if step == 1 {
+				hi = v
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseValue(s string, min, max int) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, min, max)
+	}
+	return v, nil
+}
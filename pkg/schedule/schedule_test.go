@@ -0,0 +1,75 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"空文字", ""},
+		{"フィールド不足", "0 * * *"},
+		{"範囲外の分", "60 * * * *"},
+		{"逆順の範囲", "0 5-1 * * *"},
+		{"不正なステップ", "*/0 * * * *"},
+		{"未知の記述子", "@fortnightly"},
+		{"短すぎる間隔", "@every 10s"},
+		{"不正な間隔", "@every soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.expr); err == nil {
+				t.Errorf("Expected error for %q", tt.expr)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2025-01-15 10:30:20 (水曜日)
+	base := time.Date(2025, 1, 15, 10, 30, 20, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{"毎分", "* * * * *", time.Date(2025, 1, 15, 10, 31, 0, 0, time.UTC)},
+		{"毎時0分", "@hourly", time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"毎日2時", "0 2 * * *", time.Date(2025, 1, 16, 2, 0, 0, 0, time.UTC)},
+		{"@daily", "@daily", time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"15分刻み", "*/15 * * * *", time.Date(2025, 1, 15, 10, 45, 0, 0, time.UTC)},
+		{"平日9時と18時", "0 9,18 * * 1-5", time.Date(2025, 1, 15, 18, 0, 0, 0, time.UTC)},
+		{"日曜日(7)", "0 3 * * 7", time.Date(2025, 1, 19, 3, 0, 0, 0, time.UTC)},
+		{"月初", "@monthly", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"日付と曜日のOR", "0 0 20 * 5", time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"年跨ぎ", "0 0 1 1 *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"間隔指定", "@every 6h", time.Date(2025, 1, 15, 16, 30, 20, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", tt.expr, err)
+			}
+			if got := s.Next(base); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) for %q = %s, want %s", base, tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext_Impossible(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if got := s.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Expected zero time for impossible schedule, got %s", got)
+	}
+}
@@ -0,0 +1,237 @@
+package scorecard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Scorecard formats
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// Formats lists the formats of the per-account scorecards
+var Formats = []string{FormatMarkdown, FormatHTML}
+
+// WriteTable prints the ranked accounts or teams
+func WriteTable(w io.Writer, accounts []Account) error {
+	if len(accounts) == 0 {
+		_, err := fmt.Fprintln(w, "No collected resources found")
+		return err
+	}
+	// チーム別の場合はチーム列を追加し、アカウント列にはチームのアカウントを表示する
+	byTeam := accounts[0].Team != ""
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if byTeam {
+		fmt.Fprint(tw, "RANK\tGRADE\tSCORE\tTEAM\tACCOUNTS\t")
+	} else {
+		fmt.Fprint(tw, "RANK\tGRADE\tSCORE\tACCOUNT\t")
+	}
+	fmt.Fprintln(tw, "PLATFORM\tFAILED\tPASSED\tACCEPTED\tWORST POLICY")
+	for _, a := range accounts {
+		worst := "-"
+		if len(a.Policies) > 0 {
+			p := a.Policies[0]
+			worst = fmt.Sprintf("%s (%s %.1f)", p.Policy, p.Grade, p.Score)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%.1f\t", a.Rank, a.Grade, a.Score)
+		if byTeam {
+			fmt.Fprintf(tw, "%s\t", a.Team)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n",
+			a.Account, a.Platform, a.Failed, a.Passed, a.Accepted, worst)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	unit := "accounts"
+	if byTeam {
+		unit = "teams"
+	}
+	_, err := fmt.Fprintf(w, "\nTotal: %d %s\n", len(accounts), unit)
+	return err
+}
+
+// WriteJSON writes the ranked accounts as a JSON array
+func WriteJSON(w io.Writer, accounts []Account) error {
+	if accounts == nil {
+		accounts = []Account{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(accounts)
+}
+
+// Write writes the scorecard of one account or team in the given format
+func Write(w io.Writer, format string, a Account, cfg Config, now time.Time) error {
+	switch format {
+	case FormatMarkdown:
+		return WriteMarkdown(w, a, cfg, now)
+	case FormatHTML:
+		return WriteHTML(w, a, cfg, now)
+	}
+	return fmt.Errorf("unknown scorecard format %q (%s)", format, strings.Join(Formats, ", "))
+}
+
+// WriteMarkdown writes the scorecard of one account or team
+func WriteMarkdown(w io.Writer, a Account, cfg Config, now time.Time) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Compliance scorecard: %s\n\n", a.Name())
+	fmt.Fprintf(&b, "**Grade %s** — score %.1f / 100, rank %d", a.Grade, a.Score, a.Rank)
+	if a.Platform != "" {
+		fmt.Fprintf(&b, " (%s)", a.Platform)
+	}
+	if a.Team != "" {
+		fmt.Fprintf(&b, "\n\nAccounts: %s", a.Account)
+	}
+	fmt.Fprintf(&b, "\n\nGenerated %s. %d control/resource evaluations: %d failed, %d passed, %d accepted.\n\n",
+		now.UTC().Format("2006-01-02"), a.Total(), a.Failed, a.Passed, a.Accepted)
+
+	b.WriteString("## Policies\n\n")
+	b.WriteString("| Policy | Grade | Score | Failed | Passed | Accepted | Failed by severity |\n")
+	b.WriteString("|--------|:-----:|------:|-------:|-------:|---------:|--------------------|\n")
+	for _, p := range a.Policies {
+		fmt.Fprintf(&b, "| %s | %s | %.1f | %d | %d | %d | %s |\n",
+			escapeCell(p.Policy), p.Grade, p.Score, p.Failed, p.Passed, p.Accepted, escapeCell(failedBySeverity(p, cfg)))
+	}
+
+	b.WriteString("\n")
+	b.WriteString(methodology(cfg))
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var htmlScorecard = template.Must(template.New("scorecard").Funcs(template.FuncMap{
+	"score":    func(f float64) string { return fmt.Sprintf("%.1f", f) },
+	"bySev":    failedBySeverity,
+	"gradeCSS": func(g string) string { return "grade-" + strings.ToLower(g) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Compliance scorecard: {{.A.Name}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; }
+td.num { text-align: right; }
+.grade { display: inline-block; font-size: 2em; font-weight: bold; padding: 0 .4em; border-radius: 4px; color: #fff; background: #888; }
+.grade-a { background: #2e7d32; } .grade-b { background: #689f38; } .grade-c { background: #f9a825; }
+.grade-d { background: #ef6c00; } .grade-f { background: #c62828; }
+</style>
+</head>
+<body>
+<h1>Compliance scorecard: {{.A.Name}}</h1>
+<p><span class="grade {{gradeCSS .A.Grade}}">{{.A.Grade}}</span>
+score {{score .A.Score}} / 100, rank {{.A.Rank}}{{if .A.Platform}} ({{.A.Platform}}){{end}}</p>
+{{- if .A.Team}}
+<p>Accounts: {{.A.Account}}</p>
+{{- end}}
+<p>Generated {{.Generated}}. {{.A.Total}} control/resource evaluations: {{.A.Failed}} failed, {{.A.Passed}} passed, {{.A.Accepted}} accepted.</p>
+<h2>Policies</h2>
+<table>
+<tr><th>Policy</th><th>Grade</th><th>Score</th><th>Failed</th><th>Passed</th><th>Accepted</th><th>Failed by severity</th></tr>
+{{- range .A.Policies}}
+<tr><td>{{.Policy}}</td><td class="{{gradeCSS .Grade}}">{{.Grade}}</td><td class="num">{{score .Score}}</td><td class="num">{{.Failed}}</td><td class="num">{{.Passed}}</td><td class="num">{{.Accepted}}</td><td>{{bySev . $.Cfg}}</td></tr>
+{{- end}}
+</table>
+<p>{{.Methodology}}</p>
+</body>
+</html>
+`))
+
+// WriteHTML writes the scorecard of one account or team as a standalone HTML page
+func WriteHTML(w io.Writer, a Account, cfg Config, now time.Time) error {
+	return htmlScorecard.Execute(w, struct {
+		A           Account
+		Cfg         Config
+		Generated   string
+		Methodology string
+	}{a, cfg, now.UTC().Format("2006-01-02"), methodology(cfg)})
+}
+
+// FileName returns a file name for the scorecard of an account or team. Names that are not
+// safe as they are get a short hash of the original name, so that names differing only in
+// replaced characters (e.g. "prod a" and "prod_a", or Japanese names) do not share a file.
+func FileName(account, format string) string {
+	ext := ".md"
+	if format == FormatHTML {
+		ext = ".html"
+	}
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(account, "_"), "_.")
+	if name == account && name != "" {
+		return name + ext
+	}
+	if name == "" {
+		name = "account"
+	}
+	sum := sha256.Sum256([]byte(account))
+	return name + "-" + hex.EncodeToString(sum[:4]) + ext
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// failedBySeverity lists the failed evaluations per severity, most severe weight first
+func failedBySeverity(p PolicyScore, cfg Config) string {
+	severities := make([]string, 0, len(p.Severities))
+	for s, c := range p.Severities {
+		if c.Failed > 0 {
+			severities = append(severities, s)
+		}
+	}
+	sort.Slice(severities, func(i, j int) bool {
+		wi, wj := cfg.weight(severities[i]), cfg.weight(severities[j])
+		if wi != wj {
+			return wi > wj
+		}
+		return severities[i] < severities[j]
+	})
+	parts := make([]string, len(severities))
+	for i, s := range severities {
+		parts[i] = fmt.Sprintf("%s %d", s, p.Severities[s].Failed)
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// methodology explains the score with the configured weights and grades
+func methodology(cfg Config) string {
+	severities := make([]string, 0, len(cfg.Weights))
+	for s := range cfg.Weights {
+		severities = append(severities, s)
+	}
+	sort.Slice(severities, func(i, j int) bool {
+		if cfg.Weights[severities[i]] != cfg.Weights[severities[j]] {
+			return cfg.Weights[severities[i]] > cfg.Weights[severities[j]]
+		}
+		return severities[i] < severities[j]
+	})
+	weights := make([]string, len(severities))
+	for i, s := range severities {
+		weights[i] = fmt.Sprintf("%s %g", s, cfg.Weights[s])
+	}
+	grades := make([]string, len(cfg.Grades))
+	for i, g := range cfg.Grades {
+		grades[i] = fmt.Sprintf("%s ≥ %g", g.Grade, g.MinScore)
+	}
+	return fmt.Sprintf("Score = 100 × weighted passing and accepted evaluations / weighted evaluations. Weights: %s (others 1). Grades: %s.",
+		strings.Join(weights, ", "), strings.Join(grades, ", "))
+}
+
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,288 @@
+// Package scorecard grades the compliance of each cloud account and project.
+package scorecard
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// NoAccount labels the resources collected without an account
+const NoAccount = "(no account)"
+
+// NoTeam labels the resources no ownership rule assigns to a team
+const NoTeam = "(no team)"
+
+// Groupings of the scorecards
+const (
+	GroupByAccount = "account"
+	GroupByTeam    = "team"
+)
+
+// Config configures the scorecard command (the scorecard section of the config file)
+type Config struct {
+	// Weights are the weights of the control severities (case-insensitive); severities
+	// missing from the map weigh 1
+	Weights map[string]float64 `json:"weights,omitempty"`
+	// Grades are the minimum scores of the grades, best first; scores below the last one
+	// get the last grade
+	Grades []Grade `json:"grades,omitempty"`
+}
+
+// Grade is the minimum score (0-100) for a grade
+type Grade struct {
+	Grade    string  `json:"grade"`
+	MinScore float64 `json:"min_score"`
+}
+
+// DefaultConfig returns the weights and grades used when the config file has no scorecard section
+func DefaultConfig() Config {
+	return Config{
+		Weights: map[string]float64{"critical": 10, "high": 5, "medium": 3, "low": 1},
+		Grades: []Grade{
+			{Grade: "A", MinScore: 90},
+			{Grade: "B", MinScore: 80},
+			{Grade: "C", MinScore: 70},
+			{Grade: "D", MinScore: 60},
+			{Grade: "F", MinScore: 0},
+		},
+	}
+}
+
+// WithDefaults fills the weights and grades missing from the config with the defaults
+func (c Config) WithDefaults() Config {
+	defaults := DefaultConfig()
+	weights := make(map[string]float64, len(defaults.Weights))
+	for severity, w := range defaults.Weights {
+		weights[severity] = w
+	}
+	for severity, w := range c.Weights {
+		weights[strings.ToLower(severity)] = w
+	}
+	c.Weights = weights
+	if len(c.Grades) == 0 {
+		c.Grades = defaults.Grades
+	}
+	return c
+}
+
+// Validate checks that weights are not negative and grades are ordered best first
+func (c Config) Validate() error {
+	for severity, w := range c.Weights {
+		if w < 0 {
+			return fmt.Errorf("scorecard weight of %q is negative", severity)
+		}
+	}
+	if len(c.Grades) == 0 {
+		return errors.New("scorecard needs at least one grade")
+	}
+	for i, g := range c.Grades {
+		if g.Grade == "" {
+			return fmt.Errorf("scorecard grade %d has no name", i+1)
+		}
+		if i > 0 && g.MinScore >= c.Grades[i-1].MinScore {
+			return fmt.Errorf("scorecard grades must be ordered by descending min_score (%s after %s)", g.Grade, c.Grades[i-1].Grade)
+		}
+	}
+	return nil
+}
+
+// weight returns the weight of a severity
+func (c Config) weight(severity string) float64 {
+	if w, ok := c.Weights[strings.ToLower(severity)]; ok {
+		return w
+	}
+	return 1
+}
+
+// grade returns the grade of a score
+func (c Config) grade(score float64) string {
+	for _, g := range c.Grades {
+		if score >= g.MinScore {
+			return g.Grade
+		}
+	}
+	return c.Grades[len(c.Grades)-1].Grade
+}
+
+// Counts are control/resource evaluations by status
+type Counts struct {
+	Failed   int `json:"failed"`
+	Passed   int `json:"passed"`
+	Accepted int `json:"accepted"`
+}
+
+// Total returns the number of evaluations
+func (c Counts) Total() int {
+	return c.Failed + c.Passed + c.Accepted
+}
+
+// PolicyScore is the score of one account in one policy
+type PolicyScore struct {
+	Policy string `json:"policy"`
+	Counts
+	// Severities are the evaluations per control severity
+	Severities map[string]Counts `json:"severities"`
+	Score      float64           `json:"score"`
+	Grade      string            `json:"grade"`
+}
+
+// Account is the scorecard of one account or project, or of one team
+type Account struct {
+	Rank int `json:"rank"`
+	// Team is only set for the scorecards of teams; Account then lists the accounts of the team
+	Team     string `json:"team,omitempty"`
+	Account  string `json:"account"`
+	Platform string `json:"platform"`
+	Counts
+	Score    float64       `json:"score"`
+	Grade    string        `json:"grade"`
+	Policies []PolicyScore `json:"policies"`
+}
+
+// Name returns the team of a team scorecard and the account otherwise
+func (a Account) Name() string {
+	if a.Team != "" {
+		return a.Team
+	}
+	return a.Account
+}
+
+// score is 100 × the weighted share of passing and accepted evaluations.
+// Without weighted evaluations the score is 100.
+type score struct {
+	good, total float64
+}
+
+func (s *score) add(status string, n int, weight float64) {
+	s.total += float64(n) * weight
+	if status != "failed" {
+		s.good += float64(n) * weight
+	}
+}
+
+func (s score) value() float64 {
+	if s.total == 0 {
+		return 100
+	}
+	return 100 * s.good / s.total
+}
+
+// Compute builds the scorecards of the accounts from the resource posture of the databases,
+// ranked by score, best first. Accepted evaluations count as passing.
+func Compute(counts []database.PostureCount, cfg Config) []Account {
+	return ComputeBy(counts, cfg, GroupByAccount)
+}
+
+// ComputeBy builds the scorecards of the accounts (GroupByAccount) or owning teams (GroupByTeam)
+func ComputeBy(counts []database.PostureCount, cfg Config, groupBy string) []Account {
+	type accountData struct {
+		platforms map[string]bool
+		accounts  map[string]bool
+		counts    Counts
+		score     score
+		policies  map[string]*PolicyScore
+		scores    map[string]*score
+	}
+	accounts := make(map[string]*accountData)
+
+	for _, c := range counts {
+		account := c.Account
+		if account == "" {
+			account = NoAccount
+		}
+		name := account
+		if groupBy == GroupByTeam {
+			name = c.Team
+			if name == "" {
+				name = NoTeam
+			}
+		}
+		a, ok := accounts[name]
+		if !ok {
+			a = &accountData{platforms: make(map[string]bool), accounts: make(map[string]bool), policies: make(map[string]*PolicyScore), scores: make(map[string]*score)}
+			accounts[name] = a
+		}
+		if c.Platform != "" {
+			a.platforms[c.Platform] = true
+		}
+		a.accounts[account] = true
+		p, ok := a.policies[c.Policy]
+		if !ok {
+			p = &PolicyScore{Policy: c.Policy, Severities: make(map[string]Counts)}
+			a.policies[c.Policy] = p
+			a.scores[c.Policy] = &score{}
+		}
+
+		severity := p.Severities[c.Severity]
+		addCount(&severity, c.Status, c.Evaluations)
+		p.Severities[c.Severity] = severity
+		addCount(&p.Counts, c.Status, c.Evaluations)
+		addCount(&a.counts, c.Status, c.Evaluations)
+
+		w := cfg.weight(c.Severity)
+		a.scores[c.Policy].add(c.Status, c.Evaluations, w)
+		a.score.add(c.Status, c.Evaluations, w)
+	}
+
+	result := make([]Account, 0, len(accounts))
+	for name, a := range accounts {
+		account := Account{Account: name, Counts: a.counts, Score: a.score.value()}
+		account.Grade = cfg.grade(account.Score)
+		account.Platform = joinKeys(a.platforms)
+		if groupBy == GroupByTeam {
+			account.Team = name
+			account.Account = joinKeys(a.accounts)
+		}
+
+		for policy, p := range a.policies {
+			p.Score = a.scores[policy].value()
+			p.Grade = cfg.grade(p.Score)
+			account.Policies = append(account.Policies, *p)
+		}
+		// ポリシーは悪いものから表示する
+		sort.Slice(account.Policies, func(i, j int) bool {
+			pi, pj := account.Policies[i], account.Policies[j]
+			if pi.Score != pj.Score {
+				return pi.Score < pj.Score
+			}
+			return pi.Policy < pj.Policy
+		})
+		result = append(result, account)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].Name() < result[j].Name()
+	})
+	for i := range result {
+		result[i].Rank = i + 1
+	}
+	return result
+}
+
+// joinKeys returns the sorted keys of a set separated by commas
+func joinKeys(set map[string]bool) string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+func addCount(c *Counts, status string, n int) {
+	switch status {
+	case "failed":
+		c.Failed += n
+	case "accepted":
+		c.Accepted += n
+	default:
+		c.Passed += n
+	}
+}
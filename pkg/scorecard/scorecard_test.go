@@ -0,0 +1,170 @@
+package scorecard
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+func TestCompute(t *testing.T) {
+	counts := []database.PostureCount{
+		{Policy: "CIS AWS", Platform: "AWS", Severity: "High", Account: "111", Status: "failed", Evaluations: 1},
+		{Policy: "CIS AWS", Platform: "AWS", Severity: "Low", Account: "111", Status: "passed", Evaluations: 5},
+		{Policy: "CIS AWS", Platform: "AWS", Severity: "High", Account: "222", Status: "passed", Evaluations: 3},
+		{Policy: "CIS AWS", Platform: "AWS", Severity: "High", Account: "222", Status: "accepted", Evaluations: 1},
+		{Policy: "SOC 2", Platform: "AWS", Severity: "Medium", Account: "222", Status: "failed", Evaluations: 1},
+		{Policy: "CIS Kubernetes", Platform: "Kubernetes", Severity: "Low", Account: "", Status: "passed", Evaluations: 2},
+	}
+	cfg := DefaultConfig()
+
+	accounts := Compute(counts, cfg)
+	if len(accounts) != 3 {
+		t.Fatalf("Expected 3 accounts, got %+v", accounts)
+	}
+
+	// 111: 失敗 High 1件(5) / 合格 Low 5件(5) → 50点
+	// 222: 合格・受容 High 4件(20) / 失敗 Medium 1件(3) → 86.96点
+	order := []string{NoAccount, "222", "111"}
+	for i, name := range order {
+		if accounts[i].Account != name || accounts[i].Rank != i+1 {
+			t.Errorf("Rank %d: expected %s, got %s (rank %d)", i+1, name, accounts[i].Account, accounts[i].Rank)
+		}
+	}
+	if a := accounts[2]; a.Score != 50 || a.Grade != "F" || a.Failed != 1 || a.Passed != 5 {
+		t.Errorf("Unexpected scorecard of 111: %+v", a)
+	}
+	a := accounts[1]
+	if math.Abs(a.Score-100*20.0/23) > 0.001 || a.Grade != "B" || a.Accepted != 1 {
+		t.Errorf("Unexpected scorecard of 222: %+v", a)
+	}
+	// ポリシーは悪いものから
+	if len(a.Policies) != 2 || a.Policies[0].Policy != "SOC 2" || a.Policies[0].Score != 0 || a.Policies[1].Grade != "A" {
+		t.Errorf("Unexpected policies of 222: %+v", a.Policies)
+	}
+
+	t.Run("重みの設定", func(t *testing.T) {
+		// Low を重視すると 111 の方が良くなる
+		cfg := Config{Weights: map[string]float64{"High": 1, "LOW": 10}}.WithDefaults()
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate failed: %v", err)
+		}
+		accounts := Compute(counts, cfg)
+		if accounts[1].Account != "111" || math.Abs(accounts[1].Score-100*50.0/51) > 0.001 {
+			t.Errorf("Expected the weights to change the ranking, got %+v", accounts)
+		}
+	})
+
+	t.Run("チーム別", func(t *testing.T) {
+		teamCounts := []database.PostureCount{
+			{Policy: "CIS AWS", Severity: "High", Account: "111", Team: "payments", Status: "failed", Evaluations: 1},
+			{Policy: "CIS AWS", Severity: "High", Account: "222", Team: "payments", Status: "passed", Evaluations: 1},
+			{Policy: "CIS AWS", Severity: "High", Account: "222", Team: "", Status: "passed", Evaluations: 1},
+		}
+		teams := ComputeBy(teamCounts, cfg, GroupByTeam)
+		if len(teams) != 2 || teams[0].Team != NoTeam || teams[1].Team != "payments" {
+			t.Fatalf("Unexpected teams: %+v", teams)
+		}
+		if a := teams[1]; a.Account != "111, 222" || a.Score != 50 || a.Name() != "payments" {
+			t.Errorf("Unexpected scorecard of payments: %+v", a)
+		}
+
+		var table bytes.Buffer
+		if err := WriteTable(&table, teams); err != nil {
+			t.Fatalf("WriteTable failed: %v", err)
+		}
+		if !strings.Contains(table.String(), "TEAM") || !strings.Contains(table.String(), "Total: 2 teams") {
+			t.Errorf("Unexpected table:\n%s", table.String())
+		}
+	})
+
+	t.Run("設定の検証", func(t *testing.T) {
+		for _, bad := range []Config{
+			{Weights: map[string]float64{"high": -1}, Grades: cfg.Grades},
+			{Weights: cfg.Weights, Grades: []Grade{{Grade: "A", MinScore: 50}, {Grade: "B", MinScore: 80}}},
+		} {
+			if err := bad.Validate(); err == nil {
+				t.Errorf("Expected error for %+v", bad)
+			}
+		}
+	})
+}
+
+func TestWriteScorecard(t *testing.T) {
+	cfg := DefaultConfig()
+	accounts := Compute([]database.PostureCount{
+		{Policy: "CIS AWS | v3", Platform: "AWS", Severity: "High", Account: "111/prod", Status: "failed", Evaluations: 2},
+		{Policy: "CIS AWS | v3", Platform: "AWS", Severity: "Low", Account: "111/prod", Status: "passed", Evaluations: 8},
+	}, cfg)
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+
+	var table bytes.Buffer
+	if err := WriteTable(&table, accounts); err != nil {
+		t.Fatalf("WriteTable failed: %v", err)
+	}
+	if !strings.Contains(table.String(), "111/prod") || !strings.Contains(table.String(), "Total: 1 accounts") {
+		t.Errorf("Unexpected table:\n%s", table.String())
+	}
+
+	var md bytes.Buffer
+	if err := Write(&md, FormatMarkdown, accounts[0], cfg, now); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	for _, want := range []string{"# Compliance scorecard: 111/prod", "**Grade F**", `CIS AWS \| v3`, "High 2", "Weights: critical 10"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("Expected %q in markdown:\n%s", want, md.String())
+		}
+	}
+
+	var html bytes.Buffer
+	if err := Write(&html, FormatHTML, accounts[0], cfg, now); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	if !strings.Contains(html.String(), `<span class="grade grade-f">F</span>`) || !strings.Contains(html.String(), "2026-10-18") {
+		t.Errorf("Unexpected HTML:\n%s", html.String())
+	}
+
+	if name := FileName("111111111111", FormatHTML); name != "111111111111.html" {
+		t.Errorf("Expected the account as file name, got %s", name)
+	}
+	if name := FileName("111/prod", FormatHTML); !strings.HasPrefix(name, "111_prod-") || !strings.HasSuffix(name, ".html") {
+		t.Errorf("Expected a safe file name, got %s", name)
+	}
+
+	t.Run("置換で衝突する名前は別のファイルになる", func(t *testing.T) {
+		names := map[string]string{}
+		for _, account := range []string{"prod a", "prod_a", "prod/a", "本番", "開発", ""} {
+			name := FileName(account, FormatMarkdown)
+			if other, ok := names[name]; ok {
+				t.Errorf("%q and %q share the file name %s", account, other, name)
+			}
+			names[name] = account
+		}
+	})
+}
+
+func TestExampleConfig(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "examples", "scorecard-config.json"))
+	if err != nil {
+		t.Fatalf("Failed to read example: %v", err)
+	}
+	var file struct {
+		Scorecard Config `json:"scorecard"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("Failed to parse example: %v", err)
+	}
+
+	if file.Scorecard.Weights["critical"] != 20 || len(file.Scorecard.Grades) != 5 {
+		t.Fatalf("Unexpected scorecard section: %+v", file.Scorecard)
+	}
+	if err := file.Scorecard.WithDefaults().Validate(); err != nil {
+		t.Errorf("Expected the example scorecard section to be valid: %v", err)
+	}
+}
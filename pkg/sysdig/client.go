@@ -12,9 +12,11 @@ import (
 
 // Client represents a Sysdig API client
 type Client struct {
-	baseURL    string
-	apiToken   string
-	httpClient *http.Client
+	baseURL      string
+	secureAPIURL string
+	apiToken     string
+	httpClient   *http.Client
+	rateLimiter  *RateLimiter
 }
 
 // Vulnerability represents a vulnerability from Sysdig V2 API
@@ -98,17 +100,42 @@ type VulnerabilityFilter struct {
 	CVE         string   `json:"cve,omitempty"`
 }
 
-// NewClient creates a new Sysdig API client
+// NewClient creates a new Sysdig API client.
+// The public API URL for /secure/ endpoints is derived from baseURL via the region table.
 func NewClient(baseURL, apiToken string) *Client {
+	return NewClientWithEndpoints(Endpoints{
+		APIURL:       strings.TrimRight(baseURL, "/"),
+		SecureAPIURL: DefaultSecureAPIURL(baseURL),
+	}, apiToken)
+}
+
+// NewClientWithEndpoints creates a new Sysdig API client with explicit base URLs
+func NewClientWithEndpoints(endpoints Endpoints, apiToken string) *Client {
+	secureAPIURL := endpoints.SecureAPIURL
+	if secureAPIURL == "" {
+		secureAPIURL = DefaultSecureAPIURL(endpoints.APIURL)
+	}
+
 	return &Client{
-		baseURL:  baseURL,
-		apiToken: apiToken,
+		baseURL:      endpoints.APIURL,
+		secureAPIURL: secureAPIURL,
+		apiToken:     apiToken,
 		httpClient: &http.Client{
 			Timeout: 0, // タイムアウト無効化
 		},
 	}
 }
 
+// Endpoints returns the base URLs used by the client
+func (c *Client) Endpoints() Endpoints {
+	return Endpoints{APIURL: c.baseURL, SecureAPIURL: c.secureAPIURL}
+}
+
+// SetRateLimiter makes every request wait for the given limiter (nil disables rate limiting)
+func (c *Client) SetRateLimiter(l *RateLimiter) {
+	c.rateLimiter = l
+}
+
 // MakeRequest performs an HTTP request to the Sysdig API (exported for use by other packages)
 func (c *Client) MakeRequest(method, endpoint string, body interface{}) (*http.Response, error) {
 	return c.makeRequest(method, endpoint, body)
@@ -116,27 +143,17 @@ func (c *Client) MakeRequest(method, endpoint string, body interface{}) (*http.R
 
 // makeRequest performs an HTTP request to the Sysdig API
 func (c *Client) makeRequest(method, endpoint string, body interface{}) (*http.Response, error) {
-	var apiURL string
 	var url string
 
 	// Handle different endpoint types
 	if strings.HasPrefix(endpoint, "/api/") {
-		// For v2 scanning endpoints, keep the original base URL (us2.app.sysdig.com)
-		apiURL = c.baseURL
-		url = fmt.Sprintf("%s%s", apiURL, endpoint)
+		// For CSPM and v2 scanning endpoints, use the application URL (e.g. us2.app.sysdig.com)
+		url = fmt.Sprintf("%s%s", c.baseURL, endpoint)
+	} else if strings.Contains(endpoint, "accepted-risks") {
+		// For v1 endpoints, use the public API URL of the region (e.g. api.us2.sysdig.com)
+		url = fmt.Sprintf("%s/secure/vulnerability/v1beta1%s", c.secureAPIURL, endpoint)
 	} else {
-		// For v1 endpoints, use api.us2.sysdig.com instead of us2.app.sysdig.com
-		apiURL = strings.Replace(c.baseURL, "us2.app.sysdig.com", "api.us2.sysdig.com", 1)
-		// For localhost (mock server), don't change the URL
-		if strings.Contains(c.baseURL, "localhost") {
-			apiURL = c.baseURL
-		}
-
-		if strings.Contains(endpoint, "accepted-risks") {
-			url = fmt.Sprintf("%s/secure/vulnerability/v1beta1%s", apiURL, endpoint)
-		} else {
-			url = fmt.Sprintf("%s/secure/vulnerability/v1%s", apiURL, endpoint)
-		}
+		url = fmt.Sprintf("%s/secure/vulnerability/v1%s", c.secureAPIURL, endpoint)
 	}
 
 	var reqBody io.Reader
@@ -159,6 +176,8 @@ func (c *Client) makeRequest(method, endpoint string, body interface{}) (*http.R
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", "sysdig-vuls-utils/2.0.0")
 
+	c.rateLimiter.Wait()
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -0,0 +1,45 @@
+package sysdig
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces out API requests so that at most a fixed number are sent per second.
+// A single RateLimiter can be shared by several clients and goroutines.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter creates a rate limiter allowing requestsPerSecond requests per second.
+// A non-positive value returns nil, which disables rate limiting.
+func NewRateLimiter(requestsPerSecond float64) *RateLimiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+	}
+}
+
+// Wait blocks until the next request is allowed
+func (l *RateLimiter) Wait() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
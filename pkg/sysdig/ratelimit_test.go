@@ -0,0 +1,27 @@
+package sysdig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("無効化", func(t *testing.T) {
+		l := NewRateLimiter(0)
+		if l != nil {
+			t.Fatal("Expected nil limiter for 0 requests per second")
+		}
+		l.Wait() // nilでもpanicしない
+	})
+
+	t.Run("間隔を空ける", func(t *testing.T) {
+		l := NewRateLimiter(50) // 20ms間隔
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			l.Wait()
+		}
+		if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+			t.Errorf("Expected at least 60ms for 4 requests at 50 rps, got %s", elapsed)
+		}
+	})
+}
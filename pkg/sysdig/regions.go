@@ -0,0 +1,123 @@
+package sysdig
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Region describes the hosts of a Sysdig SaaS region
+type Region struct {
+	Name string
+	// APIURL is the application host serving /api/ endpoints (CSPM, scanning v2)
+	APIURL string
+	// SecureAPIURL is the public API host serving /secure/ endpoints (vulnerability v1)
+	SecureAPIURL string
+}
+
+// Endpoints holds the resolved base URLs used by Client
+type Endpoints struct {
+	APIURL       string
+	SecureAPIURL string
+}
+
+// regions lists the Sysdig SaaS regions
+// https://docs.sysdig.com/en/docs/administration/saas-regions-and-ip-ranges/
+var regions = []Region{
+	{Name: "us1", APIURL: "https://secure.sysdig.com", SecureAPIURL: "https://api.us1.sysdig.com"},
+	{Name: "us2", APIURL: "https://us2.app.sysdig.com", SecureAPIURL: "https://api.us2.sysdig.com"},
+	{Name: "us4", APIURL: "https://app.us4.sysdig.com", SecureAPIURL: "https://api.us4.sysdig.com"},
+	{Name: "eu1", APIURL: "https://eu1.app.sysdig.com", SecureAPIURL: "https://api.eu1.sysdig.com"},
+	{Name: "au1", APIURL: "https://app.au1.sysdig.com", SecureAPIURL: "https://api.au1.sysdig.com"},
+	{Name: "me2", APIURL: "https://app.me2.sysdig.com", SecureAPIURL: "https://api.me2.sysdig.com"},
+	{Name: "in1", APIURL: "https://app.in1.sysdig.com", SecureAPIURL: "https://api.in1.sysdig.com"},
+}
+
+// Regions returns all known SaaS regions
+func Regions() []Region {
+	result := make([]Region, len(regions))
+	copy(result, regions)
+	return result
+}
+
+// RegionNames returns the names of all known SaaS regions
+func RegionNames() []string {
+	names := make([]string, 0, len(regions))
+	for _, r := range regions {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// LookupRegion returns the region with the given name (case-insensitive)
+func LookupRegion(name string) (Region, error) {
+	for _, r := range regions {
+		if strings.EqualFold(r.Name, name) {
+			return r, nil
+		}
+	}
+	return Region{}, fmt.Errorf("unknown region %q (available: %s)", name, strings.Join(RegionNames(), ", "))
+}
+
+// RegionForURL returns the SaaS region whose application or public API host matches the URL
+func RegionForURL(rawURL string) (Region, bool) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return Region{}, false
+	}
+
+	for _, r := range regions {
+		for _, candidate := range []string{r.APIURL, r.SecureAPIURL} {
+			if u, err := url.Parse(candidate); err == nil && strings.EqualFold(u.Host, parsed.Host) {
+				return r, true
+			}
+		}
+	}
+
+	return Region{}, false
+}
+
+// DefaultSecureAPIURL derives the public API URL from an application URL.
+// SaaS hosts are mapped via the region table; on-prem and local (mock) URLs are used as they are.
+func DefaultSecureAPIURL(apiURL string) string {
+	if r, ok := RegionForURL(apiURL); ok {
+		return r.SecureAPIURL
+	}
+	return strings.TrimRight(apiURL, "/")
+}
+
+// ValidateURL checks that a base URL is an absolute http(s) URL without query or fragment
+func ValidateURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if parsed.Scheme != "https" && parsed.Scheme != "http" {
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", rawURL)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("invalid URL %q: host is required", rawURL)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return fmt.Errorf("invalid URL %q: query and fragment are not allowed", rawURL)
+	}
+	return nil
+}
+
+// ResolveEndpoints validates the base URLs and fills in the public API URL when it is not given
+func ResolveEndpoints(apiURL, secureAPIURL string) (Endpoints, error) {
+	if err := ValidateURL(apiURL); err != nil {
+		return Endpoints{}, fmt.Errorf("api_url: %w", err)
+	}
+
+	if secureAPIURL == "" {
+		secureAPIURL = DefaultSecureAPIURL(apiURL)
+	} else if err := ValidateURL(secureAPIURL); err != nil {
+		return Endpoints{}, fmt.Errorf("secure_api_url: %w", err)
+	}
+
+	return Endpoints{
+		APIURL:       strings.TrimRight(apiURL, "/"),
+		SecureAPIURL: strings.TrimRight(secureAPIURL, "/"),
+	}, nil
+}
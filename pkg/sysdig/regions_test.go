@@ -0,0 +1,146 @@
+package sysdig
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLookupRegion(t *testing.T) {
+	tests := []struct {
+		name       string
+		region     string
+		wantAPI    string
+		wantSecure string
+		wantError  bool
+	}{
+		{"us1", "us1", "https://secure.sysdig.com", "https://api.us1.sysdig.com", false},
+		{"us2", "us2", "https://us2.app.sysdig.com", "https://api.us2.sysdig.com", false},
+		{"eu1 大文字", "EU1", "https://eu1.app.sysdig.com", "https://api.eu1.sysdig.com", false},
+		{"au1", "au1", "https://app.au1.sysdig.com", "https://api.au1.sysdig.com", false},
+		{"未知のリージョン", "xx9", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := LookupRegion(tt.region)
+			if tt.wantError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if r.APIURL != tt.wantAPI {
+				t.Errorf("Expected APIURL %s, got %s", tt.wantAPI, r.APIURL)
+			}
+			if r.SecureAPIURL != tt.wantSecure {
+				t.Errorf("Expected SecureAPIURL %s, got %s", tt.wantSecure, r.SecureAPIURL)
+			}
+		})
+	}
+}
+
+func TestDefaultSecureAPIURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		apiURL string
+		want   string
+	}{
+		{"us2", "https://us2.app.sysdig.com", "https://api.us2.sysdig.com"},
+		{"us4", "https://app.us4.sysdig.com/", "https://api.us4.sysdig.com"},
+		{"me2", "https://app.me2.sysdig.com", "https://api.me2.sysdig.com"},
+		{"公開APIホストそのもの", "https://api.in1.sysdig.com", "https://api.in1.sysdig.com"},
+		{"オンプレミス", "https://sysdig.example.com/", "https://sysdig.example.com"},
+		{"モックサーバー", "http://127.0.0.1:8080", "http://127.0.0.1:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultSecureAPIURL(tt.apiURL); got != tt.want {
+				t.Errorf("DefaultSecureAPIURL(%q) = %q, want %q", tt.apiURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveEndpoints(t *testing.T) {
+	tests := []struct {
+		name         string
+		apiURL       string
+		secureAPIURL string
+		want         Endpoints
+		wantError    bool
+	}{
+		{
+			name:   "SaaS URLから導出",
+			apiURL: "https://eu1.app.sysdig.com",
+			want:   Endpoints{APIURL: "https://eu1.app.sysdig.com", SecureAPIURL: "https://api.eu1.sysdig.com"},
+		},
+		{
+			name:         "明示指定",
+			apiURL:       "https://sysdig.example.com/",
+			secureAPIURL: "https://api.sysdig.example.com/",
+			want:         Endpoints{APIURL: "https://sysdig.example.com", SecureAPIURL: "https://api.sysdig.example.com"},
+		},
+		{name: "スキームなし", apiURL: "us2.app.sysdig.com", wantError: true},
+		{name: "不正なスキーム", apiURL: "ftp://sysdig.example.com", wantError: true},
+		{name: "クエリ付き", apiURL: "https://sysdig.example.com?x=1", wantError: true},
+		{name: "不正なsecure URL", apiURL: "https://sysdig.example.com", secureAPIURL: "sysdig-api", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveEndpoints(tt.apiURL, tt.secureAPIURL)
+			if tt.wantError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveEndpoints() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakeRequest_UsesEndpointHosts(t *testing.T) {
+	var appPath, securePath string
+
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		appPath = r.URL.Path
+	}))
+	defer app.Close()
+
+	secure := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		securePath = r.URL.Path
+	}))
+	defer secure.Close()
+
+	client := NewClientWithEndpoints(Endpoints{APIURL: app.URL, SecureAPIURL: secure.URL}, "test-token")
+
+	resp, err := client.MakeRequest("GET", "/api/cspm/v1/compliance/requirements", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = client.MakeRequest("GET", "/vulnerabilities", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if appPath != "/api/cspm/v1/compliance/requirements" {
+		t.Errorf("Expected /api/ request on application host, got %q", appPath)
+	}
+	if securePath != "/secure/vulnerability/v1/vulnerabilities" {
+		t.Errorf("Expected v1 request on public API host, got %q", securePath)
+	}
+}
@@ -0,0 +1,272 @@
+package trend
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// Output formats
+const (
+	FormatMarkdown = "markdown"
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{FormatMarkdown, FormatCSV, FormatJSON}
+
+// maxChangeRows caps the rows of the requirement and account change tables
+const maxChangeRows = 20
+
+// SeriesRow is one count of the time series output
+type SeriesRow struct {
+	Time      time.Time `json:"time"`
+	DB        string    `json:"db"`
+	Dimension string    `json:"dimension"`
+	Value     string    `json:"value"`
+	Level     string    `json:"level"`
+	Status    string    `json:"status"`
+	Count     int       `json:"count"`
+}
+
+// Series flattens the points into rows
+func Series(points []Point) []SeriesRow {
+	rows := []SeriesRow{}
+	for _, p := range points {
+		for _, c := range p.Counts {
+			rows = append(rows, SeriesRow{
+				Time:      p.Time.UTC(),
+				DB:        p.Source,
+				Dimension: c.Dimension,
+				Value:     c.Value,
+				Level:     c.Level,
+				Status:    c.Status,
+				Count:     c.Count,
+			})
+		}
+	}
+	return rows
+}
+
+// Write writes the points in the given format
+func Write(w io.Writer, format string, points []Point) error {
+	switch format {
+	case FormatMarkdown, "":
+		return WriteMarkdown(w, points)
+	case FormatCSV:
+		return WriteCSV(w, points)
+	case FormatJSON:
+		return WriteJSON(w, points)
+	default:
+		return fmt.Errorf("unknown format %q (%s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// WriteCSV writes the time series with a header row
+func WriteCSV(w io.Writer, points []Point) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"time", "db", "dimension", "value", "level", "status", "count"}); err != nil {
+		return err
+	}
+	for _, r := range Series(points) {
+		record := []string{r.Time.Format(time.RFC3339), r.DB, r.Dimension, r.Value, r.Level, r.Status, strconv.Itoa(r.Count)}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the time series as a JSON array
+func WriteJSON(w io.Writer, points []Point) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Series(points))
+}
+
+// WriteMarkdown writes weekly totals and the week-over-week changes of the last two weeks
+func WriteMarkdown(w io.Writer, points []Point) error {
+	var b strings.Builder
+	b.WriteString("# Compliance posture trend\n\n")
+
+	if len(points) == 0 {
+		b.WriteString("No collected data found.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	sources := make(map[string]bool)
+	for _, p := range points {
+		sources[p.Source] = true
+	}
+	fmt.Fprintf(&b, "%s – %s, %d snapshots of %d databases. Weeks start on Monday (UTC); each week uses the last snapshot of every database.\n\n",
+		points[0].Time.UTC().Format("2006-01-02"), points[len(points)-1].Time.UTC().Format("2006-01-02"), len(points), len(sources))
+
+	weeks := Weekly(points)
+
+	b.WriteString("## Weekly totals\n\n")
+	b.WriteString("| Week | DBs | Failed requirements | Failed controls | Failed resources | Accepted resources | Passed resources |\n")
+	b.WriteString("|------|----:|----:|----:|----:|----:|----:|\n")
+	for i := range weeks {
+		cur := &weeks[i]
+		var prev *Week
+		if i > 0 {
+			prev = &weeks[i-1]
+		}
+		fmt.Fprintf(&b, "| %s | %d | %s | %s | %s | %s | %s |\n",
+			cur.Start.Format("2006-01-02"), cur.Points,
+			withDelta(cur, prev, database.DimensionTotal, "", "requirements", "failed"),
+			withDelta(cur, prev, database.DimensionTotal, "", "controls", "failed"),
+			withDelta(cur, prev, database.DimensionTotal, "", "resources", "failed"),
+			withDelta(cur, prev, database.DimensionTotal, "", "resources", "accepted"),
+			withDelta(cur, prev, database.DimensionTotal, "", "resources", "passed"))
+	}
+	b.WriteString("\n")
+
+	if len(weeks) < 2 {
+		b.WriteString("Week-over-week changes need snapshots from at least two weeks.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	prev, cur := &weeks[len(weeks)-2], &weeks[len(weeks)-1]
+	fmt.Fprintf(&b, "## Week-over-week changes (%s → %s)\n\n", prev.Start.Format("2006-01-02"), cur.Start.Format("2006-01-02"))
+
+	writeChanges(&b, "By policy", "Policy", prev, cur, database.DimensionPolicy, 0, []column{
+		{"Failed controls", "controls", "failed"},
+		{"Failed resources", "resources", "failed"},
+		{"Accepted resources", "resources", "accepted"},
+	})
+	writeChanges(&b, "By severity", "Severity", prev, cur, database.DimensionSeverity, 0, []column{
+		{"Failed controls", "controls", "failed"},
+		{"Failed resources", "resources", "failed"},
+		{"Accepted resources", "resources", "accepted"},
+	})
+	writeChanges(&b, "By account", "Account", prev, cur, database.DimensionAccount, maxChangeRows, []column{
+		{"Failed resources", "resources", "failed"},
+		{"Accepted resources", "resources", "accepted"},
+	})
+	writeChanges(&b, "By team", "Team", prev, cur, database.DimensionTeam, maxChangeRows, []column{
+		{"Failed resources", "resources", "failed"},
+		{"Accepted resources", "resources", "accepted"},
+	})
+	writeChanges(&b, "By requirement", "Requirement", prev, cur, database.DimensionRequirement, maxChangeRows, []column{
+		{"Failed controls", "controls", "failed"},
+		{"Failed resources", "resources", "failed"},
+	})
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// column is one count shown in a change table
+type column struct {
+	title  string
+	level  string
+	status string
+}
+
+// writeChanges writes one table of a dimension. With limit > 0 only changed values are listed,
+// largest change first.
+func writeChanges(b *strings.Builder, title, header string, prev, cur *Week, dimension string, limit int, columns []column) {
+	values := make(map[string]bool)
+	for _, w := range []*Week{prev, cur} {
+		for k := range w.Counts {
+			if k.Dimension == dimension {
+				values[k.Value] = true
+			}
+		}
+	}
+
+	type row struct {
+		value  string
+		change int
+	}
+	var rows []row
+	for v := range values {
+		change := 0
+		for _, c := range columns {
+			change += abs(cur.Count(dimension, v, c.level, c.status) - prev.Count(dimension, v, c.level, c.status))
+		}
+		if limit > 0 && change == 0 {
+			continue
+		}
+		rows = append(rows, row{v, change})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if limit > 0 && rows[i].change != rows[j].change {
+			return rows[i].change > rows[j].change
+		}
+		return rows[i].value < rows[j].value
+	})
+
+	fmt.Fprintf(b, "### %s\n\n", title)
+	if len(rows) == 0 {
+		b.WriteString("No changes.\n\n")
+		return
+	}
+
+	b.WriteString("| " + header)
+	for _, c := range columns {
+		b.WriteString(" | " + c.title)
+	}
+	b.WriteString(" |\n|---")
+	for range columns {
+		b.WriteString("|----:")
+	}
+	b.WriteString("|\n")
+
+	more := 0
+	if limit > 0 && len(rows) > limit {
+		more = len(rows) - limit
+		rows = rows[:limit]
+	}
+	for _, r := range rows {
+		value := r.value
+		if value == "" {
+			value = "(none)"
+		}
+		b.WriteString("| " + escapeCell(value))
+		for _, c := range columns {
+			b.WriteString(" | " + withDelta(cur, prev, dimension, r.value, c.level, c.status))
+		}
+		b.WriteString(" |\n")
+	}
+	if more > 0 {
+		fmt.Fprintf(b, "\n%d more changed values are not shown (see -format csv).\n", more)
+	}
+	b.WriteString("\n")
+}
+
+// withDelta formats a count followed by its change from the previous week
+func withDelta(cur, prev *Week, dimension, value, level, status string) string {
+	n := cur.Count(dimension, value, level, status)
+	if prev == nil {
+		return strconv.Itoa(n)
+	}
+	delta := n - prev.Count(dimension, value, level, status)
+	if delta == 0 {
+		return fmt.Sprintf("%d (±0)", n)
+	}
+	return fmt.Sprintf("%d (%+d)", n, delta)
+}
+
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
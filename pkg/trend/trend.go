@@ -0,0 +1,226 @@
+package trend
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// Point is the posture of one database at one point in time
+type Point struct {
+	Time time.Time
+	// Source is the database file name without extension, so that the snapshots
+	// of one plan target in different directories form one series
+	Source string
+	Counts []database.TrendCount
+}
+
+// ExpandPaths resolves files, directories (searched recursively for *.db) and glob patterns
+func ExpandPaths(patterns []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		p = filepath.Clean(p)
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+
+	for _, pattern := range patterns {
+		if strings.ContainsAny(pattern, "*?[") {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid database pattern %q: %w", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no database matches %q", pattern)
+			}
+			sort.Strings(matches)
+			for _, m := range matches {
+				add(m)
+			}
+			continue
+		}
+
+		info, err := os.Stat(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", pattern, err)
+		}
+		if !info.IsDir() {
+			add(pattern)
+			continue
+		}
+
+		found := 0
+		err = filepath.WalkDir(pattern, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && filepath.Ext(p) == ".db" {
+				add(p)
+				found++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %s: %w", pattern, err)
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("no *.db files in %s", pattern)
+		}
+	}
+
+	return paths, nil
+}
+
+// Load reads the points of the given databases.
+// A database with recorded posture snapshots contributes one point per snapshot; an older
+// snapshot file contributes its current state at the time of its last successful collection
+// (or its modification time when no run was recorded).
+func Load(paths []string) ([]Point, error) {
+	var points []Point
+	for _, path := range paths {
+		p, err := loadDatabase(path)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p...)
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		if !points[i].Time.Equal(points[j].Time) {
+			return points[i].Time.Before(points[j].Time)
+		}
+		return points[i].Source < points[j].Source
+	})
+	return points, nil
+}
+
+func loadDatabase(path string) ([]Point, error) {
+	db, err := database.OpenReadOnly(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = db.Close() }()
+
+	source := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	snapshots, err := db.GetPostureSnapshots()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(snapshots) > 0 {
+		points := make([]Point, 0, len(snapshots))
+		for _, s := range snapshots {
+			points = append(points, Point{Time: s.TakenAt, Source: source, Counts: s.Counts})
+		}
+		return points, nil
+	}
+
+	counts, err := db.GetTrendCounts()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(counts) == 0 {
+		// 収集前の空のDBは時系列に含めない
+		return nil, nil
+	}
+
+	at, err := collectedAt(db, path)
+	if err != nil {
+		return nil, err
+	}
+	return []Point{{Time: at, Source: source, Counts: counts}}, nil
+}
+
+// collectedAt returns when the last successful collection into the database finished
+func collectedAt(db *database.Database, path string) (time.Time, error) {
+	runs, err := db.GetCollectionRuns(0)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, run := range runs {
+		if run.Status == database.RunStatusSuccess {
+			return run.FinishedAt, nil
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	return info.ModTime(), nil
+}
+
+// Key identifies one series within a dimension
+type Key struct {
+	Dimension string
+	Value     string
+	Level     string
+	Status    string
+}
+
+// Week holds the posture at the end of one week, summed over all databases
+type Week struct {
+	// Start is the Monday 00:00 UTC the week begins
+	Start time.Time
+	// Points is the number of points (one per database) the week is based on
+	Points int
+	Counts map[Key]int
+}
+
+// Count returns the count of one series (0 when absent)
+func (w *Week) Count(dimension, value, level, status string) int {
+	return w.Counts[Key{dimension, value, level, status}]
+}
+
+// WeekStart returns the Monday 00:00 UTC of the week containing t
+func WeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// Weekly buckets the points by week, keeping the latest point of each database per week
+// and summing the databases
+func Weekly(points []Point) []Week {
+	type bucket struct {
+		week   time.Time
+		source string
+	}
+	latest := make(map[bucket]Point)
+	for _, p := range points {
+		b := bucket{WeekStart(p.Time), p.Source}
+		if cur, ok := latest[b]; !ok || !p.Time.Before(cur.Time) {
+			latest[b] = p
+		}
+	}
+
+	byWeek := make(map[time.Time]*Week)
+	for b, p := range latest {
+		w, ok := byWeek[b.week]
+		if !ok {
+			w = &Week{Start: b.week, Counts: make(map[Key]int)}
+			byWeek[b.week] = w
+		}
+		w.Points++
+		for _, c := range p.Counts {
+			w.Counts[Key{c.Dimension, c.Value, c.Level, c.Status}] += c.Count
+		}
+	}
+
+	weeks := make([]Week, 0, len(byWeek))
+	for _, w := range byWeek {
+		weeks = append(weeks, *w)
+	}
+	sort.Slice(weeks, func(i, j int) bool { return weeks[i].Start.Before(weeks[j].Start) })
+	return weeks
+}
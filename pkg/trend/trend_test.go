@@ -0,0 +1,190 @@
+package trend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+// createSnapshot writes a database with the given number of failed resources, collected at the given time
+func createSnapshot(t *testing.T, path string, failed int, at time.Time, withSnapshot bool) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.NewDatabase(path)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	requirements := []models.ComplianceRequirementWithControls{
+		{
+			RequirementID: "req-1",
+			Name:          "Ensure buckets are private",
+			PolicyName:    "CIS AWS",
+			Severity:      "High",
+			Controls:      []models.Control{{ID: "ctrl-1", Name: "Bucket ACL", Severity: "High"}},
+		},
+	}
+	if err := db.SaveComplianceRequirementsWithControls(requirements); err != nil {
+		t.Fatalf("Failed to save requirements: %v", err)
+	}
+	var resources []models.CloudResource
+	for i := 0; i < failed; i++ {
+		resources = append(resources, models.CloudResource{Hash: fmt.Sprintf("hash-%d", i), Name: fmt.Sprintf("bucket-%d", i), Account: "prod"})
+	}
+	if err := db.SaveCloudResources(resources); err != nil {
+		t.Fatalf("Failed to save resources: %v", err)
+	}
+	if err := db.SaveControlResourceRelations("ctrl-1", resources); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+
+	run := &database.CollectionRun{StartedAt: at.Add(-time.Minute), FinishedAt: at, Status: database.RunStatusSuccess}
+	if err := db.RecordCollectionRun(run); err != nil {
+		t.Fatalf("Failed to record run: %v", err)
+	}
+	if withSnapshot {
+		if err := db.SavePostureSnapshot(run.ID, at); err != nil {
+			t.Fatalf("Failed to save snapshot: %v", err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	monday := time.Date(2026, 10, 5, 9, 0, 0, 0, time.UTC)
+
+	// スナップショットテーブルのない古い形式のDB（ディレクトリごとに1時点）
+	createSnapshot(t, filepath.Join(dir, "20261005_090000", "cis_aws.db"), 5, monday, false)
+	createSnapshot(t, filepath.Join(dir, "20261012_090000", "cis_aws.db"), 3, monday.AddDate(0, 0, 7), false)
+	// 収集のたびにスナップショットを記録したDB
+	createSnapshot(t, filepath.Join(dir, "history", "soc2.db"), 2, monday.AddDate(0, 0, 8), true)
+
+	paths, err := ExpandPaths([]string{dir})
+	if err != nil {
+		t.Fatalf("ExpandPaths failed: %v", err)
+	}
+	if len(paths) != 3 {
+		t.Fatalf("Expected 3 databases, got %v", paths)
+	}
+
+	points, err := Load(paths)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(points) != 3 || points[0].Source != "cis_aws" || !points[0].Time.Equal(monday) || points[2].Source != "soc2" {
+		t.Fatalf("Unexpected points: %+v", points)
+	}
+
+	weeks := Weekly(points)
+	if len(weeks) != 2 {
+		t.Fatalf("Expected 2 weeks, got %d", len(weeks))
+	}
+	if weeks[1].Points != 2 || weeks[1].Count(database.DimensionTotal, "", "resources", "failed") != 5 {
+		t.Errorf("Expected the second week to sum both databases, got %+v", weeks[1])
+	}
+
+	t.Run("Markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := Write(&buf, FormatMarkdown, points); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		out := buf.String()
+		for _, want := range []string{
+			"| 2026-10-05 | 1 | 1 | 1 | 5 | 0 | 0 |",
+			// 2週目はcis_aws（3件）とsoc2（2件）の合計
+			"| 2026-10-12 | 2 | 2 (+1) | 2 (+1) | 5 (±0) | 0 (±0) | 0 (±0) |",
+			"## Week-over-week changes (2026-10-05 → 2026-10-12)",
+			"### By account\n\nNo changes.",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %q in:\n%s", want, out)
+			}
+		}
+	})
+
+	t.Run("CSVとJSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := Write(&buf, FormatCSV, points); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if lines[0] != "time,db,dimension,value,level,status,count" {
+			t.Errorf("Unexpected header: %s", lines[0])
+		}
+		if !strings.Contains(buf.String(), "2026-10-05T09:00:00Z,cis_aws,account,prod,resources,failed,5") {
+			t.Errorf("Expected account row in:\n%s", buf.String())
+		}
+
+		buf.Reset()
+		if err := Write(&buf, FormatJSON, points); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		var rows []SeriesRow
+		if err := json.Unmarshal(buf.Bytes(), &rows); err != nil {
+			t.Fatalf("Invalid JSON: %v", err)
+		}
+		if len(rows) != len(lines)-1 {
+			t.Errorf("Expected %d JSON rows, got %d", len(lines)-1, len(rows))
+		}
+
+		if err := Write(&buf, "xml", points); err == nil {
+			t.Error("Expected error for unknown format")
+		}
+	})
+}
+
+func TestWriteMarkdown_Changes(t *testing.T) {
+	week1 := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+	point := func(at time.Time, failed int) Point {
+		return Point{Time: at, Source: "cis_aws", Counts: []database.TrendCount{
+			{Dimension: database.DimensionTotal, Level: "resources", Status: "failed", Count: failed},
+			{Dimension: database.DimensionAccount, Value: "prod", Level: "resources", Status: "failed", Count: failed},
+			{Dimension: database.DimensionAccount, Value: "dev", Level: "resources", Status: "failed", Count: 1},
+		}}
+	}
+
+	// 同じ週の中では最後の時点を使う
+	points := []Point{point(week1, 10), point(week1.AddDate(0, 0, 2), 8), point(week1.AddDate(0, 0, 7), 6)}
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, points); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	out := buf.String()
+
+	if !strings.Contains(out, "| 2026-10-12 | 1 | 0 (±0) | 0 (±0) | 6 (-2) |") {
+		t.Errorf("Expected a -2 week-over-week delta:\n%s", out)
+	}
+	// 変化のないアカウントは表示しない
+	if !strings.Contains(out, "| prod | 6 (-2) |") || strings.Contains(out, "| dev |") {
+		t.Errorf("Expected only changed accounts:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := WriteMarkdown(&buf, points[:1]); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "at least two weeks") {
+		t.Errorf("Expected a note about missing history:\n%s", buf.String())
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	for _, s := range []string{"2026-10-12T00:00:00Z", "2026-10-14T12:00:00Z", "2026-10-18T23:59:59Z"} {
+		at, _ := time.Parse(time.RFC3339, s)
+		if got := WeekStart(at).Format("2006-01-02"); got != "2026-10-12" {
+			t.Errorf("%s: expected 2026-10-12, got %s", s, got)
+		}
+	}
+}
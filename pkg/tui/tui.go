@@ -0,0 +1,705 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/acceptance"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+)
+
+// DefaultPageSize is the number of rows shown per page
+const DefaultPageSize = 20
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+type level int
+
+const (
+	levelRequirements level = iota
+	levelControls
+	levelResources
+)
+
+// view is one screen of the navigation stack
+type view struct {
+	level level
+	// requirement and control are the rows the view was opened from
+	requirement database.Row
+	control     database.Row
+	search      string
+	offset      int
+	rows        []database.Row
+	total       int
+}
+
+// Filters narrow the lists; account and region apply to resources, status to every level
+type Filters struct {
+	Account string
+	Region  string
+	// Status is failed, passed or accepted (accepted only narrows resources)
+	Status string
+}
+
+// App is a line-oriented terminal browser over a collected database
+type App struct {
+	db  *database.Database
+	in  *bufio.Scanner
+	out io.Writer
+
+	// NewCreator returns the client used to create risk acceptances; it is called only
+	// when a selection is submitted so browsing works without an API token
+	NewCreator func() (acceptance.Creator, error)
+	PageSize   int
+	// ClearScreen clears the terminal before each screen
+	ClearScreen bool
+	// DryRun keeps the marks and the database unchanged after submitting; the creator is
+	// expected to print the requests instead of sending them
+	DryRun  bool
+	Filters Filters
+
+	stack []*view
+	// marks are the resources selected for acceptance, keyed by control ID and resource hash
+	marks   map[string]acceptance.Target
+	message string
+}
+
+// New creates an app reading commands from in and writing screens to out
+func New(db *database.Database, in io.Reader, out io.Writer) *App {
+	return &App{
+		db:       db,
+		in:       bufio.NewScanner(in),
+		out:      out,
+		PageSize: DefaultPageSize,
+		marks:    make(map[string]acceptance.Target),
+	}
+}
+
+// Run shows the requirements and processes commands until q or end of input
+func (a *App) Run() error {
+	a.stack = []*view{{level: levelRequirements}}
+
+	for {
+		if err := a.load(); err != nil {
+			return err
+		}
+		a.render()
+
+		line, ok := a.prompt("> ")
+		if !ok {
+			return nil
+		}
+		quit, err := a.handle(line)
+		if err != nil {
+			return err
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+func (a *App) current() *view {
+	return a.stack[len(a.stack)-1]
+}
+
+// prompt writes the prompt and reads one trimmed line; false at end of input
+func (a *App) prompt(p string) (string, bool) {
+	fmt.Fprint(a.out, p)
+	if !a.in.Scan() {
+		fmt.Fprintln(a.out)
+		return "", false
+	}
+	return strings.TrimSpace(a.in.Text()), true
+}
+
+// handle executes one command line and reports whether the app should exit
+func (a *App) handle(line string) (bool, error) {
+	v := a.current()
+	cmd, args, _ := strings.Cut(line, " ")
+	args = strings.TrimSpace(args)
+
+	switch {
+	case line == "":
+		return false, nil
+	case line == "q":
+		return true, nil
+	case line == "b":
+		if len(a.stack) == 1 {
+			return true, nil
+		}
+		a.stack = a.stack[:len(a.stack)-1]
+	case line == "n":
+		if v.offset+a.PageSize < v.total {
+			v.offset += a.PageSize
+		}
+	case line == "p":
+		v.offset = max(v.offset-a.PageSize, 0)
+	case line == "?" || line == "h":
+		a.message = a.help()
+	case strings.HasPrefix(line, "/"):
+		v.search = strings.TrimSpace(line[1:])
+		v.offset = 0
+	case cmd == "f":
+		if err := a.setFilters(args); err != nil {
+			a.message = err.Error()
+		}
+	case cmd == "d":
+		a.describe(args)
+	case cmd == "m":
+		return false, a.mark(args)
+	case cmd == "u":
+		a.unmark(args)
+	case cmd == "a":
+		return false, a.accept()
+	default:
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			a.message = fmt.Sprintf("unknown command %q (? for help)", line)
+			return false, nil
+		}
+		a.open(n)
+	}
+	return false, nil
+}
+
+func (a *App) help() string {
+	return `Commands:
+  <n>               open row n (show details for resources)
+  n / p             next / previous page
+  b                 back (quit at the top level)
+  /text             search by name (/ alone clears the search)
+  f key=value ...   filter: account=, region=, status=failed|passed|accepted (f alone clears)
+  d [n]             show the description of row n or of the current control
+  m 1 3-5 | m *     mark failed resources (* = all failed resources matching the filters)
+  u 1 3-5 | u *     unmark resources (* = all marks)
+  a                 accept the marked resources (asks for reason, description, expiry)
+  q                 quit`
+}
+
+// load queries the rows of the current page
+func (a *App) load() error {
+	v := a.current()
+
+	var l *database.Listing
+	var filters []database.FilterValue
+	switch v.level {
+	case levelRequirements:
+		l = database.RequirementsListing
+		if v.search != "" {
+			filters = append(filters, database.FilterValue{Name: "name", Value: v.search})
+		}
+		filters = append(filters, a.passFilter()...)
+	case levelControls:
+		l = database.ControlsListing
+		filters = append(filters, database.FilterValue{Name: "requirement_id", Value: str(v.requirement, "requirement_id")})
+		if v.search != "" {
+			filters = append(filters, database.FilterValue{Name: "name", Value: v.search})
+		}
+		filters = append(filters, a.passFilter()...)
+	case levelResources:
+		l = database.RelationsListing
+		filters = a.resourceFilters(v)
+	}
+
+	result, err := a.db.List(l, database.ListQuery{Filters: filters, Limit: a.PageSize, Offset: v.offset})
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", l.Name, err)
+	}
+	v.rows = result.Rows
+	v.total = result.Total
+	return nil
+}
+
+// passFilter maps the status filter to the pass column of requirements and controls
+func (a *App) passFilter() []database.FilterValue {
+	switch a.Filters.Status {
+	case "failed":
+		return []database.FilterValue{{Name: "pass", Value: "false"}}
+	case "passed":
+		return []database.FilterValue{{Name: "pass", Value: "true"}}
+	}
+	return nil
+}
+
+func (a *App) resourceFilters(v *view) []database.FilterValue {
+	filters := []database.FilterValue{{Name: "control_id", Value: str(v.control, "control_id")}}
+	if v.search != "" {
+		filters = append(filters, database.FilterValue{Name: "resource_name", Value: v.search})
+	}
+	if a.Filters.Account != "" {
+		filters = append(filters, database.FilterValue{Name: "account", Value: a.Filters.Account})
+	}
+	if a.Filters.Region != "" {
+		filters = append(filters, database.FilterValue{Name: "location", Value: a.Filters.Region})
+	}
+	if a.Filters.Status != "" {
+		filters = append(filters, database.FilterValue{Name: "status", Value: a.Filters.Status})
+	}
+	return filters
+}
+
+func (a *App) setFilters(args string) error {
+	if args == "" {
+		a.Filters = Filters{}
+		a.current().offset = 0
+		return nil
+	}
+
+	filters := a.Filters
+	for _, arg := range strings.Fields(args) {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("invalid filter %q (use key=value)", arg)
+		}
+		switch key {
+		case "account":
+			filters.Account = value
+		case "region":
+			filters.Region = value
+		case "status":
+			if value != "" && value != "failed" && value != "passed" && value != "accepted" {
+				return fmt.Errorf("invalid status %q (failed, passed or accepted)", value)
+			}
+			filters.Status = value
+		default:
+			return fmt.Errorf("unknown filter %q (account, region or status)", key)
+		}
+	}
+	a.Filters = filters
+	a.current().offset = 0
+	return nil
+}
+
+// row returns the row for a 1-based index on the current page
+func (a *App) row(n int) (database.Row, bool) {
+	v := a.current()
+	if n < 1 || n > len(v.rows) {
+		a.message = fmt.Sprintf("no row %d on this page", n)
+		return nil, false
+	}
+	return v.rows[n-1], true
+}
+
+func (a *App) open(n int) {
+	row, ok := a.row(n)
+	if !ok {
+		return
+	}
+	v := a.current()
+
+	switch v.level {
+	case levelRequirements:
+		a.stack = append(a.stack, &view{level: levelControls, requirement: row})
+	case levelControls:
+		a.stack = append(a.stack, &view{level: levelResources, requirement: v.requirement, control: row})
+	case levelResources:
+		var b strings.Builder
+		for _, key := range []string{"resource_name", "resource_hash", "resource_type", "account", "location", "status", "acceptance_justification"} {
+			fmt.Fprintf(&b, "%-25s %s\n", key+":", str(row, key))
+		}
+		a.message = strings.TrimRight(b.String(), "\n")
+	}
+}
+
+// describe shows the description of a requirement or control
+func (a *App) describe(args string) {
+	v := a.current()
+	row := v.control
+	if args != "" {
+		n, err := strconv.Atoi(args)
+		if err != nil {
+			a.message = fmt.Sprintf("invalid row %q", args)
+			return
+		}
+		if v.level == levelResources {
+			a.message = "d <n> is not available for resources; use d for the control description"
+			return
+		}
+		var ok bool
+		if row, ok = a.row(n); !ok {
+			return
+		}
+	} else if v.level != levelResources {
+		a.message = "usage: d <n>"
+		return
+	}
+
+	description := str(row, "description")
+	if description == "" {
+		description = "(no description)"
+	}
+	a.message = fmt.Sprintf("%s\n\n%s", str(row, "name"), description)
+}
+
+// mark selects failed resources of the current control
+func (a *App) mark(args string) error {
+	v := a.current()
+	if v.level != levelResources {
+		a.message = "open a control to mark its resources"
+		return nil
+	}
+
+	var rows []database.Row
+	if args == "*" {
+		filters := a.resourceFilters(v)
+		// 承認対象はFailedのリソースのみ
+		filters = append(filters, database.FilterValue{Name: "status", Value: "failed"})
+		result, err := a.db.List(database.RelationsListing, database.ListQuery{Filters: filters})
+		if err != nil {
+			return fmt.Errorf("failed to query resources: %w", err)
+		}
+		rows = result.Rows
+	} else {
+		indexes, err := parseIndexes(args, len(v.rows))
+		if err != nil {
+			a.message = err.Error()
+			return nil
+		}
+		for _, i := range indexes {
+			rows = append(rows, v.rows[i-1])
+		}
+	}
+
+	marked, skipped := 0, 0
+	for _, row := range rows {
+		if str(row, "status") != "failed" {
+			skipped++
+			continue
+		}
+		t := acceptance.Target{
+			ControlID:    str(row, "control_id"),
+			ZoneID:       str(v.requirement, "zone_id"),
+			ResourceHash: str(row, "resource_hash"),
+			ResourceName: str(row, "resource_name"),
+			Account:      str(row, "account"),
+			Location:     str(row, "location"),
+		}
+		a.marks[markKey(t)] = t
+		marked++
+	}
+
+	a.message = fmt.Sprintf("marked %d resources", marked)
+	if skipped > 0 {
+		a.message += fmt.Sprintf(" (skipped %d that are not failed)", skipped)
+	}
+	return nil
+}
+
+func (a *App) unmark(args string) {
+	if args == "*" {
+		a.message = fmt.Sprintf("cleared %d marks", len(a.marks))
+		a.marks = make(map[string]acceptance.Target)
+		return
+	}
+
+	v := a.current()
+	if v.level != levelResources {
+		a.message = "usage: u * (or u <n> in a resource list)"
+		return
+	}
+	indexes, err := parseIndexes(args, len(v.rows))
+	if err != nil {
+		a.message = err.Error()
+		return
+	}
+	removed := 0
+	for _, i := range indexes {
+		key := rowKey(v.rows[i-1])
+		if _, ok := a.marks[key]; ok {
+			delete(a.marks, key)
+			removed++
+		}
+	}
+	a.message = fmt.Sprintf("unmarked %d resources", removed)
+}
+
+func markKey(t acceptance.Target) string {
+	return t.ControlID + "\x00" + t.ResourceHash
+}
+
+// rowKey is the mark key of a relation row
+func rowKey(row database.Row) string {
+	return str(row, "control_id") + "\x00" + str(row, "resource_hash")
+}
+
+// parseIndexes parses "1 3-5" into 1-based row indexes within 1..n
+func parseIndexes(args string, n int) ([]int, error) {
+	if args == "" {
+		return nil, fmt.Errorf("usage: m 1 3-5 or m *")
+	}
+	var indexes []int
+	for _, field := range strings.Fields(strings.ReplaceAll(args, ",", " ")) {
+		from, to, isRange := strings.Cut(field, "-")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row %q", field)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(to); err != nil {
+				return nil, fmt.Errorf("invalid row range %q", field)
+			}
+		}
+		if start < 1 || end > n || start > end {
+			return nil, fmt.Errorf("row %q is not on this page (1-%d)", field, n)
+		}
+		for i := start; i <= end; i++ {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes, nil
+}
+
+// accept asks for the acceptance options, shows the plan and creates the acceptances after confirmation
+func (a *App) accept() error {
+	if len(a.marks) == 0 {
+		a.message = "no resources marked (use m in a resource list)"
+		return nil
+	}
+
+	fmt.Fprintln(a.out, "\nReason:")
+	for i, r := range acceptance.Reasons {
+		fmt.Fprintf(a.out, "  %d. %s\n", i+1, r)
+	}
+	line, ok := a.prompt("Reason number: ")
+	n, err := strconv.Atoi(line)
+	if !ok || err != nil || n < 1 || n > len(acceptance.Reasons) {
+		a.message = "cancelled: invalid reason"
+		return nil
+	}
+	opts := acceptance.Options{Reason: acceptance.Reasons[n-1]}
+
+	if opts.Description, ok = a.prompt("Description: "); !ok {
+		a.message = "cancelled"
+		return nil
+	}
+
+	line, ok = a.prompt("Expires in days (empty = never): ")
+	if !ok {
+		a.message = "cancelled"
+		return nil
+	}
+	if line != "" {
+		days, err := strconv.Atoi(line)
+		if err != nil || days < 1 {
+			a.message = fmt.Sprintf("cancelled: invalid number of days %q", line)
+			return nil
+		}
+		opts.ExpiresAt = time.Now().AddDate(0, 0, days)
+	}
+
+	requests, err := acceptance.Plan(a.markedTargets(), opts)
+	if err != nil {
+		a.message = "cancelled: " + err.Error()
+		return nil
+	}
+
+	a.printPlan(requests, opts)
+	line, ok = a.prompt(fmt.Sprintf("Create %d risk acceptances for %d resources? [y/N]: ", len(requests), len(a.marks)))
+	if !ok || (line != "y" && line != "Y") {
+		a.message = "cancelled; marks are kept"
+		return nil
+	}
+
+	if a.NewCreator == nil {
+		a.message = "creating risk acceptances is not configured"
+		return nil
+	}
+	creator, err := a.NewCreator()
+	if err != nil {
+		a.message = fmt.Sprintf("cannot create risk acceptances: %v", err)
+		return nil
+	}
+
+	results := acceptance.Apply(creator, requests)
+	if a.DryRun {
+		var b strings.Builder
+		for _, r := range results {
+			fmt.Fprintf(&b, "  - control %d (%d resources)\n", r.Request.ControlID, len(r.Request.Targets))
+		}
+		fmt.Fprintf(&b, "Dry run: %d risk acceptances would be created; marks are kept", len(results))
+		a.message = b.String()
+		return nil
+	}
+
+	var b strings.Builder
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(&b, "  ✗ control %d (%d resources): %v\n", r.Request.ControlID, len(r.Request.Targets), r.Err)
+			continue
+		}
+		fmt.Fprintf(&b, "  ✓ control %d (%d resources): %s\n", r.Request.ControlID, len(r.Request.Targets), r.Acceptance.ID)
+		// 作成できたリソースは選択を解除し、失敗分だけ残して再試行できるようにする
+		for _, t := range r.Request.Targets {
+			delete(a.marks, markKey(t))
+		}
+	}
+
+	if created := acceptance.Created(results); len(created) > 0 {
+		if err := a.db.SaveRiskAcceptances(created); err != nil {
+			return fmt.Errorf("failed to save risk acceptances: %w", err)
+		}
+	}
+
+	fmt.Fprintf(&b, "Created %d of %d risk acceptances", len(results)-failed, len(results))
+	if failed > 0 {
+		b.WriteString("; failed resources remain marked")
+	}
+	b.WriteString("\nResource status is updated by the next collect")
+	a.message = b.String()
+	return nil
+}
+
+// markedTargets returns the marks in a stable order
+func (a *App) markedTargets() []acceptance.Target {
+	keys := make([]string, 0, len(a.marks))
+	for key := range a.marks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	targets := make([]acceptance.Target, 0, len(keys))
+	for _, key := range keys {
+		targets = append(targets, a.marks[key])
+	}
+	return targets
+}
+
+// printPlan is the confirmation screen
+func (a *App) printPlan(requests []acceptance.Request, opts acceptance.Options) {
+	expiry := "never"
+	if !opts.ExpiresAt.IsZero() {
+		expiry = opts.ExpiresAt.Format("2006-01-02")
+	}
+
+	fmt.Fprintln(a.out)
+	fmt.Fprintln(a.out, "Risk acceptances to create")
+	fmt.Fprintln(a.out, strings.Repeat("=", 60))
+	fmt.Fprintf(a.out, "Reason:      %s\n", opts.Reason)
+	fmt.Fprintf(a.out, "Description: %s\n", opts.Description)
+	fmt.Fprintf(a.out, "Expires:     %s\n\n", expiry)
+
+	for i, r := range requests {
+		fmt.Fprintf(a.out, "%d. control %d, zone %s, account %s: %d resources\n",
+			i+1, r.ControlID, orDash(strconv.Itoa(r.ZoneID), r.ZoneID == 0), orDash(r.SourceID, r.SourceID == ""), len(r.Targets))
+		fmt.Fprintf(a.out, "   filter: %s\n", r.Filter)
+	}
+	fmt.Fprintln(a.out)
+}
+
+func orDash(s string, empty bool) string {
+	if empty {
+		return "-"
+	}
+	return s
+}
+
+// render writes the current screen
+func (a *App) render() {
+	v := a.current()
+	if a.ClearScreen {
+		fmt.Fprint(a.out, clearScreen)
+	}
+
+	fmt.Fprintln(a.out)
+	fmt.Fprintln(a.out, a.breadcrumb())
+	fmt.Fprintln(a.out, strings.Repeat("=", 80))
+
+	var status []string
+	if a.Filters.Account != "" {
+		status = append(status, "account="+a.Filters.Account)
+	}
+	if a.Filters.Region != "" {
+		status = append(status, "region="+a.Filters.Region)
+	}
+	if a.Filters.Status != "" {
+		status = append(status, "status="+a.Filters.Status)
+	}
+	if v.search != "" {
+		status = append(status, fmt.Sprintf("search=%q", v.search))
+	}
+	status = append(status, fmt.Sprintf("marked=%d", len(a.marks)))
+	fmt.Fprintf(a.out, "Filters: %s\n\n", strings.Join(status, " "))
+
+	switch v.level {
+	case levelRequirements:
+		fmt.Fprintf(a.out, "%4s  %-6s %-8s %6s  %s\n", "#", "STATUS", "SEVERITY", "FAILED", "REQUIREMENT")
+		for i, row := range v.rows {
+			fmt.Fprintf(a.out, "%4d  %-6s %-8s %6s  %s\n", i+1, passLabel(row["pass"]), str(row, "severity"),
+				str(row, "failed_controls"), truncate(str(row, "name")+" ("+str(row, "policy_name")+")", 70))
+		}
+	case levelControls:
+		fmt.Fprintf(a.out, "%4s  %-6s %-8s %6s %8s  %s\n", "#", "STATUS", "SEVERITY", "FAILED", "ACCEPTED", "CONTROL")
+		for i, row := range v.rows {
+			fmt.Fprintf(a.out, "%4d  %-6s %-8s %6s %8s  %s\n", i+1, passLabel(row["pass"]), str(row, "severity"),
+				str(row, "failed_count"), str(row, "accepted_count"), truncate(str(row, "name"), 70))
+		}
+	case levelResources:
+		fmt.Fprintf(a.out, "%4s  %1s %-8s %-14s %-14s %-20s %s\n", "#", "", "STATUS", "ACCOUNT", "LOCATION", "TYPE", "RESOURCE")
+		for i, row := range v.rows {
+			mark := ""
+			if _, ok := a.marks[rowKey(row)]; ok {
+				mark = "*"
+			}
+			fmt.Fprintf(a.out, "%4d  %1s %-8s %-14s %-14s %-20s %s\n", i+1, mark, str(row, "status"),
+				truncate(str(row, "account"), 14), truncate(str(row, "location"), 14),
+				truncate(str(row, "resource_type"), 20), truncate(str(row, "resource_name"), 50))
+		}
+	}
+
+	if v.total == 0 {
+		fmt.Fprintln(a.out, "  (no rows)")
+	}
+	last := min(v.offset+a.PageSize, v.total)
+	fmt.Fprintf(a.out, "\n%d-%d of %d  (? for help)\n", min(v.offset+1, last), last, v.total)
+
+	if a.message != "" {
+		fmt.Fprintf(a.out, "\n%s\n", a.message)
+		a.message = ""
+	}
+}
+
+func (a *App) breadcrumb() string {
+	v := a.current()
+	parts := []string{"Requirements"}
+	if v.requirement != nil {
+		parts = append(parts, truncate(str(v.requirement, "name"), 40))
+	}
+	if v.control != nil {
+		parts = append(parts, truncate(str(v.control, "name"), 40))
+	}
+	return strings.Join(parts, " > ")
+}
+
+func passLabel(v interface{}) string {
+	if pass, _ := v.(bool); pass {
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+// str returns a row value as a string ("" for NULL)
+func str(row database.Row, key string) string {
+	v, ok := row[key]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
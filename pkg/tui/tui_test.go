@@ -0,0 +1,241 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/acceptance"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/database"
+	"github.com/kaz-under-the-bridge/sysdig-cspm-utils/pkg/models"
+)
+
+func newTestDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	db, err := database.NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	requirements := []models.ComplianceRequirementWithControls{
+		{
+			RequirementID: "req-1",
+			Name:          "Ensure buckets are private",
+			PolicyName:    "CIS AWS",
+			Severity:      "High",
+			Zone:          models.Zone{ID: "7", Name: "Prod"},
+			Controls: []models.Control{
+				{ID: "16022", Name: "Bucket ACL", Description: "S3 buckets must not grant public ACLs", Severity: "High"},
+			},
+		},
+		{
+			RequirementID: "req-2",
+			Name:          "Ensure MFA",
+			PolicyName:    "CIS AWS",
+			Severity:      "Medium",
+			Pass:          true,
+			Zone:          models.Zone{ID: "7", Name: "Prod"},
+		},
+	}
+	if err := db.SaveComplianceRequirementsWithControls(requirements); err != nil {
+		t.Fatalf("Failed to save requirements: %v", err)
+	}
+
+	resources := []models.CloudResource{
+		{Hash: "h1", Name: "bucket-a", Type: "bucket", Account: "111", Location: "us-east-1"},
+		{Hash: "h2", Name: "bucket-b", Type: "bucket", Account: "111", Location: "us-east-1"},
+		{Hash: "h3", Name: "bucket-c", Type: "bucket", Account: "222", Location: "eu-west-1"},
+		{Hash: "h4", Name: "bucket-d", Type: "bucket", Account: "111", Location: "us-east-1", Passed: true},
+	}
+	if err := db.SaveCloudResources(resources); err != nil {
+		t.Fatalf("Failed to save resources: %v", err)
+	}
+	if err := db.SaveControlResourceRelations("16022", resources); err != nil {
+		t.Fatalf("Failed to save relations: %v", err)
+	}
+	return db
+}
+
+type fakeCreator struct {
+	requests []models.RiskAcceptanceCreateRequest
+}
+
+func (f *fakeCreator) CreateRiskAcceptance(req models.RiskAcceptanceCreateRequest) (*models.RiskAcceptance, error) {
+	f.requests = append(f.requests, req)
+	return &models.RiskAcceptance{
+		ID:        fmt.Sprintf("ra-%d", len(f.requests)),
+		ControlID: fmt.Sprint(req.ControlID),
+		Reason:    req.Reason,
+		Filter:    req.Filter,
+	}, nil
+}
+
+// run executes the app with the given input lines and returns its output
+func run(t *testing.T, db *database.Database, creator *fakeCreator, lines ...string) string {
+	t.Helper()
+
+	var out strings.Builder
+	app := New(db, strings.NewReader(strings.Join(lines, "\n")+"\n"), &out)
+	app.NewCreator = func() (acceptance.Creator, error) {
+		if creator == nil {
+			return nil, errors.New("no token")
+		}
+		return creator, nil
+	}
+	if err := app.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	return out.String()
+}
+
+func TestApp(t *testing.T) {
+	t.Run("一覧の移動と説明表示", func(t *testing.T) {
+		out := run(t, newTestDatabase(t), nil, "f status=failed", "1", "d 1", "1", "q")
+
+		if !strings.Contains(out, "1-1 of 1") {
+			t.Errorf("Expected the status filter to hide the passing requirement:\n%s", out)
+		}
+		if !strings.Contains(out, "S3 buckets must not grant public ACLs") {
+			t.Errorf("Expected the control description:\n%s", out)
+		}
+		if !strings.Contains(out, "Requirements > Ensure buckets are private > Bucket ACL") {
+			t.Errorf("Expected the resource breadcrumb:\n%s", out)
+		}
+		// status=failedはリソース一覧にも適用される
+		if !strings.Contains(out, "1-3 of 3") || strings.Contains(out, "bucket-d") {
+			t.Errorf("Expected only failed resources:\n%s", out)
+		}
+	})
+
+	t.Run("アカウントとリージョンで絞り込み", func(t *testing.T) {
+		out := run(t, newTestDatabase(t), nil, "1", "1", "f account=222 region=eu-west-1", "q")
+		if !strings.Contains(out, "bucket-c") || !strings.Contains(out, "1-1 of 1") {
+			t.Errorf("Expected only bucket-c:\n%s", out)
+		}
+	})
+
+	t.Run("選択して一括承認", func(t *testing.T) {
+		db := newTestDatabase(t)
+		creator := &fakeCreator{}
+		out := run(t, db, creator,
+			"1", "1",
+			"m *",
+			"a", "1", "tracked in JIRA-1", "30", "y",
+			"q")
+
+		if !strings.Contains(out, "marked 3 resources") {
+			t.Errorf("Expected 3 failed resources to be marked:\n%s", out)
+		}
+		if !strings.Contains(out, "Create 2 risk acceptances for 3 resources?") {
+			t.Errorf("Expected confirmation screen:\n%s", out)
+		}
+		if len(creator.requests) != 2 {
+			t.Fatalf("Expected 2 requests, got %d", len(creator.requests))
+		}
+		first := creator.requests[0]
+		if first.ControlID != 16022 || first.ZoneID != 7 || first.Reason != "Risk Owned" || first.SourceID != "111" {
+			t.Errorf("Unexpected request: %+v", first)
+		}
+		if want := `location in ("us-east-1") and name in ("bucket-a","bucket-b")`; first.Filter != want {
+			t.Errorf("Expected filter %s, got %s", want, first.Filter)
+		}
+		if first.ExpiresAt == "" {
+			t.Error("Expected an expiry")
+		}
+
+		saved, err := db.GetRiskAcceptances("16022")
+		if err != nil {
+			t.Fatalf("GetRiskAcceptances failed: %v", err)
+		}
+		if len(saved) != 2 {
+			t.Errorf("Expected 2 saved acceptances, got %d", len(saved))
+		}
+		if !strings.Contains(out, "Created 2 of 2 risk acceptances") || !strings.Contains(out, "marked=0") {
+			t.Errorf("Expected marks to be cleared after creation:\n%s", out)
+		}
+	})
+
+	t.Run("確認で中止すると作成しない", func(t *testing.T) {
+		creator := &fakeCreator{}
+		out := run(t, newTestDatabase(t), creator, "1", "1", "m 1-2 4", "a", "2", "", "", "n", "q")
+
+		// 4行目はPassedのため選択されない
+		if !strings.Contains(out, "marked 2 resources (skipped 1 that are not failed)") {
+			t.Errorf("Expected the passed resource to be skipped:\n%s", out)
+		}
+		if len(creator.requests) != 0 {
+			t.Errorf("Expected no requests, got %d", len(creator.requests))
+		}
+		if !strings.Contains(out, "cancelled; marks are kept") || !strings.Contains(out, "marked=2") {
+			t.Errorf("Expected marks to be kept:\n%s", out)
+		}
+	})
+
+	t.Run("ドライランではDBと選択を変更しない", func(t *testing.T) {
+		db := newTestDatabase(t)
+		creator := &fakeCreator{}
+		var out strings.Builder
+		app := New(db, strings.NewReader(strings.Join([]string{"1", "1", "m *", "a", "1", "", "", "y", "q"}, "\n")+"\n"), &out)
+		app.NewCreator = func() (acceptance.Creator, error) { return creator, nil }
+		app.DryRun = true
+		if err := app.Run(); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+
+		if len(creator.requests) != 2 {
+			t.Errorf("Expected the requests to be passed to the creator, got %d", len(creator.requests))
+		}
+		saved, err := db.GetRiskAcceptances("16022")
+		if err != nil {
+			t.Fatalf("GetRiskAcceptances failed: %v", err)
+		}
+		if len(saved) != 0 {
+			t.Errorf("Expected nothing to be saved, got %d", len(saved))
+		}
+		if !strings.Contains(out.String(), "Dry run: 2 risk acceptances would be created") || !strings.Contains(out.String(), "marked=3") {
+			t.Errorf("Expected marks to be kept:\n%s", out.String())
+		}
+	})
+
+	t.Run("トークンがなくても閲覧できる", func(t *testing.T) {
+		out := run(t, newTestDatabase(t), nil, "1", "1", "m 1", "a", "1", "", "", "y", "q")
+		if !strings.Contains(out, "cannot create risk acceptances: no token") {
+			t.Errorf("Expected creator error:\n%s", out)
+		}
+	})
+
+	t.Run("不正な入力", func(t *testing.T) {
+		out := run(t, newTestDatabase(t), nil, "m 1", "9", "f bogus=1", "1", "1", "m 7", "xyz")
+		for _, want := range []string{
+			"open a control to mark its resources",
+			"no row 9 on this page",
+			`unknown filter "bogus"`,
+			`row "7" is not on this page`,
+			`unknown command "xyz"`,
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %q in output:\n%s", want, out)
+			}
+		}
+	})
+}
+
+func TestParseIndexes(t *testing.T) {
+	indexes, err := parseIndexes("1 3-5,7", 10)
+	if err != nil {
+		t.Fatalf("parseIndexes failed: %v", err)
+	}
+	if fmt.Sprint(indexes) != "[1 3 4 5 7]" {
+		t.Errorf("Unexpected indexes: %v", indexes)
+	}
+
+	for _, args := range []string{"", "0", "11", "5-3", "a"} {
+		if _, err := parseIndexes(args, 10); err == nil {
+			t.Errorf("%q: expected error", args)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+'use strict';
+
+// sysdig-cspm-utils dashboard: ポリシー → 要件 → コントロール → リソース の順に辿る
+(function () {
+  const PAGE_SIZE = 50;
+  const KEY_STORAGE = 'cspm-api-key';
+
+  const view = document.getElementById('view');
+  const breadcrumb = document.getElementById('breadcrumb');
+  const errorBox = document.getElementById('error');
+
+  // el builds DOM nodes; strings are always inserted as text (never as HTML)
+  function el(tag, attrs, ...children) {
+    const node = document.createElement(tag);
+    for (const [name, value] of Object.entries(attrs || {})) {
+      if (name.startsWith('on')) {
+        node.addEventListener(name.slice(2), value);
+      } else if (value !== undefined && value !== null && value !== false) {
+        node.setAttribute(name, value === true ? '' : value);
+      }
+    }
+    for (const child of children.flat()) {
+      if (child === null || child === undefined) {
+        continue;
+      }
+      node.appendChild(child instanceof Node ? child : document.createTextNode(String(child)));
+    }
+    return node;
+  }
+
+  function link(hash, text) {
+    return el('a', { href: hash }, text);
+  }
+
+  function badge(status, text) {
+    return el('span', { class: 'badge ' + status }, text === undefined ? status : text);
+  }
+
+  function passBadge(pass) {
+    return pass ? badge('passed') : badge('failed');
+  }
+
+  function query(params) {
+    const search = new URLSearchParams();
+    for (const [name, value] of Object.entries(params)) {
+      if (value !== undefined && value !== null && value !== '') {
+        search.set(name, value);
+      }
+    }
+    return search.toString();
+  }
+
+  // api fetches a JSON endpoint; on 401 it asks for an API key once and retries
+  async function api(path, params, retried) {
+    const headers = {};
+    const key = sessionStorage.getItem(KEY_STORAGE);
+    if (key) {
+      headers.Authorization = 'Bearer ' + key;
+    }
+    const qs = query(params || {});
+    const resp = await fetch('/api/v1/' + path + (qs ? '?' + qs : ''), { headers });
+    if (resp.status === 401 && !retried) {
+      const entered = window.prompt('API key');
+      if (entered) {
+        sessionStorage.setItem(KEY_STORAGE, entered.trim());
+        return api(path, params, true);
+      }
+    }
+    const body = await resp.json();
+    if (!resp.ok) {
+      throw new Error(body.error || resp.statusText);
+    }
+    return body;
+  }
+
+  function showError(err) {
+    errorBox.hidden = false;
+    errorBox.textContent = err.message || String(err);
+  }
+
+  function setBreadcrumb(items) {
+    breadcrumb.replaceChildren(...items.flatMap((item, i) => [i > 0 ? ' / ' : null, item]));
+  }
+
+  // pagedTable renders a searchable, paginated table of a list endpoint
+  function pagedTable(options) {
+    const container = el('div');
+    const state = { offset: 0, search: '', status: '' };
+
+    const searchInput = options.searchParam
+      ? el('input', { type: 'search', placeholder: options.searchLabel || '検索' })
+      : null;
+    const statusSelect = options.statusParam
+      ? el('select', {},
+        el('option', { value: '' }, 'すべて'),
+        ...options.statuses.map((s) => (Array.isArray(s)
+          ? el('option', { value: s[0] }, s[1])
+          : el('option', { value: s }, s))))
+      : null;
+    const table = el('table');
+    const pager = el('div', { class: 'pager' });
+
+    async function load() {
+      const params = Object.assign({}, options.params, {
+        limit: PAGE_SIZE,
+        offset: state.offset,
+        sort: options.sort,
+      });
+      if (options.searchParam && state.search) {
+        params[options.searchParam] = state.search;
+      }
+      if (options.statusParam && state.status) {
+        params[options.statusParam] = state.status;
+      }
+
+      let page;
+      try {
+        page = await api(options.path, params);
+      } catch (err) {
+        showError(err);
+        return;
+      }
+
+      table.replaceChildren(
+        el('thead', {}, el('tr', {}, options.columns.map((c) => el('th', {}, c.title)))),
+        el('tbody', {}, page.items.length === 0
+          ? el('tr', {}, el('td', { colspan: options.columns.length }, '該当なし'))
+          : page.items.map((row) => el('tr', {}, options.columns.map((c) =>
+            el('td', { class: c.num ? 'num' : null }, c.render ? c.render(row) : row[c.field]))))));
+
+      const last = Math.min(page.offset + page.items.length, page.total);
+      pager.replaceChildren(
+        el('button', {
+          disabled: page.offset === 0,
+          onclick: () => { state.offset = Math.max(0, state.offset - PAGE_SIZE); load(); },
+        }, '前へ'),
+        el('span', {}, page.total === 0 ? '0件' : `${page.offset + 1}-${last} / ${page.total}件`),
+        el('button', {
+          disabled: last >= page.total,
+          onclick: () => { state.offset += PAGE_SIZE; load(); },
+        }, '次へ'));
+    }
+
+    if (searchInput || statusSelect) {
+      let timer;
+      const reload = () => {
+        state.search = searchInput ? searchInput.value.trim() : '';
+        state.status = statusSelect ? statusSelect.value : '';
+        state.offset = 0;
+        load();
+      };
+      if (searchInput) {
+        searchInput.addEventListener('input', () => {
+          clearTimeout(timer);
+          timer = setTimeout(reload, 300);
+        });
+      }
+      if (statusSelect) {
+        statusSelect.addEventListener('change', reload);
+      }
+      container.appendChild(el('div', { class: 'toolbar' }, searchInput, statusSelect));
+    }
+    container.append(table, pager);
+    load();
+    return container;
+  }
+
+  function detail(row, fields) {
+    return el('dl', { class: 'detail' }, fields.flatMap(([label, value]) => [
+      el('dt', {}, label),
+      el('dd', {}, value === undefined || value === null || value === '' ? '-' : value),
+    ]));
+  }
+
+  async function getRow(path, key) {
+    return api(path + '/' + encodeURIComponent(key));
+  }
+
+  function counts(failed, accepted, passed) {
+    return el('span', {}, badge('failed', failed), ' ', badge('accepted', accepted), ' ', badge('passed', passed));
+  }
+
+  // --- views ---
+
+  async function policiesView() {
+    setBreadcrumb(['ポリシー']);
+    const list = await api('policies');
+    let filter = '';
+
+    const table = el('table');
+    const render = () => {
+      const items = list.items.filter((p) => p.policy_name.toLowerCase().includes(filter));
+      table.replaceChildren(
+        el('thead', {}, el('tr', {},
+          el('th', {}, 'ポリシー'), el('th', {}, 'ゾーン'),
+          el('th', {}, '要件 (failed/passed)'), el('th', {}, 'コントロール (failed/passed)'),
+          el('th', {}, 'リソース (failed/accepted/passed)'))),
+        el('tbody', {}, items.length === 0
+          ? el('tr', {}, el('td', { colspan: 5 }, '該当なし'))
+          : items.map((p) => el('tr', {},
+            el('td', {}, link('#/policy?' + query({ policy_id: p.policy_id, zone_name: p.zone_name, name: p.policy_name }), p.policy_name)),
+            el('td', {}, p.zone_name),
+            el('td', {}, badge('failed', p.requirements_failed), ' ', badge('passed', p.requirements_passed)),
+            el('td', {}, badge('failed', p.controls_failed), ' ', badge('passed', p.controls_passed)),
+            el('td', {}, counts(p.resources_failed, p.resources_accepted, p.resources_passed))))));
+    };
+
+    const search = el('input', { type: 'search', placeholder: 'ポリシー名で検索' });
+    search.addEventListener('input', () => { filter = search.value.trim().toLowerCase(); render(); });
+    render();
+
+    return [el('h1', {}, 'ポリシー'), el('div', { class: 'toolbar' }, search), table];
+  }
+
+  function policyView(params) {
+    const name = params.get('name') || params.get('policy_id');
+    setBreadcrumb([link('#/', 'ポリシー'), name]);
+
+    return [
+      el('h1', {}, name, ' ', el('small', {}, params.get('zone_name') || '')),
+      pagedTable({
+        path: 'requirements',
+        params: { policy_id: params.get('policy_id'), zone_name: params.get('zone_name') },
+        searchParam: 'name',
+        searchLabel: '要件名で検索',
+        statusParam: 'pass',
+        statuses: [['false', 'failed'], ['true', 'passed']],
+        sort: 'pass,-severity,requirement_id',
+        columns: [
+          { title: '要件', render: (r) => link('#/requirement/' + r.id, r.requirement_id + ' ' + r.name) },
+          { title: '重要度', field: 'severity' },
+          { title: '結果', render: (r) => passBadge(r.pass) },
+          { title: 'コントロール (failed/accepted/passed)', render: (r) => counts(r.failed_controls, r.accepted_count, r.passing_count) },
+        ],
+      }),
+    ];
+  }
+
+  async function requirementView(id) {
+    const r = await getRow('requirements', id);
+    setBreadcrumb([
+      link('#/', 'ポリシー'),
+      link('#/policy?' + query({ policy_id: r.policy_id, zone_name: r.zone_name, name: r.policy_name }), r.policy_name),
+      r.requirement_id,
+    ]);
+
+    return [
+      el('h1', {}, r.requirement_id, ' ', r.name),
+      detail(r, [
+        ['結果', passBadge(r.pass)],
+        ['重要度', r.severity],
+        ['ゾーン', r.zone_name],
+        ['プラットフォーム', r.platform],
+        ['説明', r.description],
+      ]),
+      el('h2', {}, 'コントロール'),
+      pagedTable({
+        path: 'controls',
+        params: { requirement_id: r.requirement_id },
+        searchParam: 'name',
+        searchLabel: 'コントロール名で検索',
+        statusParam: 'pass',
+        statuses: [['false', 'failed'], ['true', 'passed']],
+        sort: 'pass,-severity,control_id',
+        columns: [
+          { title: 'コントロール', render: (c) => link('#/control/' + encodeURIComponent(c.control_id), c.name) },
+          { title: '重要度', field: 'severity' },
+          { title: '結果', render: (c) => passBadge(c.pass) },
+          { title: 'リソース (failed/accepted/passed)', render: (c) => counts(c.failed_count, c.accepted_count, c.passing_count) },
+          { title: '種別', field: 'resource_kind' },
+        ],
+      }),
+    ];
+  }
+
+  function riskAcceptanceColumns() {
+    return [
+      { title: 'ID', render: (a) => link('#/risk-acceptance/' + encodeURIComponent(a.id), a.id) },
+      { title: 'コントロール', render: (a) => link('#/control/' + encodeURIComponent(a.control_id), a.control_id) },
+      { title: '理由', field: 'reason' },
+      { title: '説明', field: 'description' },
+      { title: '作成者', field: 'username' },
+      { title: '期限', field: 'expires_at' },
+      { title: '状態', render: (a) => (a.is_expired ? badge('failed', 'expired') : badge('accepted', 'active')) },
+    ];
+  }
+
+  async function controlView(id) {
+    const c = await getRow('controls', id);
+    setBreadcrumb([link('#/', 'ポリシー'), c.requirement_id, c.control_id]);
+
+    return [
+      el('h1', {}, c.name),
+      detail(c, [
+        ['コントロールID', c.control_id],
+        ['結果', passBadge(c.pass)],
+        ['重要度', c.severity],
+        ['リソース', counts(c.failed_count, c.accepted_count, c.passing_count)],
+        ['種別', c.resource_kind],
+        ['説明', c.description],
+      ]),
+      el('h2', {}, 'リソース'),
+      pagedTable({
+        path: 'relations',
+        params: { control_id: c.control_id },
+        searchParam: 'resource_name',
+        searchLabel: 'リソース名で検索',
+        statusParam: 'status',
+        statuses: ['failed', 'accepted', 'passed'],
+        sort: 'status,resource_name',
+        columns: [
+          { title: 'リソース', render: (r) => link('#/resource/' + encodeURIComponent(r.resource_hash), r.resource_name || r.resource_hash) },
+          { title: '種別', field: 'resource_type' },
+          { title: '結果', render: (r) => badge(r.status) },
+          { title: '受容理由', field: 'acceptance_justification' },
+        ],
+      }),
+      el('h2', {}, 'リスク受容'),
+      pagedTable({
+        path: 'risk-acceptances',
+        params: { control_id: c.control_id },
+        columns: riskAcceptanceColumns(),
+      }),
+    ];
+  }
+
+  function resourcesView() {
+    setBreadcrumb(['リソース']);
+    return [
+      el('h1', {}, 'リソース'),
+      pagedTable({
+        path: 'resources',
+        searchParam: 'name',
+        searchLabel: 'リソース名で検索',
+        statusParam: 'status',
+        statuses: ['failed', 'accepted', 'passed'],
+        columns: [
+          { title: 'リソース', render: (r) => link('#/resource/' + encodeURIComponent(r.hash), r.name) },
+          { title: '種別', field: 'type' },
+          { title: 'プラットフォーム', field: 'platform' },
+          { title: 'アカウント', field: 'account' },
+          { title: 'ロケーション', field: 'location' },
+        ],
+      }),
+    ];
+  }
+
+  async function resourceView(hash) {
+    const r = await getRow('resources', hash);
+    setBreadcrumb([link('#/resources', 'リソース'), r.name]);
+
+    return [
+      el('h1', {}, r.name),
+      detail(r, [
+        ['種別', r.type],
+        ['プラットフォーム', r.platform],
+        ['アカウント', r.account],
+        ['ロケーション', r.location],
+        ['クラスタ', r.cluster_name],
+        ['ハッシュ', r.hash],
+        ['最終検出', r.last_seen_date],
+      ]),
+      el('h2', {}, '評価結果'),
+      pagedTable({
+        path: 'relations',
+        params: { resource_hash: r.hash },
+        statusParam: 'status',
+        statuses: ['failed', 'accepted', 'passed'],
+        sort: 'status,control_id',
+        columns: [
+          { title: 'コントロール', render: (rel) => link('#/control/' + encodeURIComponent(rel.control_id), rel.control_name || rel.control_id) },
+          { title: '結果', render: (rel) => badge(rel.status) },
+          { title: '受容理由', field: 'acceptance_justification' },
+        ],
+      }),
+    ];
+  }
+
+  function riskAcceptancesView() {
+    setBreadcrumb(['リスク受容']);
+    return [
+      el('h1', {}, 'リスク受容'),
+      pagedTable({
+        path: 'risk-acceptances',
+        searchParam: 'description',
+        searchLabel: '説明で検索',
+        statusParam: 'is_expired',
+        statuses: [['false', 'active'], ['true', 'expired']],
+        columns: riskAcceptanceColumns(),
+      }),
+    ];
+  }
+
+  async function riskAcceptanceView(id) {
+    const a = await getRow('risk-acceptances', id);
+    setBreadcrumb([link('#/risk-acceptances', 'リスク受容'), a.id]);
+
+    return [
+      el('h1', {}, 'リスク受容 ', a.id),
+      detail(a, [
+        ['コントロール', link('#/control/' + encodeURIComponent(a.control_id), a.control_id)],
+        ['理由', a.reason],
+        ['説明', a.description],
+        ['作成者', a.user_display_name || a.username],
+        ['受容日', a.acceptance_date],
+        ['期限', a.expires_at],
+        ['状態', a.is_expired ? badge('failed', 'expired') : badge('accepted', 'active')],
+        ['フィルタ', a.filter],
+        ['ゾーン', a.zone_id],
+      ]),
+    ];
+  }
+
+  // --- routing ---
+
+  async function route() {
+    const hash = location.hash.replace(/^#\/?/, '');
+    const [path, qs] = hash.split('?');
+    const [name, ...rest] = path.split('/');
+    const id = decodeURIComponent(rest.join('/'));
+    const params = new URLSearchParams(qs || '');
+
+    errorBox.hidden = true;
+    view.replaceChildren();
+    try {
+      let nodes;
+      switch (name) {
+        case '':
+          nodes = await policiesView();
+          break;
+        case 'policy':
+          nodes = policyView(params);
+          break;
+        case 'requirement':
+          nodes = await requirementView(id);
+          break;
+        case 'control':
+          nodes = await controlView(id);
+          break;
+        case 'resources':
+          nodes = resourcesView();
+          break;
+        case 'resource':
+          nodes = await resourceView(id);
+          break;
+        case 'risk-acceptances':
+          nodes = riskAcceptancesView();
+          break;
+        case 'risk-acceptance':
+          nodes = await riskAcceptanceView(id);
+          break;
+        default:
+          throw new Error('ページが見つかりません: ' + hash);
+      }
+      view.replaceChildren(...nodes);
+    } catch (err) {
+      showError(err);
+    }
+  }
+
+  window.addEventListener('hashchange', route);
+  route();
+})();
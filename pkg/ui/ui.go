@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// contentSecurityPolicy only allows the embedded assets and same-origin API calls
+const contentSecurityPolicy = "default-src 'self'; img-src 'self' data:; frame-ancestors 'none'"
+
+// Handler serves the dashboard on / and /static/, and everything else (the JSON API) with api
+func Handler(api http.Handler) http.Handler {
+	assets, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", api)
+	mux.Handle("GET /static/", withSecurityHeaders(http.StripPrefix("/static/", http.FileServer(http.FS(assets)))))
+	mux.Handle("GET /{$}", withSecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, assets, "index.html")
+	})))
+	return mux
+}
+
+func withSecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		next.ServeHTTP(w, r)
+	})
+}
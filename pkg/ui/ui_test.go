@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "api:"+r.URL.Path)
+	})
+	server := httptest.NewServer(Handler(api))
+	defer server.Close()
+
+	fetch := func(t *testing.T, path string) (*http.Response, string) {
+		t.Helper()
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	t.Run("トップページ", func(t *testing.T) {
+		resp, body := fetch(t, "/")
+		if resp.StatusCode != http.StatusOK || !strings.Contains(body, "/static/app.js") {
+			t.Errorf("Unexpected index: %d %s", resp.StatusCode, body)
+		}
+		if resp.Header.Get("Content-Security-Policy") == "" {
+			t.Error("Expected Content-Security-Policy header")
+		}
+	})
+
+	t.Run("静的ファイル", func(t *testing.T) {
+		for _, path := range []string{"/static/app.js", "/static/style.css"} {
+			resp, _ := fetch(t, path)
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("%s: expected 200, got %d", path, resp.StatusCode)
+			}
+		}
+		if resp, _ := fetch(t, "/static/missing.js"); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 for missing asset, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("APIへの委譲", func(t *testing.T) {
+		_, body := fetch(t, "/api/v1/policies")
+		if body != "api:/api/v1/policies" {
+			t.Errorf("Expected API handler, got %q", body)
+		}
+	})
+}
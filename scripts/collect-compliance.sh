@@ -2,6 +2,8 @@
 #
 # collect-compliance.sh - Sysdig CSPMコンプライアンスデータ収集スクリプト
 #
+# 新規の収集には収集プラン（cspm-utils -command collect -plan examples/collection-plan.yaml）を推奨
+#
 # 使用方法:
 #   ./scripts/collect-compliance.sh [aws|gcp|soc2|all] [options]
 #
@@ -116,7 +118,7 @@ function check_environment() {
     export SYSDIG_API_URL="${SYSDIG_API_URL:-https://us2.app.sysdig.com}"
 
     info "API URL: ${SYSDIG_API_URL}"
-    info "API Token: ${SYSDIG_API_TOKEN:0:10}..."
+    info "API Token: ********${SYSDIG_API_TOKEN: -4}"
 }
 
 # 関数: バイナリのビルド